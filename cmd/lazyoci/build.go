@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/mistergrinvalds/lazyoci/pkg/build"
@@ -23,6 +24,8 @@ var (
 	buildPlatform []string
 	buildQuiet    bool
 	buildInsecure bool
+	buildParallel int
+	buildNoCache  bool
 )
 
 var buildCmd = &cobra.Command{
@@ -37,11 +40,14 @@ OCI registries. Supported artifact types:
   helm      Package a Helm chart directory as an OCI artifact
   artifact  Package generic files as an OCI artifact with custom media types
   docker    Push an existing Docker daemon image to a registry
+  layers    Assemble an image from a base image and local files (no Docker)
+  go        Compile a Go main package into an image (no Docker)
 
 Template variables (usable in both registry and tag fields):
   {{ .Registry }}          Base registry URL (from LAZYOCI_REGISTRY env var)
   {{ .Tag }}               Value of --tag flag (or LAZYOCI_TAG env var)
   {{ .GitSHA }}            Current git commit SHA (short)
+  {{ .GitCommit }}         Current git commit SHA (full)
   {{ .GitBranch }}         Current git branch name
   {{ .ChartVersion }}      Chart.yaml version (helm type only)
   {{ .Timestamp }}         Build timestamp (YYYYMMDDHHmmss)
@@ -52,6 +58,13 @@ Template variables (usable in both registry and tag fields):
   {{ .VersionPrerelease }} Prerelease identifier, e.g. "rc.1"
   {{ .VersionMajorMinor }} "MAJOR.MINOR", e.g. "1.2"
   {{ .VersionRaw }}        Raw git tag string, e.g. "v1.2.3-rc.1"
+  {{ .Matrix.<key> }}      Matrix value of the variant being built (also
+                           in dockerfile, context and buildArgs)
+
+Build cache: each artifact's latest OCI layout is kept under the cache
+directory (cacheDir in the app config, plus /build) with a hash of its
+inputs, and reused while they are unchanged. Pushes only set the tag when
+the registry already has the digest. Use --no-cache to always rebuild.
 
 Version auto-detection: {{ .Version }} is resolved automatically from git tags.
 Override with LAZYOCI_VERSION env var or by passing a semver to --tag.
@@ -80,9 +93,15 @@ Examples:
   # Build specific artifact by name
   lazyoci build --tag v1.0.0 --artifact myapp
 
-  # Override platforms for image builds
+  # Override platforms for image, layers and go builds
   lazyoci build --tag v1.0.0 --platform linux/amd64 --platform linux/arm64
 
+  # Build up to 4 independent artifacts at once
+  lazyoci build --tag v1.0.0 --parallel 4
+
+  # Rebuild even if the inputs are unchanged
+  lazyoci build --tag v1.0.0 --no-cache
+
   # Build with JSON output
   lazyoci build --tag v1.0.0 -o json
 
@@ -96,6 +115,10 @@ Examples:
   LAZYOCI_REGISTRY=registry.digitalocean.com/myteam lazyoci build --tag v1.0.0`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if buildParallel < 1 {
+			return fmt.Errorf("--parallel must be at least 1")
+		}
+
 		// Determine config path
 		configPath := buildFile
 		if len(args) > 0 {
@@ -139,6 +162,11 @@ Examples:
 
 		regClient := registry.NewClient(appCfg)
 
+		cacheDir := ""
+		if !buildNoCache && appCfg.CacheDir != "" {
+			cacheDir = filepath.Join(config.ExpandPath(appCfg.CacheDir), "build")
+		}
+
 		// Build options
 		opts := build.BuilderOptions{
 			Tag:            tag,
@@ -148,6 +176,8 @@ Examples:
 			Insecure:       buildInsecure,
 			Platforms:      buildPlatform,
 			ArtifactFilter: buildArtifact,
+			Parallel:       buildParallel,
+			CacheDir:       cacheDir,
 			CredentialFunc: func(registryURL string) auth.CredentialFunc {
 				return regClient.CredentialFunc(registryURL)
 			},
@@ -177,22 +207,41 @@ Examples:
 		return printResult(results, func() {
 			fmt.Println()
 			for _, r := range results {
+				if r.Skipped {
+					fmt.Printf("SKIP  %s (%s): %s\n", r.Name, r.Type, r.Error)
+					continue
+				}
 				if r.Error != "" {
 					fmt.Printf("FAIL  %s (%s): %s\n", r.Name, r.Type, r.Error)
 					continue
 				}
-				fmt.Printf("OK    %s (%s)\n", r.Name, r.Type)
+				if r.Cached {
+					fmt.Printf("OK    %s (%s, cached)\n", r.Name, r.Type)
+				} else {
+					fmt.Printf("OK    %s (%s)\n", r.Name, r.Type)
+				}
 				for _, t := range r.Targets {
 					status := "built"
-					if t.Pushed {
+					switch {
+					case t.Skipped:
+						status = "unchanged"
+					case t.Retagged:
+						status = "retagged"
+					case t.Pushed:
 						status = "pushed"
 					}
+					if t.Signature != "" {
+						status += ", signed"
+					}
 					if t.Digest != "" {
 						fmt.Printf("      %s [%s] %s\n", t.Reference, status, t.Digest)
 					} else {
 						fmt.Printf("      %s [%s]\n", t.Reference, status)
 					}
 				}
+				for _, ref := range r.Referrers {
+					fmt.Printf("      + %s (%s) %s\n", ref.Path, ref.ArtifactType, ref.Digest)
+				}
 			}
 		})
 	},
@@ -204,10 +253,12 @@ func init() {
 	buildCmd.Flags().BoolVar(&buildPush, "push", true, "Push to registries after build")
 	buildCmd.Flags().BoolVar(&buildNoPush, "no-push", false, "Build only, don't push")
 	buildCmd.Flags().BoolVar(&buildDryRun, "dry-run", false, "Show what would be built/pushed")
-	buildCmd.Flags().StringVarP(&buildArtifact, "artifact", "a", "", "Build only specific artifact by name, type, or index")
-	buildCmd.Flags().StringSliceVar(&buildPlatform, "platform", nil, "Override platforms for image builds (can be specified multiple times)")
+	buildCmd.Flags().StringVarP(&buildArtifact, "artifact", "a", "", "Build only specific artifact by name, matrix variant, type, or index")
+	buildCmd.Flags().StringSliceVar(&buildPlatform, "platform", nil, "Override platforms for image, layers and go builds (can be specified multiple times)")
 	buildCmd.Flags().BoolVarP(&buildQuiet, "quiet", "q", false, "Suppress progress output")
-	buildCmd.Flags().BoolVar(&buildInsecure, "insecure", false, "Allow HTTP for push targets")
+	buildCmd.Flags().BoolVar(&buildInsecure, "insecure", false, "Allow HTTP for push targets and base images")
+	buildCmd.Flags().IntVar(&buildParallel, "parallel", 1, "Number of independent artifacts to build at once")
+	buildCmd.Flags().BoolVar(&buildNoCache, "no-cache", false, "Rebuild even if an artifact's inputs are unchanged")
 
 	rootCmd.AddCommand(buildCmd)
 }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/artifacts"
+	"github.com/mistergrinvalds/lazyoci/pkg/config"
+	"github.com/mistergrinvalds/lazyoci/pkg/preview"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+var catRaw bool
+
+// catFileItem is one file in the structured output of cat without a filename.
+type catFileItem struct {
+	Name      string `json:"name" yaml:"name"`
+	MediaType string `json:"mediaType" yaml:"mediaType"`
+	Digest    string `json:"digest" yaml:"digest"`
+	Size      int64  `json:"size" yaml:"size"`
+}
+
+var catCmd = &cobra.Command{
+	Use:   "cat <registry/repo:tag|@digest> [filename]",
+	Short: "Print a file from an artifact",
+	Long: `Print one file of an OCI artifact, such as a policy bundle or config
+pushed with lazyoci build or oras push.
+
+Files are the artifact's layers, named by their
+org.opencontainers.image.title annotation. A file can be selected by its
+full name, its base name, or a digest prefix of at least 7 characters.
+Without a filename, the artifact's files are listed.
+
+Content is streamed. On a terminal, JSON, YAML and Rego are syntax
+highlighted and binary files are shown as a hexdump. When output is
+redirected, or with --raw, the file's bytes are written unchanged.
+
+Examples:
+  # List the files in an artifact
+  lazyoci cat localhost:5050/policies/bundle:v1
+
+  # Show a policy with highlighting
+  lazyoci cat localhost:5050/policies/bundle:v1 policy.rego
+
+  # Save a file
+  lazyoci cat localhost:5050/policies/bundle:v1 config.json > config.json`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repoPath, reference, err := splitInspectRef(args[0])
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		client := registry.NewClient(cfg)
+
+		files, err := artifacts.ListFiles(client, repoPath, reference)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 1 {
+			items := make([]catFileItem, 0, len(files))
+			for _, f := range files {
+				items = append(items, catFileItem{Name: f.Name, MediaType: f.MediaType, Digest: f.Digest, Size: f.Size})
+			}
+			return printResult(items, func() {
+				if len(items) == 0 {
+					fmt.Println("No files found")
+					return
+				}
+				w := newTabWriter()
+				fmt.Fprintln(w, "NAME\tSIZE\tMEDIA TYPE")
+				fmt.Fprintln(w, "----\t----\t----------")
+				for _, item := range items {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", item.Name, formatBytes(item.Size), item.MediaType)
+				}
+				w.Flush()
+			})
+		}
+
+		file, err := artifacts.FindFile(files, args[1])
+		if err != nil {
+			return err
+		}
+
+		rc, err := artifacts.OpenFile(client, repoPath, file)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", file.Name, err)
+		}
+		defer rc.Close()
+
+		if catRaw || !isTerminal(os.Stdout) {
+			if _, err := io.Copy(os.Stdout, rc); err != nil {
+				return fmt.Errorf("failed to read %s: %w", file.Name, err)
+			}
+			return nil
+		}
+
+		if _, err := preview.Render(os.Stdout, rc, file.Name, file.MediaType, preview.ANSI{}); err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		return nil
+	},
+}
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	catCmd.Flags().BoolVar(&catRaw, "raw", false, "Write the file's bytes without highlighting or hexdump")
+
+	rootCmd.AddCommand(catCmd)
+}
@@ -20,6 +20,7 @@ Supported configuration keys:
   artifact-dir       Directory for storing pulled artifacts
   cache-dir          Directory for metadata cache
   default-registry   Default registry shown in TUI
+  vuln-db            OSV database directory used by scan and the TUI
 
 Examples:
   # Get a configuration value
@@ -99,6 +100,7 @@ var configListCmd = &cobra.Command{
 			"cache-dir":        cfg.CacheDir,
 			"default-registry": cfg.DefaultRegistry,
 			"registries":       len(cfg.Registries),
+			"vuln-db":          cfg.GetVulnDB(),
 		}
 
 		// Add source information for artifact-dir
@@ -113,6 +115,7 @@ var configListCmd = &cobra.Command{
 			fmt.Printf("cache-dir:        %s\n", cfg.CacheDir)
 			fmt.Printf("default-registry: %s\n", cfg.DefaultRegistry)
 			fmt.Printf("registries:       %d configured\n", len(cfg.Registries))
+			fmt.Printf("vuln-db:          %s\n", cfg.GetVulnDB())
 		})
 	},
 }
@@ -152,6 +155,8 @@ func getConfigValue(cfg *config.Config, key string) (string, error) {
 		return cfg.CacheDir, nil
 	case "default-registry", "defaultregistry":
 		return cfg.DefaultRegistry, nil
+	case "vuln-db", "vulndb":
+		return cfg.GetVulnDB(), nil
 	default:
 		return "", fmt.Errorf("unknown configuration key: %s", key)
 	}
@@ -179,6 +184,14 @@ func setConfigValue(cfg *config.Config, key, value string, createDir bool) error
 	case "default-registry", "defaultregistry":
 		cfg.DefaultRegistry = value
 		return cfg.Save()
+	case "vuln-db", "vulndb":
+		if value != "" {
+			if err := config.ValidatePath(config.ExpandPath(value), createDir); err != nil {
+				return err
+			}
+		}
+		cfg.VulnDB = value
+		return cfg.Save()
 	default:
 		return fmt.Errorf("unknown configuration key: %s", key)
 	}
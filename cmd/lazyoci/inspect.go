@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/attestation"
+	"github.com/mistergrinvalds/lazyoci/pkg/config"
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/wasm"
+	"github.com/spf13/cobra"
+)
+
+// attestationResult is the structured output of inspect attestation.
+type attestationResult struct {
+	Reference    string                     `json:"reference" yaml:"reference"`
+	Attestations []*attestation.Attestation `json:"attestations" yaml:"attestations"`
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Decode and inspect artifact content",
+	Long: `Fetch an artifact from a registry and decode its content.
+
+All commands support --output json|yaml|text for structured output.`,
+}
+
+var inspectAttestationCmd = &cobra.Command{
+	Use:   "attestation <registry/repo:tag|@digest>",
+	Short: "Show in-toto attestations and SLSA provenance",
+	Long: `Decode the in-toto attestations stored in an artifact.
+
+Both bare in-toto statements and DSSE envelopes are supported. SLSA
+provenance predicates (v0.2 and v1) are summarized with builder, source
+repository, ref, commit, and build timestamps. Vulnerability scan
+predicates are summarized by severity.
+
+Signatures on DSSE envelopes are counted but not verified.
+
+Examples:
+  lazyoci inspect attestation ghcr.io/org/app:sha256-abc123.att
+  lazyoci inspect attestation localhost:5050/test/myapp@sha256:abc123...
+  lazyoci inspect attestation localhost:5050/test/myapp:att -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repoPath, reference, err := splitInspectRef(args[0])
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		client := registry.NewClient(cfg)
+
+		atts, err := attestation.Fetch(client, repoPath, reference)
+		if err != nil {
+			return fmt.Errorf("failed to read attestation: %w", err)
+		}
+
+		result := attestationResult{
+			Reference:    args[0],
+			Attestations: atts,
+		}
+
+		return printResult(result, func() {
+			for i, att := range atts {
+				if i > 0 {
+					fmt.Println()
+				}
+				printAttestation(att)
+			}
+		})
+	},
+}
+
+var inspectWasmCmd = &cobra.Command{
+	Use:   "wasm <registry/repo:tag|@digest>",
+	Short: "Show imports, exports, and custom sections of a wasm artifact",
+	Long: `Download the wasm layer of an artifact and decode the binary.
+
+Core modules and component-model components are both supported. The
+output lists imports and exports with their signatures, custom sections,
+the WASI version in use, and, for components, the embedded core modules.
+
+No wasm runtime is required; the binary is parsed, never executed.
+
+Examples:
+  lazyoci inspect wasm ghcr.io/fermyon/spin-hello:v1
+  lazyoci inspect wasm localhost:5050/test/hello-wasm:latest -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repoPath, reference, err := splitInspectRef(args[0])
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		client := registry.NewClient(cfg)
+
+		m, err := wasm.Fetch(client, repoPath, reference)
+		if err != nil {
+			return fmt.Errorf("failed to inspect wasm: %w", err)
+		}
+
+		return printResult(m, func() {
+			printWasmModule(m)
+		})
+	},
+}
+
+// splitInspectRef parses a CLI reference into the repository path and the
+// tag or digest expected by registry.Client.
+func splitInspectRef(ref string) (repoPath, reference string, err error) {
+	parsed, err := ociutil.ParseReference(ref)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid reference %q: %w", ref, err)
+	}
+
+	reference = parsed.Tag
+	if parsed.Digest != "" {
+		reference = parsed.Digest
+	}
+	return parsed.Registry + "/" + parsed.Repository, reference, nil
+}
+
+func printAttestation(att *attestation.Attestation) {
+	fmt.Printf("Predicate:   %s\n", att.PredicateType)
+	fmt.Printf("Statement:   %s\n", att.StatementType)
+	if att.Enveloped {
+		fmt.Printf("Envelope:    DSSE (%s), %d signature(s), not verified\n", att.PayloadType, att.SignatureCount)
+	} else {
+		fmt.Println("Envelope:    none (bare statement)")
+	}
+
+	if len(att.Subjects) > 0 {
+		fmt.Println("Subjects:")
+		for _, s := range att.Subjects {
+			fmt.Printf("  %s\n", s.Name)
+			for _, alg := range sortedKeys(s.Digest) {
+				fmt.Printf("    %s:%s\n", alg, s.Digest[alg])
+			}
+		}
+	}
+
+	if p := att.Provenance; p != nil {
+		fmt.Printf("\nSLSA Provenance (%s)\n", p.Version)
+		w := newTabWriter()
+		printField(w, "Builder", p.BuilderID)
+		printField(w, "Build Type", p.BuildType)
+		printField(w, "Source", p.SourceRepo)
+		printField(w, "Ref", p.SourceRef)
+		printField(w, "Commit", p.Commit)
+		printField(w, "Entry Point", p.EntryPoint)
+		printField(w, "Invocation", p.InvocationID)
+		printField(w, "Started", p.StartedOn)
+		printField(w, "Finished", p.FinishedOn)
+		w.Flush()
+
+		if len(p.Parameters) > 0 {
+			fmt.Println("Parameters:")
+			for _, k := range p.SortedParameterKeys() {
+				fmt.Printf("  %s: %s\n", k, p.Parameters[k])
+			}
+		}
+		if len(p.Materials) > 0 {
+			fmt.Println("Materials:")
+			for _, m := range p.Materials {
+				fmt.Printf("  %s\n", m.URI)
+			}
+		}
+	}
+
+	if v := att.VulnScan; v != nil {
+		fmt.Println("\nVulnerability Scan")
+		w := newTabWriter()
+		printField(w, "Scanner", strings.TrimSpace(v.Scanner+" "+v.ScannerVersion))
+		printField(w, "DB Version", v.DBVersion)
+		printField(w, "Started", v.StartedOn)
+		printField(w, "Finished", v.FinishedOn)
+		w.Flush()
+
+		fmt.Printf("Findings:    %d\n", v.Total())
+		for _, sev := range attestation.Severities() {
+			if n := v.Counts[sev]; n > 0 {
+				fmt.Printf("  %-10s %d\n", sev, n)
+			}
+		}
+	}
+}
+
+func printWasmModule(m *wasm.Module) {
+	w := newTabWriter()
+	printField(w, "Kind", string(m.Kind))
+	printField(w, "Version", fmt.Sprintf("%d", m.Version))
+	printField(w, "Size", formatBytes(m.Size))
+	printField(w, "WASI", m.WASIVersion)
+	if m.Functions > 0 {
+		printField(w, "Functions", fmt.Sprintf("%d", m.Functions))
+	}
+	for _, field := range sortedKeys(m.Producers) {
+		printField(w, "Producer "+field, strings.Join(m.Producers[field], ", "))
+	}
+	w.Flush()
+
+	fmt.Printf("\nImports (%d):\n", len(m.Imports))
+	w = newTabWriter()
+	for _, imp := range m.Imports {
+		name := imp.Name
+		if imp.Module != "" {
+			name = imp.Module + "." + imp.Name
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", imp.Kind, name, imp.Signature)
+		for _, member := range imp.Members {
+			fmt.Fprintf(w, "    %s\t%s\t%s\n", member.Kind, member.Name, member.Signature)
+		}
+	}
+	w.Flush()
+
+	fmt.Printf("\nExports (%d):\n", len(m.Exports))
+	w = newTabWriter()
+	for _, exp := range m.Exports {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", exp.Kind, exp.Name, exp.Signature)
+		for _, member := range exp.Members {
+			fmt.Fprintf(w, "    %s\t%s\t%s\n", member.Kind, member.Name, member.Signature)
+		}
+	}
+	w.Flush()
+
+	if len(m.CustomSections) > 0 {
+		fmt.Printf("\nCustom Sections (%d):\n", len(m.CustomSections))
+		w = newTabWriter()
+		for _, cs := range m.CustomSections {
+			fmt.Fprintf(w, "  %s\t%s\n", cs.Name, formatBytes(int64(cs.Size)))
+		}
+		w.Flush()
+	}
+
+	if len(m.CoreModules) > 0 {
+		fmt.Printf("\nCore Modules (%d):\n", len(m.CoreModules))
+		w = newTabWriter()
+		for i, core := range m.CoreModules {
+			wasi := core.WASIVersion
+			if wasi == "" {
+				wasi = "-"
+			}
+			fmt.Fprintf(w, "  #%d\t%s\t%d imports\t%d exports\tWASI %s\n",
+				i, formatBytes(core.Size), len(core.Imports), len(core.Exports), wasi)
+		}
+		w.Flush()
+	}
+}
+
+// printField writes a "Label:\tvalue" row, skipping empty values.
+func printField(w io.Writer, label, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(w, "%s:\t%s\n", label, value)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	inspectCmd.AddCommand(inspectAttestationCmd)
+	inspectCmd.AddCommand(inspectWasmCmd)
+
+	rootCmd.AddCommand(inspectCmd)
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/config"
+	"github.com/mistergrinvalds/lazyoci/pkg/local"
+	"github.com/spf13/cobra"
+)
+
+var (
+	localType   string
+	localRmAll  bool
+	localDryRun bool
+)
+
+// localDuItem is one row of local du.
+type localDuItem struct {
+	Name        string `json:"name" yaml:"name"`
+	Reference   string `json:"reference,omitempty" yaml:"reference,omitempty"`
+	Size        int64  `json:"size" yaml:"size"`
+	Reclaimable int64  `json:"reclaimable" yaml:"reclaimable"`
+}
+
+// localDuResult is the structured output of local du.
+type localDuResult struct {
+	Directory   string        `json:"directory" yaml:"directory"`
+	Artifacts   []localDuItem `json:"artifacts" yaml:"artifacts"`
+	Size        int64         `json:"size" yaml:"size"`
+	Reclaimable int64         `json:"reclaimable" yaml:"reclaimable"`
+}
+
+// localRmResult is the structured output of local rm.
+type localRmResult struct {
+	Removed []string `json:"removed" yaml:"removed"`
+	Freed   int64    `json:"freed" yaml:"freed"`
+}
+
+// localGCResult is the structured output of local gc.
+type localGCResult struct {
+	DryRun  bool              `json:"dryRun,omitempty" yaml:"dryRun,omitempty"`
+	Layouts []*local.GCResult `json:"layouts" yaml:"layouts"`
+	Freed   int64             `json:"freed" yaml:"freed"`
+}
+
+var localCmd = &cobra.Command{
+	Use:   "local",
+	Short: "Manage artifacts pulled to the artifact directory",
+	Long: `List, inspect, remove and garbage-collect the artifacts pulled into the
+artifact directory.
+
+Each pull without --dest stores an OCI layout at
+<artifact-dir>/<type>/<registry>/<repository>/<tag>. Commands that take an
+artifact accept its name as listed by local ls, the reference it was pulled
+from (short forms like nginx:1.25 are expanded as pull does), its path, or
+the digest of a manifest in it.
+
+All commands support --output json|yaml|text for structured output.`,
+}
+
+var localLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List pulled artifacts",
+	Long: `List the OCI layouts in the artifact directory with their type, tags and
+size on disk.
+
+Examples:
+  lazyoci local ls
+  lazyoci local ls --type helm
+  lazyoci local ls -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		layouts, err := listLocal()
+		if err != nil {
+			return err
+		}
+
+		if layouts == nil {
+			layouts = []*local.Layout{}
+		}
+		return printResult(layouts, func() {
+			if len(layouts) == 0 {
+				fmt.Println("No artifacts pulled")
+				return
+			}
+			w := newTabWriter()
+			fmt.Fprintln(w, "NAME\tTYPE\tTAGS\tDIGEST\tSIZE")
+			for _, l := range layouts {
+				digest := ""
+				if len(l.Manifests) > 0 {
+					digest = l.Manifests[0].Digest
+					if len(digest) > 19 {
+						digest = digest[:19] + "..."
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", l.Name, l.Type, strings.Join(l.Tags(), ","), digest, formatBytes(l.Size))
+			}
+			w.Flush()
+		})
+	},
+}
+
+var localInspectCmd = &cobra.Command{
+	Use:   "inspect <artifact>",
+	Short: "Show the manifests in a pulled artifact",
+	Long: `Show where a pulled artifact is stored and every manifest in its OCI
+layout: the tagged ones, and untagged ones such as the platforms of an index
+or referrers pulled with --with-referrers.
+
+Examples:
+  lazyoci local inspect nginx:1.25
+  lazyoci local inspect oci/ghcr.io/org/app/v1 -o yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		l, err := local.Find(cfg.GetArtifactDir(), args[0])
+		if err != nil {
+			return err
+		}
+
+		return printResult(l, func() {
+			fmt.Printf("Name:       %s\n", l.Name)
+			if l.Reference != "" {
+				fmt.Printf("Reference:  %s\n", l.Reference)
+			}
+			fmt.Printf("Type:       %s\n", l.Type)
+			fmt.Printf("Path:       %s\n", l.Path)
+			fmt.Printf("Size:       %s\n", formatBytes(l.Size))
+			fmt.Println()
+
+			w := newTabWriter()
+			fmt.Fprintln(w, "TAG\tDIGEST\tMEDIA TYPE\tARTIFACT TYPE")
+			for _, m := range l.Manifests {
+				tag := m.Tag
+				if tag == "" {
+					tag = "<none>"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tag, m.Digest, m.MediaType, m.ArtifactType)
+			}
+			w.Flush()
+		})
+	},
+}
+
+var localRmCmd = &cobra.Command{
+	Use:   "rm <artifact>... | --all",
+	Short: "Remove pulled artifacts",
+	Long: `Remove pulled artifacts from the artifact directory, along with the
+directories they leave empty.
+
+Examples:
+  # Remove one artifact
+  lazyoci local rm nginx:1.25
+
+  # Remove several
+  lazyoci local rm ghcr.io/org/app:v1 ghcr.io/org/app:v2
+
+  # Remove every Helm chart
+  lazyoci local rm --all --type helm`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if localRmAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		base := cfg.GetArtifactDir()
+
+		var layouts []*local.Layout
+		if localRmAll {
+			if layouts, err = listLocal(); err != nil {
+				return err
+			}
+		} else {
+			// Resolve every name before removing anything
+			for _, name := range args {
+				l, err := local.Find(base, name)
+				if err != nil {
+					return err
+				}
+				layouts = append(layouts, l)
+			}
+		}
+
+		result := localRmResult{Removed: []string{}}
+		for _, l := range layouts {
+			if err := local.Remove(base, l); err != nil {
+				return err
+			}
+			result.Removed = append(result.Removed, l.Name)
+			result.Freed += l.Size
+		}
+
+		return printResult(result, func() {
+			for _, name := range result.Removed {
+				fmt.Printf("Removed %s\n", name)
+			}
+			fmt.Printf("Freed %s\n", formatBytes(result.Freed))
+		})
+	},
+}
+
+var localGCCmd = &cobra.Command{
+	Use:   "gc [artifact...]",
+	Short: "Delete blobs no pulled artifact uses",
+	Long: `Garbage-collect the OCI layouts in the artifact directory, or the ones
+given.
+
+Re-pulling a tag after it moved leaves the old manifest in the layout,
+untagged, along with its layers. gc drops such manifests from index.json
+and deletes every blob the remaining tags don't lead to. Referrers of what
+is kept, such as signatures and SBOMs, are kept too. Partial downloads left
+by interrupted pulls are deleted, so pulling again starts those layers over.
+
+Examples:
+  # See what would be deleted
+  lazyoci local gc --dry-run
+
+  # Collect everything
+  lazyoci local gc
+
+  # Collect one artifact
+  lazyoci local gc nginx:latest`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		base := cfg.GetArtifactDir()
+
+		var layouts []*local.Layout
+		if len(args) == 0 {
+			if layouts, err = listLocal(); err != nil {
+				return err
+			}
+		}
+		for _, name := range args {
+			l, err := local.Find(base, name)
+			if err != nil {
+				return err
+			}
+			layouts = append(layouts, l)
+		}
+
+		result := localGCResult{DryRun: localDryRun, Layouts: []*local.GCResult{}}
+		for _, l := range layouts {
+			collected, err := local.GC(context.Background(), l, localDryRun)
+			if err != nil {
+				return err
+			}
+			if collected.Bytes == 0 && collected.Manifests == 0 {
+				continue
+			}
+			result.Layouts = append(result.Layouts, collected)
+			result.Freed += collected.Bytes
+		}
+
+		return printResult(result, func() {
+			if len(result.Layouts) == 0 {
+				fmt.Println("Nothing to collect")
+				return
+			}
+			w := newTabWriter()
+			fmt.Fprintln(w, "NAME\tMANIFESTS\tBLOBS\tPARTIAL\tSIZE")
+			for _, r := range result.Layouts {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", r.Layout, r.Manifests, r.Blobs, r.Partial, formatBytes(r.Bytes))
+			}
+			w.Flush()
+			if result.DryRun {
+				fmt.Printf("\nWould free %s (dry run)\n", formatBytes(result.Freed))
+			} else {
+				fmt.Printf("\nFreed %s\n", formatBytes(result.Freed))
+			}
+		})
+	},
+}
+
+var localDuCmd = &cobra.Command{
+	Use:   "du",
+	Short: "Show disk usage per pulled artifact",
+	Long: `Show how much disk each pulled artifact uses, largest first, and how much
+of it local gc would free.
+
+Layouts don't share blobs, so the sizes add up to the artifact directory's
+total.
+
+Examples:
+  lazyoci local du
+  lazyoci local du --type oci -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		layouts, err := listLocal()
+		if err != nil {
+			return err
+		}
+
+		result := localDuResult{Directory: cfg.GetArtifactDir(), Artifacts: []localDuItem{}}
+		for _, l := range layouts {
+			collectable, err := local.GC(context.Background(), l, true)
+			if err != nil {
+				return err
+			}
+			result.Artifacts = append(result.Artifacts, localDuItem{
+				Name:        l.Name,
+				Reference:   l.Reference,
+				Size:        l.Size,
+				Reclaimable: collectable.Bytes,
+			})
+			result.Size += l.Size
+			result.Reclaimable += collectable.Bytes
+		}
+		sort.SliceStable(result.Artifacts, func(i, j int) bool {
+			return result.Artifacts[i].Size > result.Artifacts[j].Size
+		})
+
+		return printResult(result, func() {
+			w := newTabWriter()
+			fmt.Fprintln(w, "NAME\tSIZE\tRECLAIMABLE")
+			for _, a := range result.Artifacts {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", a.Name, formatBytes(a.Size), formatBytes(a.Reclaimable))
+			}
+			fmt.Fprintf(w, "TOTAL\t%s\t%s\n", formatBytes(result.Size), formatBytes(result.Reclaimable))
+			w.Flush()
+		})
+	},
+}
+
+// listLocal lists the layouts in the artifact directory, of --type if
+// given.
+func listLocal() ([]*local.Layout, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	layouts, err := local.List(cfg.GetArtifactDir())
+	if err != nil || localType == "" {
+		return layouts, err
+	}
+	var filtered []*local.Layout
+	for _, l := range layouts {
+		if l.Type == localType {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered, nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{localLsCmd, localRmCmd, localDuCmd} {
+		cmd.Flags().StringVar(&localType, "type", "", "Only artifacts of this type directory (oci, helm, sbom, sig, att, wasm)")
+	}
+	localRmCmd.Flags().BoolVar(&localRmAll, "all", false, "Remove every pulled artifact (of --type if given)")
+	localGCCmd.Flags().BoolVar(&localDryRun, "dry-run", false, "Report what would be deleted without deleting it")
+
+	localCmd.AddCommand(localLsCmd)
+	localCmd.AddCommand(localInspectCmd)
+	localCmd.AddCommand(localRmCmd)
+	localCmd.AddCommand(localGCCmd)
+	localCmd.AddCommand(localDuCmd)
+
+	rootCmd.AddCommand(localCmd)
+}
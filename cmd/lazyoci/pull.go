@@ -12,17 +12,29 @@ import (
 	"github.com/mistergrinvalds/lazyoci/pkg/registry"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
+	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
 var (
-	pullDest     string
-	pullPlatform string
-	pullDocker   bool
-	pullQuiet    bool
+	pullDest         string
+	pullPlatform     string
+	pullAllPlatforms bool
+	pullFormat       string
+	pullExtract      bool
+	pullUntar        bool
+	pullLoad         string
+	pullNamespace    string
+	pullDocker       bool
+	pullQuiet        bool
+	pullConcurrency  int
+	pullFile         string
+	pullParallel     int
+	pullReferrers    bool
+	pullRefTypes     []string
 )
 
 var pullCmd = &cobra.Command{
-	Use:   "pull <reference>",
+	Use:   "pull <reference> | -f <file>",
 	Short: "Pull an OCI artifact from a registry",
 	Long: `Pull an OCI artifact (image, Helm chart, etc.) from a registry to local storage.
 
@@ -33,7 +45,60 @@ The artifact directory is resolved in priority order:
   3. artifactDir in config file
   4. Default: ~/.cache/lazyoci/artifacts
 
-Use --docker to load the pulled image into the Docker daemon.
+Use --load to load the pulled image into a container engine: docker talks
+to the Docker Engine API (DOCKER_HOST, or /var/run/docker.sock), podman to
+Podman's compatible API (CONTAINER_HOST, or its rootless then system
+socket), and containerd to its API socket, importing into --namespace
+(CONTAINERD_ADDRESS, or /run/containerd/containerd.sock). The image is
+streamed to the engine without a temporary tarball. --docker is short for
+--load docker.
+
+From an image index, only the host platform is pulled unless --platform or
+--all-platforms says otherwise. With a single platform, the pulled tag points
+at that platform's manifest. With several platforms, or --all-platforms, the
+tag points at the index itself, stored unchanged so it keeps its original
+digest and the layout can be pushed back as is. Only the selected platforms'
+manifests and layers are downloaded; the others stay listed in the index but
+are absent from the layout.
+
+Use --format to write the pull as a tarball instead of an OCI layout
+directory: oci-tar packs the layout, and docker-archive writes the
+"docker save" format that "docker load" and image scanners read. For these
+formats --dest names the tarball to write (-o is the global output format);
+the layout itself is kept in the artifact directory.
+
+Use --extract to write an artifact's files instead of an OCI layout, into
+--dest or the current directory: a Helm chart is written as
+<name>-<version>.tgz (unpacked into <name>/ with --untar), and other
+artifacts have each layer saved under its org.opencontainers.image.title
+file name. Names that would escape the destination are rejected.
+
+Layers are downloaded in parallel (3 at a time by default; see --concurrency).
+On a terminal, each in-flight layer gets a live progress bar above an overall
+bar with throughput and ETA; otherwise progress is logged one line per layer.
+
+Use --with-referrers to also pull the signatures, SBOMs and attestations
+that refer to the pulled manifests (and the referrers of those, such as a
+signature on an SBOM) into the same layout, keeping supply-chain metadata
+for verification offline. --artifact-type limits them to the given types.
+Registries without the referrers API are queried through the referrers tag
+schema. Signatures under cosign's sha256-<hex>.sig tags, where cosign and
+lazyoci sign put them by default, and attestations under .att tags are
+pulled too. Referrers are kept in OCI layouts and oci-tar archives; a
+docker-archive cannot hold them.
+
+Use -f to pull every reference listed in a file, several at a time (see
+--parallel), into the single OCI layout named by --dest, so blobs shared
+between images are stored once. A .yaml or .yml file holds a list of
+references, or of entries with their own platform ("all" for every
+platform) and destination layout; any other file lists one reference per
+line, with # comments. Each manifest is tagged with its full reference in
+the layout. A failed reference does not stop the others: every result is
+reported, failures in a summary, and the command exits non-zero.
+
+Interrupted layer downloads are kept as partial files in the layout's ingest
+directory. Pulling again resumes them with range requests when the registry
+supports it, and every layer's digest is verified before it is stored.
 
 Examples:
   # Pull to local OCI layout
@@ -43,82 +108,139 @@ Examples:
   lazyoci pull nginx:latest
   lazyoci pull docker.io/library/alpine:3.19
 
-  # Pull and load into Docker
+  # Pull and load into Docker, Podman or containerd
   lazyoci pull alpine:latest --docker
+  lazyoci pull alpine:latest --load podman
+  lazyoci pull alpine:latest --load containerd --namespace k8s.io
 
   # Pull specific platform
   lazyoci pull nginx:latest --platform linux/arm64
 
+  # Bring signatures, SBOMs and attestations along
+  lazyoci pull ghcr.io/org/app:v1 --with-referrers
+  lazyoci pull ghcr.io/org/app:v1 --with-referrers --artifact-type application/spdx+json
+
+  # Pull several platforms for an air-gapped transfer
+  lazyoci pull nginx:latest --platform linux/amd64,linux/arm64
+  lazyoci pull nginx:latest --all-platforms
+
+  # Export as tarballs for scanners or machines without a registry
+  lazyoci pull nginx:latest --format docker-archive --dest nginx.tar
+  lazyoci pull nginx:latest --format oci-tar --dest nginx-oci.tar
+
+  # Write a chart archive or an artifact's files to the current directory
+  lazyoci pull ghcr.io/org/charts/app:1.2.0 --extract
+  lazyoci pull ghcr.io/org/charts/app:1.2.0 --extract --untar --dest ./charts
+  lazyoci pull localhost:5050/policies/bundle:v1 --extract --dest ./policies
+
+  # Pull a list of images into one layout for an offline bundle
+  lazyoci pull -f images.txt --dest ./bundle
+  lazyoci pull -f images.yaml --dest ./bundle --parallel 8 -o json
+
   # Pull to custom directory
   lazyoci pull nginx:latest --artifact-dir ~/my-artifacts
 
   # Use environment variable
   LAZYOCI_ARTIFACT_DIR=/tmp/oci lazyoci pull nginx:latest
 
+  # Download up to 8 layers at once
+  lazyoci pull nginx:latest --concurrency 8
+
   # Quiet mode with JSON output
   lazyoci pull nginx:latest -q -o json`,
-	Args: cobra.ExactArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if pullFile != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		reference := args[0]
-
 		// Load config to check for insecure registries
 		cfg, err := config.Load()
 		if err != nil {
 			return err
 		}
 
+		if pullFile != "" {
+			return runBatchPull(cmd, cfg)
+		}
+		reference := args[0]
+
 		// Parse the reference to get registry
 		ref, err := ociutil.ParseReference(reference)
 		if err != nil {
 			return fmt.Errorf("invalid reference: %w", err)
 		}
 
-		// Check if this registry is marked as insecure
-		insecure := false
-		for _, r := range cfg.Registries {
-			if r.URL == ref.Registry && r.Insecure {
-				insecure = true
-				break
-			}
-		}
+		insecure, credFn := registryAccess(cfg, ref.Registry)
 
-		// Resolve registry credentials through the credential chain
-		regClient := registry.NewClient(cfg)
-		credFn := regClient.CredentialFunc(ref.Registry)
-
-		// Parse platform if specified (nil means no platform filter — for generic OCI artifacts)
-		var platform *ocispec.Platform
+		// Parse platforms if specified (none means the host platform for image
+		// indexes; other artifacts are pulled whole)
+		var platforms []ocispec.Platform
 		if pullPlatform != "" {
-			parts := strings.Split(pullPlatform, "/")
-			if len(parts) < 2 {
-				return fmt.Errorf("invalid platform format %q, expected os/arch (e.g., linux/amd64)", pullPlatform)
-			}
-			platform = &ocispec.Platform{
-				OS:           parts[0],
-				Architecture: parts[1],
+			platforms, err = pull.ParsePlatforms(pullPlatform)
+			if err != nil {
+				return err
 			}
-			if len(parts) > 2 {
-				platform.Variant = parts[2]
+		}
+
+		format, err := pull.ParseFormat(pullFormat)
+		if err != nil {
+			return err
+		}
+		if pullUntar && !pullExtract {
+			return fmt.Errorf("--untar requires --extract")
+		}
+		var load pull.LoadTarget
+		if pullLoad != "" {
+			load, err = pull.ParseLoadTarget(pullLoad)
+			if err != nil {
+				return err
 			}
 		}
-		// Note: if platform is nil, we pull all platforms / generic artifacts
+		if pullDocker {
+			load = pull.LoadDocker
+		}
+		if pullNamespace != "" && load != pull.LoadContainerd {
+			return fmt.Errorf("--namespace requires --load containerd")
+		}
+		if len(pullRefTypes) > 0 && !pullReferrers {
+			return fmt.Errorf("--artifact-type requires --with-referrers")
+		}
+		if format.IsArchive() && pullDest == "" {
+			return fmt.Errorf("--format %s needs --dest with the tarball to write", format)
+		}
 
 		opts := pull.PullOptions{
 			Reference:      reference,
 			Destination:    pullDest, // Empty means puller will use type-aware default
 			ArtifactBase:   cfg.GetArtifactDir(),
-			Platform:       platform,
-			ToDocker:       pullDocker,
+			Format:         format,
+			Extract:        pullExtract,
+			Untar:          pullUntar,
+			Platforms:      platforms,
+			AllPlatforms:   pullAllPlatforms,
+			WithReferrers:  pullReferrers,
+			ReferrerTypes:  pullRefTypes,
+			Load:           load,
+			LoadNamespace:  pullNamespace,
 			Quiet:          pullQuiet || isStructuredOutput(),
+			Concurrency:    pullConcurrency,
 			Insecure:       insecure,
 			CredentialFunc: credFn,
 		}
 
+		if pullConcurrency < 1 {
+			return fmt.Errorf("--concurrency must be at least 1")
+		}
+
 		// Show what we're doing
 		if !opts.Quiet && !isStructuredOutput() {
 			fmt.Fprintf(cmd.ErrOrStderr(), "Pulling %s...\n", reference)
-			if opts.Platform != nil {
-				fmt.Fprintf(cmd.ErrOrStderr(), "Platform: %s/%s\n", opts.Platform.OS, opts.Platform.Architecture)
+			if opts.AllPlatforms {
+				fmt.Fprintln(cmd.ErrOrStderr(), "Platform: all")
+			} else if len(opts.Platforms) > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Platform: %s\n", pullPlatform)
 			}
 		}
 
@@ -140,20 +262,156 @@ Examples:
 			}
 			fmt.Printf("  Digest:      %s\n", result.Digest)
 			fmt.Printf("  Size:        %s\n", formatBytes(result.Size))
+			if len(result.Platforms) > 0 {
+				fmt.Printf("  Platforms:   %s\n", strings.Join(result.Platforms, ", "))
+			}
 			fmt.Printf("  Layers:      %d\n", result.Layers)
+			for _, r := range result.Referrers {
+				if r.Tag != "" {
+					fmt.Printf("  Referrer:    %s %s (tag %s)\n", r.Digest, r.ArtifactType, r.Tag)
+				} else {
+					fmt.Printf("  Referrer:    %s %s\n", r.Digest, r.ArtifactType)
+				}
+			}
 			fmt.Printf("  Destination: %s\n", result.Destination)
-			if result.LoadedToDocker {
-				fmt.Println("  Loaded into Docker daemon")
+			if result.Format.IsArchive() {
+				fmt.Printf("  Format:      %s\n", result.Format)
+			}
+			for _, f := range result.Files {
+				fmt.Printf("  Extracted:   %s\n", f)
+			}
+			if result.LoadedInto != "" {
+				fmt.Printf("  Loaded into: %s\n", result.LoadedInto.Name())
 			}
 		})
 	},
 }
 
+// runBatchPull pulls the references listed in --file.
+func runBatchPull(cmd *cobra.Command, cfg *config.Config) error {
+	entries, err := pull.ParseBatchFile(pullFile)
+	if err != nil {
+		return err
+	}
+	if pullDest == "" {
+		for _, e := range entries {
+			if e.Destination == "" {
+				return fmt.Errorf("--dest is required for the shared layout of entries without a destination")
+			}
+		}
+	}
+	if len(pullRefTypes) > 0 && !pullReferrers {
+		return fmt.Errorf("--artifact-type requires --with-referrers")
+	}
+	if pullParallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+	if pullConcurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+
+	var platforms []ocispec.Platform
+	if pullPlatform != "" {
+		platforms, err = pull.ParsePlatforms(pullPlatform)
+		if err != nil {
+			return err
+		}
+	}
+
+	opts := pull.BatchOptions{
+		Entries:       entries,
+		Destination:   pullDest,
+		Platforms:     platforms,
+		AllPlatforms:  pullAllPlatforms,
+		WithReferrers: pullReferrers,
+		ReferrerTypes: pullRefTypes,
+		Parallel:      pullParallel,
+		Concurrency:   pullConcurrency,
+		Access: func(host string) (bool, auth.CredentialFunc) {
+			return registryAccess(cfg, host)
+		},
+	}
+	if !pullQuiet && !isStructuredOutput() {
+		opts.Log = cmd.ErrOrStderr()
+		fmt.Fprintf(cmd.ErrOrStderr(), "Pulling %d references (%d at a time)...\n", len(entries), pullParallel)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+
+	results := pull.NewPuller(true).PullBatch(ctx, opts)
+
+	var failed []*pull.PullResult
+	for _, r := range results {
+		if r.Error != "" {
+			failed = append(failed, r)
+		}
+	}
+
+	if err := printResult(results, func() {
+		fmt.Println()
+		fmt.Printf("Pulled %d of %d references", len(results)-len(failed), len(results))
+		if pullDest != "" {
+			fmt.Printf(" into %s", pullDest)
+		}
+		fmt.Println()
+		if len(failed) > 0 {
+			fmt.Println()
+			fmt.Println("Failed:")
+			w := newTabWriter()
+			for _, r := range failed {
+				fmt.Fprintf(w, "  %s\t%s\n", r.Reference, r.Error)
+			}
+			w.Flush()
+		}
+	}); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d pulls failed", len(failed), len(results))
+	}
+	return nil
+}
+
+// registryAccess returns whether host is configured as insecure, and its
+// credentials resolved through the credential chain.
+func registryAccess(cfg *config.Config, host string) (bool, auth.CredentialFunc) {
+	insecure := false
+	for _, r := range cfg.Registries {
+		if r.URL == host && r.Insecure {
+			insecure = true
+			break
+		}
+	}
+	return insecure, registry.NewClient(cfg).CredentialFunc(host)
+}
+
 func init() {
-	pullCmd.Flags().StringVarP(&pullDest, "dest", "d", "", "Explicit destination directory (overrides artifact-dir)")
-	pullCmd.Flags().StringVar(&pullPlatform, "platform", "", "Target platform (e.g., linux/amd64)")
-	pullCmd.Flags().BoolVar(&pullDocker, "docker", false, "Load pulled image into Docker daemon")
+	pullCmd.Flags().StringVarP(&pullDest, "dest", "d", "", "Explicit destination directory (overrides artifact-dir), or the tarball for archive formats")
+	pullCmd.Flags().StringVar(&pullPlatform, "platform", "", "Target platforms, comma-separated (e.g., linux/amd64,linux/arm64)")
+	pullCmd.Flags().BoolVar(&pullAllPlatforms, "all-platforms", false, "Pull every platform of an image index")
+	pullCmd.Flags().StringVar(&pullFormat, "format", "oci-dir", "Output format: oci-dir, oci-tar, docker-archive")
+	pullCmd.Flags().BoolVar(&pullExtract, "extract", false, "Write the chart archive or artifact files instead of an OCI layout")
+	pullCmd.Flags().BoolVar(&pullUntar, "untar", false, "With --extract, unpack a Helm chart into a directory")
+	pullCmd.Flags().StringVar(&pullLoad, "load", "", "Load pulled image into a container engine: docker, podman, containerd")
+	pullCmd.Flags().StringVar(&pullNamespace, "namespace", "", "containerd namespace for --load containerd (default: CONTAINERD_NAMESPACE or default)")
+	pullCmd.Flags().BoolVar(&pullDocker, "docker", false, "Load pulled image into Docker (same as --load docker)")
 	pullCmd.Flags().BoolVarP(&pullQuiet, "quiet", "q", false, "Suppress progress output")
+	pullCmd.Flags().IntVar(&pullConcurrency, "concurrency", 3, "Maximum number of layers to download in parallel")
+	pullCmd.Flags().BoolVar(&pullReferrers, "with-referrers", false, "Also pull signatures, SBOMs and attestations that refer to the pulled manifests")
+	pullCmd.Flags().StringSliceVar(&pullRefTypes, "artifact-type", nil, "With --with-referrers, only pull referrers of these artifact types")
+	pullCmd.Flags().StringVarP(&pullFile, "file", "f", "", "Pull every reference listed in a text or YAML file")
+	pullCmd.Flags().IntVar(&pullParallel, "parallel", 4, "With --file, number of references to pull at once")
+
+	pullCmd.MarkFlagsMutuallyExclusive("platform", "all-platforms")
+	pullCmd.MarkFlagsMutuallyExclusive("extract", "format")
+	pullCmd.MarkFlagsMutuallyExclusive("extract", "docker")
+	pullCmd.MarkFlagsMutuallyExclusive("extract", "load")
+	pullCmd.MarkFlagsMutuallyExclusive("load", "docker")
+	for _, f := range []string{"format", "extract", "load", "docker"} {
+		pullCmd.MarkFlagsMutuallyExclusive("file", f)
+	}
 
 	rootCmd.AddCommand(pullCmd)
 }
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/attestation"
+	"github.com/mistergrinvalds/lazyoci/pkg/config"
+	"github.com/mistergrinvalds/lazyoci/pkg/osv"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/sbom"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scanDB     string
+	scanSBOM   string
+	scanFailOn string
+)
+
+// scanResult is the structured output of scan.
+type scanResult struct {
+	Reference  string      `json:"reference,omitempty" yaml:"reference,omitempty"`
+	SBOM       string      `json:"sbom" yaml:"sbom"`
+	Format     string      `json:"format" yaml:"format"`
+	Database   string      `json:"database" yaml:"database"`
+	Advisories int         `json:"advisories" yaml:"advisories"`
+	Report     *osv.Report `json:"report" yaml:"report"`
+}
+
+var scanCmd = &cobra.Command{
+	Use:   "scan <registry/repo:tag|@digest>",
+	Short: "Match an image's SBOM against a local OSV database",
+	Long: `Find known vulnerabilities in an image using its SBOM and an offline
+copy of the OSV database.
+
+The SBOM is located in this order:
+  1. The reference itself, when it is an SBOM artifact
+  2. Attestation manifests in the image index (docker buildx --sbom)
+  3. Referrers with an SPDX, CycloneDX or in-toto artifact type
+  4. cosign "sha256-<digest>.sbom" and ".att" tags
+Use --sbom to scan a local SBOM file instead.
+
+Packages are matched by package URL. Versions are compared with each
+ecosystem's own ordering (semver, PEP 440, Maven, dpkg, apk, ...).
+Packages without a purl are skipped.
+
+The database is a directory of OSV JSON records, or the all.zip archives
+from https://osv-vulnerabilities.storage.googleapis.com/<ecosystem>/all.zip.
+It defaults to the vuln-db configuration value.
+
+Examples:
+  # Scan an image
+  lazyoci scan ghcr.io/org/app:v1 --db ~/osv
+
+  # Use the configured database and fail on high or critical findings
+  lazyoci config set vuln-db ~/osv
+  lazyoci scan localhost:5050/test/myapp:latest --fail-on high
+
+  # Scan a local SBOM
+  lazyoci scan --sbom sbom.spdx.json -o json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && scanSBOM == "" {
+			return fmt.Errorf("requires an image reference or --sbom")
+		}
+
+		failOn := attestation.NormalizeSeverity(scanFailOn)
+		if scanFailOn != "" && failOn == "UNKNOWN" {
+			return fmt.Errorf("invalid --fail-on severity: %s", scanFailOn)
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		dbDir := config.ExpandPath(scanDB)
+		if dbDir == "" {
+			dbDir = cfg.GetVulnDB()
+		}
+		if dbDir == "" {
+			return fmt.Errorf("no vulnerability database: pass --db or run 'lazyoci config set vuln-db <dir>'")
+		}
+
+		result := scanResult{Database: dbDir}
+
+		var doc *sbom.Document
+		if scanSBOM != "" {
+			data, err := os.ReadFile(scanSBOM)
+			if err != nil {
+				return fmt.Errorf("failed to read SBOM: %w", err)
+			}
+			doc, err = sbom.Parse(data)
+			if err != nil {
+				return fmt.Errorf("failed to parse SBOM: %w", err)
+			}
+			result.SBOM = scanSBOM
+		} else {
+			repoPath, reference, err := splitInspectRef(args[0])
+			if err != nil {
+				return err
+			}
+			client := registry.NewClient(cfg)
+
+			var source string
+			doc, source, err = sbom.Discover(client, repoPath, reference)
+			if err != nil {
+				return fmt.Errorf("failed to find SBOM: %w", err)
+			}
+			result.Reference = args[0]
+			result.SBOM = source
+		}
+		result.Format = strings.TrimSpace(string(doc.Format) + " " + doc.SpecVersion)
+
+		db, err := osv.Load(dbDir)
+		if err != nil {
+			return err
+		}
+		result.Advisories = db.Len()
+		result.Report = db.Match(doc.Packages)
+
+		if err := printResult(result, func() {
+			printScanReport(result)
+		}); err != nil {
+			return err
+		}
+
+		if scanFailOn != "" {
+			failing := 0
+			for _, f := range result.Report.Findings {
+				if r := severityRank(f.Severity); r >= 0 && r <= severityRank(failOn) {
+					failing++
+				}
+			}
+			if failing > 0 {
+				// The report was printed; a policy failure is not a usage error.
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d vulnerabilities at or above %s", failing, failOn)
+			}
+		}
+		return nil
+	},
+}
+
+// severityRank returns the position of sev in attestation.Severities()
+// (0 is most severe), or -1 if it is not a canonical severity.
+func severityRank(sev string) int {
+	for i, s := range attestation.Severities() {
+		if s == sev {
+			return i
+		}
+	}
+	return -1
+}
+
+func printScanReport(r scanResult) {
+	w := newTabWriter()
+	printField(w, "Reference", r.Reference)
+	printField(w, "SBOM", r.SBOM)
+	printField(w, "Format", r.Format)
+	printField(w, "Database", fmt.Sprintf("%s (%d advisories)", r.Database, r.Advisories))
+	packages := fmt.Sprintf("%d scanned", r.Report.Packages)
+	if r.Report.Unsupported > 0 {
+		packages += fmt.Sprintf(", %d skipped (no purl or unsupported ecosystem)", r.Report.Unsupported)
+	}
+	printField(w, "Packages", packages)
+	w.Flush()
+
+	fmt.Printf("\nFindings:    %d\n", r.Report.Total())
+	for _, sev := range attestation.Severities() {
+		if n := r.Report.Counts[sev]; n > 0 {
+			fmt.Printf("  %-10s %d\n", sev, n)
+		}
+	}
+	if r.Report.Total() == 0 {
+		return
+	}
+
+	fmt.Println()
+	w = newTabWriter()
+	fmt.Fprintln(w, "SEVERITY\tID\tPACKAGE\tVERSION\tFIXED IN\tSUMMARY")
+	fmt.Fprintln(w, "--------\t--\t-------\t-------\t--------\t-------")
+	for _, f := range r.Report.Findings {
+		fixed := f.FixedIn
+		if fixed == "" {
+			fixed = "-"
+		}
+		summary := f.Summary
+		if len(summary) > 60 {
+			summary = summary[:57] + "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", f.Severity, f.ID, f.Package, f.Version, fixed, summary)
+	}
+	w.Flush()
+}
+
+func init() {
+	scanCmd.Flags().StringVar(&scanDB, "db", "", "OSV database directory (default: vuln-db config value)")
+	scanCmd.Flags().StringVar(&scanSBOM, "sbom", "", "Scan a local SBOM file instead of discovering one")
+	scanCmd.Flags().StringVar(&scanFailOn, "fail-on", "", "Exit non-zero on findings at or above this severity (critical, high, medium, low)")
+
+	rootCmd.AddCommand(scanCmd)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/config"
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	"github.com/mistergrinvalds/lazyoci/pkg/sign"
+	"github.com/spf13/cobra"
+)
+
+var (
+	signKey      string
+	signMode     string
+	signInsecure bool
+)
+
+// signResult is the output of lazyoci sign.
+type signResult struct {
+	Reference string `json:"reference" yaml:"reference"`
+	Signature string `json:"signature" yaml:"signature"`
+	Mode      string `json:"mode" yaml:"mode"`
+	Tag       string `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Unchanged bool   `json:"unchanged,omitempty" yaml:"unchanged,omitempty"`
+}
+
+var signCmd = &cobra.Command{
+	Use:   "sign <registry/repo:tag|@digest>",
+	Short: "Sign an image digest with a cosign key",
+	Long: `Sign an image or artifact with a local private key, as cosign sign --key
+does. A tag is resolved to its digest, and the digest is what is signed.
+
+The key is a cosign.key from cosign generate-key-pair (decrypted with the
+COSIGN_PASSWORD environment variable), or an unencrypted PKCS #8 or EC PEM
+key. ECDSA and Ed25519 keys are supported.
+
+Signature modes:
+  tag       Store the signature under the sha256-<hex>.sig tag (default),
+            where cosign verify looks
+  referrer  Push the signature as an OCI 1.1 referrer of the digest
+            (cosign verify --experimental-oci11)
+
+Signing a digest that already has a signature from the key pushes nothing.
+Signatures are not uploaded to a transparency log, so verify them with
+--insecure-ignore-tlog.
+
+Environment variables:
+  COSIGN_PASSWORD  Password of an encrypted key
+
+Examples:
+  # Sign an image
+  COSIGN_PASSWORD=... lazyoci sign ghcr.io/myorg/app:v1.0.0 --key cosign.key
+
+  # Sign as an OCI 1.1 referrer
+  lazyoci sign ghcr.io/myorg/app@sha256:abc... --key cosign.key --mode referrer
+
+  # Verify with cosign
+  cosign verify --key cosign.pub --insecure-ignore-tlog ghcr.io/myorg/app:v1.0.0`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mode, err := sign.ParseMode(signMode)
+		if err != nil {
+			return err
+		}
+		if signKey == "" {
+			return fmt.Errorf("--key is required")
+		}
+		signer, err := sign.LoadKey(signKey, []byte(os.Getenv(sign.PasswordEnv)))
+		if err != nil {
+			return err
+		}
+
+		ref, err := ociutil.ParseReference(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid reference: %w", err)
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		insecure, credFn := registryAccess(cfg, ref.Registry)
+		repo, err := ociutil.NewRemoteRepository(ref, insecure || signInsecure, credFn)
+		if err != nil {
+			return fmt.Errorf("failed to create remote repository: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		target := ref.Tag
+		if ref.Digest != "" {
+			target = ref.Digest
+		}
+		subject, err := repo.Resolve(ctx, target)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", args[0], err)
+		}
+
+		identity := sign.DockerReference(repo.Reference.Registry, repo.Reference.Repository)
+		result, err := sign.Sign(ctx, repo, identity, subject, signer, mode)
+		if err != nil {
+			return err
+		}
+
+		out := signResult{
+			Reference: ref.Registry + "/" + ref.Repository + "@" + subject.Digest.String(),
+			Signature: result.Signature.Digest.String(),
+			Mode:      string(mode),
+			Tag:       result.Tag,
+			Unchanged: result.Unchanged,
+		}
+		return printResult(out, func() {
+			if out.Unchanged {
+				fmt.Printf("%s is already signed with this key\n", out.Reference)
+			} else {
+				fmt.Printf("Signed %s\n", out.Reference)
+			}
+			if out.Tag != "" {
+				fmt.Printf("  Signature: %s (%s)\n", out.Tag, out.Signature)
+			} else {
+				fmt.Printf("  Signature: %s (referrer)\n", out.Signature)
+			}
+		})
+	},
+}
+
+func init() {
+	signCmd.Flags().StringVar(&signKey, "key", "", "Private key file (e.g. cosign.key)")
+	signCmd.Flags().StringVar(&signMode, "mode", "tag", "Where to store the signature: tag or referrer")
+	signCmd.Flags().BoolVar(&signInsecure, "insecure", false, "Allow HTTP for the registry")
+
+	rootCmd.AddCommand(signCmd)
+}
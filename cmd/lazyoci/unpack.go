@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/config"
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	"github.com/mistergrinvalds/lazyoci/pkg/pull"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/unpack"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/spf13/cobra"
+)
+
+var (
+	unpackPlatform string
+	unpackQuiet    bool
+)
+
+// unpackResult is the structured output of unpack.
+type unpackResult struct {
+	Reference   string `json:"reference" yaml:"reference"`
+	Digest      string `json:"digest" yaml:"digest"`
+	Platform    string `json:"platform,omitempty" yaml:"platform,omitempty"`
+	Destination string `json:"destination" yaml:"destination"`
+	Layers      int    `json:"layers" yaml:"layers"`
+	Skipped     int    `json:"skipped" yaml:"skipped"`
+}
+
+var unpackCmd = &cobra.Command{
+	Use:   "unpack <reference> <dir>",
+	Short: "Unpack an image's root filesystem to a directory",
+	Long: `Pull an image and apply its layers in order to produce its root filesystem,
+without a container runtime or Docker daemon.
+
+The host platform is unpacked from a multi-platform image unless --platform
+selects another. The image is pulled into the artifact directory first, so
+unpacking it again only fetches what changed. The destination must be empty
+or not exist.
+
+Layers are applied the way a container runtime does:
+  - Whiteout files (.wh.<name>) delete files from lower layers, and opaque
+    markers (.wh..wh..opq) hide a directory's lower contents
+  - Symlinks are created as they are, but are resolved inside the
+    destination when unpacking, so a layer cannot write outside it
+  - Hardlinks, file modes and modification times are preserved; ownership
+    is preserved when running as root
+  - Device nodes and FIFOs need privileges to create and are skipped
+
+Examples:
+  # Unpack an image
+  lazyoci unpack alpine:3.19 ./rootfs
+
+  # Extract a binary from a vendor image in CI
+  lazyoci unpack ghcr.io/org/tool:v1.2.0 /tmp/tool --platform linux/amd64
+  cp /tmp/tool/usr/local/bin/tool ./bin/`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reference, dest := args[0], args[1]
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+
+		ref, err := ociutil.ParseReference(reference)
+		if err != nil {
+			return fmt.Errorf("invalid reference: %w", err)
+		}
+		insecure, credFn := registryAccess(cfg, ref.Registry)
+
+		var platforms []ocispec.Platform
+		if unpackPlatform != "" {
+			platforms, err = pull.ParsePlatforms(unpackPlatform)
+			if err != nil {
+				return err
+			}
+			if len(platforms) > 1 {
+				return fmt.Errorf("unpack takes a single platform")
+			}
+		}
+
+		quiet := unpackQuiet || isStructuredOutput()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+
+		pulled, err := pull.NewPuller(quiet).Pull(ctx, pull.PullOptions{
+			Reference:      reference,
+			ArtifactBase:   cfg.GetArtifactDir(),
+			Platforms:      platforms,
+			Quiet:          quiet,
+			Insecure:       insecure,
+			CredentialFunc: credFn,
+		})
+		if err != nil {
+			return err
+		}
+		if pulled.ArtifactType != registry.ArtifactTypeImage {
+			return fmt.Errorf("cannot unpack %s artifact (only images have a root filesystem)", pulled.ArtifactType)
+		}
+
+		unpacked, err := unpack.Image(pulled.Destination, pulled.Digest, dest)
+		if err != nil {
+			return fmt.Errorf("failed to unpack %s: %w", reference, err)
+		}
+
+		result := unpackResult{
+			Reference:   reference,
+			Digest:      pulled.Digest,
+			Destination: dest,
+			Layers:      unpacked.Layers,
+			Skipped:     unpacked.Skipped,
+		}
+		if len(pulled.Platforms) > 0 {
+			result.Platform = pulled.Platforms[0]
+		}
+
+		return printResult(result, func() {
+			fmt.Printf("Unpacked %s to %s\n", result.Reference, result.Destination)
+			if result.Platform != "" {
+				fmt.Printf("  Platform: %s\n", result.Platform)
+			}
+			fmt.Printf("  Digest:   %s\n", result.Digest)
+			fmt.Printf("  Layers:   %d\n", result.Layers)
+			if result.Skipped > 0 {
+				fmt.Printf("  Skipped:  %d device nodes or FIFOs\n", result.Skipped)
+			}
+		})
+	},
+}
+
+func init() {
+	unpackCmd.Flags().StringVar(&unpackPlatform, "platform", "", "Platform to unpack (e.g., linux/arm64)")
+	unpackCmd.Flags().BoolVarP(&unpackQuiet, "quiet", "q", false, "Suppress progress output")
+
+	rootCmd.AddCommand(unpackCmd)
+}
@@ -9,9 +9,14 @@ module.exports = {
       link: {type: 'doc', id: 'cli/index'},
       items: [
         'cli/pull',
+        'cli/unpack',
+        'cli/local',
         'cli/build',
         'cli/mirror',
         'cli/browse',
+        'cli/inspect',
+        'cli/scan',
+        'cli/cat',
         'cli/registry',
         'cli/config',
       ],
@@ -0,0 +1,128 @@
+package artifacts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/attestation"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+)
+
+// AttestationHandler handles in-toto attestations, bare or DSSE-wrapped.
+// When Fetcher is set, GetDetails decodes SLSA provenance and vulnerability
+// scan predicates. DSSE signatures are counted, not verified.
+type AttestationHandler struct {
+	Fetcher registry.Fetcher
+}
+
+// CanHandle returns true for attestation artifacts
+func (h *AttestationHandler) CanHandle(artifact *registry.Artifact) bool {
+	return artifact.Type == registry.ArtifactTypeAttestation
+}
+
+// GetDetails returns details for an attestation artifact
+func (h *AttestationHandler) GetDetails(artifact *registry.Artifact) (*Details, error) {
+	details := &Details{
+		Summary: "Attestation",
+		Properties: map[string]string{
+			"Tag":    artifact.Tag,
+			"Digest": artifact.Digest,
+		},
+	}
+
+	if h.Fetcher == nil {
+		return details, nil
+	}
+
+	atts, err := attestation.Fetch(h.Fetcher, artifact.Repository, artifactRef(artifact))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode attestation: %w", err)
+	}
+
+	var predicates, subjects []string
+	signatures := 0
+	for _, att := range atts {
+		predicates = append(predicates, attestation.ShortPredicateType(att.PredicateType))
+		for _, s := range att.Subjects {
+			subjects = append(subjects, s.Name)
+		}
+		signatures += att.SignatureCount
+		if att.Enveloped {
+			details.Properties["Envelope"] = fmt.Sprintf("DSSE, %d sig(s) (unverified)", signatures)
+		}
+
+		if p := att.Provenance; p != nil {
+			details.Summary = "SLSA Provenance"
+			setProperty(details, "Builder", p.BuilderID)
+			setProperty(details, "Source", p.SourceRepo)
+			setProperty(details, "Ref", p.SourceRef)
+			setProperty(details, "Commit", p.Commit)
+			setProperty(details, "Entry Point", p.EntryPoint)
+			setProperty(details, "Started", p.StartedOn)
+			setProperty(details, "Finished", p.FinishedOn)
+			for _, m := range p.Materials {
+				details.Components = append(details.Components, Component{
+					Name: m.URI,
+					Type: "material",
+				})
+			}
+		}
+
+		if v := att.VulnScan; v != nil {
+			details.Summary = "Vulnerability Scan"
+			setProperty(details, "Scanner", strings.TrimSpace(v.Scanner+" "+v.ScannerVersion))
+			details.Properties["Findings"] = severitySummary(v.Counts, v.Total())
+			for _, f := range v.Findings {
+				details.Components = append(details.Components, vulnComponent(f.ID, f.Severity, f.Package, f.Version, f.FixedIn))
+			}
+		}
+	}
+	if len(atts) > 1 {
+		details.Summary = fmt.Sprintf("Attestations (%d)", len(atts))
+	}
+
+	details.Properties["Predicate"] = strings.Join(predicates, ", ")
+	setProperty(details, "Subject", strings.Join(subjects, ", "))
+
+	return details, nil
+}
+
+// GetActions returns available actions for an attestation
+func (h *AttestationHandler) GetActions(artifact *registry.Artifact) []Action {
+	ref := artifact.Repository + ":" + artifact.Tag
+	return []Action{
+		{
+			Name:        "Pull",
+			Description: "Pull the attestation",
+			Command:     "lazyoci pull " + ref,
+		},
+		{
+			Name:        "Inspect",
+			Description: "Decode the in-toto statement and predicate",
+			Command:     "lazyoci inspect attestation " + ref,
+			Key:         'i',
+		},
+	}
+}
+
+// setProperty sets a property, skipping empty values.
+func setProperty(details *Details, key, value string) {
+	if value != "" {
+		details.Properties[key] = value
+	}
+}
+
+// severitySummary renders finding counts, most severe first,
+// e.g. "3 (1 CRITICAL, 2 HIGH)".
+func severitySummary(counts map[string]int, total int) string {
+	var parts []string
+	for _, sev := range attestation.Severities() {
+		if n := counts[sev]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, sev))
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%d", total)
+	}
+	return fmt.Sprintf("%d (%s)", total, strings.Join(parts, ", "))
+}
@@ -0,0 +1,56 @@
+package artifacts
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
+)
+
+func TestAttestationHandlerGetDetails(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/fixtures/attestation-intoto.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	h := &AttestationHandler{Fetcher: &registrytest.Fetcher{
+		Manifest: &registry.Manifest{Layers: []registry.Descriptor{
+			{MediaType: "application/vnd.in-toto+json", Digest: "sha256:att"},
+		}},
+		Blobs: map[string][]byte{"sha256:att": data},
+	}}
+
+	details, err := h.GetDetails(&registry.Artifact{
+		Repository: "localhost:5050/test/myapp",
+		Tag:        "att",
+		Type:       registry.ArtifactTypeAttestation,
+	})
+	if err != nil {
+		t.Fatalf("GetDetails() error = %v", err)
+	}
+
+	if details.Summary != "SLSA Provenance" {
+		t.Errorf("Summary = %q, want %q", details.Summary, "SLSA Provenance")
+	}
+	if got := details.Properties["Predicate"]; got != "slsa-provenance v0.2" {
+		t.Errorf("Properties[Predicate] = %q", got)
+	}
+	if got := details.Properties["Builder"]; got != "https://github.com/actions/runner" {
+		t.Errorf("Properties[Builder] = %q", got)
+	}
+	if got := details.Properties["Subject"]; got != "localhost:5050/test/myapp" {
+		t.Errorf("Properties[Subject] = %q", got)
+	}
+}
+
+func TestAttestationHandlerGetActions(t *testing.T) {
+	h := &AttestationHandler{}
+	actions := h.GetActions(&registry.Artifact{Repository: "ghcr.io/org/app", Tag: "att"})
+
+	if len(actions) != 2 {
+		t.Fatalf("len(actions) = %d, want 2", len(actions))
+	}
+	if actions[1].Command != "lazyoci inspect attestation ghcr.io/org/app:att" || actions[1].Key != 'i' {
+		t.Errorf("actions[1] = %+v", actions[1])
+	}
+}
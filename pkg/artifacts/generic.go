@@ -0,0 +1,192 @@
+package artifacts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+)
+
+// annotationTitle names a layer's file, as set by `oras push` and lazyoci build.
+const annotationTitle = "org.opencontainers.image.title"
+
+// File is one layer of an artifact, named by its title annotation.
+type File struct {
+	Name       string
+	MediaType  string
+	Digest     string
+	Size       int64
+	Descriptor registry.Descriptor
+}
+
+// FileLister is implemented by handlers whose artifacts are a set of files
+// that can be previewed individually.
+type FileLister interface {
+	ListFiles(artifact *registry.Artifact) ([]File, error)
+	OpenFile(artifact *registry.Artifact, file File) (io.ReadCloser, error)
+}
+
+// BlobOpener streams blobs. Fetchers that implement it let OpenFile avoid
+// buffering whole files; *registry.Client satisfies it.
+type BlobOpener interface {
+	OpenBlob(repoPath string, desc registry.Descriptor) (io.ReadCloser, error)
+}
+
+// ListFiles returns the layers of the manifest at reference as files. Layers
+// without a title annotation are named by their short digest.
+func ListFiles(f registry.Fetcher, repoPath, reference string) ([]File, error) {
+	manifest, _, err := f.GetManifest(repoPath, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	return filesOf(manifest), nil
+}
+
+// filesOf returns a manifest's layers as files.
+func filesOf(manifest *registry.Manifest) []File {
+	files := make([]File, 0, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		name := layer.Annotations[annotationTitle]
+		if name == "" {
+			name = shortDigest(layer.Digest)
+		}
+		files = append(files, File{
+			Name:       name,
+			MediaType:  layer.MediaType,
+			Digest:     layer.Digest,
+			Size:       layer.Size,
+			Descriptor: layer,
+		})
+	}
+	return files
+}
+
+// OpenFile returns a reader for a file's content, streamed when f is a
+// BlobOpener.
+func OpenFile(f registry.Fetcher, repoPath string, file File) (io.ReadCloser, error) {
+	if opener, ok := f.(BlobOpener); ok {
+		return opener.OpenBlob(repoPath, file.Descriptor)
+	}
+	data, err := f.FetchBlob(repoPath, file.Descriptor)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// FindFile returns the file called name. An exact name wins over a match on
+// the base name; a digest or digest prefix (with or without "sha256:") also
+// matches.
+func FindFile(files []File, name string) (File, error) {
+	for _, f := range files {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+
+	var matches []File
+	for _, f := range files {
+		hex := strings.TrimPrefix(f.Digest, "sha256:")
+		if path.Base(f.Name) == name || f.Digest == name ||
+			(len(name) >= 7 && strings.HasPrefix(hex, strings.TrimPrefix(name, "sha256:"))) {
+			matches = append(matches, f)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return File{}, fmt.Errorf("no file named %q", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return File{}, fmt.Errorf("%q matches %d files", name, len(matches))
+	}
+}
+
+// GenericHandler handles artifacts of unrecognized types by listing their
+// layers as files. When Fetcher is set, GetDetails reads the manifest and
+// files can be listed and opened for preview.
+type GenericHandler struct {
+	Fetcher registry.Fetcher
+}
+
+// CanHandle returns true for artifacts of unknown type
+func (h *GenericHandler) CanHandle(artifact *registry.Artifact) bool {
+	return artifact.Type == registry.ArtifactTypeUnknown
+}
+
+// GetDetails returns details for a generic artifact
+func (h *GenericHandler) GetDetails(artifact *registry.Artifact) (*Details, error) {
+	details := &Details{
+		Summary: "OCI Artifact",
+		Properties: map[string]string{
+			"Tag":    artifact.Tag,
+			"Digest": artifact.Digest,
+		},
+	}
+
+	if h.Fetcher == nil {
+		return details, nil
+	}
+
+	manifest, _, err := h.Fetcher.GetManifest(artifact.Repository, artifactRef(artifact))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+
+	artifactType := manifest.ArtifactType
+	if artifactType == "" {
+		artifactType = manifest.Config.MediaType
+	}
+	setProperty(details, "Artifact Type", artifactType)
+	details.Properties["Files"] = fmt.Sprintf("%d", len(manifest.Layers))
+
+	for _, f := range filesOf(manifest) {
+		details.Components = append(details.Components, Component{
+			Name:        f.Name,
+			Type:        "file",
+			Size:        f.Size,
+			Description: f.MediaType,
+		})
+	}
+	if manifest.Subject != nil {
+		details.RelatedArtifacts = append(details.RelatedArtifacts, artifact.Repository+"@"+manifest.Subject.Digest)
+	}
+
+	return details, nil
+}
+
+// GetActions returns available actions for a generic artifact
+func (h *GenericHandler) GetActions(artifact *registry.Artifact) []Action {
+	return []Action{
+		{
+			Name:        "Pull",
+			Description: "Pull the artifact files",
+			Command:     "lazyoci pull " + artifact.Repository + ":" + artifact.Tag,
+		},
+		{
+			Name:        "Preview",
+			Description: "Show a file's content",
+			Command:     "lazyoci cat " + artifact.Repository + ":" + artifact.Tag,
+			Key:         'f',
+		},
+	}
+}
+
+// ListFiles lists the artifact's layers as files.
+func (h *GenericHandler) ListFiles(artifact *registry.Artifact) ([]File, error) {
+	if h.Fetcher == nil {
+		return nil, fmt.Errorf("no registry client to list files")
+	}
+	return ListFiles(h.Fetcher, artifact.Repository, artifactRef(artifact))
+}
+
+// OpenFile opens one of the artifact's files.
+func (h *GenericHandler) OpenFile(artifact *registry.Artifact, file File) (io.ReadCloser, error) {
+	if h.Fetcher == nil {
+		return nil, fmt.Errorf("no registry client to open files")
+	}
+	return OpenFile(h.Fetcher, artifact.Repository, file)
+}
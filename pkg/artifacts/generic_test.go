@@ -0,0 +1,119 @@
+package artifacts
+
+import (
+	"io"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
+)
+
+func TestGenericHandlerGetDetails(t *testing.T) {
+	h := &GenericHandler{Fetcher: &registrytest.Fetcher{
+		Manifest: &registry.Manifest{
+			ArtifactType: "application/vnd.example.policy",
+			Layers: []registry.Descriptor{
+				{
+					MediaType:   "application/vnd.example.rego",
+					Digest:      "sha256:0123456789abcdef",
+					Size:        120,
+					Annotations: map[string]string{"org.opencontainers.image.title": "policy.rego"},
+				},
+				{MediaType: "application/octet-stream", Digest: "sha256:fedcba9876543210", Size: 8},
+			},
+		},
+	}}
+
+	details, err := h.GetDetails(&registry.Artifact{Repository: "localhost:5050/policy", Tag: "v1"})
+	if err != nil {
+		t.Fatalf("GetDetails() error = %v", err)
+	}
+
+	if got := details.Properties["Artifact Type"]; got != "application/vnd.example.policy" {
+		t.Errorf("Properties[Artifact Type] = %q", got)
+	}
+	want := []Component{
+		{Name: "policy.rego", Type: "file", Size: 120, Description: "application/vnd.example.rego"},
+		{Name: "fedcba987654", Type: "file", Size: 8, Description: "application/octet-stream"},
+	}
+	if len(details.Components) != len(want) {
+		t.Fatalf("Components = %+v", details.Components)
+	}
+	for i, w := range want {
+		if details.Components[i] != w {
+			t.Errorf("Components[%d] = %+v, want %+v", i, details.Components[i], w)
+		}
+	}
+}
+
+func TestGenericHandlerFiles(t *testing.T) {
+	h := &GenericHandler{Fetcher: &registrytest.Fetcher{
+		Manifest: &registry.Manifest{
+			Layers: []registry.Descriptor{
+				{
+					MediaType:   "application/vnd.example.config.v1+json",
+					Digest:      "sha256:1111111111111111",
+					Size:        2,
+					Annotations: map[string]string{"org.opencontainers.image.title": "config.json"},
+				},
+				{
+					MediaType:   "application/vnd.openpolicyagent.policy.layer.v1+rego",
+					Digest:      "sha256:2222222222222222",
+					Size:        10,
+					Annotations: map[string]string{"org.opencontainers.image.title": "policies/policy.rego"},
+				},
+			},
+		},
+		Blobs: map[string][]byte{"sha256:2222222222222222": []byte("package x\n")},
+	}}
+	artifact := &registry.Artifact{Repository: "localhost:5050/policy", Tag: "v1"}
+
+	files, err := h.ListFiles(artifact)
+	if err != nil {
+		t.Fatalf("ListFiles() error = %v", err)
+	}
+	if len(files) != 2 || files[0].Name != "config.json" || files[1].Name != "policies/policy.rego" {
+		t.Fatalf("ListFiles() = %+v", files)
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "config.json", want: "config.json"},
+		{name: "policies/policy.rego", want: "policies/policy.rego"},
+		{name: "policy.rego", want: "policies/policy.rego"},
+		{name: "sha256:2222222", want: "policies/policy.rego"},
+		{name: "1111111", want: "config.json"},
+		{name: "111", wantErr: true},
+		{name: "missing.txt", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := FindFile(files, tt.name)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("FindFile() = %+v, want error", f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindFile() error = %v", err)
+			}
+			if f.Name != tt.want {
+				t.Errorf("FindFile() = %q, want %q", f.Name, tt.want)
+			}
+		})
+	}
+
+	rc, err := h.OpenFile(artifact, files[1])
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil || string(data) != "package x\n" {
+		t.Errorf("OpenFile() content = %q, %v", data, err)
+	}
+}
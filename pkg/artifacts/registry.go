@@ -0,0 +1,106 @@
+package artifacts
+
+import (
+	"sync"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+)
+
+// Registry resolves artifacts to handlers. Handlers registered later take
+// precedence over earlier ones, so an extension can replace a built-in
+// handler for a type. Artifacts no handler claims fall back to ImageHandler.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers []Handler
+	fallback Handler
+}
+
+// Package-level handlers added with Register, applied to every new Registry.
+var (
+	registeredMu sync.RWMutex
+	registered   []Handler
+)
+
+// Register adds a handler to every Registry created afterwards, including the
+// one behind GetHandler. It is intended to be called from an init function.
+func Register(h Handler) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registered = append(registered, h)
+}
+
+// Options configures the built-in handlers of a Registry.
+type Options struct {
+	// Fetcher is used by handlers that fetch artifact content. When nil
+	// they report only what the artifact carries.
+	Fetcher registry.Fetcher
+
+	// VulnDB, when set, enables vulnerability summaries for images and
+	// SBOMs. It is called on first use and may be called concurrently.
+	VulnDB VulnDBFunc
+}
+
+// NewRegistry returns a registry holding the built-in handlers followed by
+// any handlers added with Register.
+func NewRegistry(opts Options) *Registry {
+	f := opts.Fetcher
+	r := &Registry{fallback: &ImageHandler{Fetcher: f, VulnDB: opts.VulnDB}}
+
+	r.Register(r.fallback)
+	r.Register(&GenericHandler{Fetcher: f})
+	r.Register(&HelmHandler{})
+	r.Register(&WasmHandler{Fetcher: f})
+	r.Register(&SBOMHandler{Fetcher: f, VulnDB: opts.VulnDB})
+	r.Register(&SignatureHandler{Fetcher: f})
+	r.Register(&AttestationHandler{Fetcher: f})
+
+	registeredMu.RLock()
+	defer registeredMu.RUnlock()
+	for _, h := range registered {
+		r.Register(h)
+	}
+
+	return r
+}
+
+// Register adds a handler to this registry only.
+func (r *Registry) Register(h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, h)
+}
+
+// Get returns the most recently registered handler that can handle the
+// artifact, or the image handler when none can.
+func (r *Registry) Get(artifact *registry.Artifact) Handler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := len(r.handlers) - 1; i >= 0; i-- {
+		if r.handlers[i].CanHandle(artifact) {
+			return r.handlers[i]
+		}
+	}
+	return r.fallback
+}
+
+// GetHandler returns the appropriate handler for an artifact
+func GetHandler(artifact *registry.Artifact) Handler {
+	return NewRegistry(Options{}).Get(artifact)
+}
+
+// WithInfo returns a copy of artifact with the type, digest, and size from
+// resolved manifest info applied, ready for handler lookup.
+func WithInfo(artifact *registry.Artifact, info *registry.ArtifactInfo) *registry.Artifact {
+	resolved := *artifact
+	if info == nil {
+		return &resolved
+	}
+	resolved.Type = info.Type
+	if info.Digest != "" {
+		resolved.Digest = info.Digest
+	}
+	if info.Size > 0 {
+		resolved.Size = info.Size
+	}
+	return &resolved
+}
@@ -0,0 +1,89 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
+)
+
+// stubHandler claims artifacts of a single type.
+type stubHandler struct {
+	artifactType registry.ArtifactType
+}
+
+func (h *stubHandler) CanHandle(artifact *registry.Artifact) bool {
+	return artifact.Type == h.artifactType
+}
+
+func (h *stubHandler) GetDetails(artifact *registry.Artifact) (*Details, error) {
+	return &Details{Summary: "stub"}, nil
+}
+
+func (h *stubHandler) GetActions(artifact *registry.Artifact) []Action {
+	return nil
+}
+
+func TestRegistryRegisterOverridesBuiltin(t *testing.T) {
+	r := NewRegistry(Options{})
+	stub := &stubHandler{artifactType: registry.ArtifactTypeHelmChart}
+	r.Register(stub)
+
+	if got := r.Get(&registry.Artifact{Type: registry.ArtifactTypeHelmChart}); got != stub {
+		t.Errorf("Get(helm) = %T, want registered stub", got)
+	}
+	if _, ok := r.Get(&registry.Artifact{Type: registry.ArtifactTypeImage}).(*ImageHandler); !ok {
+		t.Error("Get(image) no longer returns ImageHandler")
+	}
+}
+
+func TestRegisterAppliesToNewRegistries(t *testing.T) {
+	saved := registered
+	t.Cleanup(func() { registered = saved })
+
+	before := NewRegistry(Options{})
+	stub := &stubHandler{artifactType: "custom"}
+	Register(stub)
+
+	if got := GetHandler(&registry.Artifact{Type: "custom"}); got != stub {
+		t.Errorf("GetHandler(custom) = %T, want registered stub", got)
+	}
+	if got := NewRegistry(Options{}).Get(&registry.Artifact{Type: "custom"}); got != stub {
+		t.Errorf("NewRegistry().Get(custom) = %T, want registered stub", got)
+	}
+	if _, ok := before.Get(&registry.Artifact{Type: "custom"}).(*ImageHandler); !ok {
+		t.Error("Register() changed a registry created before it")
+	}
+}
+
+func TestRegistryPassesFetcher(t *testing.T) {
+	f := &registrytest.Fetcher{}
+	r := NewRegistry(Options{Fetcher: f})
+
+	h, ok := r.Get(&registry.Artifact{Type: registry.ArtifactTypeWasm}).(*WasmHandler)
+	if !ok {
+		t.Fatal("Get(wasm) did not return WasmHandler")
+	}
+	if h.Fetcher != f {
+		t.Error("WasmHandler.Fetcher not set from NewRegistry")
+	}
+}
+
+func TestWithInfo(t *testing.T) {
+	artifact := &registry.Artifact{Repository: "ghcr.io/org/app", Tag: "v1"}
+	info := &registry.ArtifactInfo{Type: registry.ArtifactTypeSBOM, Digest: "sha256:abc", Size: 42}
+
+	got := WithInfo(artifact, info)
+	if got.Type != registry.ArtifactTypeSBOM || got.Digest != "sha256:abc" || got.Size != 42 {
+		t.Errorf("WithInfo() = %+v", got)
+	}
+	if got.Tag != "v1" || got.Repository != "ghcr.io/org/app" {
+		t.Errorf("WithInfo() lost identity: %+v", got)
+	}
+	if artifact.Type != "" {
+		t.Error("WithInfo() modified the original artifact")
+	}
+	if WithInfo(artifact, nil) == artifact {
+		t.Error("WithInfo(nil) returned the original pointer, want a copy")
+	}
+}
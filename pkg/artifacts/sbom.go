@@ -0,0 +1,122 @@
+package artifacts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/sbom"
+)
+
+// SBOMHandler handles SPDX and CycloneDX software bills of materials.
+// When Fetcher is set, GetDetails downloads the document and lists its
+// packages; when VulnDB is also set, the packages are checked for known
+// vulnerabilities.
+type SBOMHandler struct {
+	Fetcher registry.Fetcher
+	VulnDB  VulnDBFunc
+}
+
+// CanHandle returns true for SBOM artifacts
+func (h *SBOMHandler) CanHandle(artifact *registry.Artifact) bool {
+	return artifact.Type == registry.ArtifactTypeSBOM
+}
+
+// GetDetails returns details for an SBOM artifact, including its package list
+// when the document can be fetched.
+func (h *SBOMHandler) GetDetails(artifact *registry.Artifact) (*Details, error) {
+	details := &Details{
+		Summary: "Software Bill of Materials",
+		Properties: map[string]string{
+			"Tag":    artifact.Tag,
+			"Digest": artifact.Digest,
+		},
+	}
+
+	if h.Fetcher == nil {
+		return details, nil
+	}
+
+	doc, err := sbom.Fetch(h.Fetcher, artifact.Repository, artifactRef(artifact))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM: %w", err)
+	}
+
+	details.Properties["Format"] = string(doc.Format)
+	details.Properties["Spec Version"] = doc.SpecVersion
+	details.Properties["Packages"] = fmt.Sprintf("%d", len(doc.Packages))
+	if doc.Name != "" {
+		details.Properties["Document"] = doc.Name
+	}
+	if doc.Created != "" {
+		details.Properties["Created"] = doc.Created
+	}
+	if len(doc.Tools) > 0 {
+		details.Properties["Tools"] = strings.Join(doc.Tools, ", ")
+	}
+	if eco := ecosystemSummary(doc.Packages); eco != "" {
+		details.Properties["Ecosystems"] = eco
+	}
+
+	for _, p := range doc.Packages {
+		typ := p.Type()
+		if typ == "" {
+			typ = "package"
+		}
+		desc := p.Version
+		if len(p.Licenses) > 0 {
+			desc = strings.TrimSpace(desc + " (" + strings.Join(p.Licenses, ", ") + ")")
+		}
+		details.Components = append(details.Components, Component{
+			Name:        p.Name,
+			Type:        typ,
+			Description: desc,
+		})
+	}
+
+	if h.VulnDB != nil {
+		addVulnerabilities(details, h.VulnDB, doc.Packages)
+	}
+
+	return details, nil
+}
+
+// GetActions returns available actions for an SBOM
+func (h *SBOMHandler) GetActions(artifact *registry.Artifact) []Action {
+	return []Action{
+		{
+			Name:        "Pull",
+			Description: "Pull the SBOM document",
+			Command:     "lazyoci pull " + artifact.Repository + ":" + artifact.Tag,
+		},
+	}
+}
+
+// ecosystemSummary counts packages by PURL type, most common first,
+// e.g. "golang 42, deb 7".
+func ecosystemSummary(packages []sbom.Package) string {
+	counts := make(map[string]int)
+	for _, p := range packages {
+		if t := p.Type(); t != "" {
+			counts[t]++
+		}
+	}
+
+	types := make([]string, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if counts[types[i]] != counts[types[j]] {
+			return counts[types[i]] > counts[types[j]]
+		}
+		return types[i] < types[j]
+	})
+
+	parts := make([]string, len(types))
+	for i, t := range types {
+		parts[i] = fmt.Sprintf("%s %d", t, counts[t])
+	}
+	return strings.Join(parts, ", ")
+}
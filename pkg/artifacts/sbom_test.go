@@ -0,0 +1,66 @@
+package artifacts
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
+	"github.com/mistergrinvalds/lazyoci/pkg/sbom"
+)
+
+func TestSBOMHandlerGetDetails(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/fixtures/sbom-cyclonedx.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	h := &SBOMHandler{Fetcher: &registrytest.Fetcher{
+		Manifest: &registry.Manifest{Layers: []registry.Descriptor{
+			{MediaType: "application/vnd.cyclonedx+json", Digest: "sha256:bom"},
+		}},
+		Blobs: map[string][]byte{"sha256:bom": data},
+	}}
+
+	details, err := h.GetDetails(&registry.Artifact{
+		Repository: "localhost:5050/test/myapp",
+		Tag:        "sbom",
+		Type:       registry.ArtifactTypeSBOM,
+	})
+	if err != nil {
+		t.Fatalf("GetDetails() error = %v", err)
+	}
+
+	wantProps := map[string]string{
+		"Format":     "cyclonedx",
+		"Packages":   "1",
+		"Ecosystems": "golang 1",
+		"Tools":      "test-fixture 0.1.0",
+	}
+	for k, want := range wantProps {
+		if got := details.Properties[k]; got != want {
+			t.Errorf("Properties[%s] = %q, want %q", k, got, want)
+		}
+	}
+
+	want := Component{Name: "example-lib", Type: "golang", Description: "2.0.0"}
+	if len(details.Components) != 1 || details.Components[0] != want {
+		t.Errorf("Components = %+v, want [%+v]", details.Components, want)
+	}
+}
+
+func TestEcosystemSummary(t *testing.T) {
+	packages := []sbom.Package{
+		{Name: "a", PURL: "pkg:npm/a@1"},
+		{Name: "b", PURL: "pkg:golang/b@1"},
+		{Name: "c", PURL: "pkg:npm/c@1"},
+		{Name: "d", PURL: "pkg:deb/debian/d@1"},
+		{Name: "e"},
+	}
+
+	if got, want := ecosystemSummary(packages), "npm 2, deb 1, golang 1"; got != want {
+		t.Errorf("ecosystemSummary() = %q, want %q", got, want)
+	}
+	if got := ecosystemSummary(nil); got != "" {
+		t.Errorf("ecosystemSummary(nil) = %q, want empty", got)
+	}
+}
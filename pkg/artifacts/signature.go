@@ -0,0 +1,231 @@
+package artifacts
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+)
+
+// Cosign signature layer annotations.
+const (
+	annotationCosignSignature   = "dev.cosignproject.cosign/signature"
+	annotationCosignCertificate = "dev.sigstore.cosign/certificate"
+	annotationCosignBundle      = "dev.sigstore.cosign/bundle"
+)
+
+// Fulcio certificate extensions carrying the OIDC issuer.
+var (
+	oidFulcioIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// SignatureHandler handles cosign and Notary signature artifacts.
+// When Fetcher is set, GetDetails decodes cosign simple-signing payloads
+// and the signer identity from keyless certificates. Signatures are
+// displayed, not verified.
+type SignatureHandler struct {
+	Fetcher registry.Fetcher
+}
+
+// CanHandle returns true for signature artifacts
+func (h *SignatureHandler) CanHandle(artifact *registry.Artifact) bool {
+	return artifact.Type == registry.ArtifactTypeSignature
+}
+
+// simpleSigning is the cosign simple-signing payload.
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]any `json:"optional"`
+}
+
+// rekorBundle is the subset of a cosign Rekor bundle annotation we display.
+type rekorBundle struct {
+	Payload struct {
+		IntegratedTime int64 `json:"integratedTime"`
+		LogIndex       int64 `json:"logIndex"`
+	} `json:"Payload"`
+}
+
+// GetDetails returns details for a signature artifact
+func (h *SignatureHandler) GetDetails(artifact *registry.Artifact) (*Details, error) {
+	details := &Details{
+		Summary: "Signature",
+		Properties: map[string]string{
+			"Tag":    artifact.Tag,
+			"Digest": artifact.Digest,
+		},
+	}
+	if subject := signedSubject(artifact); subject != "" {
+		details.RelatedArtifacts = append(details.RelatedArtifacts, subject)
+	}
+
+	if h.Fetcher == nil {
+		return details, nil
+	}
+
+	manifest, _, err := h.Fetcher.GetManifest(artifact.Repository, artifactRef(artifact))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+
+	details.Properties["Signatures"] = fmt.Sprintf("%d", len(manifest.Layers))
+
+	for _, layer := range manifest.Layers {
+		comp := Component{
+			Name: shortDigest(layer.Digest),
+			Type: "signature",
+			Size: layer.Size,
+		}
+
+		if !strings.Contains(layer.MediaType, "simplesigning") {
+			comp.Description = layer.MediaType
+			details.Components = append(details.Components, comp)
+			continue
+		}
+		details.Summary = "Cosign Signature"
+
+		var notes []string
+		if signer, issuer := certificateIdentity(layer.Annotations[annotationCosignCertificate]); signer != "" {
+			notes = append(notes, "keyless: "+signer)
+			details.Properties["Signer"] = signer
+			if issuer != "" {
+				details.Properties["Issuer"] = issuer
+			}
+		} else if layer.Annotations[annotationCosignSignature] != "" {
+			notes = append(notes, "key-based")
+		}
+		if entry := rekorEntry(layer.Annotations[annotationCosignBundle]); entry != "" {
+			notes = append(notes, "rekor "+entry)
+			details.Properties["Transparency Log"] = "rekor " + entry
+		}
+		comp.Description = strings.Join(notes, ", ")
+		details.Components = append(details.Components, comp)
+
+		data, err := h.Fetcher.FetchBlob(artifact.Repository, layer)
+		if err != nil {
+			continue
+		}
+		var payload simpleSigning
+		if err := json.Unmarshal(data, &payload); err != nil {
+			continue
+		}
+		if ref := payload.Critical.Identity.DockerReference; ref != "" {
+			details.Properties["Signed Image"] = ref
+		}
+		if d := payload.Critical.Image.DockerManifestDigest; d != "" {
+			details.Properties["Signed Digest"] = d
+		}
+		keys := make([]string, 0, len(payload.Optional))
+		for k := range payload.Optional {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			details.Components = append(details.Components, Component{
+				Name:        k,
+				Type:        "claim",
+				Description: fmt.Sprint(payload.Optional[k]),
+			})
+		}
+	}
+
+	return details, nil
+}
+
+// GetActions returns available actions for a signature
+func (h *SignatureHandler) GetActions(artifact *registry.Artifact) []Action {
+	actions := []Action{
+		{
+			Name:        "Pull",
+			Description: "Pull the signature payload",
+			Command:     "lazyoci pull " + artifact.Repository + ":" + artifact.Tag,
+		},
+	}
+	if subject := signedSubject(artifact); subject != "" {
+		actions = append(actions, Action{
+			Name:        "Tree",
+			Description: "List everything attached to the signed image (requires cosign)",
+			Command:     "cosign tree " + subject,
+			Key:         't',
+		})
+	}
+	return actions
+}
+
+// signedSubject derives the signed image reference from a cosign signature
+// tag ("sha256-<hex>.sig" → "repo@sha256:<hex>"). It returns "" for other tags.
+func signedSubject(artifact *registry.Artifact) string {
+	hex, ok := strings.CutPrefix(artifact.Tag, "sha256-")
+	if !ok {
+		return ""
+	}
+	hex, ok = strings.CutSuffix(hex, ".sig")
+	if !ok || hex == "" {
+		return ""
+	}
+	return artifact.Repository + "@sha256:" + hex
+}
+
+// certificateIdentity extracts the signer (email or URI SAN) and the OIDC
+// issuer from a Fulcio certificate in PEM form.
+func certificateIdentity(certPEM string) (signer, issuer string) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return "", ""
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", ""
+	}
+
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		signer = cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		signer = cert.URIs[0].String()
+	}
+
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidFulcioIssuerV2):
+			var s string
+			if _, err := asn1.Unmarshal(ext.Value, &s); err == nil {
+				issuer = s
+			}
+		case ext.Id.Equal(oidFulcioIssuer) && issuer == "":
+			issuer = string(ext.Value)
+		}
+	}
+	return signer, issuer
+}
+
+// rekorEntry renders a Rekor bundle annotation as "#<index> (<time>)".
+func rekorEntry(bundle string) string {
+	if bundle == "" {
+		return ""
+	}
+	var b rekorBundle
+	if err := json.Unmarshal([]byte(bundle), &b); err != nil {
+		return ""
+	}
+	entry := fmt.Sprintf("#%d", b.Payload.LogIndex)
+	if b.Payload.IntegratedTime > 0 {
+		entry += " (" + time.Unix(b.Payload.IntegratedTime, 0).UTC().Format(time.RFC3339) + ")"
+	}
+	return entry
+}
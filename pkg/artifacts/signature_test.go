@@ -0,0 +1,110 @@
+package artifacts
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
+)
+
+// fulcioCert returns a self-signed PEM certificate shaped like a Fulcio
+// keyless signing certificate.
+func fulcioCert(t *testing.T, email, issuer string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerValue, err := asn1.Marshal(issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{},
+		NotBefore:       time.Now(),
+		NotAfter:        time.Now().Add(10 * time.Minute),
+		EmailAddresses:  []string{email},
+		ExtraExtensions: []pkix.Extension{{Id: oidFulcioIssuerV2, Value: issuerValue}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestSignatureHandlerGetDetails(t *testing.T) {
+	payload, err := os.ReadFile("../../testdata/fixtures/cosign-sig.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	h := &SignatureHandler{Fetcher: &registrytest.Fetcher{
+		Manifest: &registry.Manifest{Layers: []registry.Descriptor{{
+			MediaType: "application/vnd.dev.cosign.simplesigning.v1+json",
+			Digest:    "sha256:0123456789abcdef",
+			Annotations: map[string]string{
+				annotationCosignSignature:   "MEUCIQ==",
+				annotationCosignCertificate: fulcioCert(t, "dev@example.com", "https://accounts.example.com"),
+				annotationCosignBundle:      `{"Payload": {"integratedTime": 1704067200, "logIndex": 42}}`,
+			},
+		}}},
+		Blobs: map[string][]byte{"sha256:0123456789abcdef": payload},
+	}}
+
+	artifact := &registry.Artifact{
+		Repository: "localhost:5050/test/myapp",
+		Tag:        "sha256-abc123.sig",
+		Type:       registry.ArtifactTypeSignature,
+	}
+	details, err := h.GetDetails(artifact)
+	if err != nil {
+		t.Fatalf("GetDetails() error = %v", err)
+	}
+
+	wantProps := map[string]string{
+		"Signed Image":     "localhost:5050/test/myapp",
+		"Signer":           "dev@example.com",
+		"Issuer":           "https://accounts.example.com",
+		"Transparency Log": "rekor #42 (2024-01-01T00:00:00Z)",
+		"Signatures":       "1",
+	}
+	for k, want := range wantProps {
+		if got := details.Properties[k]; got != want {
+			t.Errorf("Properties[%s] = %q, want %q", k, got, want)
+		}
+	}
+	if len(details.RelatedArtifacts) != 1 || details.RelatedArtifacts[0] != "localhost:5050/test/myapp@sha256:abc123" {
+		t.Errorf("RelatedArtifacts = %v", details.RelatedArtifacts)
+	}
+	if got := details.Components[0].Description; got != "keyless: dev@example.com, rekor #42 (2024-01-01T00:00:00Z)" {
+		t.Errorf("Components[0].Description = %q", got)
+	}
+}
+
+func TestSignedSubject(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{tag: "sha256-abc.sig", want: "r@sha256:abc"},
+		{tag: "sha256-abc.att", want: ""},
+		{tag: "v1", want: ""},
+		{tag: "sha256-.sig", want: ""},
+	}
+	for _, tt := range tests {
+		if got := signedSubject(&registry.Artifact{Repository: "r", Tag: tt.tag}); got != tt.want {
+			t.Errorf("signedSubject(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package artifacts
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/sbom"
 )
 
 // Handler defines the interface for artifact type handlers
@@ -45,10 +49,22 @@ type Action struct {
 	Description string
 	Command     string
 	Dangerous   bool
+
+	// Key binds the action in the TUI details panel. Zero leaves it unbound.
+	// Keys already used by the TUI (p, d, j, k, g, G, q, S, T, ?, /, 1-4) are
+	// ignored.
+	Key rune
 }
 
-// ImageHandler handles container image artifacts
-type ImageHandler struct{}
+// ImageHandler handles container image artifacts.
+// When Fetcher is set and the artifact carries no layers, GetDetails
+// reads them (or the platform manifests of an index) from the registry.
+// When VulnDB is also set, the image's SBOM is located with sbom.Discover
+// and checked for known vulnerabilities.
+type ImageHandler struct {
+	Fetcher registry.Fetcher
+	VulnDB  VulnDBFunc
+}
 
 // CanHandle returns true for image artifacts
 func (h *ImageHandler) CanHandle(artifact *registry.Artifact) bool {
@@ -74,9 +90,66 @@ func (h *ImageHandler) GetDetails(artifact *registry.Artifact) (*Details, error)
 		})
 	}
 
+	if h.Fetcher == nil {
+		return details, nil
+	}
+	if len(artifact.Layers) == 0 {
+		if err := h.addManifest(details, artifact); err != nil {
+			return nil, err
+		}
+	}
+	if h.VulnDB != nil {
+		doc, _, err := sbom.Discover(h.Fetcher, artifact.Repository, artifactRef(artifact))
+		if err != nil {
+			details.Properties["Vulnerabilities"] = "no SBOM attached"
+		} else {
+			addVulnerabilities(details, h.VulnDB, doc.Packages)
+		}
+	}
+
 	return details, nil
 }
 
+// addManifest describes the platforms of an index, or the config and layers
+// of an image manifest.
+func (h *ImageHandler) addManifest(details *Details, artifact *registry.Artifact) error {
+	manifest, _, err := h.Fetcher.GetManifest(artifact.Repository, artifactRef(artifact))
+	if err != nil {
+		return fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+
+	if len(manifest.Manifests) > 0 {
+		details.Summary = "Multi-platform Image"
+		details.Properties["Platforms"] = fmt.Sprintf("%d", len(manifest.Manifests))
+		for _, m := range manifest.Manifests {
+			name := m.Platform.String()
+			if name == "" {
+				name = shortDigest(m.Digest)
+			}
+			details.Components = append(details.Components, Component{
+				Name:        name,
+				Type:        "platform",
+				Size:        m.Size,
+				Description: shortDigest(m.Digest),
+			})
+		}
+		return nil
+	}
+
+	if manifest.Config.MediaType != "" {
+		details.Properties["Config"] = manifest.Config.MediaType
+	}
+	for _, layer := range manifest.Layers {
+		details.Components = append(details.Components, Component{
+			Name:        shortDigest(layer.Digest),
+			Type:        "layer",
+			Size:        layer.Size,
+			Description: layer.MediaType,
+		})
+	}
+	return nil
+}
+
 // GetActions returns available actions for an image
 func (h *ImageHandler) GetActions(artifact *registry.Artifact) []Action {
 	return []Action{
@@ -89,6 +162,7 @@ func (h *ImageHandler) GetActions(artifact *registry.Artifact) []Action {
 			Name:        "Inspect",
 			Description: "Inspect the image manifest",
 			Command:     "docker manifest inspect " + artifact.Repository + ":" + artifact.Tag,
+			Key:         'i',
 		},
 		{
 			Name:        "Copy Digest",
@@ -130,27 +204,25 @@ func (h *HelmHandler) GetActions(artifact *registry.Artifact) []Action {
 			Name:        "Show Values",
 			Description: "Show default values",
 			Command:     "helm show values oci://" + artifact.Repository + " --version " + artifact.Tag,
+			Key:         'v',
 		},
 		{
 			Name:        "Template",
 			Description: "Render chart templates",
 			Command:     "helm template oci://" + artifact.Repository + " --version " + artifact.Tag,
+			Key:         't',
 		},
 	}
 }
 
-// GetHandler returns the appropriate handler for an artifact
-func GetHandler(artifact *registry.Artifact) Handler {
-	handlers := []Handler{
-		&HelmHandler{},
-		&ImageHandler{}, // Default fallback
+// shortDigest returns the first 12 hex characters of a digest,
+// without the algorithm prefix.
+func shortDigest(digest string) string {
+	if i := strings.IndexByte(digest, ':'); i != -1 {
+		digest = digest[i+1:]
 	}
-
-	for _, h := range handlers {
-		if h.CanHandle(artifact) {
-			return h
-		}
+	if len(digest) > 12 {
+		return digest[:12]
 	}
-
-	return &ImageHandler{}
+	return digest
 }
@@ -1,9 +1,11 @@
 package artifacts
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
 )
 
 func TestGetHandler(t *testing.T) {
@@ -23,14 +25,34 @@ func TestGetHandler(t *testing.T) {
 			wantType: "*artifacts.HelmHandler",
 		},
 		{
-			name:     "unknown artifact falls back to ImageHandler",
+			name:     "unknown artifact gets GenericHandler",
 			artifact: &registry.Artifact{Type: registry.ArtifactTypeUnknown},
+			wantType: "*artifacts.GenericHandler",
+		},
+		{
+			name:     "unresolved artifact falls back to ImageHandler",
+			artifact: &registry.Artifact{},
 			wantType: "*artifacts.ImageHandler",
 		},
 		{
-			name:     "sbom artifact falls back to ImageHandler",
+			name:     "wasm artifact gets WasmHandler",
+			artifact: &registry.Artifact{Type: registry.ArtifactTypeWasm},
+			wantType: "*artifacts.WasmHandler",
+		},
+		{
+			name:     "sbom artifact gets SBOMHandler",
 			artifact: &registry.Artifact{Type: registry.ArtifactTypeSBOM},
-			wantType: "*artifacts.ImageHandler",
+			wantType: "*artifacts.SBOMHandler",
+		},
+		{
+			name:     "signature artifact gets SignatureHandler",
+			artifact: &registry.Artifact{Type: registry.ArtifactTypeSignature},
+			wantType: "*artifacts.SignatureHandler",
+		},
+		{
+			name:     "attestation artifact gets AttestationHandler",
+			artifact: &registry.Artifact{Type: registry.ArtifactTypeAttestation},
+			wantType: "*artifacts.AttestationHandler",
 		},
 	}
 
@@ -41,16 +63,8 @@ func TestGetHandler(t *testing.T) {
 				t.Fatal("GetHandler() returned nil")
 			}
 
-			// Check handler type via CanHandle behavior
-			switch tt.wantType {
-			case "*artifacts.HelmHandler":
-				if _, ok := handler.(*HelmHandler); !ok {
-					t.Errorf("expected HelmHandler, got %T", handler)
-				}
-			case "*artifacts.ImageHandler":
-				if _, ok := handler.(*ImageHandler); !ok {
-					t.Errorf("expected ImageHandler, got %T", handler)
-				}
+			if got := fmt.Sprintf("%T", handler); got != tt.wantType {
+				t.Errorf("GetHandler() = %s, want %s", got, tt.wantType)
 			}
 		})
 	}
@@ -204,3 +218,28 @@ func TestHelmHandlerGetDetails(t *testing.T) {
 		t.Errorf("Properties[Digest] = %q", details.Properties["Digest"])
 	}
 }
+
+func TestImageHandlerGetDetailsFetchesIndex(t *testing.T) {
+	h := &ImageHandler{Fetcher: &registrytest.Fetcher{
+		Manifest: &registry.Manifest{Manifests: []registry.Descriptor{
+			{Digest: "sha256:aaaaaaaaaaaaaaaa", Size: 500, Platform: &registry.Platform{OS: "linux", Architecture: "amd64"}},
+			{Digest: "sha256:bbbbbbbbbbbbbbbb", Size: 600, Platform: &registry.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+		}},
+	}}
+
+	details, err := h.GetDetails(&registry.Artifact{Repository: "docker.io/library/nginx", Tag: "latest"})
+	if err != nil {
+		t.Fatalf("GetDetails() error = %v", err)
+	}
+
+	if details.Summary != "Multi-platform Image" {
+		t.Errorf("Summary = %q", details.Summary)
+	}
+	if len(details.Components) != 2 {
+		t.Fatalf("len(Components) = %d, want 2", len(details.Components))
+	}
+	want := Component{Name: "linux/arm64/v8", Type: "platform", Size: 600, Description: "bbbbbbbbbbbb"}
+	if details.Components[1] != want {
+		t.Errorf("Components[1] = %+v, want %+v", details.Components[1], want)
+	}
+}
@@ -0,0 +1,49 @@
+package artifacts
+
+import (
+	"fmt"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/osv"
+	"github.com/mistergrinvalds/lazyoci/pkg/sbom"
+)
+
+// VulnDBFunc returns the OSV database used for vulnerability summaries.
+type VulnDBFunc func() (*osv.DB, error)
+
+// addVulnerabilities matches packages against the database returned by load
+// and records the result as a "Vulnerabilities" property and one
+// "vulnerability <SEVERITY>" component per finding.
+func addVulnerabilities(details *Details, load VulnDBFunc, packages []sbom.Package) {
+	db, err := load()
+	if err != nil {
+		details.Properties["Vulnerabilities"] = "database unavailable: " + err.Error()
+		return
+	}
+
+	report := db.Match(packages)
+	if report.Total() == 0 {
+		details.Properties["Vulnerabilities"] = fmt.Sprintf("none found (%d packages)", report.Packages)
+		return
+	}
+	details.Properties["Vulnerabilities"] = severitySummary(report.Counts, report.Total())
+	for _, f := range report.Findings {
+		details.Components = append(details.Components, vulnComponent(f.ID, f.Severity, f.Package, f.Version, f.FixedIn))
+	}
+}
+
+// vulnComponent describes one vulnerability finding,
+// e.g. "openssl@3.0.11-1 (fixed in 3.0.13-1)".
+func vulnComponent(id, severity, pkg, version, fixedIn string) Component {
+	desc := pkg
+	if version != "" {
+		desc += "@" + version
+	}
+	if fixedIn != "" {
+		desc += " (fixed in " + fixedIn + ")"
+	}
+	return Component{
+		Name:        id,
+		Type:        "vulnerability " + severity,
+		Description: desc,
+	}
+}
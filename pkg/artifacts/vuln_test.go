@@ -0,0 +1,108 @@
+package artifacts
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/osv"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
+)
+
+// testVulnDB affects example.com/lib (the package in sbom-cyclonedx.json)
+// below 2.1.0.
+func testVulnDB() (*osv.DB, error) {
+	db := osv.NewDB()
+	db.Add(&osv.Vulnerability{
+		ID:               "GHSA-test-0001",
+		DatabaseSpecific: map[string]any{"severity": "HIGH"},
+		Affected: []osv.Affected{{
+			Package: osv.Package{Ecosystem: "Go", Name: "example.com/lib"},
+			Ranges: []osv.Range{{Type: "SEMVER", Events: []osv.Event{
+				{Introduced: "0"},
+				{Fixed: "2.1.0"},
+			}}},
+		}},
+	})
+	return db, nil
+}
+
+func TestVulnerabilitySummary(t *testing.T) {
+	bom, err := os.ReadFile("../../testdata/fixtures/sbom-cyclonedx.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	sbomFetcher := &registrytest.Fetcher{
+		Manifest: &registry.Manifest{Layers: []registry.Descriptor{
+			{MediaType: "application/vnd.cyclonedx+json", Digest: "sha256:bom"},
+		}},
+		Blobs: map[string][]byte{"sha256:bom": bom},
+	}
+	imageFetcher := &registrytest.Fetcher{
+		Manifest: &registry.Manifest{Layers: []registry.Descriptor{
+			{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: "sha256:layer"},
+		}},
+	}
+	artifact := &registry.Artifact{Repository: "localhost:5050/test/myapp", Tag: "v1"}
+
+	tests := []struct {
+		name           string
+		handler        Handler
+		wantProperty   string
+		wantComponents int
+	}{
+		{
+			name:           "sbom",
+			handler:        &SBOMHandler{Fetcher: sbomFetcher, VulnDB: testVulnDB},
+			wantProperty:   "1 (1 HIGH)",
+			wantComponents: 1,
+		},
+		{
+			name:           "image with sbom",
+			handler:        &ImageHandler{Fetcher: sbomFetcher, VulnDB: testVulnDB},
+			wantProperty:   "1 (1 HIGH)",
+			wantComponents: 1,
+		},
+		{
+			name:         "image without sbom",
+			handler:      &ImageHandler{Fetcher: imageFetcher, VulnDB: testVulnDB},
+			wantProperty: "no SBOM attached",
+		},
+		{
+			name: "database error",
+			handler: &SBOMHandler{Fetcher: sbomFetcher, VulnDB: func() (*osv.DB, error) {
+				return nil, errors.New("boom")
+			}},
+			wantProperty: "database unavailable: boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details, err := tt.handler.GetDetails(artifact)
+			if err != nil {
+				t.Fatalf("GetDetails() error = %v", err)
+			}
+			if got := details.Properties["Vulnerabilities"]; got != tt.wantProperty {
+				t.Errorf("Properties[Vulnerabilities] = %q, want %q", got, tt.wantProperty)
+			}
+
+			var vulns []Component
+			for _, c := range details.Components {
+				if c.Type == "vulnerability HIGH" {
+					vulns = append(vulns, c)
+				}
+			}
+			if len(vulns) != tt.wantComponents {
+				t.Fatalf("vulnerability components = %+v, want %d", vulns, tt.wantComponents)
+			}
+			if len(vulns) > 0 {
+				want := "example.com/lib@2.0.0 (fixed in 2.1.0)"
+				if vulns[0].Name != "GHSA-test-0001" || vulns[0].Description != want {
+					t.Errorf("component = %+v, want GHSA-test-0001 %q", vulns[0], want)
+				}
+			}
+		})
+	}
+}
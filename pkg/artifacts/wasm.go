@@ -0,0 +1,115 @@
+package artifacts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/wasm"
+)
+
+// WasmHandler handles WebAssembly modules and components.
+// When Fetcher is set, GetDetails downloads and parses the wasm layer.
+type WasmHandler struct {
+	Fetcher registry.Fetcher
+}
+
+// CanHandle returns true for wasm artifacts
+func (h *WasmHandler) CanHandle(artifact *registry.Artifact) bool {
+	return artifact.Type == registry.ArtifactTypeWasm
+}
+
+// GetDetails returns details for a wasm artifact, including imports,
+// exports, and custom sections when the binary can be fetched.
+func (h *WasmHandler) GetDetails(artifact *registry.Artifact) (*Details, error) {
+	details := &Details{
+		Summary: "WebAssembly",
+		Properties: map[string]string{
+			"Tag":    artifact.Tag,
+			"Digest": artifact.Digest,
+		},
+	}
+
+	if h.Fetcher == nil {
+		return details, nil
+	}
+
+	m, err := wasm.Fetch(h.Fetcher, artifact.Repository, artifactRef(artifact))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect wasm: %w", err)
+	}
+
+	details.Summary = "WebAssembly Module"
+	if m.Kind == wasm.KindComponent {
+		details.Summary = "WebAssembly Component"
+	}
+
+	details.Properties["Kind"] = string(m.Kind)
+	details.Properties["Binary Version"] = fmt.Sprintf("%d", m.Version)
+	if m.WASIVersion != "" {
+		details.Properties["WASI"] = m.WASIVersion
+	}
+	if m.Functions > 0 {
+		details.Properties["Functions"] = fmt.Sprintf("%d", m.Functions)
+	}
+	if len(m.CoreModules) > 0 {
+		details.Properties["Core Modules"] = fmt.Sprintf("%d", len(m.CoreModules))
+	}
+	if langs := m.Producers["language"]; len(langs) > 0 {
+		details.Properties["Language"] = strings.Join(langs, ", ")
+	}
+
+	for _, imp := range m.Imports {
+		name := imp.Name
+		if imp.Module != "" {
+			name = imp.Module + "." + imp.Name
+		}
+		details.Components = append(details.Components, Component{
+			Name:        name,
+			Type:        "import " + imp.Kind,
+			Description: imp.Signature,
+		})
+	}
+	for _, exp := range m.Exports {
+		details.Components = append(details.Components, Component{
+			Name:        exp.Name,
+			Type:        "export " + exp.Kind,
+			Description: exp.Signature,
+		})
+	}
+	for _, cs := range m.CustomSections {
+		details.Components = append(details.Components, Component{
+			Name: cs.Name,
+			Type: "custom",
+			Size: int64(cs.Size),
+		})
+	}
+
+	return details, nil
+}
+
+// GetActions returns available actions for a wasm artifact
+func (h *WasmHandler) GetActions(artifact *registry.Artifact) []Action {
+	ref := artifact.Repository + ":" + artifact.Tag
+	return []Action{
+		{
+			Name:        "Pull",
+			Description: "Pull the .wasm binary",
+			Command:     "lazyoci pull " + ref,
+		},
+		{
+			Name:        "Inspect",
+			Description: "Show imports, exports, and custom sections",
+			Command:     "lazyoci inspect wasm " + ref,
+			Key:         'i',
+		},
+	}
+}
+
+// artifactRef returns the tag, or the digest for untagged artifacts.
+func artifactRef(artifact *registry.Artifact) string {
+	if artifact.Tag != "" {
+		return artifact.Tag
+	}
+	return artifact.Digest
+}
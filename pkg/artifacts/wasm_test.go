@@ -0,0 +1,106 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
+)
+
+// minimalWasm is a core module importing env.log and exporting "run":
+//
+//	(module
+//	  (type (func (param i32)))
+//	  (import "env" "log" (func (type 0)))
+//	  (export "run" (func 0)))
+var minimalWasm = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	0x01, 0x05, 0x01, 0x60, 0x01, 0x7f, 0x00,
+	0x02, 0x0b, 0x01, 0x03, 'e', 'n', 'v', 0x03, 'l', 'o', 'g', 0x00, 0x00,
+	0x07, 0x07, 0x01, 0x03, 'r', 'u', 'n', 0x00, 0x00,
+}
+
+func TestWasmHandlerCanHandle(t *testing.T) {
+	h := &WasmHandler{}
+
+	if !h.CanHandle(&registry.Artifact{Type: registry.ArtifactTypeWasm}) {
+		t.Error("WasmHandler.CanHandle() = false for wasm artifact")
+	}
+	if h.CanHandle(&registry.Artifact{Type: registry.ArtifactTypeImage}) {
+		t.Error("WasmHandler.CanHandle() = true for image artifact")
+	}
+}
+
+func TestWasmHandlerGetDetails(t *testing.T) {
+	h := &WasmHandler{Fetcher: &registrytest.Fetcher{
+		Manifest: &registry.Manifest{Layers: []registry.Descriptor{
+			{MediaType: "application/vnd.wasm.content.layer.v1+wasm", Digest: "sha256:wasm"},
+		}},
+		Blobs: map[string][]byte{"sha256:wasm": minimalWasm},
+	}}
+	artifact := &registry.Artifact{
+		Repository: "localhost:5050/test/hello-wasm",
+		Tag:        "v1",
+		Type:       registry.ArtifactTypeWasm,
+	}
+
+	details, err := h.GetDetails(artifact)
+	if err != nil {
+		t.Fatalf("GetDetails() error = %v", err)
+	}
+
+	if details.Summary != "WebAssembly Module" {
+		t.Errorf("Summary = %q, want %q", details.Summary, "WebAssembly Module")
+	}
+	if details.Properties["Kind"] != "core" {
+		t.Errorf("Properties[Kind] = %q, want core", details.Properties["Kind"])
+	}
+
+	want := []Component{
+		{Name: "env.log", Type: "import func", Description: "(i32)"},
+		{Name: "run", Type: "export func", Description: "(i32)"},
+	}
+	if len(details.Components) != len(want) {
+		t.Fatalf("Components = %+v", details.Components)
+	}
+	for i, w := range want {
+		if details.Components[i] != w {
+			t.Errorf("Components[%d] = %+v, want %+v", i, details.Components[i], w)
+		}
+	}
+}
+
+func TestWasmHandlerGetDetailsWithoutFetcher(t *testing.T) {
+	h := &WasmHandler{}
+
+	details, err := h.GetDetails(&registry.Artifact{Tag: "v1", Digest: "sha256:abc"})
+	if err != nil {
+		t.Fatalf("GetDetails() error = %v", err)
+	}
+	if details.Properties["Tag"] != "v1" {
+		t.Errorf("Properties[Tag] = %q", details.Properties["Tag"])
+	}
+	if len(details.Components) != 0 {
+		t.Errorf("Components = %+v, want none", details.Components)
+	}
+}
+
+func TestWasmHandlerGetDetailsError(t *testing.T) {
+	h := &WasmHandler{Fetcher: &registrytest.Fetcher{}}
+
+	if _, err := h.GetDetails(&registry.Artifact{Repository: "localhost:5050/x", Tag: "v1"}); err == nil {
+		t.Error("GetDetails() expected error when manifest is missing")
+	}
+}
+
+func TestWasmHandlerGetActions(t *testing.T) {
+	h := &WasmHandler{}
+	actions := h.GetActions(&registry.Artifact{Repository: "ghcr.io/org/app", Tag: "v1"})
+
+	if len(actions) != 2 {
+		t.Fatalf("len(actions) = %d, want 2", len(actions))
+	}
+	if actions[1].Command != "lazyoci inspect wasm ghcr.io/org/app:v1" {
+		t.Errorf("actions[1].Command = %q", actions[1].Command)
+	}
+}
@@ -0,0 +1,205 @@
+// Package attestation decodes in-toto attestations stored as OCI artifacts.
+//
+// Attestations arrive either as a bare in-toto Statement
+// (application/vnd.in-toto+json) or wrapped in a DSSE envelope
+// (application/vnd.dsse.envelope.v1+json) whose payload is the base64-encoded
+// Statement. Decode handles both and interprets the well-known predicate
+// types: SLSA provenance (v0.2 and v1) and vulnerability scans.
+package attestation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// In-toto payload and predicate type identifiers.
+const (
+	// PayloadTypeInToto is the DSSE payloadType for in-toto statements.
+	PayloadTypeInToto = "application/vnd.in-toto+json"
+
+	PredicateSLSAProvenanceV02 = "https://slsa.dev/provenance/v0.2"
+	PredicateSLSAProvenanceV1  = "https://slsa.dev/provenance/v1"
+	PredicateCosignVuln        = "https://cosign.sigstore.dev/attestation/vuln/v1"
+)
+
+// Kind classifies a decoded attestation by its predicate.
+type Kind string
+
+const (
+	KindProvenance Kind = "provenance"
+	KindVulnScan   Kind = "vuln-scan"
+	KindOther      Kind = "other"
+)
+
+// Envelope is a DSSE envelope (https://github.com/secure-systems-lab/dsse).
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a single DSSE signature.
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// Statement is an in-toto v0.1 / v1 Statement.
+type Statement struct {
+	Type          string          `json:"_type"`
+	PredicateType string          `json:"predicateType"`
+	Subject       []Subject       `json:"subject"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// Subject identifies an artifact the statement is about.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Attestation is a decoded attestation ready for display.
+type Attestation struct {
+	// Kind is the predicate classification.
+	Kind Kind `json:"kind" yaml:"kind"`
+
+	// Enveloped is true when the statement was wrapped in a DSSE envelope.
+	Enveloped bool `json:"enveloped" yaml:"enveloped"`
+
+	// PayloadType is the DSSE payload type (empty for bare statements).
+	PayloadType string `json:"payloadType,omitempty" yaml:"payloadType,omitempty"`
+
+	// SignatureCount is the number of DSSE signatures (not verified).
+	SignatureCount int `json:"signatureCount" yaml:"signatureCount"`
+
+	// KeyIDs lists the key IDs of DSSE signatures, where present.
+	KeyIDs []string `json:"keyIDs,omitempty" yaml:"keyIDs,omitempty"`
+
+	// StatementType is the in-toto statement _type.
+	StatementType string `json:"statementType" yaml:"statementType"`
+
+	// PredicateType is the predicate URI.
+	PredicateType string `json:"predicateType" yaml:"predicateType"`
+
+	// Subjects lists the attested artifacts.
+	Subjects []Subject `json:"subjects" yaml:"subjects"`
+
+	// Provenance is set for SLSA provenance predicates.
+	Provenance *Provenance `json:"provenance,omitempty" yaml:"provenance,omitempty"`
+
+	// VulnScan is set for vulnerability scan predicates.
+	VulnScan *VulnScan `json:"vulnScan,omitempty" yaml:"vulnScan,omitempty"`
+
+	// Predicate is the raw predicate JSON, kept for unknown predicate types.
+	Predicate json.RawMessage `json:"predicate,omitempty" yaml:"-"`
+}
+
+// Decode parses an attestation blob. It accepts a DSSE envelope or a bare
+// in-toto Statement.
+func Decode(data []byte) (*Attestation, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("attestation is not a JSON object: %w", err)
+	}
+
+	att := &Attestation{}
+	statementData := data
+
+	if _, ok := probe["payload"]; ok {
+		var env Envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, fmt.Errorf("invalid DSSE envelope: %w", err)
+		}
+		payload, err := decodeBase64(env.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DSSE payload encoding: %w", err)
+		}
+		att.Enveloped = true
+		att.PayloadType = env.PayloadType
+		att.SignatureCount = len(env.Signatures)
+		for _, sig := range env.Signatures {
+			if sig.KeyID != "" {
+				att.KeyIDs = append(att.KeyIDs, sig.KeyID)
+			}
+		}
+		statementData = payload
+	} else if _, ok := probe["_type"]; !ok {
+		return nil, fmt.Errorf("not a DSSE envelope or in-toto statement")
+	}
+
+	var st Statement
+	if err := json.Unmarshal(statementData, &st); err != nil {
+		return nil, fmt.Errorf("invalid in-toto statement: %w", err)
+	}
+	if st.PredicateType == "" {
+		return nil, fmt.Errorf("in-toto statement has no predicateType")
+	}
+
+	att.StatementType = st.Type
+	att.PredicateType = st.PredicateType
+	att.Subjects = st.Subject
+	att.Predicate = st.Predicate
+	att.Kind = KindOther
+
+	switch {
+	case isProvenance(st.PredicateType):
+		prov, err := parseProvenance(st.PredicateType, st.Predicate)
+		if err != nil {
+			return nil, err
+		}
+		att.Kind = KindProvenance
+		att.Provenance = prov
+	case isVulnScan(st.PredicateType):
+		scan, err := parseVulnScan(st.Predicate)
+		if err != nil {
+			return nil, err
+		}
+		att.Kind = KindVulnScan
+		att.VulnScan = scan
+	}
+
+	return att, nil
+}
+
+// isProvenance reports whether a predicate type is a SLSA provenance predicate.
+func isProvenance(predicateType string) bool {
+	return strings.HasPrefix(predicateType, "https://slsa.dev/provenance/")
+}
+
+// isVulnScan reports whether a predicate type is a vulnerability scan.
+func isVulnScan(predicateType string) bool {
+	return predicateType == PredicateCosignVuln ||
+		strings.Contains(predicateType, "/vuln")
+}
+
+// decodeBase64 decodes standard or URL-safe base64, padded or not.
+// DSSE specifies standard encoding but URL-safe payloads occur in the wild.
+func decodeBase64(s string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("payload is not valid base64")
+}
+
+// ShortPredicateType returns a compact label for a predicate URI,
+// e.g. "slsa-provenance v0.2" or "cosign vuln v1".
+func ShortPredicateType(predicateType string) string {
+	switch {
+	case strings.HasPrefix(predicateType, "https://slsa.dev/provenance/"):
+		return "slsa-provenance " + strings.TrimPrefix(predicateType, "https://slsa.dev/provenance/")
+	case predicateType == PredicateCosignVuln:
+		return "cosign vuln v1"
+	case strings.HasPrefix(predicateType, "https://spdx.dev/"):
+		return "spdx"
+	case strings.HasPrefix(predicateType, "https://cyclonedx.org/"):
+		return "cyclonedx"
+	}
+	return predicateType
+}
@@ -0,0 +1,361 @@
+package attestation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
+)
+
+func readFixture(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../testdata/fixtures/attestation-intoto.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return data
+}
+
+func wrapDSSE(t *testing.T, statement []byte) []byte {
+	t.Helper()
+	env := Envelope{
+		PayloadType: PayloadTypeInToto,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures:  []Signature{{KeyID: "key-1", Sig: "c2ln"}},
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+	return data
+}
+
+func TestDecodeProvenanceV02(t *testing.T) {
+	fixture := readFixture(t)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		enveloped bool
+	}{
+		{name: "bare statement", data: fixture},
+		{name: "DSSE envelope", data: wrapDSSE(t, fixture), enveloped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			att, err := Decode(tt.data)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if att.Enveloped != tt.enveloped {
+				t.Errorf("Enveloped = %v, want %v", att.Enveloped, tt.enveloped)
+			}
+			if att.Kind != KindProvenance {
+				t.Errorf("Kind = %q, want %q", att.Kind, KindProvenance)
+			}
+			if att.StatementType != "https://in-toto.io/Statement/v0.1" {
+				t.Errorf("StatementType = %q", att.StatementType)
+			}
+			if att.PredicateType != PredicateSLSAProvenanceV02 {
+				t.Errorf("PredicateType = %q", att.PredicateType)
+			}
+			if len(att.Subjects) != 1 || att.Subjects[0].Name != "localhost:5050/test/myapp" {
+				t.Errorf("Subjects = %+v", att.Subjects)
+			}
+
+			p := att.Provenance
+			if p == nil {
+				t.Fatal("Provenance is nil")
+			}
+			if p.Version != "v0.2" {
+				t.Errorf("Version = %q, want v0.2", p.Version)
+			}
+			if p.BuilderID != "https://github.com/actions/runner" {
+				t.Errorf("BuilderID = %q", p.BuilderID)
+			}
+			if p.BuildType != "https://github.com/actions/runner/v1" {
+				t.Errorf("BuildType = %q", p.BuildType)
+			}
+			if p.SourceRepo != "https://github.com/example/myapp" {
+				t.Errorf("SourceRepo = %q", p.SourceRepo)
+			}
+			if p.SourceRef != "refs/heads/main" {
+				t.Errorf("SourceRef = %q", p.SourceRef)
+			}
+			if p.StartedOn != "2025-01-01T00:00:00Z" || p.FinishedOn != "2025-01-01T00:05:00Z" {
+				t.Errorf("timestamps = %q..%q", p.StartedOn, p.FinishedOn)
+			}
+		})
+	}
+}
+
+func TestDecodeEnvelopeSignatures(t *testing.T) {
+	att, err := Decode(wrapDSSE(t, readFixture(t)))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if att.PayloadType != PayloadTypeInToto {
+		t.Errorf("PayloadType = %q", att.PayloadType)
+	}
+	if att.SignatureCount != 1 {
+		t.Errorf("SignatureCount = %d, want 1", att.SignatureCount)
+	}
+	if len(att.KeyIDs) != 1 || att.KeyIDs[0] != "key-1" {
+		t.Errorf("KeyIDs = %v", att.KeyIDs)
+	}
+}
+
+func TestDecodeProvenanceV1(t *testing.T) {
+	statement := `{
+		"_type": "https://in-toto.io/Statement/v1",
+		"predicateType": "https://slsa.dev/provenance/v1",
+		"subject": [{"name": "ghcr.io/example/app", "digest": {"sha256": "abc"}}],
+		"predicate": {
+			"buildDefinition": {
+				"buildType": "https://slsa-framework.github.io/github-actions-buildtypes/workflow/v1",
+				"externalParameters": {
+					"workflow": {
+						"ref": "refs/tags/v1.2.3",
+						"repository": "https://github.com/example/app",
+						"path": ".github/workflows/release.yml"
+					}
+				},
+				"resolvedDependencies": [
+					{"uri": "git+https://github.com/example/app@refs/tags/v1.2.3", "digest": {"gitCommit": "deadbeef"}}
+				]
+			},
+			"runDetails": {
+				"builder": {"id": "https://github.com/actions/runner/github-hosted"},
+				"metadata": {"invocationId": "run-42", "startedOn": "2025-02-01T10:00:00Z"}
+			}
+		}
+	}`
+
+	att, err := Decode([]byte(statement))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	p := att.Provenance
+	if p == nil {
+		t.Fatal("Provenance is nil")
+	}
+
+	checks := map[string][2]string{
+		"Version":      {p.Version, "v1"},
+		"BuilderID":    {p.BuilderID, "https://github.com/actions/runner/github-hosted"},
+		"SourceRepo":   {p.SourceRepo, "https://github.com/example/app"},
+		"SourceRef":    {p.SourceRef, "refs/tags/v1.2.3"},
+		"Commit":       {p.Commit, "deadbeef"},
+		"EntryPoint":   {p.EntryPoint, ".github/workflows/release.yml"},
+		"InvocationID": {p.InvocationID, "run-42"},
+		"StartedOn":    {p.StartedOn, "2025-02-01T10:00:00Z"},
+	}
+	for field, c := range checks {
+		if c[0] != c[1] {
+			t.Errorf("%s = %q, want %q", field, c[0], c[1])
+		}
+	}
+	if len(p.Materials) != 1 {
+		t.Errorf("Materials = %d, want 1", len(p.Materials))
+	}
+}
+
+func TestDecodeVulnScan(t *testing.T) {
+	tests := []struct {
+		name      string
+		result    string
+		wantTotal int
+		wantFirst string
+		wantCount map[string]int
+	}{
+		{
+			name: "trivy",
+			result: `{"Results": [{"Type": "debian", "Vulnerabilities": [
+				{"VulnerabilityID": "CVE-1", "PkgName": "libc", "InstalledVersion": "1.0", "Severity": "LOW"},
+				{"VulnerabilityID": "CVE-2", "PkgName": "openssl", "InstalledVersion": "3.0", "Severity": "CRITICAL"}
+			]}]}`,
+			wantTotal: 2,
+			wantFirst: "CVE-2",
+			wantCount: map[string]int{"CRITICAL": 1, "LOW": 1},
+		},
+		{
+			name: "grype",
+			result: `{"matches": [
+				{"vulnerability": {"id": "GHSA-1", "severity": "Medium"}, "artifact": {"name": "lodash", "version": "4.0.0"}},
+				{"vulnerability": {"id": "GHSA-2", "severity": "High"}, "artifact": {"name": "minimist", "version": "1.0.0"}}
+			]}`,
+			wantTotal: 2,
+			wantFirst: "GHSA-2",
+			wantCount: map[string]int{"HIGH": 1, "MEDIUM": 1},
+		},
+		{
+			name:      "unknown result format",
+			result:    `{"something": "else"}`,
+			wantTotal: 0,
+			wantCount: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement := `{
+				"_type": "https://in-toto.io/Statement/v0.1",
+				"predicateType": "https://cosign.sigstore.dev/attestation/vuln/v1",
+				"subject": [],
+				"predicate": {
+					"scanner": {"uri": "pkg:github/aquasecurity/trivy", "version": "0.50.0", "result": ` + tt.result + `},
+					"metadata": {"scanStartedOn": "2025-01-01T00:00:00Z"}
+				}
+			}`
+
+			att, err := Decode([]byte(statement))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if att.Kind != KindVulnScan {
+				t.Fatalf("Kind = %q, want %q", att.Kind, KindVulnScan)
+			}
+
+			scan := att.VulnScan
+			if scan.Scanner != "pkg:github/aquasecurity/trivy" {
+				t.Errorf("Scanner = %q", scan.Scanner)
+			}
+			if scan.Total() != tt.wantTotal {
+				t.Errorf("Total() = %d, want %d", scan.Total(), tt.wantTotal)
+			}
+			if tt.wantFirst != "" && scan.Findings[0].ID != tt.wantFirst {
+				t.Errorf("first finding = %q, want %q", scan.Findings[0].ID, tt.wantFirst)
+			}
+			for sev, n := range tt.wantCount {
+				if scan.Counts[sev] != n {
+					t.Errorf("Counts[%s] = %d, want %d", sev, scan.Counts[sev], n)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not JSON", data: `not json`},
+		{name: "JSON array", data: `[]`},
+		{name: "unrelated object", data: `{"foo": "bar"}`},
+		{name: "bad base64 payload", data: `{"payloadType": "x", "payload": "!!!"}`},
+		{name: "missing predicateType", data: `{"_type": "https://in-toto.io/Statement/v0.1"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode([]byte(tt.data)); err == nil {
+				t.Error("Decode() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestDecodeUnknownPredicate(t *testing.T) {
+	att, err := Decode([]byte(`{
+		"_type": "https://in-toto.io/Statement/v1",
+		"predicateType": "https://example.com/custom/v1",
+		"subject": [],
+		"predicate": {"hello": "world"}
+	}`))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if att.Kind != KindOther {
+		t.Errorf("Kind = %q, want %q", att.Kind, KindOther)
+	}
+	if len(att.Predicate) == 0 {
+		t.Error("Predicate should be preserved for unknown types")
+	}
+}
+
+func TestSplitSourceURI(t *testing.T) {
+	tests := []struct {
+		uri      string
+		wantRepo string
+		wantRef  string
+	}{
+		{"git+https://github.com/example/myapp@refs/heads/main", "https://github.com/example/myapp", "refs/heads/main"},
+		{"https://github.com/example/myapp", "https://github.com/example/myapp", ""},
+		{"git+ssh://git@github.com/example/myapp", "ssh://git@github.com/example/myapp", ""},
+		{"git+ssh://git@github.com/example/myapp@v1", "ssh://git@github.com/example/myapp", "v1"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			repo, ref := splitSourceURI(tt.uri)
+			if repo != tt.wantRepo || ref != tt.wantRef {
+				t.Errorf("splitSourceURI(%q) = (%q, %q), want (%q, %q)", tt.uri, repo, ref, tt.wantRepo, tt.wantRef)
+			}
+		})
+	}
+}
+
+func TestShortPredicateType(t *testing.T) {
+	tests := map[string]string{
+		PredicateSLSAProvenanceV02:     "slsa-provenance v0.2",
+		PredicateSLSAProvenanceV1:      "slsa-provenance v1",
+		PredicateCosignVuln:            "cosign vuln v1",
+		"https://spdx.dev/Document":    "spdx",
+		"https://example.com/custom/1": "https://example.com/custom/1",
+	}
+	for in, want := range tests {
+		if got := ShortPredicateType(in); got != want {
+			t.Errorf("ShortPredicateType(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	fixture := readFixture(t)
+
+	f := &registrytest.Fetcher{
+		Manifest: &registry.Manifest{
+			Layers: []registry.Descriptor{
+				{MediaType: "application/vnd.dsse.envelope.v1+json", Digest: "sha256:dsse"},
+				{MediaType: "application/octet-stream", Digest: "sha256:bin"},
+				{MediaType: "application/vnd.in-toto+json", Digest: "sha256:bare"},
+				{MediaType: "application/json", Digest: "sha256:junk"},
+			},
+		},
+		Blobs: map[string][]byte{
+			"sha256:dsse": wrapDSSE(t, fixture),
+			"sha256:bare": fixture,
+			"sha256:junk": []byte(`{"not": "an attestation"}`),
+		},
+	}
+
+	atts, err := Fetch(f, "localhost:5050/test/myapp", "sha256-abc.att")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(atts) != 2 {
+		t.Fatalf("Fetch() returned %d attestations, want 2", len(atts))
+	}
+	if !atts[0].Enveloped || atts[1].Enveloped {
+		t.Errorf("unexpected layer order: enveloped = %v, %v", atts[0].Enveloped, atts[1].Enveloped)
+	}
+}
+
+func TestFetchNoAttestations(t *testing.T) {
+	f := &registrytest.Fetcher{
+		Manifest: &registry.Manifest{
+			Layers: []registry.Descriptor{{MediaType: "application/octet-stream", Digest: "sha256:bin"}},
+		},
+	}
+	if _, err := Fetch(f, "localhost:5050/test/myapp", "latest"); err == nil {
+		t.Error("Fetch() expected error for manifest without attestation layers")
+	}
+}
@@ -0,0 +1,61 @@
+package attestation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+)
+
+// Fetch loads every attestation layer from the manifest at reference.
+// Layers that are not JSON, or do not decode as an attestation, are skipped.
+// An error is returned only when the manifest cannot be read or no layer
+// decodes successfully.
+func Fetch(f registry.Fetcher, repoPath, reference string) ([]*Attestation, error) {
+	manifest, _, err := f.GetManifest(repoPath, reference)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Manifests) > 0 {
+		return nil, fmt.Errorf("%s is an index, not an attestation manifest", reference)
+	}
+
+	var (
+		atts    []*Attestation
+		lastErr error
+	)
+	for _, layer := range manifest.Layers {
+		if !isAttestationLayer(layer.MediaType) {
+			continue
+		}
+		data, err := f.FetchBlob(repoPath, layer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		att, err := Decode(data)
+		if err != nil {
+			lastErr = fmt.Errorf("layer %s: %w", layer.Digest, err)
+			continue
+		}
+		atts = append(atts, att)
+	}
+
+	if len(atts) == 0 {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, fmt.Errorf("no attestation layers found in %s", reference)
+	}
+	return atts, nil
+}
+
+// isAttestationLayer reports whether a layer media type may carry an
+// in-toto statement or DSSE envelope.
+func isAttestationLayer(mediaType string) bool {
+	mt := strings.ToLower(mediaType)
+	return strings.Contains(mt, "in-toto") ||
+		strings.Contains(mt, "dsse") ||
+		strings.HasSuffix(mt, "+json") ||
+		strings.HasSuffix(mt, "/json")
+}
@@ -0,0 +1,252 @@
+package attestation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Provenance is the display-oriented subset of a SLSA provenance predicate.
+// Fields are normalized across SLSA v0.2 and v1.
+type Provenance struct {
+	// Version is the SLSA provenance version ("v0.2", "v1").
+	Version string `json:"version" yaml:"version"`
+
+	// BuilderID identifies the build platform.
+	BuilderID string `json:"builderId" yaml:"builderId"`
+
+	// BuildType is the URI describing the build template.
+	BuildType string `json:"buildType,omitempty" yaml:"buildType,omitempty"`
+
+	// SourceRepo is the source repository URI (without ref or VCS prefix).
+	SourceRepo string `json:"sourceRepo,omitempty" yaml:"sourceRepo,omitempty"`
+
+	// SourceRef is the git ref the build ran from (e.g. refs/heads/main).
+	SourceRef string `json:"sourceRef,omitempty" yaml:"sourceRef,omitempty"`
+
+	// Commit is the source commit digest.
+	Commit string `json:"commit,omitempty" yaml:"commit,omitempty"`
+
+	// EntryPoint is the build entry point (workflow path, make target, ...).
+	EntryPoint string `json:"entryPoint,omitempty" yaml:"entryPoint,omitempty"`
+
+	// InvocationID identifies the specific build run.
+	InvocationID string `json:"invocationId,omitempty" yaml:"invocationId,omitempty"`
+
+	// Parameters are the user-controlled build parameters, flattened to strings.
+	Parameters map[string]string `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+
+	// StartedOn and FinishedOn are RFC 3339 timestamps, when recorded.
+	StartedOn  string `json:"startedOn,omitempty" yaml:"startedOn,omitempty"`
+	FinishedOn string `json:"finishedOn,omitempty" yaml:"finishedOn,omitempty"`
+
+	// Materials lists resolved build inputs.
+	Materials []Material `json:"materials,omitempty" yaml:"materials,omitempty"`
+}
+
+// Material is a single build input.
+type Material struct {
+	URI    string            `json:"uri" yaml:"uri"`
+	Digest map[string]string `json:"digest,omitempty" yaml:"digest,omitempty"`
+}
+
+// slsaV02 mirrors the SLSA v0.2 predicate.
+type slsaV02 struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	BuildType  string `json:"buildType"`
+	Invocation struct {
+		ConfigSource struct {
+			URI        string            `json:"uri"`
+			Digest     map[string]string `json:"digest"`
+			EntryPoint string            `json:"entryPoint"`
+		} `json:"configSource"`
+		Parameters map[string]any `json:"parameters"`
+	} `json:"invocation"`
+	Metadata struct {
+		BuildInvocationID string `json:"buildInvocationId"`
+		BuildStartedOn    string `json:"buildStartedOn"`
+		BuildFinishedOn   string `json:"buildFinishedOn"`
+	} `json:"metadata"`
+	Materials []Material `json:"materials"`
+}
+
+// slsaV1 mirrors the SLSA v1 predicate.
+type slsaV1 struct {
+	BuildDefinition struct {
+		BuildType            string         `json:"buildType"`
+		ExternalParameters   map[string]any `json:"externalParameters"`
+		ResolvedDependencies []struct {
+			URI    string            `json:"uri"`
+			Digest map[string]string `json:"digest"`
+		} `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Metadata struct {
+			InvocationID string `json:"invocationId"`
+			StartedOn    string `json:"startedOn"`
+			FinishedOn   string `json:"finishedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// parseProvenance decodes a SLSA predicate of either supported version.
+func parseProvenance(predicateType string, raw json.RawMessage) (*Provenance, error) {
+	version := strings.TrimPrefix(predicateType, "https://slsa.dev/provenance/")
+
+	if strings.HasPrefix(version, "v0.") {
+		var p slsaV02
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, fmt.Errorf("invalid SLSA %s predicate: %w", version, err)
+		}
+		prov := &Provenance{
+			Version:      version,
+			BuilderID:    p.Builder.ID,
+			BuildType:    p.BuildType,
+			EntryPoint:   p.Invocation.ConfigSource.EntryPoint,
+			InvocationID: p.Metadata.BuildInvocationID,
+			Parameters:   flattenParams(p.Invocation.Parameters),
+			StartedOn:    p.Metadata.BuildStartedOn,
+			FinishedOn:   p.Metadata.BuildFinishedOn,
+			Materials:    p.Materials,
+		}
+		prov.SourceRepo, prov.SourceRef = splitSourceURI(p.Invocation.ConfigSource.URI)
+		prov.Commit = commitDigest(p.Invocation.ConfigSource.Digest)
+
+		// Fall back to the first git material when configSource is sparse.
+		if prov.Commit == "" || prov.SourceRepo == "" {
+			for _, m := range p.Materials {
+				if !strings.HasPrefix(m.URI, "git+") {
+					continue
+				}
+				repo, ref := splitSourceURI(m.URI)
+				if prov.SourceRepo == "" {
+					prov.SourceRepo, prov.SourceRef = repo, ref
+				}
+				if prov.Commit == "" && repo == prov.SourceRepo {
+					prov.Commit = commitDigest(m.Digest)
+				}
+				break
+			}
+		}
+		return prov, nil
+	}
+
+	var p slsaV1
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("invalid SLSA %s predicate: %w", version, err)
+	}
+	prov := &Provenance{
+		Version:      version,
+		BuilderID:    p.RunDetails.Builder.ID,
+		BuildType:    p.BuildDefinition.BuildType,
+		InvocationID: p.RunDetails.Metadata.InvocationID,
+		Parameters:   flattenParams(p.BuildDefinition.ExternalParameters),
+		StartedOn:    p.RunDetails.Metadata.StartedOn,
+		FinishedOn:   p.RunDetails.Metadata.FinishedOn,
+	}
+	for _, dep := range p.BuildDefinition.ResolvedDependencies {
+		prov.Materials = append(prov.Materials, Material{URI: dep.URI, Digest: dep.Digest})
+	}
+
+	// GitHub Actions builders record the workflow under externalParameters.
+	if wf, ok := p.BuildDefinition.ExternalParameters["workflow"].(map[string]any); ok {
+		if repo, ok := wf["repository"].(string); ok {
+			prov.SourceRepo = repo
+		}
+		if ref, ok := wf["ref"].(string); ok {
+			prov.SourceRef = ref
+		}
+		if path, ok := wf["path"].(string); ok {
+			prov.EntryPoint = path
+		}
+	}
+	for _, m := range prov.Materials {
+		if !strings.HasPrefix(m.URI, "git+") {
+			continue
+		}
+		repo, ref := splitSourceURI(m.URI)
+		if prov.SourceRepo == "" {
+			prov.SourceRepo = repo
+		}
+		if prov.SourceRef == "" {
+			prov.SourceRef = ref
+		}
+		if prov.Commit == "" {
+			prov.Commit = commitDigest(m.Digest)
+		}
+		break
+	}
+
+	return prov, nil
+}
+
+// splitSourceURI splits "git+https://github.com/o/r@refs/heads/main" into
+// ("https://github.com/o/r", "refs/heads/main").
+func splitSourceURI(uri string) (repo, ref string) {
+	uri = strings.TrimPrefix(uri, "git+")
+
+	// Only an '@' inside the path separates the ref; one in the authority
+	// (git@host) belongs to the URL itself.
+	hostStart := 0
+	if i := strings.Index(uri, "://"); i != -1 {
+		hostStart = i + 3
+	}
+	pathStart := strings.Index(uri[hostStart:], "/")
+	if pathStart == -1 {
+		return uri, ""
+	}
+	pathStart += hostStart
+
+	if at := strings.LastIndex(uri, "@"); at > pathStart {
+		return uri[:at], uri[at+1:]
+	}
+	return uri, ""
+}
+
+// commitDigest picks the VCS commit from a digest set.
+func commitDigest(d map[string]string) string {
+	for _, alg := range []string{"gitCommit", "sha1", "sha256"} {
+		if v := d[alg]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// flattenParams renders parameter values as strings for display.
+// Nested objects are JSON-encoded.
+func flattenParams(params map[string]any) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		switch val := v.(type) {
+		case string:
+			out[k] = val
+		default:
+			b, err := json.Marshal(val)
+			if err != nil {
+				continue
+			}
+			out[k] = string(b)
+		}
+	}
+	return out
+}
+
+// SortedParameterKeys returns the parameter names in stable display order.
+func (p *Provenance) SortedParameterKeys() []string {
+	keys := make([]string, 0, len(p.Parameters))
+	for k := range p.Parameters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,206 @@
+package attestation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity levels in display order, most severe first.
+var severityOrder = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "NEGLIGIBLE", "UNKNOWN"}
+
+// VulnScan summarizes a vulnerability scan predicate.
+type VulnScan struct {
+	// Scanner is the scanner URI (e.g. pkg:github/aquasecurity/trivy).
+	Scanner string `json:"scanner" yaml:"scanner"`
+
+	// ScannerVersion is the scanner release, when recorded.
+	ScannerVersion string `json:"scannerVersion,omitempty" yaml:"scannerVersion,omitempty"`
+
+	// DBVersion is the vulnerability database version, when recorded.
+	DBVersion string `json:"dbVersion,omitempty" yaml:"dbVersion,omitempty"`
+
+	// StartedOn and FinishedOn bound the scan, when recorded.
+	StartedOn  string `json:"startedOn,omitempty" yaml:"startedOn,omitempty"`
+	FinishedOn string `json:"finishedOn,omitempty" yaml:"finishedOn,omitempty"`
+
+	// Counts maps upper-case severity to the number of findings.
+	Counts map[string]int `json:"counts" yaml:"counts"`
+
+	// Findings lists individual vulnerabilities, most severe first.
+	Findings []Finding `json:"findings,omitempty" yaml:"findings,omitempty"`
+}
+
+// Finding is one vulnerability reported by a scanner.
+type Finding struct {
+	ID        string `json:"id" yaml:"id"`
+	Severity  string `json:"severity" yaml:"severity"`
+	Package   string `json:"package,omitempty" yaml:"package,omitempty"`
+	Version   string `json:"version,omitempty" yaml:"version,omitempty"`
+	FixedIn   string `json:"fixedIn,omitempty" yaml:"fixedIn,omitempty"`
+	Ecosystem string `json:"ecosystem,omitempty" yaml:"ecosystem,omitempty"`
+}
+
+// cosignVuln mirrors the cosign vuln/v1 predicate envelope.
+type cosignVuln struct {
+	Invocation struct {
+		URI string `json:"uri"`
+	} `json:"invocation"`
+	Scanner struct {
+		URI     string          `json:"uri"`
+		Version string          `json:"version"`
+		DB      map[string]any  `json:"db"`
+		Result  json.RawMessage `json:"result"`
+	} `json:"scanner"`
+	Metadata struct {
+		ScanStartedOn  string `json:"scanStartedOn"`
+		ScanFinishedOn string `json:"scanFinishedOn"`
+	} `json:"metadata"`
+}
+
+// trivyResult is the subset of Trivy JSON output we understand.
+type trivyResult struct {
+	Results []struct {
+		Type            string `json:"Type"`
+		Vulnerabilities []struct {
+			VulnerabilityID  string `json:"VulnerabilityID"`
+			PkgName          string `json:"PkgName"`
+			InstalledVersion string `json:"InstalledVersion"`
+			FixedVersion     string `json:"FixedVersion"`
+			Severity         string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// grypeResult is the subset of Grype JSON output we understand.
+type grypeResult struct {
+	Matches []struct {
+		Vulnerability struct {
+			ID       string `json:"id"`
+			Severity string `json:"severity"`
+			Fix      struct {
+				Versions []string `json:"versions"`
+			} `json:"fix"`
+		} `json:"vulnerability"`
+		Artifact struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			Type    string `json:"type"`
+		} `json:"artifact"`
+	} `json:"matches"`
+}
+
+// parseVulnScan decodes a cosign vuln predicate and the embedded scanner
+// output (Trivy or Grype JSON). Unknown result formats yield an empty
+// finding list rather than an error.
+func parseVulnScan(raw json.RawMessage) (*VulnScan, error) {
+	var p cosignVuln
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("invalid vulnerability predicate: %w", err)
+	}
+
+	scan := &VulnScan{
+		Scanner:        p.Scanner.URI,
+		ScannerVersion: p.Scanner.Version,
+		StartedOn:      p.Metadata.ScanStartedOn,
+		FinishedOn:     p.Metadata.ScanFinishedOn,
+		Counts:         make(map[string]int),
+	}
+	if v, ok := p.Scanner.DB["version"].(string); ok {
+		scan.DBVersion = v
+	}
+
+	if len(p.Scanner.Result) > 0 {
+		scan.Findings = parseScannerResult(p.Scanner.Result)
+	}
+
+	for _, f := range scan.Findings {
+		scan.Counts[f.Severity]++
+	}
+	sort.SliceStable(scan.Findings, func(i, j int) bool {
+		return severityRank(scan.Findings[i].Severity) < severityRank(scan.Findings[j].Severity)
+	})
+
+	return scan, nil
+}
+
+// parseScannerResult extracts findings from raw scanner output.
+func parseScannerResult(raw json.RawMessage) []Finding {
+	var findings []Finding
+
+	var trivy trivyResult
+	if err := json.Unmarshal(raw, &trivy); err == nil && len(trivy.Results) > 0 {
+		for _, r := range trivy.Results {
+			for _, v := range r.Vulnerabilities {
+				findings = append(findings, Finding{
+					ID:        v.VulnerabilityID,
+					Severity:  NormalizeSeverity(v.Severity),
+					Package:   v.PkgName,
+					Version:   v.InstalledVersion,
+					FixedIn:   v.FixedVersion,
+					Ecosystem: r.Type,
+				})
+			}
+		}
+		return findings
+	}
+
+	var grype grypeResult
+	if err := json.Unmarshal(raw, &grype); err == nil && len(grype.Matches) > 0 {
+		for _, m := range grype.Matches {
+			findings = append(findings, Finding{
+				ID:        m.Vulnerability.ID,
+				Severity:  NormalizeSeverity(m.Vulnerability.Severity),
+				Package:   m.Artifact.Name,
+				Version:   m.Artifact.Version,
+				FixedIn:   strings.Join(m.Vulnerability.Fix.Versions, ", "),
+				Ecosystem: m.Artifact.Type,
+			})
+		}
+	}
+
+	return findings
+}
+
+// NormalizeSeverity maps scanner severity labels onto the canonical
+// upper-case set used for counting.
+func NormalizeSeverity(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	switch s {
+	case "CRITICAL", "HIGH", "MEDIUM", "LOW", "NEGLIGIBLE":
+		return s
+	case "MODERATE":
+		return "MEDIUM"
+	case "IMPORTANT":
+		return "HIGH"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Severities returns the canonical severity levels, most severe first.
+func Severities() []string {
+	out := make([]string, len(severityOrder))
+	copy(out, severityOrder)
+	return out
+}
+
+// severityRank orders severities for sorting (lower is more severe).
+func severityRank(s string) int {
+	for i, level := range severityOrder {
+		if level == s {
+			return i
+		}
+	}
+	return len(severityOrder)
+}
+
+// Total returns the number of findings across all severities.
+func (v *VulnScan) Total() int {
+	total := 0
+	for _, n := range v.Counts {
+		total += n
+	}
+	return total
+}
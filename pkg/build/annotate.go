@@ -0,0 +1,219 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// dockerReferenceDigest is the index annotation linking a buildx
+// attestation manifest to the platform manifest it describes.
+const dockerReferenceDigest = "vnd.docker.reference.digest"
+
+// autoAnnotations returns the org.opencontainers.image annotations every
+// artifact gets: created, revision, source, version and ref.name. Empty
+// values are left out.
+func autoAnnotations(vars *TemplateVars) map[string]string {
+	version := vars.Version
+	if vars.ChartVersion != "" {
+		version = vars.ChartVersion
+	}
+	refName := vars.Tag
+	if refName == "" {
+		refName = version
+	}
+
+	annotations := map[string]string{}
+	for k, v := range map[string]string{
+		ocispec.AnnotationCreated:  vars.Created,
+		ocispec.AnnotationRevision: vars.GitCommit,
+		ocispec.AnnotationSource:   vars.Source,
+		ocispec.AnnotationVersion:  version,
+		ocispec.AnnotationRefName:  refName,
+	} {
+		if v != "" {
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
+
+// renderMetadata returns a copy of artifact whose annotations are the
+// automatic ones plus its own, and whose annotations and labels are
+// rendered with vars.
+func renderMetadata(artifact *Artifact, vars *TemplateVars) (*Artifact, error) {
+	rendered := *artifact
+	rendered.Annotations = autoAnnotations(vars)
+	for k, v := range artifact.Annotations {
+		value, err := renderTemplate(v, vars)
+		if err != nil {
+			return nil, fmt.Errorf("annotations[%s]: failed to render %q: %w", k, v, err)
+		}
+		rendered.Annotations[k] = value
+	}
+
+	if artifact.Labels != nil {
+		rendered.Labels = make(map[string]string, len(artifact.Labels))
+		for k, v := range artifact.Labels {
+			value, err := renderTemplate(v, vars)
+			if err != nil {
+				return nil, fmt.Errorf("labels[%s]: failed to render %q: %w", k, v, err)
+			}
+			rendered.Labels[k] = value
+		}
+	}
+	return &rendered, nil
+}
+
+// annotateLayout adds annotations to the tagged manifest of an OCI layout
+// and, if it is an index, to each platform manifest in it. The rewritten
+// manifest takes over the tag. Annotations are applied after the build
+// cache, so a cached layout is reused whatever the commit.
+func annotateLayout(ctx context.Context, ociLayoutPath string, annotations map[string]string) error {
+	if len(annotations) == 0 {
+		return nil
+	}
+	index, err := ociutil.ReadOCIIndex(ociLayoutPath)
+	if err != nil {
+		return fmt.Errorf("failed to read OCI index: %w", err)
+	}
+	if len(index.Manifests) == 0 {
+		return fmt.Errorf("OCI layout has no manifests")
+	}
+	tagged := index.TaggedManifest()
+	tag := tagged.Annotations[ocispec.AnnotationRefName]
+	if tag == "" {
+		tag = "latest"
+	}
+
+	store, err := oci.New(ociLayoutPath)
+	if err != nil {
+		return fmt.Errorf("failed to open OCI layout: %w", err)
+	}
+	root, err := store.Resolve(ctx, tagged.Digest)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s in OCI layout: %w", tagged.Digest, err)
+	}
+	desc, err := annotateManifest(ctx, store, root, annotations)
+	if err != nil {
+		return err
+	}
+	if err := store.Tag(ctx, desc, tag); err != nil {
+		return fmt.Errorf("failed to tag manifest: %w", err)
+	}
+	return nil
+}
+
+// annotateManifest stores a copy of the manifest or index desc with
+// annotations added and returns its descriptor. Fields it does not know
+// are kept. In an index, platform manifests are annotated too, and
+// attestation manifests are pointed at the rewritten ones.
+func annotateManifest(ctx context.Context, store *oci.Store, desc ocispec.Descriptor, annotations map[string]string) (ocispec.Descriptor, error) {
+	data, err := content.FetchAll(ctx, store, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to fetch manifest %s: %w", desc.Digest, err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to parse manifest %s: %w", desc.Digest, err)
+	}
+
+	if desc.MediaType == ocispec.MediaTypeImageIndex || desc.MediaType == dockerManifestList {
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to parse index %s: %w", desc.Digest, err)
+		}
+		rewritten := map[digest.Digest]digest.Digest{}
+		for i, m := range index.Manifests {
+			if m.Platform == nil || m.Platform.OS == "unknown" {
+				continue
+			}
+			annotated, err := annotateManifest(ctx, store, m, annotations)
+			if err != nil {
+				return ocispec.Descriptor{}, err
+			}
+			rewritten[m.Digest] = annotated.Digest
+			index.Manifests[i].Digest, index.Manifests[i].Size = annotated.Digest, annotated.Size
+		}
+		for i, m := range index.Manifests {
+			if d, ok := rewritten[digest.Digest(m.Annotations[dockerReferenceDigest])]; ok {
+				index.Manifests[i].Annotations = maps.Clone(m.Annotations)
+				index.Manifests[i].Annotations[dockerReferenceDigest] = d.String()
+			}
+		}
+		if fields["manifests"], err = json.Marshal(index.Manifests); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+
+	merged := map[string]string{}
+	if raw, ok := fields["annotations"]; ok {
+		if err := json.Unmarshal(raw, &merged); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("failed to parse annotations of %s: %w", desc.Digest, err)
+		}
+		if merged == nil {
+			merged = map[string]string{}
+		}
+	}
+	maps.Copy(merged, annotations)
+	if fields["annotations"], err = json.Marshal(merged); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	annotated, err := pushIfMissing(ctx, store, desc.MediaType, data)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to store manifest: %w", err)
+	}
+	return annotated, nil
+}
+
+// setConfigLabels adds labels to an image config, keeping the fields it
+// does not know.
+func setConfigLabels(configData []byte, labels map[string]string) ([]byte, error) {
+	if len(labels) == 0 {
+		return configData, nil
+	}
+	var image map[string]json.RawMessage
+	if err := json.Unmarshal(configData, &image); err != nil {
+		return nil, fmt.Errorf("failed to parse image config: %w", err)
+	}
+	var config map[string]json.RawMessage
+	if raw, ok := image["config"]; ok {
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse image config: %w", err)
+		}
+	}
+	if config == nil {
+		config = map[string]json.RawMessage{}
+	}
+	merged := map[string]string{}
+	if raw, ok := config["Labels"]; ok {
+		if err := json.Unmarshal(raw, &merged); err != nil {
+			return nil, fmt.Errorf("failed to parse image labels: %w", err)
+		}
+		if merged == nil {
+			merged = map[string]string{}
+		}
+	}
+	maps.Copy(merged, labels)
+
+	var err error
+	if config["Labels"], err = json.Marshal(merged); err != nil {
+		return nil, err
+	}
+	if image["config"], err = json.Marshal(config); err != nil {
+		return nil, err
+	}
+	return json.Marshal(image)
+}
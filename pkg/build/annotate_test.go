@@ -0,0 +1,265 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestRenderMetadata(t *testing.T) {
+	vars := &TemplateVars{
+		Tag:       "v1.2.3",
+		GitSHA:    "abc1234",
+		GitCommit: "abc1234def5678abc1234def5678abc1234def56",
+		Created:   "2024-01-02T03:04:05Z",
+		Source:    "https://github.com/owner/app",
+		Version:   "1.2.3",
+	}
+	artifact := &Artifact{
+		Type: TypeLayers,
+		Annotations: map[string]string{
+			"org.example.branch":              "main",
+			ocispec.AnnotationSource:          "https://example.com/app",
+			"org.opencontainers.image.vendor": "built from {{ .GitSHA }}",
+		},
+		Labels: map[string]string{"version": "{{ .Version }}"},
+	}
+
+	rendered, err := renderMetadata(artifact, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		ocispec.AnnotationCreated:         "2024-01-02T03:04:05Z",
+		ocispec.AnnotationRevision:        "abc1234def5678abc1234def5678abc1234def56",
+		ocispec.AnnotationSource:          "https://example.com/app",
+		ocispec.AnnotationVersion:         "1.2.3",
+		ocispec.AnnotationRefName:         "v1.2.3",
+		"org.example.branch":              "main",
+		"org.opencontainers.image.vendor": "built from abc1234",
+	}
+	if !reflect.DeepEqual(rendered.Annotations, want) {
+		t.Errorf("Annotations = %v\nwant %v", rendered.Annotations, want)
+	}
+	if rendered.Labels["version"] != "1.2.3" {
+		t.Errorf("Labels = %v", rendered.Labels)
+	}
+	if artifact.Labels["version"] != "{{ .Version }}" {
+		t.Error("renderMetadata modified the artifact")
+	}
+
+	// Helm charts are versioned by Chart.yaml; empty values are left out
+	rendered, err = renderMetadata(&Artifact{Type: TypeHelm}, &TemplateVars{ChartVersion: "0.3.0", Version: "1.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]string{ocispec.AnnotationVersion: "0.3.0", ocispec.AnnotationRefName: "0.3.0"}
+	if !reflect.DeepEqual(rendered.Annotations, want) || rendered.Labels != nil {
+		t.Errorf("helm annotations = %v, labels = %v; want %v", rendered.Annotations, rendered.Labels, want)
+	}
+
+	if _, err := renderMetadata(&Artifact{Annotations: map[string]string{"a": "{{ .Nope }}"}}, vars); err == nil {
+		t.Error("expected an error for an unknown template variable")
+	}
+}
+
+func TestAnnotateLayout(t *testing.T) {
+	ctx := context.Background()
+	layout := t.TempDir()
+	store, err := oci.New(layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushJSON := func(mediaType string, v any) ocispec.Descriptor {
+		t.Helper()
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc, err := pushIfMissing(ctx, store, mediaType, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return desc
+	}
+
+	config := pushJSON(ocispec.MediaTypeImageConfig, ocispec.Image{})
+	image := pushJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned:   specs.Versioned{SchemaVersion: 2},
+		MediaType:   ocispec.MediaTypeImageManifest,
+		Config:      config,
+		Layers:      []ocispec.Descriptor{},
+		Annotations: map[string]string{ocispec.AnnotationBaseImageName: "debian:12"},
+	})
+	image.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	attestation := pushJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{},
+	})
+	attestation.Platform = &ocispec.Platform{OS: "unknown", Architecture: "unknown"}
+	attestation.Annotations = map[string]string{dockerReferenceDigest: image.Digest.String()}
+	index := pushJSON(ocispec.MediaTypeImageIndex, ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{image, attestation},
+	})
+	if err := store.Tag(ctx, index, "latest"); err != nil {
+		t.Fatal(err)
+	}
+
+	annotations := map[string]string{ocispec.AnnotationRevision: "abc1234"}
+	if err := annotateLayout(ctx, layout, annotations); err != nil {
+		t.Fatal(err)
+	}
+
+	root := rootDescriptor(t, layout)
+	if root.Digest == index.Digest {
+		t.Fatal("tag still points at the original index")
+	}
+	var annotated ocispec.Index
+	readJSON(t, layout, root, &annotated)
+	if annotated.Annotations[ocispec.AnnotationRevision] != "abc1234" || len(annotated.Manifests) != 2 {
+		t.Fatalf("index = %+v", annotated)
+	}
+
+	platform, att := annotated.Manifests[0], annotated.Manifests[1]
+	if platform.Digest == image.Digest || !reflect.DeepEqual(platform.Platform, image.Platform) {
+		t.Errorf("platform descriptor = %+v", platform)
+	}
+	var manifest ocispec.Manifest
+	readJSON(t, layout, platform, &manifest)
+	want := map[string]string{
+		ocispec.AnnotationBaseImageName: "debian:12",
+		ocispec.AnnotationRevision:      "abc1234",
+	}
+	if !reflect.DeepEqual(manifest.Annotations, want) || manifest.Config.Digest != config.Digest {
+		t.Errorf("manifest = %+v, want annotations %v", manifest, want)
+	}
+	if data, err := ociutil.ReadBlob(layout, platform.Digest.String()); err != nil || int64(len(data)) != platform.Size {
+		t.Errorf("platform descriptor size %d does not match the manifest (%v)", platform.Size, err)
+	}
+
+	// Attestations are not annotated, but follow the manifest they describe
+	if att.Digest != attestation.Digest || att.Annotations[dockerReferenceDigest] != platform.Digest.String() {
+		t.Errorf("attestation descriptor = %+v, want reference to %s", att, platform.Digest)
+	}
+}
+
+func TestSetConfigLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "merge",
+			config: `{"architecture":"amd64","container_config":{"Hostname":"x"},"config":{"Env":["A=1"],"Labels":{"a":"1","b":"2"}}}`,
+			want:   `{"architecture":"amd64","config":{"Env":["A=1"],"Labels":{"a":"1","b":"3","c":"4"}},"container_config":{"Hostname":"x"}}`,
+		},
+		{
+			name:   "null labels",
+			config: `{"config":{"Labels":null}}`,
+			want:   `{"config":{"Labels":{"b":"3","c":"4"}}}`,
+		},
+		{
+			name:   "no config",
+			config: `{"os":"linux"}`,
+			want:   `{"config":{"Labels":{"b":"3","c":"4"}},"os":"linux"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setConfigLabels([]byte(tt.config), map[string]string{"b": "3", "c": "4"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("setConfigLabels() = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildAnnotations(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1704164645")
+	t.Setenv("LAZYOCI_VERSION", "")
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "bin", "app"), "binary", 0755)
+	manifests := map[string][]byte{}
+	blobs := map[digest.Digest][]byte{}
+	host := fakeRegistry(t, "app", manifests, blobs)
+
+	artifact := &Artifact{
+		Type:        TypeLayers,
+		Name:        "app",
+		Platforms:   []string{"linux/amd64", "linux/arm64"},
+		Layers:      []LayerEntry{{Src: "bin/app", Dest: "/usr/bin/app"}},
+		Labels:      map[string]string{"org.example.version": "{{ .Version }}"},
+		Annotations: map[string]string{"org.example.tag": "{{ .Tag }}"},
+		Targets:     []Target{{Registry: host + "/app", Tags: []string{"{{ .Tag }}"}}},
+	}
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{
+		Tag:      "v1.2.3",
+		Quiet:    true,
+		Push:     true,
+		Insecure: true,
+		CacheDir: filepath.Join(dir, "cache"),
+	})
+
+	var digests []string
+	for i := 0; i < 2; i++ {
+		result, err := b.buildOne(context.Background(), artifact, 0)
+		if err != nil {
+			t.Fatalf("build %d: %v", i, err)
+		}
+		if result.Cached != (i == 1) {
+			t.Errorf("build %d: cached = %v", i, result.Cached)
+		}
+		digests = append(digests, result.Targets[0].Digest)
+	}
+	// The cached layout is annotated the same way
+	if digests[0] != digests[1] {
+		t.Errorf("digests = %v, want the same digest twice", digests)
+	}
+
+	var index ocispec.Index
+	if err := json.Unmarshal(manifests["v1.2.3"], &index); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		ocispec.AnnotationCreated: "2024-01-02T03:04:05Z",
+		ocispec.AnnotationVersion: "1.2.3",
+		ocispec.AnnotationRefName: "v1.2.3",
+		"org.example.tag":         "v1.2.3",
+	} {
+		if index.Annotations[key] != want {
+			t.Errorf("index annotation %s = %q, want %q", key, index.Annotations[key], want)
+		}
+	}
+	for _, desc := range index.Manifests {
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(manifests[desc.Digest.String()], &manifest); err != nil {
+			t.Fatalf("%s: %v", desc.Platform.Architecture, err)
+		}
+		if manifest.Annotations["org.example.tag"] != "v1.2.3" {
+			t.Errorf("%s: annotations = %v", desc.Platform.Architecture, manifest.Annotations)
+		}
+		var config ocispec.Image
+		if err := json.Unmarshal(blobs[manifest.Config.Digest], &config); err != nil {
+			t.Fatal(err)
+		}
+		if config.Config.Labels["org.example.version"] != "1.2.3" {
+			t.Errorf("%s: labels = %v", desc.Platform.Architecture, config.Config.Labels)
+		}
+	}
+}
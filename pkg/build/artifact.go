@@ -2,42 +2,91 @@ package build
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2"
-	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/file"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
 )
 
-// buildArtifact packages generic files as an OCI artifact.
+// fileMediaTypes maps file extensions to the layer media type of files
+// entries that set none. Other files are application/octet-stream.
+var fileMediaTypes = map[string]string{
+	".json": "application/json",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".toml": "application/toml",
+	".xml":  "application/xml",
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+	".csv":  "text/csv",
+	".html": "text/html",
+	".rego": "application/vnd.cncf.openpolicyagent.policy.layer.v1+rego",
+	".wasm": "application/wasm",
+	".tar":  "application/x-tar",
+	".gz":   "application/gzip",
+	".tgz":  "application/gzip",
+	".zip":  "application/zip",
+	".pdf":  "application/pdf",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".svg":  "image/svg+xml",
+}
+
+// buildArtifact packages generic files as an OCI artifact. Each file,
+// directory or glob match of a files entry becomes a layer; directories
+// are packed as gzipped tarballs. Content is streamed into the layout, so
+// large files are never held in memory.
 // Returns the path to a temporary OCI layout directory.
 func (b *Builder) buildArtifact(ctx context.Context, artifact *Artifact) (string, error) {
 	b.logf("  Packaging generic artifact (%d files)...\n", len(artifact.Files))
 
-	store := memory.New()
+	tmpDir, err := os.MkdirTemp("", "lazyoci-artifact-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	store, err := oci.New(tmpDir)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", fmt.Errorf("failed to create OCI store at %s: %w", tmpDir, err)
+	}
 
-	// Push each file as a layer
+	// Push each file or directory as a layer
 	var layers []ocispec.Descriptor
-	for _, f := range artifact.Files {
-		filePath := b.resolvePath(f.Path)
-		data, err := os.ReadFile(filePath)
-		if err != nil {
-			return "", fmt.Errorf("failed to read file %s: %w", f.Path, err)
-		}
-
-		desc, err := pushBlob(ctx, store, f.MediaType, data)
+	titles := map[string]bool{}
+	for i, f := range artifact.Files {
+		paths, err := b.expandFiles(f.Path)
 		if err != nil {
-			return "", fmt.Errorf("failed to store file %s: %w", f.Path, err)
+			os.RemoveAll(tmpDir)
+			return "", fmt.Errorf("files[%d]: %w", i, err)
 		}
-
-		// Annotate with the original filename
-		desc.Annotations = map[string]string{
-			ocispec.AnnotationTitle: f.Path,
+		for _, p := range paths {
+			title := fileTitle(p)
+			if titles[title] {
+				os.RemoveAll(tmpDir)
+				return "", fmt.Errorf("files[%d]: %s is already in the artifact", i, title)
+			}
+			titles[title] = true
+
+			desc, err := addFile(ctx, store, b.resolvePath(p), title, f.MediaType)
+			if err != nil {
+				os.RemoveAll(tmpDir)
+				return "", fmt.Errorf("failed to store file %s: %w", p, err)
+			}
+			layers = append(layers, desc)
+			b.logf("    Added %s (%s, %d bytes)\n", title, desc.MediaType, desc.Size)
 		}
-
-		layers = append(layers, desc)
-		b.logf("    Added %s (%s, %d bytes)\n", f.Path, f.MediaType, len(data))
 	}
 
 	// Determine artifact type annotation
@@ -53,27 +102,153 @@ func (b *Builder) buildArtifact(ctx context.Context, artifact *Artifact) (string
 
 	manifestDesc, err := oras.PackManifest(ctx, store, oras.PackManifestVersion1_1, artifactType, packOpts)
 	if err != nil {
+		os.RemoveAll(tmpDir)
 		return "", fmt.Errorf("failed to pack manifest: %w", err)
 	}
 
 	// Tag the manifest
-	tag := "latest"
-	if err := store.Tag(ctx, manifestDesc, tag); err != nil {
+	if err := store.Tag(ctx, manifestDesc, "latest"); err != nil {
+		os.RemoveAll(tmpDir)
 		return "", fmt.Errorf("failed to tag manifest: %w", err)
 	}
 
-	// Export to OCI layout on disk
-	tmpDir, err := os.MkdirTemp("", "lazyoci-artifact-*")
+	b.logf("  Artifact packaged (%d layers)\n", len(layers))
+
+	return tmpDir, nil
+}
+
+// expandFiles returns the files and directories a files entry names: its
+// path, or the sorted matches of a glob pattern. Matches of a relative
+// pattern are relative to the .lazy file, like the pattern.
+func (b *Builder) expandFiles(pattern string) ([]string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil
+	}
+	if err := checkGlob(pattern); err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(b.resolvePath(pattern))
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp dir: %w", err)
+		return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%q matches no files", pattern)
+	}
+	if filepath.IsAbs(pattern) {
+		return matches, nil
+	}
+	for i, m := range matches {
+		rel, err := filepath.Rel(b.baseDir, m)
+		if err != nil {
+			return nil, err
+		}
+		matches[i] = rel
 	}
+	return matches, nil
+}
 
-	if err := exportToOCILayout(ctx, store, tag, tmpDir); err != nil {
-		os.RemoveAll(tmpDir)
-		return "", fmt.Errorf("failed to export OCI layout: %w", err)
+// checkGlob returns an error if pattern is not a glob filepath.Glob
+// supports. Glob has no recursive **: it would match a single directory
+// level, so such patterns are rejected rather than add fewer files than
+// meant.
+func checkGlob(pattern string) error {
+	if strings.Contains(pattern, "**") {
+		return fmt.Errorf("invalid glob %q: ** is not supported, as * does not cross directories; name a directory to add all of it", pattern)
 	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+	return nil
+}
 
-	b.logf("  Artifact packaged (%d layers)\n", len(layers))
+// fileTitle returns the org.opencontainers.image.title of a files path:
+// the path itself, slash-separated, or its base name if it is absolute or
+// outside the .lazy file's directory, which pulls would reject.
+func fileTitle(p string) string {
+	title := filepath.ToSlash(filepath.Clean(p))
+	if path.IsAbs(title) || filepath.IsAbs(p) || title == ".." || strings.HasPrefix(title, "../") {
+		return filepath.Base(p)
+	}
+	return title
+}
 
-	return tmpDir, nil
+// addFile stores the file or directory at src as a layer titled title.
+// An empty mediaType is inferred from the extension.
+func addFile(ctx context.Context, store content.Storage, src, title, mediaType string) (ocispec.Descriptor, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	var desc ocispec.Descriptor
+	if info.IsDir() {
+		desc, err = dirLayer(ctx, store, src, title)
+	} else {
+		if mediaType == "" {
+			mediaType = inferMediaType(src)
+		}
+		desc, err = fileLayer(ctx, store, src, mediaType)
+	}
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if mediaType != "" {
+		desc.MediaType = mediaType
+	}
+	if desc.Annotations == nil {
+		desc.Annotations = map[string]string{}
+	}
+	desc.Annotations[ocispec.AnnotationTitle] = title
+	return desc, nil
+}
+
+// fileLayer streams a file into store. It is read twice, to digest it and
+// then to push it, rather than buffered.
+func fileLayer(ctx context.Context, store content.Storage, src, mediaType string) (ocispec.Descriptor, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	defer f.Close()
+
+	d := digest.SHA256.Digester()
+	size, err := io.Copy(d.Hash(), f)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    d.Digest(),
+		Size:      size,
+	}
+	if err := store.Push(ctx, desc, f); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return ocispec.Descriptor{}, err
+	}
+	return desc, nil
+}
+
+// dirLayer packs a directory as a gzipped tarball whose entries are named
+// from title, with the annotations oras uses to unpack it. Timestamps and
+// owners are zeroed, so the same files give the same digest.
+func dirLayer(ctx context.Context, store content.Storage, src, title string) (ocispec.Descriptor, error) {
+	desc, diffID, err := writeLayer(ctx, store, src, LayerEntry{Dest: "/" + title})
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc.Annotations = map[string]string{
+		file.AnnotationDigest: diffID.String(),
+		file.AnnotationUnpack: "true",
+	}
+	return desc, nil
+}
+
+// inferMediaType returns the layer media type for a file from its
+// extension.
+func inferMediaType(name string) string {
+	if mediaType, ok := fileMediaTypes[strings.ToLower(filepath.Ext(name))]; ok {
+		return mediaType
+	}
+	return "application/octet-stream"
 }
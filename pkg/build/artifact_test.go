@@ -0,0 +1,165 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/pull"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/file"
+)
+
+func TestBuildArtifact(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "models", "bert", "config.json"), `{"layers":12}`, 0644)
+	writeFile(t, filepath.Join(dir, "models", "bert", "vocab", "en.txt"), "hello", 0644)
+	writeFile(t, filepath.Join(dir, "policy.rego"), "package main", 0644)
+	writeFile(t, filepath.Join(dir, "data", "a.csv"), "a,1", 0644)
+	writeFile(t, filepath.Join(dir, "data", "b.csv"), "b,2", 0644)
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{Quiet: true})
+	artifact := &Artifact{
+		Type:      TypeArtifact,
+		MediaType: "application/vnd.example.model",
+		Files: []FileEntry{
+			{Path: "models/bert"},
+			{Path: "policy.rego"},
+			{Path: "data/*.csv", MediaType: "text/x-example"},
+		},
+	}
+
+	build := func() ocispec.Manifest {
+		t.Helper()
+		layout, err := b.buildArtifact(context.Background(), artifact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(layout) })
+		var manifest ocispec.Manifest
+		readJSON(t, layout, rootDescriptor(t, layout), &manifest)
+
+		// Pulls unpack the directory where it was
+		out := t.TempDir()
+		if _, err := pull.Extract(layout, rootDescriptor(t, layout).Digest.String(), registry.ArtifactTypeUnknown, out, false); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(out, "models", "bert", "vocab", "en.txt"))
+		if err != nil || string(data) != "hello" {
+			t.Errorf("pulled en.txt = %q, %v", data, err)
+		}
+		return manifest
+	}
+
+	manifest := build()
+	if manifest.ArtifactType != artifact.MediaType {
+		t.Errorf("artifactType = %q", manifest.ArtifactType)
+	}
+	type layer struct{ title, mediaType string }
+	var got []layer
+	for _, l := range manifest.Layers {
+		got = append(got, layer{l.Annotations[ocispec.AnnotationTitle], l.MediaType})
+	}
+	want := []layer{
+		{"models/bert", ocispec.MediaTypeImageLayerGzip},
+		{"policy.rego", "application/vnd.cncf.openpolicyagent.policy.layer.v1+rego"},
+		{"data/a.csv", "text/x-example"},
+		{"data/b.csv", "text/x-example"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("layers = %v\nwant %v", got, want)
+	}
+	dirLayer := manifest.Layers[0]
+	if dirLayer.Annotations[file.AnnotationUnpack] != "true" || dirLayer.Annotations[file.AnnotationDigest] == "" {
+		t.Errorf("directory annotations = %v", dirLayer.Annotations)
+	}
+
+	// Directory layers do not depend on timestamps
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "models", "bert", "config.json"), future, future); err != nil {
+		t.Fatal(err)
+	}
+	if again := build(); again.Layers[0].Digest != dirLayer.Digest {
+		t.Errorf("directory layer digest changed from %s to %s", dirLayer.Digest, again.Layers[0].Digest)
+	}
+}
+
+func TestBuildArtifactErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "conf", "app.yaml"), "port: 8080", 0644)
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{Quiet: true})
+
+	tests := []struct {
+		name  string
+		files []FileEntry
+		want  string
+	}{
+		{
+			name:  "no matches",
+			files: []FileEntry{{Path: "conf/*.toml"}},
+			want:  "matches no files",
+		},
+		{
+			name:  "duplicate",
+			files: []FileEntry{{Path: "conf/app.yaml"}, {Path: "conf/*.yaml"}},
+			want:  "conf/app.yaml is already in the artifact",
+		},
+		{
+			name:  "recursive glob",
+			files: []FileEntry{{Path: "conf/**"}},
+			want:  "** is not supported",
+		},
+		{
+			name:  "missing",
+			files: []FileEntry{{Path: "conf/missing.yaml"}},
+			want:  "failed to store file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := b.buildArtifact(context.Background(), &Artifact{Type: TypeArtifact, Files: tt.files})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("buildArtifact() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileTitle(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"model.onnx", "model.onnx"},
+		{"./models/bert/", "models/bert"},
+		{"../shared/policy.rego", "policy.rego"},
+		{"/etc/app/config.yaml", "config.yaml"},
+	}
+	for _, tt := range tests {
+		if got := fileTitle(filepath.FromSlash(tt.path)); got != tt.want {
+			t.Errorf("fileTitle(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestInferMediaType(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"config.json", "application/json"},
+		{"values.YML", "application/yaml"},
+		{"chart.tgz", "application/gzip"},
+		{"model.onnx", "application/octet-stream"},
+		{"README", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := inferMediaType(tt.name); got != tt.want {
+			t.Errorf("inferMediaType(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+)
+
+// ReferrerResult describes a file attached to a built artifact.
+type ReferrerResult struct {
+	// Path is the attached file, as written in the .lazy file, or the
+	// file name of a generated SBOM.
+	Path string `json:"path" yaml:"path"`
+
+	// ArtifactType is the referrer manifest's artifactType.
+	ArtifactType string `json:"artifactType" yaml:"artifactType"`
+
+	// Digest is the referrer manifest digest.
+	Digest string `json:"digest" yaml:"digest"`
+}
+
+// packAttachments packs each of the artifact's attach entries into store as
+// a referrer of the OCI layout's root manifest.
+func (b *Builder) packAttachments(ctx context.Context, store content.Storage, artifact *Artifact, ociLayoutPath string) ([]ocispec.Descriptor, error) {
+	subject, err := layoutSubject(ociLayoutPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var referrers []ocispec.Descriptor
+	for _, at := range artifact.Attach {
+		data, err := os.ReadFile(b.resolvePath(at.Path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment %s: %w", at.Path, err)
+		}
+
+		mediaType := at.MediaType
+		if mediaType == "" {
+			mediaType = at.ArtifactType
+		}
+		desc, err := packReferrer(ctx, store, subject, at.ArtifactType, mediaType, filepath.Base(at.Path), data, at.Annotations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack attachment %s: %w", at.Path, err)
+		}
+
+		b.logf("  Attached %s (%s, digest: %s)\n", at.Path, at.ArtifactType, desc.Digest)
+		referrers = append(referrers, desc)
+	}
+	return referrers, nil
+}
+
+// packReferrer packs data into store as a single-layer manifest whose
+// subject is subject. The manifest carries a fixed creation time, so
+// unchanged content keeps its digest and pushing it again adds no duplicate
+// referrer.
+func packReferrer(ctx context.Context, store content.Storage, subject ocispec.Descriptor, artifactType, mediaType, title string, data []byte, annotations map[string]string) (ocispec.Descriptor, error) {
+	layer, err := pushIfMissing(ctx, store, mediaType, data)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	layer.Annotations = map[string]string{
+		ocispec.AnnotationTitle: title,
+	}
+
+	manifestAnnotations := map[string]string{
+		ocispec.AnnotationCreated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+	}
+	for k, v := range annotations {
+		manifestAnnotations[k] = v
+	}
+	return oras.PackManifest(ctx, store, oras.PackManifestVersion1_1, artifactType, oras.PackManifestOptions{
+		Subject:             &subject,
+		Layers:              []ocispec.Descriptor{layer},
+		ManifestAnnotations: manifestAnnotations,
+	})
+}
+
+// layoutSubject returns the descriptor of an OCI layout's root manifest, as
+// a referrer's subject.
+func layoutSubject(ociLayoutPath string) (ocispec.Descriptor, error) {
+	index, err := ociutil.ReadOCIIndex(ociLayoutPath)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to read OCI index: %w", err)
+	}
+	if len(index.Manifests) == 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("OCI layout has no manifests")
+	}
+	root := index.TaggedManifest()
+	return ocispec.Descriptor{
+		MediaType: root.MediaType,
+		Digest:    digest.Digest(root.Digest),
+		Size:      root.Size,
+	}, nil
+}
+
+// pushReferrers copies referrer manifests and their blobs from src to the
+// repository of a target. Their subject, which is not in src, was just
+// pushed and is left out. Registries without the referrers API get the referrers tag schema.
+func (b *Builder) pushReferrers(ctx context.Context, src content.ReadOnlyStorage, registry string, referrers []ocispec.Descriptor) error {
+	if len(referrers) == 0 {
+		return nil
+	}
+	remoteRepo, err := b.remoteRepository(registry)
+	if err != nil {
+		return err
+	}
+
+	opts := oras.CopyGraphOptions{
+		FindSuccessors: func(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+			successors, err := content.Successors(ctx, fetcher, desc)
+			if err != nil {
+				return nil, err
+			}
+			var packed []ocispec.Descriptor
+			for _, s := range successors {
+				if ok, err := src.Exists(ctx, s); err == nil && ok {
+					packed = append(packed, s)
+				}
+			}
+			return packed, nil
+		},
+	}
+	for _, desc := range referrers {
+		if err := oras.CopyGraph(ctx, src, remoteRepo, desc, opts); err != nil {
+			return fmt.Errorf("failed to push referrer %s: %w", desc.Digest, err)
+		}
+	}
+	return nil
+}
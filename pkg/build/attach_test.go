@@ -0,0 +1,68 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestAttach(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "openapi.yaml"), "openapi: 3.1.0", 0644)
+	manifests := map[string][]byte{}
+	blobs := map[digest.Digest][]byte{}
+	host := fakeRegistry(t, "api-spec", manifests, blobs)
+
+	artifact := &Artifact{
+		Type:    TypeArtifact,
+		Name:    "api-spec",
+		Files:   []FileEntry{{Path: "openapi.yaml", MediaType: "application/vnd.oai.openapi+yaml"}},
+		Targets: []Target{{Registry: host + "/api-spec", Tags: []string{"v1"}}},
+		Attach: []AttachEntry{{
+			Path:         "openapi.yaml",
+			ArtifactType: "application/vnd.example.report.v1",
+			Annotations:  map[string]string{"dev.example.suite": "contract"},
+		}},
+	}
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{Quiet: true, Push: true, Insecure: true})
+	result, err := b.buildOne(context.Background(), artifact, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Referrers) != 1 || len(result.Targets) != 1 {
+		t.Fatalf("result = %+v, want one target and one referrer", result)
+	}
+	subject := digest.Digest(result.Targets[0].Digest)
+
+	data, ok := manifests[result.Referrers[0].Digest]
+	if !ok {
+		t.Fatalf("referrer %s was not pushed", result.Referrers[0].Digest)
+	}
+	var referrer ocispec.Manifest
+	if err := json.Unmarshal(data, &referrer); err != nil {
+		t.Fatal(err)
+	}
+	if referrer.Subject == nil || referrer.Subject.Digest != subject {
+		t.Errorf("referrer subject = %v, want %s", referrer.Subject, subject)
+	}
+	if referrer.ArtifactType != "application/vnd.example.report.v1" ||
+		referrer.Annotations["dev.example.suite"] != "contract" {
+		t.Errorf("referrer = %+v", referrer)
+	}
+	if len(referrer.Layers) != 1 || string(blobs[referrer.Layers[0].Digest]) != "openapi: 3.1.0" {
+		t.Errorf("referrer layers = %+v", referrer.Layers)
+	}
+
+	// The fake registry has no referrers API, so the tag schema is used
+	var index ocispec.Index
+	if err := json.Unmarshal(manifests["sha256-"+subject.Encoded()], &index); err != nil {
+		t.Fatalf("referrers index: %v", err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Digest.String() != result.Referrers[0].Digest {
+		t.Errorf("referrers index = %+v", index.Manifests)
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 
+	"github.com/opencontainers/go-digest"
+	"oras.land/oras-go/v2/content/memory"
 	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
@@ -28,15 +32,25 @@ type BuilderOptions struct {
 	// Quiet suppresses progress output.
 	Quiet bool
 
-	// Insecure allows HTTP for push targets.
+	// Insecure allows HTTP for push targets and layers- and go-type base images.
 	Insecure bool
 
-	// Platforms overrides platforms for image-type artifacts.
+	// Platforms overrides platforms for image-, layers- and go-type artifacts.
 	Platforms []string
 
-	// ArtifactFilter limits the build to a specific artifact by name or 0-based index string.
+	// ArtifactFilter limits the build to a specific artifact by name, matrix
+	// variant name, type or 0-based index string.
 	ArtifactFilter string
 
+	// Parallel is how many artifacts may build at once (default 1). Output
+	// lines are prefixed with the artifact's name when it is above 1.
+	Parallel int
+
+	// CacheDir enables the build cache: each artifact's latest OCI layout is
+	// kept here with a hash of its inputs, and reused while they are
+	// unchanged. Empty disables the cache.
+	CacheDir string
+
 	// CredentialFunc resolves auth credentials for a given registry URL.
 	CredentialFunc func(registryURL string) auth.CredentialFunc
 
@@ -46,9 +60,10 @@ type BuilderOptions struct {
 
 // Builder orchestrates building and pushing OCI artifacts from a .lazy config.
 type Builder struct {
-	config  *Config
-	opts    BuilderOptions
-	baseDir string // directory containing the .lazy file
+	config     *Config
+	opts       BuilderOptions
+	configPath string
+	baseDir    string // directory containing the .lazy file
 }
 
 // BuildResult describes the outcome of building a single artifact.
@@ -62,8 +77,20 @@ type BuildResult struct {
 	// Targets lists push results for each target/tag combination.
 	Targets []TargetResult `json:"targets,omitempty" yaml:"targets,omitempty"`
 
-	// Error is set if the build failed.
+	// Error is set if the build failed or was skipped.
 	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+
+	// Skipped is true if the artifact was not built because a dependency
+	// failed.
+	Skipped bool `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+
+	// Referrers lists the files attached to the artifact. They are pushed
+	// to each target's repository.
+	Referrers []ReferrerResult `json:"referrers,omitempty" yaml:"referrers,omitempty"`
+
+	// Cached is true if the inputs were unchanged and the OCI layout came
+	// from the build cache.
+	Cached bool `json:"cached,omitempty" yaml:"cached,omitempty"`
 }
 
 // TargetResult describes the outcome of pushing to a single target tag.
@@ -74,8 +101,20 @@ type TargetResult struct {
 	// Digest is the manifest digest after push.
 	Digest string `json:"digest,omitempty" yaml:"digest,omitempty"`
 
-	// Pushed is true if the artifact was actually pushed (false in dry-run or no-push mode).
+	// Pushed is true if the target was pushed to (false in dry-run or no-push mode).
 	Pushed bool `json:"pushed" yaml:"pushed"`
+
+	// Skipped is true if the tag already pointed at the digest, so nothing
+	// was sent.
+	Skipped bool `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+
+	// Retagged is true if the registry already had the digest and only the
+	// tag was set.
+	Retagged bool `json:"retagged,omitempty" yaml:"retagged,omitempty"`
+
+	// Signature is the digest of the manifest holding the digest's cosign
+	// signature, for targets with sign set.
+	Signature string `json:"signature,omitempty" yaml:"signature,omitempty"`
 }
 
 // NewBuilder creates a Builder for the given config.
@@ -85,13 +124,16 @@ func NewBuilder(cfg *Config, configPath string, opts BuilderOptions) *Builder {
 		opts.Output = os.Stderr
 	}
 	return &Builder{
-		config:  cfg,
-		opts:    opts,
-		baseDir: filepath.Dir(configPath),
+		config:     cfg,
+		opts:       opts,
+		configPath: configPath,
+		baseDir:    filepath.Dir(configPath),
 	}
 }
 
-// Build builds all artifacts (or a filtered subset) and returns results.
+// Build builds all artifacts (or a filtered subset) and returns results in
+// config order. Artifacts start after the ones they depend on, up to
+// opts.Parallel at a time.
 func (b *Builder) Build(ctx context.Context) ([]BuildResult, error) {
 	artifacts := b.config.Artifacts
 
@@ -104,21 +146,7 @@ func (b *Builder) Build(ctx context.Context) ([]BuildResult, error) {
 		artifacts = filtered
 	}
 
-	var results []BuildResult
-	for i, a := range artifacts {
-		result, err := b.buildOne(ctx, &a, i)
-		if err != nil {
-			results = append(results, BuildResult{
-				Name:  a.Name,
-				Type:  a.Type,
-				Error: err.Error(),
-			})
-			continue
-		}
-		results = append(results, *result)
-	}
-
-	return results, nil
+	return b.buildAll(ctx, expandMatrix(artifacts)), nil
 }
 
 // BuildArtifact builds a single artifact by its 0-based index.
@@ -126,6 +154,9 @@ func (b *Builder) BuildArtifact(ctx context.Context, idx int) (*BuildResult, err
 	if idx < 0 || idx >= len(b.config.Artifacts) {
 		return nil, fmt.Errorf("artifact index %d out of range (0-%d)", idx, len(b.config.Artifacts)-1)
 	}
+	if len(b.config.Artifacts[idx].Matrix) > 0 {
+		return nil, fmt.Errorf("artifact index %d has a matrix; build its variants with Build", idx)
+	}
 	return b.buildOne(ctx, &b.config.Artifacts[idx], idx)
 }
 
@@ -151,46 +182,130 @@ func (b *Builder) buildOne(ctx context.Context, artifact *Artifact, idx int) (*B
 
 	// Resolve template variables and render tags
 	vars := ResolveTemplateVars(b.opts.Tag, chartVersion)
+	vars.Matrix = artifact.variant
 	renderedTargets, err := RenderTags(artifact.Targets, vars)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render tags: %w", err)
 	}
+	if artifact, err = renderInputs(artifact, vars); err != nil {
+		return nil, err
+	}
+	artifact, err = renderMetadata(artifact, vars)
+	if err != nil {
+		return nil, err
+	}
 
 	if b.opts.DryRun {
 		return b.dryRunResult(name, artifact, renderedTargets), nil
 	}
 
-	// Dispatch to type-specific builder
+	// Reuse the cached layout when the inputs are unchanged
+	var key digest.Digest
+	var slot string
+	if b.opts.CacheDir != "" {
+		k, ok, err := b.inputHash(ctx, artifact, vars)
+		if err != nil {
+			b.logf("  Build cache skipped: %v\n", err)
+		} else if ok {
+			key, slot = k, b.cacheSlot(artifact, idx)
+		}
+	}
 	var ociLayoutPath string
+	cached := false
+	if key != "" {
+		if layout, ok := cachedLayout(slot, key); ok {
+			b.logf("  Inputs unchanged, reusing cached build\n")
+			// Annotate a copy, so the cached layout stays as it was built
+			if ociLayoutPath, err = cloneLayout(layout); err != nil {
+				return nil, fmt.Errorf("failed to copy cached build: %w", err)
+			}
+			cached = true
+		}
+	}
+	if !cached {
+		// Dispatch to type-specific builder
+		if ociLayoutPath, err = b.dispatch(ctx, artifact, chartVersion, vars); err != nil {
+			return nil, err
+		}
+		if key != "" {
+			if err := storeLayout(slot, key, ociLayoutPath); err != nil {
+				b.logf("  Failed to cache build: %v\n", err)
+			}
+		}
+	}
+
+	// Clean up the temporary OCI layout when done
+	if ociLayoutPath != "" {
+		defer os.RemoveAll(ociLayoutPath)
+	}
+
+	if err := annotateLayout(ctx, ociLayoutPath, artifact.Annotations); err != nil {
+		return nil, fmt.Errorf("failed to annotate: %w", err)
+	}
+
+	return b.pushAll(ctx, name, artifact, ociLayoutPath, renderedTargets, cached)
+}
+
+// dispatch builds an artifact with the handler for its type and returns
+// the path to a temporary OCI layout directory.
+func (b *Builder) dispatch(ctx context.Context, artifact *Artifact, chartVersion string, vars *TemplateVars) (string, error) {
 	switch artifact.Type {
 	case TypeImage:
-		ociLayoutPath, err = b.buildImage(ctx, artifact)
+		return b.buildImage(ctx, artifact)
 	case TypeHelm:
-		ociLayoutPath, err = b.buildHelm(ctx, artifact, chartVersion)
+		return b.buildHelm(ctx, artifact, chartVersion)
 	case TypeArtifact:
-		ociLayoutPath, err = b.buildArtifact(ctx, artifact)
+		return b.buildArtifact(ctx, artifact)
 	case TypeDocker:
-		ociLayoutPath, err = b.buildDocker(ctx, artifact)
-	default:
-		return nil, fmt.Errorf("unsupported artifact type: %s", artifact.Type)
+		return b.buildDocker(ctx, artifact)
+	case TypeLayers:
+		return b.buildLayers(ctx, artifact)
+	case TypeGo:
+		return b.buildGo(ctx, artifact, vars)
+	}
+	return "", fmt.Errorf("unsupported artifact type: %s", artifact.Type)
+}
+
+// pushAll pushes an artifact's OCI layout to each target tag, or records
+// the tags when pushing is disabled.
+func (b *Builder) pushAll(ctx context.Context, name string, artifact *Artifact, ociLayoutPath string, renderedTargets []Target, cached bool) (*BuildResult, error) {
+	result := &BuildResult{
+		Name:   name,
+		Type:   artifact.Type,
+		Cached: cached,
 	}
+
+	// Pack attachments and the SBOM once, so every target gets the same
+	// referrers
+	attachments := memory.New()
+	referrers, err := b.packAttachments(ctx, attachments, artifact, ociLayoutPath)
 	if err != nil {
 		return nil, err
 	}
-
-	// Clean up the temporary OCI layout when done
-	if ociLayoutPath != "" {
-		defer os.RemoveAll(ociLayoutPath)
+	for i, desc := range referrers {
+		result.Referrers = append(result.Referrers, ReferrerResult{
+			Path:         artifact.Attach[i].Path,
+			ArtifactType: artifact.Attach[i].ArtifactType,
+			Digest:       desc.Digest.String(),
+		})
 	}
-
-	// Push to all targets
-	result := &BuildResult{
-		Name: name,
-		Type: artifact.Type,
+	if artifact.SBOM != nil {
+		desc, err := b.packSBOM(ctx, attachments, name, artifact, ociLayoutPath)
+		if err != nil {
+			return nil, err
+		}
+		format := artifact.SBOM.format()
+		referrers = append(referrers, desc)
+		result.Referrers = append(result.Referrers, ReferrerResult{
+			Path:         sbomFileName(format),
+			ArtifactType: format.MediaType(),
+			Digest:       desc.Digest.String(),
+		})
 	}
 
 	if b.opts.Push {
 		for _, target := range renderedTargets {
+			first := len(result.Targets)
 			for _, tag := range target.Tags {
 				tr, err := b.pushToTarget(ctx, ociLayoutPath, tag, target)
 				if err != nil {
@@ -198,6 +313,18 @@ func (b *Builder) buildOne(ctx context.Context, artifact *Artifact, idx int) (*B
 				}
 				result.Targets = append(result.Targets, *tr)
 			}
+			if err := b.pushReferrers(ctx, attachments, target.Registry, referrers); err != nil {
+				return nil, fmt.Errorf("push to %s failed: %w", target.Registry, err)
+			}
+			if target.Sign != nil {
+				signature, err := b.signTarget(ctx, ociLayoutPath, target)
+				if err != nil {
+					return nil, fmt.Errorf("signing %s failed: %w", target.Registry, err)
+				}
+				for i := first; i < len(result.Targets); i++ {
+					result.Targets[i].Signature = signature
+				}
+			}
 		}
 	} else {
 		// No push — just record what would have been pushed
@@ -230,13 +357,26 @@ func (b *Builder) dryRunResult(name string, artifact *Artifact, targets []Target
 				Pushed:    false,
 			})
 		}
+		if target.Sign != nil {
+			b.logf("  [dry-run] would sign %s with %s\n", target.Registry, target.Sign.Key)
+		}
+	}
+	for _, at := range artifact.Attach {
+		b.logf("  [dry-run] would attach %s (%s)\n", at.Path, at.ArtifactType)
+	}
+	if artifact.SBOM != nil {
+		b.logf("  [dry-run] would generate %s SBOM\n", artifact.SBOM.format())
+	}
+	for _, k := range slices.Sorted(maps.Keys(artifact.Annotations)) {
+		b.logf("  [dry-run] would annotate %s=%s\n", k, artifact.Annotations[k])
 	}
 
 	return result
 }
 
 // filterArtifacts returns only the artifacts matching the filter.
-// The filter can be a name or a 0-based index string.
+// The filter can be a name, a matrix variant's name, a type or a 0-based
+// index string.
 func (b *Builder) filterArtifacts(artifacts []Artifact) ([]Artifact, error) {
 	filter := b.opts.ArtifactFilter
 
@@ -247,6 +387,18 @@ func (b *Builder) filterArtifacts(artifacts []Artifact) ([]Artifact, error) {
 		}
 	}
 
+	// Try matching one variant of a matrix artifact
+	for i := range artifacts {
+		if len(artifacts[i].Matrix) == 0 {
+			continue
+		}
+		for _, v := range artifacts[i].variants(i) {
+			if v.Name == filter {
+				return []Artifact{v}, nil
+			}
+		}
+	}
+
 	// Try matching by type
 	var byType []Artifact
 	for _, a := range artifacts {
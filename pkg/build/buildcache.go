@@ -0,0 +1,412 @@
+package build
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// buildCacheVersion is hashed into every input hash. Bump it when a handler
+// change makes the same inputs build a different layout.
+const buildCacheVersion = "lazyoci-build-cache/1"
+
+// inputHash returns a digest of everything an artifact's OCI layout is built
+// from: its definition (targets, name, dependsOn, attach, sbom and
+// annotations aside, with labels as rendered by renderMetadata), the
+// --platform override, the contents and modes of the files it reads,
+// rendered ldflags, and the resolved digest of base images: the base of
+// layers and go artifacts, and the FROM images of a Dockerfile. ok is false
+// for the docker type, whose input is a daemon image rather than files, and
+// for a Dockerfile whose FROM images cannot be worked out.
+func (b *Builder) inputHash(ctx context.Context, artifact *Artifact, vars *TemplateVars) (digest.Digest, bool, error) {
+	if artifact.Type == TypeDocker {
+		return "", false, nil
+	}
+
+	def := *artifact
+	def.Name, def.Targets, def.DependsOn, def.Attach, def.SBOM = "", nil, nil, nil, nil
+	def.Annotations = nil // applied to the layout after the cache
+	if artifact.Type == TypeGo {
+		ldflags, err := renderLdflags(artifact, vars)
+		if err != nil {
+			return "", false, err
+		}
+		def.Ldflags = ldflags
+	}
+	data, err := json.Marshal(def)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to encode artifact: %w", err)
+	}
+
+	d := digest.SHA256.Digester()
+	h := d.Hash()
+	fmt.Fprintf(h, "%s\n%s\nplatforms %s\n", buildCacheVersion, data, strings.Join(b.opts.Platforms, ","))
+
+	switch artifact.Type {
+	case TypeImage:
+		dockerfile, buildContext := b.imagePaths(artifact)
+		if err := hashTree(h, dockerfile); err != nil {
+			return "", false, err
+		}
+		if err := hashTree(h, buildContext); err != nil {
+			return "", false, err
+		}
+
+		// As for layers, a FROM tag can move
+		data, err := os.ReadFile(dockerfile)
+		if err != nil {
+			return "", false, err
+		}
+		bases, ok := dockerfileBases(data, artifact.BuildArgs)
+		if !ok {
+			return "", false, nil
+		}
+		for _, name := range bases {
+			baseImg, err := b.openBase(ctx, name)
+			if err != nil {
+				return "", false, err
+			}
+			fmt.Fprintf(h, "base %s %s\n", name, baseImg.desc.Digest)
+		}
+	case TypeHelm:
+		if err := hashTree(h, b.resolvePath(artifact.ChartPath)); err != nil {
+			return "", false, err
+		}
+	case TypeArtifact:
+		for _, f := range artifact.Files {
+			paths, err := b.expandFiles(f.Path)
+			if err != nil {
+				return "", false, err
+			}
+			for _, p := range paths {
+				fmt.Fprintf(h, "file %s\n", p)
+				if err := hashTree(h, b.resolvePath(p)); err != nil {
+					return "", false, err
+				}
+			}
+		}
+	case TypeLayers, TypeGo:
+		base := artifact.Base
+		if artifact.Type == TypeGo {
+			if base == "" {
+				base = DefaultGoBase
+			}
+			if err := b.hashGoInputs(ctx, h, artifact); err != nil {
+				return "", false, err
+			}
+		}
+
+		platforms, err := b.platforms(artifact)
+		if err != nil {
+			return "", false, err
+		}
+		for i, entry := range artifact.Layers {
+			for _, p := range platforms {
+				src, err := renderSrc(entry.Src, p)
+				if err != nil {
+					return "", false, fmt.Errorf("layers[%d].src: %w", i, err)
+				}
+				if err := hashTree(h, b.resolvePath(src)); err != nil {
+					return "", false, err
+				}
+			}
+		}
+
+		// A base tag can move, so the digest it resolves to is the input
+		baseImg, err := b.openBase(ctx, base)
+		if err != nil {
+			return "", false, err
+		}
+		if baseImg != nil {
+			fmt.Fprintf(h, "base %s\n", baseImg.desc.Digest)
+		}
+	}
+
+	return d.Digest(), true, nil
+}
+
+// dockerfileBases returns the images named by the FROM instructions of a
+// Dockerfile, with variables expanded from buildArgs and the defaults of
+// the ARGs declared before the first FROM. Earlier stages and scratch are
+// left out. ok is false when a name uses a variable that has no value, or
+// a modifier such as ${VAR:-default}.
+func dockerfileBases(data []byte, buildArgs map[string]string) (bases []string, ok bool) {
+	args := map[string]string{}
+	stages := map[string]bool{}
+	seenFrom := false
+	ok = true
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			if v, found := buildArgs[name]; found {
+				return v
+			}
+			if v := args[name]; v != "" {
+				return v
+			}
+			ok = false
+			return ""
+		})
+	}
+
+	for _, line := range dockerfileInstructions(data) {
+		fields := strings.Fields(line)
+		switch strings.ToUpper(fields[0]) {
+		case "ARG":
+			if seenFrom {
+				continue
+			}
+			for _, arg := range fields[1:] {
+				name, value, _ := strings.Cut(arg, "=")
+				args[name] = strings.Trim(value, `"'`)
+			}
+		case "FROM":
+			seenFrom = true
+			var words []string
+			for _, f := range fields[1:] {
+				if !strings.HasPrefix(f, "--") {
+					words = append(words, f)
+				}
+			}
+			if len(words) == 0 {
+				return nil, false
+			}
+			name := expand(words[0])
+			if !ok {
+				return nil, false
+			}
+			if lower := strings.ToLower(name); !stages[lower] && lower != "scratch" {
+				bases = append(bases, name)
+			}
+			if len(words) == 3 && strings.EqualFold(words[1], "AS") {
+				stages[strings.ToLower(words[2])] = true
+			}
+		}
+	}
+	return bases, true
+}
+
+// dockerfileInstructions splits a Dockerfile into instructions, joining
+// lines continued with a backslash and dropping comments and blank lines.
+func dockerfileInstructions(data []byte) []string {
+	var instructions []string
+	var current strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if cont, found := strings.CutSuffix(line, "\\"); found {
+			current.WriteString(cont + " ")
+			continue
+		}
+		current.WriteString(line)
+		instructions = append(instructions, current.String())
+		current.Reset()
+	}
+	if current.Len() > 0 {
+		instructions = append(instructions, current.String())
+	}
+	return instructions
+}
+
+// hashGoInputs hashes the Go module that holds the artifact's main package
+// and the version of the local toolchain.
+func (b *Builder) hashGoInputs(ctx context.Context, h io.Writer, artifact *Artifact) error {
+	dir := b.baseDir
+	if main := artifact.Main; strings.HasPrefix(main, ".") || filepath.IsAbs(main) {
+		dir = b.resolvePath(main)
+	}
+	if err := hashTree(h, goModuleRoot(dir)); err != nil {
+		return err
+	}
+
+	out, err := exec.CommandContext(ctx, "go", "env", "GOVERSION").Output()
+	if err != nil {
+		return fmt.Errorf("failed to read Go version: %w", err)
+	}
+	fmt.Fprintf(h, "go %s\n", strings.TrimSpace(string(out)))
+	return nil
+}
+
+// goModuleRoot returns the nearest directory at or above dir with a go.mod,
+// or dir itself when there is none.
+func goModuleRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// hashTree writes the relative paths, modes and contents of a file or
+// directory tree to h in walk order. .git directories are skipped. Paths
+// are relative to root, so the same tree hashes the same wherever it is
+// checked out.
+func hashTree(h io.Writer, root string) error {
+	fmt.Fprintf(h, "tree\n")
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			fmt.Fprintf(h, "d %s %o\n", rel, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "l %s %s\n", rel, target)
+		case d.Type().IsRegular():
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			fd := digest.SHA256.Digester()
+			_, err = io.Copy(fd.Hash(), f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "f %s %o %s\n", rel, info.Mode().Perm(), fd.Digest())
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", root, err)
+	}
+	fmt.Fprintf(h, "end\n")
+	return nil
+}
+
+// cacheSlot returns the build cache directory of an artifact. Each artifact
+// keeps only its latest layout, so the cache does not grow with every
+// change.
+func (b *Builder) cacheSlot(artifact *Artifact, idx int) string {
+	key := artifact.Name
+	if key == "" {
+		key = fmt.Sprintf("#%d", idx)
+	}
+	sum := sha256.Sum256([]byte(b.configPath + "\x00" + artifact.Type + "\x00" + key))
+	return filepath.Join(b.opts.CacheDir, hex.EncodeToString(sum[:8]))
+}
+
+// cachedLayout returns the OCI layout cached in slot if it was built from
+// inputs with hash key.
+func cachedLayout(slot string, key digest.Digest) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(slot, "inputs"))
+	if err != nil || strings.TrimSpace(string(data)) != key.String() {
+		return "", false
+	}
+	layout := filepath.Join(slot, "layout")
+	if _, err := os.Stat(filepath.Join(layout, "index.json")); err != nil {
+		return "", false
+	}
+	return layout, true
+}
+
+// storeLayout replaces the layout cached in slot with a copy of layout,
+// built from inputs with hash key.
+func storeLayout(slot string, key digest.Digest, layout string) error {
+	if err := os.MkdirAll(filepath.Dir(slot), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(slot), filepath.Base(slot)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if err := copyTree(layout, filepath.Join(tmp, "layout"), false); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "inputs"), []byte(key.String()+"\n"), 0o644); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if err := os.RemoveAll(slot); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	return os.Rename(tmp, slot)
+}
+
+// cloneLayout copies a cached OCI layout to a temporary directory. Blobs
+// are hard-linked where possible: they are never written in place.
+func cloneLayout(layout string) (string, error) {
+	tmp, err := os.MkdirTemp("", "lazyoci-cached-*")
+	if err != nil {
+		return "", err
+	}
+	if err := copyTree(layout, tmp, true); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// copyTree copies the directories and regular files under src to dst.
+// With linkBlobs, files under blobs/ are hard-linked instead when src and
+// dst are on the same file system.
+func copyTree(src, dst string, linkBlobs bool) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if linkBlobs && strings.HasPrefix(filepath.ToSlash(rel), "blobs/") && os.Link(p, target) == nil {
+			return nil
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
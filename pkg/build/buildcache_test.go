@@ -0,0 +1,279 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestInputHash(t *testing.T) {
+	newArtifact := func() *Artifact {
+		return &Artifact{
+			Type:      TypeArtifact,
+			Name:      "config",
+			Files:     []FileEntry{{Path: "conf", MediaType: "text/plain"}},
+			Targets:   []Target{{Registry: "example.com/config", Tags: []string{"v1"}}},
+			MediaType: "application/vnd.example.config",
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, dir string, a *Artifact, opts *BuilderOptions)
+		same   bool
+	}{
+		{
+			name:   "unchanged",
+			change: func(t *testing.T, dir string, a *Artifact, opts *BuilderOptions) {},
+			same:   true,
+		},
+		{
+			name: "targets and name",
+			change: func(t *testing.T, dir string, a *Artifact, opts *BuilderOptions) {
+				a.Name = "renamed"
+				a.Targets[0].Tags = []string{"v2"}
+			},
+			same: true,
+		},
+		{
+			name: "annotations",
+			change: func(t *testing.T, dir string, a *Artifact, opts *BuilderOptions) {
+				a.Annotations = map[string]string{"org.opencontainers.image.revision": "abc1234"}
+			},
+			same: true,
+		},
+		{
+			name: "mtime",
+			change: func(t *testing.T, dir string, a *Artifact, opts *BuilderOptions) {
+				future := time.Now().Add(time.Hour)
+				if err := os.Chtimes(filepath.Join(dir, "conf", "app.yaml"), future, future); err != nil {
+					t.Fatal(err)
+				}
+			},
+			same: true,
+		},
+		{
+			name: "file content",
+			change: func(t *testing.T, dir string, a *Artifact, opts *BuilderOptions) {
+				writeFile(t, filepath.Join(dir, "conf", "app.yaml"), "port: 9090", 0644)
+			},
+		},
+		{
+			name: "file mode",
+			change: func(t *testing.T, dir string, a *Artifact, opts *BuilderOptions) {
+				if err := os.Chmod(filepath.Join(dir, "conf", "app.yaml"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "new file",
+			change: func(t *testing.T, dir string, a *Artifact, opts *BuilderOptions) {
+				writeFile(t, filepath.Join(dir, "conf", "extra.yaml"), "", 0644)
+			},
+		},
+		{
+			name: "definition",
+			change: func(t *testing.T, dir string, a *Artifact, opts *BuilderOptions) {
+				a.MediaType = "application/vnd.example.other"
+			},
+		},
+		{
+			name: "platform override",
+			change: func(t *testing.T, dir string, a *Artifact, opts *BuilderOptions) {
+				opts.Platforms = []string{"linux/arm64"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "conf", "app.yaml"), "port: 8080", 0644)
+			configPath := filepath.Join(dir, ".lazy")
+			vars := &TemplateVars{}
+
+			before, ok, err := NewBuilder(&Config{}, configPath, BuilderOptions{}).inputHash(context.Background(), newArtifact(), vars)
+			if err != nil || !ok {
+				t.Fatalf("inputHash() = %v, %v", ok, err)
+			}
+
+			a := newArtifact()
+			var opts BuilderOptions
+			tt.change(t, dir, a, &opts)
+			after, _, err := NewBuilder(&Config{}, configPath, opts).inputHash(context.Background(), a, vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (before == after) != tt.same {
+				t.Errorf("hash changed = %v, want %v", before != after, !tt.same)
+			}
+		})
+	}
+}
+
+func TestInputHashGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "models", "a.onnx"), "weights", 0644)
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{})
+	artifact := &Artifact{Type: TypeArtifact, Files: []FileEntry{{Path: "models/*.onnx"}}}
+
+	before, _, err := b.inputHash(context.Background(), artifact, &TemplateVars{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A renamed match changes the artifact's titles, so the hash too
+	if err := os.Rename(filepath.Join(dir, "models", "a.onnx"), filepath.Join(dir, "models", "b.onnx")); err != nil {
+		t.Fatal(err)
+	}
+	after, _, err := b.inputHash(context.Background(), artifact, &TemplateVars{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before == after {
+		t.Error("hash unchanged after renaming a glob match")
+	}
+
+	artifact.Files[0].Path = "models/*.bin"
+	if _, _, err := b.inputHash(context.Background(), artifact, &TemplateVars{}); err == nil {
+		t.Error("expected an error for a glob matching no files")
+	}
+}
+
+func TestInputHashDocker(t *testing.T) {
+	b := NewBuilder(&Config{}, filepath.Join(t.TempDir(), ".lazy"), BuilderOptions{})
+	if _, ok, err := b.inputHash(context.Background(), &Artifact{Type: TypeDocker, Image: "app:latest"}, &TemplateVars{}); ok || err != nil {
+		t.Errorf("inputHash(docker) = %v, %v; want not cacheable", ok, err)
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "bin", "app"), "binary", 0755)
+	artifact := &Artifact{
+		Type:      TypeLayers,
+		Name:      "app",
+		Platforms: []string{"linux/amd64"},
+		Layers:    []LayerEntry{{Src: "bin/app", Dest: "/usr/bin/app"}},
+	}
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{
+		Quiet:    true,
+		CacheDir: filepath.Join(dir, "cache"),
+	})
+
+	for i, want := range []bool{false, true, false} {
+		if i == 2 {
+			writeFile(t, filepath.Join(dir, "bin", "app"), "new binary", 0755)
+		}
+		result, err := b.buildOne(context.Background(), artifact, 0)
+		if err != nil {
+			t.Fatalf("build %d: %v", i, err)
+		}
+		if result.Cached != want {
+			t.Errorf("build %d: cached = %v, want %v", i, result.Cached, want)
+		}
+	}
+
+	// Only the latest layout is kept
+	entries, err := os.ReadDir(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("cache has %d entries, want 1", len(entries))
+	}
+}
+
+func TestDockerfileBases(t *testing.T) {
+	tests := []struct {
+		name       string
+		dockerfile string
+		buildArgs  map[string]string
+		want       []string
+		ok         bool
+	}{
+		{
+			name:       "single stage",
+			dockerfile: "# syntax=docker/dockerfile:1\nFROM alpine:3.20\nRUN apk add curl\n",
+			want:       []string{"alpine:3.20"},
+			ok:         true,
+		},
+		{
+			name: "stages and scratch",
+			dockerfile: "FROM --platform=$BUILDPLATFORM golang:1.25 AS build\nRUN go build\n" +
+				"FROM build AS test\nFROM scratch\nCOPY --from=build /app /app\n",
+			want: []string{"golang:1.25"},
+			ok:   true,
+		},
+		{
+			name:       "arg default and build arg",
+			dockerfile: "ARG BASE=debian\nARG VERSION=12\nFROM ${BASE}:$VERSION \\\n  AS runtime\nARG BASE=ignored\n",
+			buildArgs:  map[string]string{"VERSION": "13"},
+			want:       []string{"debian:13"},
+			ok:         true,
+		},
+		{
+			name:       "arg without value",
+			dockerfile: "ARG BASE\nFROM $BASE\n",
+			ok:         false,
+		},
+		{
+			name:       "modifier",
+			dockerfile: "ARG BASE=alpine\nFROM ${BASE:-debian}\n",
+			ok:         false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := dockerfileBases([]byte(tt.dockerfile), tt.buildArgs)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dockerfileBases() = %q, %v; want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestInputHashImageBase(t *testing.T) {
+	manifest := func(layer string) []byte {
+		data, _ := json.Marshal(ocispec.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageManifest,
+			Layers:    []ocispec.Descriptor{{MediaType: ocispec.MediaTypeImageLayerGzip, Digest: digest.FromString(layer), Size: int64(len(layer))}},
+		})
+		return data
+	}
+	manifests := map[string][]byte{"3.20": manifest("v1")}
+	host := fakeRegistry(t, "library/alpine", manifests, map[digest.Digest][]byte{})
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "Dockerfile"), "FROM "+host+"/library/alpine:3.20\n", 0644)
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{Insecure: true})
+	artifact := &Artifact{Type: TypeImage}
+
+	before, ok, err := b.inputHash(context.Background(), artifact, &TemplateVars{})
+	if err != nil || !ok {
+		t.Fatalf("inputHash() = %v, %v", ok, err)
+	}
+	// The tag moving to a new image is a new input
+	manifests["3.20"] = manifest("v2")
+	after, _, err := b.inputHash(context.Background(), artifact, &TemplateVars{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before == after {
+		t.Error("hash unchanged after the FROM tag moved")
+	}
+
+	writeFile(t, filepath.Join(dir, "Dockerfile"), "ARG BASE\nFROM $BASE\n", 0644)
+	if _, ok, err := b.inputHash(context.Background(), artifact, &TemplateVars{}); ok || err != nil {
+		t.Errorf("inputHash() with an unknown FROM = %v, %v; want not cacheable", ok, err)
+	}
+}
@@ -3,13 +3,18 @@ package build
 import (
 	"bytes"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
+	"github.com/mistergrinvalds/lazyoci/pkg/sbom"
+	"github.com/mistergrinvalds/lazyoci/pkg/sign"
 	"gopkg.in/yaml.v3"
 )
 
@@ -26,6 +31,12 @@ const (
 	TypeArtifact = "artifact"
 	// TypeDocker pushes an existing Docker daemon image to a registry.
 	TypeDocker = "docker"
+	// TypeLayers assembles an image from a base image and local files
+	// without a container runtime.
+	TypeLayers = "layers"
+	// TypeGo compiles a Go main package and layers it onto a base image
+	// without a container runtime.
+	TypeGo = "go"
 )
 
 // validTypes is the set of supported artifact types.
@@ -34,6 +45,8 @@ var validTypes = map[string]bool{
 	TypeHelm:     true,
 	TypeArtifact: true,
 	TypeDocker:   true,
+	TypeLayers:   true,
+	TypeGo:       true,
 }
 
 // ---------------------------------------------------------------------------
@@ -51,7 +64,7 @@ type Config struct {
 
 // Artifact describes a single OCI artifact to build and push.
 type Artifact struct {
-	// Type is the artifact type: "image", "helm", "artifact", or "docker".
+	// Type is the artifact type: "image", "helm", "artifact", "docker", "layers", or "go".
 	Type string `yaml:"type"`
 
 	// Name is a human-readable identifier for this artifact.
@@ -60,18 +73,51 @@ type Artifact struct {
 	// Targets specifies where to push the built artifact.
 	Targets []Target `yaml:"targets"`
 
+	// DependsOn names artifacts that must build successfully before this
+	// one starts. If one fails, this artifact is skipped.
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+
+	// Attach lists files to push as referrers of the built manifest, e.g.
+	// an SBOM or a test report.
+	Attach []AttachEntry `yaml:"attach,omitempty"`
+
+	// SBOM generates a software bill of materials for the built artifact
+	// and pushes it as a referrer.
+	SBOM *SBOMConfig `yaml:"sbom,omitempty"`
+
+	// Annotations are added to the pushed manifest and, for multi-platform
+	// images, the index and each platform's manifest. Values are templates
+	// rendered with TemplateVars, and override the automatic
+	// org.opencontainers.image annotations.
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+
+	// Matrix builds the artifact once per combination of these values,
+	// e.g. {variant: [slim, full], python: ["3.11", "3.12"]}. Each value is
+	// available as {{ .Matrix.<key> }} in targets, buildArgs, dockerfile
+	// and context.
+	Matrix map[string][]string `yaml:"matrix,omitempty"`
+
+	// variant holds the matrix values of one combination, and matrixName
+	// the name of the artifact it was expanded from (see expandMatrix).
+	variant    map[string]string
+	matrixName string
+
 	// --- type: image ---
 
 	// Dockerfile is the path to the Dockerfile (default: "Dockerfile").
+	// Template variables are supported.
 	Dockerfile string `yaml:"dockerfile,omitempty"`
 
 	// Context is the build context directory (default: ".").
+	// Template variables are supported.
 	Context string `yaml:"context,omitempty"`
 
 	// Platforms lists target platforms for multi-arch builds (e.g., ["linux/amd64", "linux/arm64"]).
+	// Also used by the layers and go types.
 	Platforms []string `yaml:"platforms,omitempty"`
 
 	// BuildArgs are --build-arg key=value pairs passed to docker buildx.
+	// Values are templates rendered with TemplateVars.
 	BuildArgs map[string]string `yaml:"buildArgs,omitempty"`
 
 	// --- type: helm ---
@@ -91,6 +137,51 @@ type Artifact struct {
 
 	// Image is the Docker daemon image reference to push (e.g., "myapp:latest").
 	Image string `yaml:"image,omitempty"`
+
+	// --- type: layers (all fields also apply to type: go) ---
+
+	// Base is the image to build on (e.g., "gcr.io/distroless/static:nonroot").
+	// "scratch" starts from an empty filesystem, as does an empty Base for
+	// the layers type; the go type defaults to DefaultGoBase.
+	Base string `yaml:"base,omitempty"`
+
+	// Layers lists the files and directories to add, one layer each.
+	Layers []LayerEntry `yaml:"layers,omitempty"`
+
+	// Entrypoint replaces the base image's entrypoint (and its cmd, unless
+	// Cmd is set too).
+	Entrypoint []string `yaml:"entrypoint,omitempty"`
+
+	// Cmd replaces the base image's cmd.
+	Cmd []string `yaml:"cmd,omitempty"`
+
+	// Env sets environment variables, replacing base image values of the
+	// same name.
+	Env map[string]string `yaml:"env,omitempty"`
+
+	// WorkDir replaces the base image's working directory.
+	WorkDir string `yaml:"workdir,omitempty"`
+
+	// User replaces the base image's user (e.g., "65532" or "65532:65532").
+	User string `yaml:"user,omitempty"`
+
+	// Labels are added to the base image's labels. Values are templates
+	// rendered with TemplateVars. Also used by the docker type.
+	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// --- type: go ---
+
+	// Main is the main package to build, as passed to go build from the
+	// .lazy file's directory (default: ".").
+	Main string `yaml:"main,omitempty"`
+
+	// Binary is the name of the binary, installed in /usr/local/bin
+	// (default: the last element of Main).
+	Binary string `yaml:"binary,omitempty"`
+
+	// Ldflags are extra -ldflags passed to go build. Template variables are
+	// supported, e.g. "-X main.version={{ .Version }}".
+	Ldflags []string `yaml:"ldflags,omitempty"`
 }
 
 // Target describes a registry push destination.
@@ -103,6 +194,7 @@ type Target struct {
 	//   {{ .Registry }}          — base registry URL (from LAZYOCI_REGISTRY env var)
 	//   {{ .Tag }}               — value of --tag flag (or LAZYOCI_TAG env var)
 	//   {{ .GitSHA }}            — current git commit SHA (short)
+	//   {{ .GitCommit }}         — current git commit SHA (full)
 	//   {{ .GitBranch }}         — current git branch name
 	//   {{ .ChartVersion }}      — version from Chart.yaml (helm only)
 	//   {{ .Timestamp }}         — build timestamp (YYYYMMDDHHmmss)
@@ -114,15 +206,89 @@ type Target struct {
 	//   {{ .VersionMajorMinor }} — "MAJOR.MINOR", e.g. "1.2"
 	//   {{ .VersionRaw }}        — raw git tag string, e.g. "v1.2.3-rc.1"
 	Tags []string `yaml:"tags"`
+
+	// Sign signs the pushed digest with a cosign key.
+	Sign *SignConfig `yaml:"sign,omitempty"`
+}
+
+// SignConfig configures the cosign signature of a target's pushed digest.
+type SignConfig struct {
+	// Key is the private key file relative to the .lazy file location,
+	// e.g. a cosign.key from cosign generate-key-pair. Encrypted keys are
+	// decrypted with the COSIGN_PASSWORD environment variable.
+	Key string `yaml:"key"`
+
+	// Mode is where the signature goes: "tag" (default) for the
+	// sha256-<hex>.sig tag cosign reads, or "referrer" for an OCI 1.1
+	// referrer.
+	Mode string `yaml:"mode,omitempty"`
 }
 
 // FileEntry describes a file to include in a generic OCI artifact.
 type FileEntry struct {
+	// Path is the file or directory path relative to the .lazy file
+	// location, or a glob pattern (e.g., "models/*.onnx") adding a layer
+	// for each match. A directory is packed as a gzipped tarball that
+	// oras pull unpacks.
+	Path string `yaml:"path"`
+
+	// MediaType is the OCI media type for this entry's layers. Defaults to
+	// a type inferred from the file extension, or the OCI gzip layer type
+	// for directories.
+	MediaType string `yaml:"mediaType,omitempty"`
+}
+
+// AttachEntry describes a file pushed as a referrer: a manifest whose
+// subject is the built artifact, found with the OCI referrers API.
+type AttachEntry struct {
 	// Path is the file path relative to the .lazy file location.
 	Path string `yaml:"path"`
 
-	// MediaType is the OCI media type for this file's layer.
-	MediaType string `yaml:"mediaType"`
+	// ArtifactType is the referrer manifest's artifactType, e.g.
+	// "application/spdx+json".
+	ArtifactType string `yaml:"artifactType"`
+
+	// MediaType is the media type of the file's layer (default: artifactType).
+	MediaType string `yaml:"mediaType,omitempty"`
+
+	// Annotations are added to the referrer manifest.
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// SBOMConfig configures the SBOM generated for an artifact. Images list
+// the OS packages and Go modules found in their layers; other artifacts
+// list their files and digests.
+type SBOMConfig struct {
+	// Format is "spdx" (default) or "cyclonedx".
+	Format string `yaml:"format,omitempty"`
+}
+
+// format returns the configured SBOM format.
+func (c *SBOMConfig) format() sbom.Format {
+	if c.Format == "" {
+		return sbom.FormatSPDX
+	}
+	return sbom.Format(c.Format)
+}
+
+// LayerEntry maps a local file or directory into a layers-type image.
+type LayerEntry struct {
+	// Src is the file or directory path relative to the .lazy file location.
+	// {{ .OS }}, {{ .Arch }} and {{ .Variant }} expand to the platform being
+	// built, e.g. "bin/app-{{ .Arch }}".
+	Src string `yaml:"src"`
+
+	// Dest is the absolute path in the image. A directory's contents are
+	// copied into Dest; a file is copied to Dest, or into it if Dest ends
+	// with "/".
+	Dest string `yaml:"dest"`
+
+	// Mode is the octal permission mode for files (e.g., "0755").
+	// Defaults to the mode on disk.
+	Mode string `yaml:"mode,omitempty"`
+
+	// Owner is the numeric "uid[:gid]" owning the added files (default "0:0").
+	Owner string `yaml:"owner,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
@@ -141,6 +307,10 @@ type TemplateVars struct {
 	// GitSHA is the current git commit SHA (short).
 	GitSHA string
 
+	// GitCommit is the current git commit SHA (full), as recorded in the
+	// revision annotation.
+	GitCommit string
+
 	// GitBranch is the current git branch name.
 	GitBranch string
 
@@ -150,6 +320,16 @@ type TemplateVars struct {
 	// Timestamp is the build timestamp in YYYYMMDDHHmmss format.
 	Timestamp string
 
+	// Created is the RFC 3339 creation time recorded in annotations: the
+	// SOURCE_DATE_EPOCH env var, or the current commit's time (empty
+	// outside a git checkout). Builds of the same commit get the same time,
+	// so rebuilds give the same digests.
+	Created string
+
+	// Source is the https URL of the git remote "origin", without
+	// credentials (empty if there is none).
+	Source string
+
 	// Version is the clean semver from git tag (v prefix stripped), e.g., "1.2.3".
 	// Source priority: LAZYOCI_VERSION env var > --tag flag (if semver) > git describe tag.
 	Version string
@@ -171,6 +351,10 @@ type TemplateVars struct {
 
 	// VersionRaw is the raw version string before parsing (e.g., "v1.2.3-rc.1").
 	VersionRaw string
+
+	// Matrix holds the values of the matrix combination being built, e.g.
+	// {{ .Matrix.variant }} (empty for artifacts without a matrix).
+	Matrix map[string]string
 }
 
 // ---------------------------------------------------------------------------
@@ -209,21 +393,93 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	return c.validateDependencies()
+}
+
+// validateDependencies checks that every dependsOn entry names exactly one
+// other artifact and that the dependencies form no cycle.
+func (c *Config) validateDependencies() error {
+	byName := map[string]int{}
+	duplicate := map[string]bool{}
+	for i, a := range c.Artifacts {
+		if a.Name == "" {
+			continue
+		}
+		if _, ok := byName[a.Name]; ok {
+			duplicate[a.Name] = true
+		}
+		byName[a.Name] = i
+	}
+
+	for i, a := range c.Artifacts {
+		for _, dep := range a.DependsOn {
+			switch _, ok := byName[dep]; {
+			case dep == a.Name:
+				return fmt.Errorf("%s: cannot depend on itself", artifactPrefix(&a, i))
+			case !ok:
+				return fmt.Errorf("%s: dependsOn %q does not name an artifact", artifactPrefix(&a, i), dep)
+			case duplicate[dep]:
+				return fmt.Errorf("%s: dependsOn %q is ambiguous (several artifacts have that name)", artifactPrefix(&a, i), dep)
+			}
+		}
+	}
+
+	// Depth-first search; reaching an artifact still on the path is a cycle
+	const (
+		unvisited = iota
+		onPath
+		visited
+	)
+	state := make([]int, len(c.Artifacts))
+	var path []string
+	var visit func(i int) error
+	visit = func(i int) error {
+		state[i] = onPath
+		path = append(path, c.Artifacts[i].Name)
+		for _, dep := range c.Artifacts[i].DependsOn {
+			j := byName[dep]
+			switch state[j] {
+			case onPath:
+				start := slices.Index(path, dep)
+				return fmt.Errorf("dependency cycle: %s", strings.Join(append(path[start:], dep), " -> "))
+			case unvisited:
+				if err := visit(j); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		return nil
+	}
+	for i := range c.Artifacts {
+		if state[i] == unvisited {
+			if err := visit(i); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
-// validate checks a single artifact definition for errors.
-func (a *Artifact) validate(index int) error {
-	prefix := fmt.Sprintf("artifact[%d]", index)
+// artifactPrefix names an artifact in error messages: by name, or by
+// index if it has none.
+func artifactPrefix(a *Artifact, index int) string {
 	if a.Name != "" {
-		prefix = fmt.Sprintf("artifact %q", a.Name)
+		return fmt.Sprintf("artifact %q", a.Name)
 	}
+	return fmt.Sprintf("artifact[%d]", index)
+}
+
+// validate checks a single artifact definition for errors.
+func (a *Artifact) validate(index int) error {
+	prefix := artifactPrefix(a, index)
 
 	if a.Type == "" {
 		return fmt.Errorf("%s: type is required", prefix)
 	}
 	if !validTypes[a.Type] {
-		return fmt.Errorf("%s: unsupported type %q (must be one of: image, helm, artifact, docker)", prefix, a.Type)
+		return fmt.Errorf("%s: unsupported type %q (must be one of: image, helm, artifact, docker, layers, go)", prefix, a.Type)
 	}
 
 	if len(a.Targets) == 0 {
@@ -237,6 +493,31 @@ func (a *Artifact) validate(index int) error {
 		if len(t.Tags) == 0 {
 			return fmt.Errorf("%s: target[%d].tags must have at least one tag", prefix, i)
 		}
+		if t.Sign != nil {
+			if t.Sign.Key == "" {
+				return fmt.Errorf("%s: target[%d].sign.key is required", prefix, i)
+			}
+			if _, err := sign.ParseMode(t.Sign.Mode); err != nil {
+				return fmt.Errorf("%s: target[%d].sign: %w", prefix, i, err)
+			}
+		}
+	}
+
+	for i, at := range a.Attach {
+		if at.Path == "" {
+			return fmt.Errorf("%s: attach[%d].path is required", prefix, i)
+		}
+		if at.ArtifactType == "" {
+			return fmt.Errorf("%s: attach[%d].artifactType is required", prefix, i)
+		}
+	}
+
+	if a.SBOM != nil && a.SBOM.format().MediaType() == "" {
+		return fmt.Errorf("%s: unsupported sbom.format %q (must be spdx or cyclonedx)", prefix, a.SBOM.Format)
+	}
+
+	if err := a.validateMatrix(index); err != nil {
+		return fmt.Errorf("%s: %w", prefix, err)
 	}
 
 	// Type-specific validation
@@ -255,14 +536,35 @@ func (a *Artifact) validate(index int) error {
 			if f.Path == "" {
 				return fmt.Errorf("%s: files[%d].path is required", prefix, i)
 			}
-			if f.MediaType == "" {
-				return fmt.Errorf("%s: files[%d].mediaType is required", prefix, i)
+			if err := checkGlob(f.Path); err != nil {
+				return fmt.Errorf("%s: files[%d].path: %w", prefix, i, err)
 			}
 		}
 	case TypeDocker:
 		if a.Image == "" {
 			return fmt.Errorf("%s: image is required for docker artifacts", prefix)
 		}
+	case TypeLayers, TypeGo:
+		if a.Type == TypeLayers && a.Base == "" && len(a.Layers) == 0 {
+			return fmt.Errorf("%s: layers artifacts require a base or at least one layer", prefix)
+		}
+		if _, err := parsePlatforms(a.Platforms); err != nil {
+			return fmt.Errorf("%s: %w", prefix, err)
+		}
+		for i, l := range a.Layers {
+			if l.Src == "" {
+				return fmt.Errorf("%s: layers[%d].src is required", prefix, i)
+			}
+			if !strings.HasPrefix(l.Dest, "/") {
+				return fmt.Errorf("%s: layers[%d].dest must be an absolute path", prefix, i)
+			}
+			if _, err := parseMode(l.Mode); err != nil {
+				return fmt.Errorf("%s: layers[%d].mode: %w", prefix, i, err)
+			}
+			if _, _, err := parseOwner(l.Owner); err != nil {
+				return fmt.Errorf("%s: layers[%d].owner: %w", prefix, i, err)
+			}
+		}
 	}
 
 	return nil
@@ -297,18 +599,80 @@ func ResolveTemplateVars(tag, chartVersion string) *TemplateVars {
 	if out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output(); err == nil {
 		vars.GitSHA = strings.TrimSpace(string(out))
 	}
+	if out, err := exec.Command("git", "rev-parse", "HEAD").Output(); err == nil {
+		vars.GitCommit = strings.TrimSpace(string(out))
+	}
 
 	// Resolve git branch
 	if out, err := exec.Command("git", "branch", "--show-current").Output(); err == nil {
 		vars.GitBranch = strings.TrimSpace(string(out))
 	}
 
+	// Resolve source repository
+	if out, err := exec.Command("git", "remote", "get-url", "origin").Output(); err == nil {
+		vars.Source = sourceURL(strings.TrimSpace(string(out)))
+	}
+
+	vars.Created = resolveCreated()
+
 	// Resolve version — try sources in priority order
 	resolveVersion(vars, tag)
 
 	return vars
 }
 
+// resolveCreated returns the RFC 3339 creation time of the build:
+// SOURCE_DATE_EPOCH, or the commit time of HEAD. It is empty when neither
+// is known rather than the current time, which would change the digest of
+// every rebuild.
+func resolveCreated() string {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if sec, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC().Format(time.RFC3339)
+		}
+	}
+	if out, err := exec.Command("git", "log", "-1", "--format=%ct").Output(); err == nil {
+		if sec, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC().Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// sourceURL returns the https URL of a git remote, e.g.
+// "git@github.com:owner/repo.git" gives "https://github.com/owner/repo".
+// Credentials are dropped. Local paths give "".
+func sourceURL(remote string) string {
+	if !strings.Contains(remote, "://") {
+		// scp-like syntax: [user@]host:path
+		host, path, ok := strings.Cut(remote, ":")
+		if !ok || strings.Contains(host, "/") {
+			return ""
+		}
+		if _, h, found := strings.Cut(host, "@"); found {
+			host = h
+		}
+		remote = "ssh://" + host + "/" + path
+	}
+
+	u, err := url.Parse(remote)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "ssh", "git", "git+ssh":
+		u.Scheme = "https"
+		u.Host = u.Hostname()
+	default:
+		return ""
+	}
+	u.User = nil
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git")
+	u.RawQuery, u.Fragment = "", ""
+	return u.String()
+}
+
 // resolveVersion populates the Version* fields on TemplateVars.
 // Priority: LAZYOCI_VERSION env > tag param (if semver) > git tag.
 func resolveVersion(vars *TemplateVars, tag string) {
@@ -373,6 +737,7 @@ func RenderTags(targets []Target, vars *TemplateVars) ([]Target, error) {
 		rendered[i] = Target{
 			Registry: reg,
 			Tags:     make([]string, len(t.Tags)),
+			Sign:     t.Sign,
 		}
 		for j, tagTmpl := range t.Tags {
 			result, err := renderTemplate(tagTmpl, vars)
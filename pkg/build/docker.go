@@ -50,7 +50,7 @@ func (b *Builder) buildDocker(ctx context.Context, artifact *Artifact) (string,
 
 	// Convert Docker save format to OCI layout
 	ociLayoutDir := filepath.Join(tmpDir, "oci-layout")
-	if err := dockerSaveToOCILayout(saveTar, ociLayoutDir); err != nil {
+	if err := dockerSaveToOCILayout(saveTar, ociLayoutDir, artifact.Labels); err != nil {
 		os.RemoveAll(tmpDir)
 		return "", fmt.Errorf("failed to convert Docker save to OCI layout: %w", err)
 	}
@@ -64,6 +64,7 @@ func (b *Builder) buildDocker(ctx context.Context, artifact *Artifact) (string,
 }
 
 // dockerSaveToOCILayout converts a Docker save format tarball to an OCI layout directory.
+// Labels are added to the image config.
 //
 // Docker save format:
 //
@@ -76,7 +77,7 @@ func (b *Builder) buildDocker(ctx context.Context, artifact *Artifact) (string,
 //	oci-layout          — {"imageLayoutVersion": "1.0.0"}
 //	index.json          — {manifests: [{digest, mediaType, size}]}
 //	blobs/sha256/<hash> — config, layers (gzipped), manifest
-func dockerSaveToOCILayout(saveTarPath, destDir string) error {
+func dockerSaveToOCILayout(saveTarPath, destDir string, labels map[string]string) error {
 	// Extract Docker save tarball to a temp directory
 	extractDir, err := os.MkdirTemp("", "lazyoci-docker-extract-*")
 	if err != nil {
@@ -121,6 +122,9 @@ func dockerSaveToOCILayout(saveTarPath, destDir string) error {
 	if err != nil {
 		return fmt.Errorf("failed to read config %s: %w", dm.Config, err)
 	}
+	if configData, err = setConfigLabels(configData, labels); err != nil {
+		return err
+	}
 	configDigest := digestSHA256(configData)
 	if err := os.WriteFile(filepath.Join(blobDir, configDigest), configData, 0644); err != nil {
 		return err
@@ -0,0 +1,175 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/pull"
+)
+
+// DefaultGoBase is the base image of go-type artifacts that set none. It
+// has CA certificates, tzdata and a nonroot user, which static Go binaries
+// need but scratch lacks.
+const DefaultGoBase = "gcr.io/distroless/static:nonroot"
+
+// Labels recorded from the build info of go-type binaries.
+const (
+	labelRevision = "org.opencontainers.image.revision"
+	labelGoModule = "dev.lazyoci.go.module"
+	labelGoVer    = "dev.lazyoci.go.version"
+)
+
+// buildGo cross-compiles a Go main package for each platform with the local
+// toolchain and layers the binaries onto the base image, like the layers
+// type does for prebuilt files. Binaries are built with -trimpath and no
+// build ID, so the same source gives the same image. Ldflags are rendered
+// with vars.
+// Returns the path to a temporary OCI layout directory.
+func (b *Builder) buildGo(ctx context.Context, artifact *Artifact, vars *TemplateVars) (string, error) {
+	if _, err := exec.LookPath("go"); err != nil {
+		return "", fmt.Errorf("go not found in PATH: %w", err)
+	}
+
+	platforms, err := b.platforms(artifact)
+	if err != nil {
+		return "", err
+	}
+
+	mainPkg := artifact.Main
+	if mainPkg == "" {
+		mainPkg = "."
+	}
+	name := artifact.Binary
+	if name == "" {
+		name = path.Base(filepath.ToSlash(mainPkg))
+		if mainPkg == "." {
+			name = filepath.Base(b.baseDir)
+		}
+	}
+
+	ldflags, err := renderLdflags(artifact, vars)
+	if err != nil {
+		return "", err
+	}
+
+	binDir, err := os.MkdirTemp("", "lazyoci-go-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(binDir)
+
+	labels := map[string]string{}
+	for i, p := range platforms {
+		// One directory per platform, matching the layer's src template below
+		out := filepath.Join(binDir, p.OS+"-"+p.Architecture+p.Variant, name)
+		b.logf("  Compiling %s for %s...\n", mainPkg, pull.PlatformString(p))
+		if err := b.goBuild(ctx, mainPkg, out, p.OS, p.Architecture, p.Variant, ldflags); err != nil {
+			return "", err
+		}
+		if i == 0 {
+			labels = goBuildLabels(out)
+		}
+	}
+
+	// Assemble as a layers artifact: the binary first, then any extra layers
+	assembled := *artifact
+	assembled.Platforms = nil
+	for _, p := range platforms {
+		assembled.Platforms = append(assembled.Platforms, pull.PlatformString(p))
+	}
+	if assembled.Base == "" {
+		assembled.Base = DefaultGoBase
+	}
+	dest := "/usr/local/bin/" + name
+	assembled.Layers = append([]LayerEntry{{
+		Src:  filepath.Join(binDir, "{{ .OS }}-{{ .Arch }}{{ .Variant }}", name),
+		Dest: dest,
+		Mode: "0755",
+	}}, artifact.Layers...)
+	if assembled.Entrypoint == nil {
+		assembled.Entrypoint = []string{dest}
+	}
+	for k, v := range artifact.Labels {
+		labels[k] = v
+	}
+	assembled.Labels = labels
+
+	return b.buildLayers(ctx, &assembled)
+}
+
+// renderLdflags renders the artifact's ldflags with vars.
+func renderLdflags(artifact *Artifact, vars *TemplateVars) ([]string, error) {
+	ldflags := make([]string, len(artifact.Ldflags))
+	for i, flag := range artifact.Ldflags {
+		var err error
+		if ldflags[i], err = renderTemplate(flag, vars); err != nil {
+			return nil, fmt.Errorf("ldflags[%d]: failed to render %q: %w", i, flag, err)
+		}
+	}
+	return ldflags, nil
+}
+
+// goBuild compiles the package mainPkg for one platform into out.
+func (b *Builder) goBuild(ctx context.Context, mainPkg, out, goos, goarch, variant string, ldflags []string) error {
+	args := []string{"build", "-trimpath",
+		"-ldflags", strings.Join(append([]string{"-buildid="}, ldflags...), " "),
+		"-o", out, mainPkg}
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = b.baseDir
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS="+goos, "GOARCH="+goarch)
+	switch goarch {
+	case "arm":
+		if variant != "" {
+			cmd.Env = append(cmd.Env, "GOARM="+strings.TrimPrefix(variant, "v"))
+		}
+	case "amd64":
+		if variant != "" {
+			cmd.Env = append(cmd.Env, "GOAMD64="+variant)
+		}
+	}
+
+	var stderr bytes.Buffer
+	if !b.opts.Quiet {
+		cmd.Stdout = b.opts.Output
+		cmd.Stderr = b.opts.Output
+	} else {
+		cmd.Stderr = &stderr
+	}
+	if err := cmd.Run(); err != nil {
+		errMsg := strings.TrimSpace(stderr.String())
+		if errMsg == "" {
+			errMsg = err.Error()
+		}
+		return fmt.Errorf("go build failed for %s/%s: %s", goos, goarch, errMsg)
+	}
+	return nil
+}
+
+// goBuildLabels returns image labels for the module path, Go version and
+// VCS revision recorded in a binary's build info. Unreadable build info
+// gives no labels.
+func goBuildLabels(binary string) map[string]string {
+	labels := map[string]string{}
+	info, err := buildinfo.ReadFile(binary)
+	if err != nil {
+		return labels
+	}
+	if info.Main.Path != "" {
+		labels[labelGoModule] = info.Main.Path
+	}
+	labels[labelGoVer] = info.GoVersion
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			labels[labelRevision] = s.Value
+		}
+	}
+	return labels
+}
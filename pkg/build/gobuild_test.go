@@ -0,0 +1,76 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestBuildGo(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/hello\n\ngo 1.21\n", 0644)
+	writeFile(t, filepath.Join(dir, "cmd", "hello", "main.go"), "package main\n\nfunc main() { println(\"hello\") }\n", 0644)
+	writeFile(t, filepath.Join(dir, "config.yaml"), "greeting: hello\n", 0644)
+
+	artifact := &Artifact{
+		Type:      TypeGo,
+		Base:      "scratch",
+		Main:      "./cmd/hello",
+		Ldflags:   []string{"-X main.version={{ .Tag }}"},
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+		Layers:    []LayerEntry{{Src: "config.yaml", Dest: "/etc/hello/"}},
+		Labels:    map[string]string{"org.opencontainers.image.title": "hello"},
+	}
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{Quiet: true})
+
+	build := func() (string, ocispec.Index) {
+		t.Helper()
+		layout, err := b.buildGo(context.Background(), artifact, &TemplateVars{Tag: "v1.0.0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(layout) })
+		var index ocispec.Index
+		readJSON(t, layout, rootDescriptor(t, layout), &index)
+		return layout, index
+	}
+
+	layout, index := build()
+	if len(index.Manifests) != 2 {
+		t.Fatalf("index has %d manifests, want 2", len(index.Manifests))
+	}
+	for _, desc := range index.Manifests {
+		arch := desc.Platform.Architecture
+		var manifest ocispec.Manifest
+		readJSON(t, layout, desc, &manifest)
+		if len(manifest.Layers) != 2 {
+			t.Fatalf("%s: %d layers, want the binary and config.yaml", arch, len(manifest.Layers))
+		}
+		headers, _ := layerFiles(t, layout, manifest.Layers[0])
+		if h, ok := headers["usr/local/bin/hello"]; !ok || h.Mode != 0755 {
+			t.Errorf("%s: binary layer = %v, want usr/local/bin/hello with mode 0755", arch, headers)
+		}
+		if _, contents := layerFiles(t, layout, manifest.Layers[1]); contents["etc/hello/config.yaml"] != "greeting: hello\n" {
+			t.Errorf("%s: config layer = %v", arch, contents)
+		}
+
+		var config ocispec.Image
+		readJSON(t, layout, manifest.Config, &config)
+		if !reflect.DeepEqual(config.Config.Entrypoint, []string{"/usr/local/bin/hello"}) {
+			t.Errorf("%s: entrypoint = %v, want the binary", arch, config.Config.Entrypoint)
+		}
+		if config.Config.Labels[labelGoModule] != "example.com/hello" || config.Config.Labels[labelGoVer] == "" ||
+			config.Config.Labels["org.opencontainers.image.title"] != "hello" {
+			t.Errorf("%s: labels = %v, want build info and the artifact's labels", arch, config.Config.Labels)
+		}
+	}
+
+	// The same source builds the same image
+	if _, again := build(); again.Manifests[0].Digest != index.Manifests[0].Digest {
+		t.Errorf("rebuild digest = %s, want %s", again.Manifests[0].Digest, index.Manifests[0].Digest)
+	}
+}
@@ -10,10 +10,21 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 const lazyociBuilderName = "lazyoci"
 
+var (
+	// builderMu serializes finding or creating the buildx builder, so that
+	// parallel image builds don't both try to create it.
+	builderMu sync.Mutex
+
+	// fallbackBuilds numbers the temporary images of buildImageFallback.
+	fallbackBuilds atomic.Int64
+)
+
 // buildImage builds a container image from a Dockerfile using docker buildx.
 // Returns the path to a temporary OCI layout directory.
 func (b *Builder) buildImage(ctx context.Context, artifact *Artifact) (string, error) {
@@ -22,18 +33,7 @@ func (b *Builder) buildImage(ctx context.Context, artifact *Artifact) (string, e
 		return "", err
 	}
 
-	dockerfile := artifact.Dockerfile
-	if dockerfile == "" {
-		dockerfile = "Dockerfile"
-	}
-	buildContext := artifact.Context
-	if buildContext == "" {
-		buildContext = "."
-	}
-
-	// Resolve paths relative to .lazy file
-	dockerfile = b.resolvePath(dockerfile)
-	buildContext = b.resolvePath(buildContext)
+	dockerfile, buildContext := b.imagePaths(artifact)
 
 	// Determine platforms
 	platforms := artifact.Platforms
@@ -128,12 +128,26 @@ func (b *Builder) buildImage(ctx context.Context, artifact *Artifact) (string, e
 	return ociLayoutDir, nil
 }
 
+// imagePaths returns the artifact's Dockerfile and build context, resolved
+// relative to the .lazy file.
+func (b *Builder) imagePaths(artifact *Artifact) (dockerfile, buildContext string) {
+	dockerfile = artifact.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	buildContext = artifact.Context
+	if buildContext == "" {
+		buildContext = "."
+	}
+	return b.resolvePath(dockerfile), b.resolvePath(buildContext)
+}
+
 // buildImageFallback builds a single-platform image using plain docker build + docker save,
 // then converts the Docker save format to OCI layout.
 // This is used when no docker-container buildx builder is available.
 func (b *Builder) buildImageFallback(ctx context.Context, artifact *Artifact, dockerfile, buildContext string, platforms []string) (string, error) {
-	// Generate a temporary image tag for the build
-	tmpTag := fmt.Sprintf("lazyoci-build-%d:latest", os.Getpid())
+	// Generate a temporary image tag for the build, unique among parallel builds
+	tmpTag := fmt.Sprintf("lazyoci-build-%d-%d:latest", os.Getpid(), fallbackBuilds.Add(1))
 
 	// Build the docker build command
 	args := []string{"build", "-f", dockerfile, "-t", tmpTag}
@@ -198,7 +212,7 @@ func (b *Builder) buildImageFallback(ctx context.Context, artifact *Artifact, do
 
 	// Convert Docker save format to OCI layout
 	ociLayoutDir := filepath.Join(tmpDir, "oci-layout")
-	if err := dockerSaveToOCILayout(saveTar, ociLayoutDir); err != nil {
+	if err := dockerSaveToOCILayout(saveTar, ociLayoutDir, nil); err != nil {
 		os.RemoveAll(tmpDir)
 		return "", fmt.Errorf("failed to convert to OCI layout: %w", err)
 	}
@@ -213,6 +227,9 @@ func (b *Builder) buildImageFallback(ctx context.Context, artifact *Artifact, do
 // ensureOCICapableBuilder finds or creates a buildx builder that supports OCI export.
 // Returns the builder name, or an error if none can be made available.
 func ensureOCICapableBuilder(ctx context.Context, quiet bool) (string, error) {
+	builderMu.Lock()
+	defer builderMu.Unlock()
+
 	// Check if the "lazyoci" builder already exists
 	if builderExists(ctx, lazyociBuilderName) {
 		return lazyociBuilderName, nil
@@ -0,0 +1,581 @@
+package build
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	"github.com/mistergrinvalds/lazyoci/pkg/pull"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// Docker media types that base images may still use. A Docker layer is a
+// gzipped tar like its OCI equivalent, so it is relabeled when reused.
+const (
+	dockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerLayerGzip    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+// baseImage is the image a layers artifact builds on. A nil *baseImage
+// is scratch.
+type baseImage struct {
+	name  string
+	repo  content.ReadOnlyStorage
+	desc  ocispec.Descriptor
+	index *ocispec.Index
+}
+
+// buildLayers assembles an image from a base image and local files in pure
+// Go: each layers entry becomes a gzipped tar layer on top of the base's
+// layers, and the config gets the artifact's entrypoint, env and so on.
+// More than one platform produces an image index.
+// Returns the path to a temporary OCI layout directory.
+func (b *Builder) buildLayers(ctx context.Context, artifact *Artifact) (string, error) {
+	platforms, err := b.platforms(artifact)
+	if err != nil {
+		return "", err
+	}
+
+	base, err := b.openBase(ctx, artifact.Base)
+	if err != nil {
+		return "", err
+	}
+	if base != nil {
+		b.logf("  Base image: %s (%s)\n", base.name, base.desc.Digest)
+	} else {
+		b.logf("  Base image: scratch\n")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "lazyoci-layers-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	store, err := oci.New(tmpDir)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return "", fmt.Errorf("failed to create OCI store at %s: %w", tmpDir, err)
+	}
+
+	var manifests []ocispec.Descriptor
+	for _, p := range platforms {
+		b.logf("  Assembling %s...\n", pull.PlatformString(p))
+		desc, err := b.assembleImage(ctx, store, artifact, base, p)
+		if err != nil {
+			os.RemoveAll(tmpDir)
+			return "", fmt.Errorf("%s: %w", pull.PlatformString(p), err)
+		}
+		manifests = append(manifests, desc)
+	}
+
+	root := manifests[0]
+	if len(manifests) > 1 {
+		index := ocispec.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageIndex,
+			Manifests: manifests,
+		}
+		data, err := json.Marshal(index)
+		if err != nil {
+			os.RemoveAll(tmpDir)
+			return "", fmt.Errorf("failed to encode index: %w", err)
+		}
+		if root, err = pushIfMissing(ctx, store, ocispec.MediaTypeImageIndex, data); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", fmt.Errorf("failed to store index: %w", err)
+		}
+	}
+	if err := store.Tag(ctx, root, "latest"); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", fmt.Errorf("failed to tag manifest: %w", err)
+	}
+
+	b.logf("  Image assembled (%d platform(s), digest: %s)\n", len(manifests), root.Digest)
+
+	return tmpDir, nil
+}
+
+// platforms returns the platforms to build an artifact for: --platform,
+// or the artifact's platforms, or Linux on the host's architecture.
+func (b *Builder) platforms(artifact *Artifact) ([]ocispec.Platform, error) {
+	names := artifact.Platforms
+	if len(b.opts.Platforms) > 0 {
+		names = b.opts.Platforms // CLI override
+	}
+	platforms, err := parsePlatforms(names)
+	if err != nil {
+		return nil, err
+	}
+	if len(platforms) == 0 {
+		platforms = []ocispec.Platform{{OS: "linux", Architecture: runtime.GOARCH}}
+	}
+	return platforms, nil
+}
+
+// openBase resolves the base image reference. Empty and "scratch" return nil.
+func (b *Builder) openBase(ctx context.Context, name string) (*baseImage, error) {
+	if name == "" || name == "scratch" {
+		return nil, nil
+	}
+
+	parsed, err := ociutil.ParseReference(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base image %q: %w", name, err)
+	}
+	var credFn auth.CredentialFunc
+	if b.opts.CredentialFunc != nil {
+		credFn = b.opts.CredentialFunc(parsed.Registry)
+	}
+	repo, err := ociutil.NewRemoteRepository(parsed, b.opts.Insecure, credFn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote repository: %w", err)
+	}
+
+	ref := parsed.Tag
+	if parsed.Digest != "" {
+		ref = parsed.Digest
+	}
+	desc, err := repo.Resolve(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve base image %s: %w", parsed, err)
+	}
+
+	base := &baseImage{name: parsed.String(), repo: repo, desc: desc}
+	if desc.MediaType == ocispec.MediaTypeImageIndex || desc.MediaType == dockerManifestList {
+		data, err := content.FetchAll(ctx, repo, desc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch base image index: %w", err)
+		}
+		base.index = &ocispec.Index{}
+		if err := json.Unmarshal(data, base.index); err != nil {
+			return nil, fmt.Errorf("failed to parse base image index: %w", err)
+		}
+	}
+	return base, nil
+}
+
+// manifest returns the base image's manifest and config for platform p.
+func (base *baseImage) manifest(ctx context.Context, p ocispec.Platform) (*ocispec.Manifest, *ocispec.Image, error) {
+	desc := base.desc
+	if base.index != nil {
+		var available []string
+		found := false
+		for _, m := range base.index.Manifests {
+			if m.Platform == nil || m.Platform.OS == "unknown" {
+				continue
+			}
+			if platformMatches(p, *m.Platform) {
+				desc, found = m, true
+				break
+			}
+			available = append(available, pull.PlatformString(*m.Platform))
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("base image %s has no %s manifest (available: %s)",
+				base.name, pull.PlatformString(p), strings.Join(available, ", "))
+		}
+	}
+
+	data, err := content.FetchAll(ctx, base.repo, desc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch base image manifest: %w", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse base image manifest: %w", err)
+	}
+
+	data, err = content.FetchAll(ctx, base.repo, manifest.Config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch base image config: %w", err)
+	}
+	var config ocispec.Image
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse base image config: %w", err)
+	}
+
+	// A single-platform base must be the platform being built
+	if base.index == nil && !platformMatches(p, config.Platform) {
+		return nil, nil, fmt.Errorf("base image %s is %s", base.name, pull.PlatformString(config.Platform))
+	}
+	return &manifest, &config, nil
+}
+
+// assembleImage builds the image for platform p in store and returns its
+// manifest descriptor, annotated with p.
+func (b *Builder) assembleImage(ctx context.Context, store *oci.Store, artifact *Artifact, base *baseImage, p ocispec.Platform) (ocispec.Descriptor, error) {
+	config := &ocispec.Image{
+		Platform: p,
+		RootFS:   ocispec.RootFS{Type: "layers"},
+	}
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+	}
+
+	if base != nil {
+		baseManifest, baseConfig, err := base.manifest(ctx, p)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		config = baseConfig
+		for _, layer := range baseManifest.Layers {
+			layer.MediaType = ociLayerMediaType(layer.MediaType)
+			if err := copyBlob(ctx, base.repo, store, layer); err != nil {
+				return ocispec.Descriptor{}, fmt.Errorf("failed to copy base layer %s: %w", layer.Digest, err)
+			}
+			manifest.Layers = append(manifest.Layers, layer)
+		}
+		manifest.Annotations = map[string]string{
+			ocispec.AnnotationBaseImageName:   base.name,
+			ocispec.AnnotationBaseImageDigest: base.desc.Digest.String(),
+		}
+	}
+
+	for i, entry := range artifact.Layers {
+		src, err := renderSrc(entry.Src, p)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("layers[%d].src: %w", i, err)
+		}
+		layer, diffID, err := writeLayer(ctx, store, b.resolvePath(src), entry)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("layers[%d]: %w", i, err)
+		}
+		manifest.Layers = append(manifest.Layers, layer)
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, diffID)
+		// Temporary files, such as go-type binaries, are recorded by name
+		// only, so the history does not change between builds
+		recorded := src
+		if filepath.IsAbs(recorded) {
+			recorded = filepath.Base(recorded)
+		}
+		config.History = append(config.History, ocispec.History{
+			CreatedBy: fmt.Sprintf("lazyoci build: add %s %s", recorded, entry.Dest),
+		})
+		b.logf("    Added %s -> %s (%d bytes)\n", src, entry.Dest, layer.Size)
+	}
+
+	applyImageConfig(&config.Config, artifact)
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if manifest.Config, err = pushIfMissing(ctx, store, ocispec.MediaTypeImageConfig, configJSON); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to store config: %w", err)
+	}
+	if manifest.Layers == nil {
+		manifest.Layers = []ocispec.Descriptor{}
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	desc, err := pushIfMissing(ctx, store, ocispec.MediaTypeImageManifest, manifestJSON)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to store manifest: %w", err)
+	}
+	desc.Platform = &p
+	return desc, nil
+}
+
+// applyImageConfig sets the artifact's entrypoint, cmd, env, workdir, user
+// and labels on cfg. Setting an entrypoint without a cmd clears the base
+// image's cmd, as a Dockerfile ENTRYPOINT does.
+func applyImageConfig(cfg *ocispec.ImageConfig, artifact *Artifact) {
+	if artifact.Entrypoint != nil {
+		cfg.Entrypoint = artifact.Entrypoint
+		cfg.Cmd = nil
+	}
+	if artifact.Cmd != nil {
+		cfg.Cmd = artifact.Cmd
+	}
+
+	keys := make([]string, 0, len(artifact.Env))
+	for k := range artifact.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kv := k + "=" + artifact.Env[k]
+		replaced := false
+		for i, e := range cfg.Env {
+			if strings.HasPrefix(e, k+"=") {
+				cfg.Env[i], replaced = kv, true
+			}
+		}
+		if !replaced {
+			cfg.Env = append(cfg.Env, kv)
+		}
+	}
+
+	if artifact.WorkDir != "" {
+		cfg.WorkingDir = artifact.WorkDir
+	}
+	if artifact.User != "" {
+		cfg.User = artifact.User
+	}
+	if len(artifact.Labels) > 0 && cfg.Labels == nil {
+		cfg.Labels = make(map[string]string, len(artifact.Labels))
+	}
+	for k, v := range artifact.Labels {
+		cfg.Labels[k] = v
+	}
+}
+
+// writeLayer writes src as a gzipped tar layer at entry.Dest into store and
+// returns the layer's descriptor and its diff ID (the digest of the
+// uncompressed tar).
+func writeLayer(ctx context.Context, store content.Storage, src string, entry LayerEntry) (ocispec.Descriptor, digest.Digest, error) {
+	tmp, err := os.CreateTemp("", "lazyoci-layer-*.tar.gz")
+	if err != nil {
+		return ocispec.Descriptor{}, "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	compressed := digest.SHA256.Digester()
+	uncompressed := digest.SHA256.Digester()
+	gz := gzip.NewWriter(io.MultiWriter(tmp, compressed.Hash()))
+	tw := tar.NewWriter(io.MultiWriter(gz, uncompressed.Hash()))
+
+	if err := writeLayerTar(tw, src, entry); err != nil {
+		return ocispec.Descriptor{}, "", err
+	}
+	if err := tw.Close(); err != nil {
+		return ocispec.Descriptor{}, "", fmt.Errorf("failed to write layer: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return ocispec.Descriptor{}, "", fmt.Errorf("failed to compress layer: %w", err)
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return ocispec.Descriptor{}, "", err
+	}
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    compressed.Digest(),
+		Size:      size,
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return ocispec.Descriptor{}, "", err
+	}
+	if err := store.Push(ctx, desc, tmp); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return ocispec.Descriptor{}, "", fmt.Errorf("failed to store layer: %w", err)
+	}
+	return desc, uncompressed.Digest(), nil
+}
+
+// writeLayerTar writes the tar entries for src at entry.Dest. Directories
+// above Dest get no entries, so existing ones in the base image keep their
+// owner and mode; runtimes create missing ones. Timestamps are zeroed so
+// the same files always give the same layer digest.
+func writeLayerTar(tw *tar.Writer, src string, entry LayerEntry) error {
+	mode, err := parseMode(entry.Mode)
+	if err != nil {
+		return err
+	}
+	uid, gid, err := parseOwner(entry.Owner)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	dest := path.Clean(entry.Dest)
+	if !info.IsDir() && strings.HasSuffix(entry.Dest, "/") {
+		dest = path.Join(dest, filepath.Base(src))
+	}
+
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(path.Join(dest, filepath.ToSlash(rel)), "/")
+		if name == "" {
+			// Dest is the root directory, which every image has
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("cannot add %s: %w", p, err)
+		}
+		header.Name = name
+		header.Uid, header.Gid = uid, gid
+		header.Uname, header.Gname = "", ""
+		header.ModTime = time.Unix(0, 0)
+		header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+		header.Format = tar.FormatPAX
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			header.Name += "/"
+			return tw.WriteHeader(header)
+		case tar.TypeSymlink:
+			return tw.WriteHeader(header)
+		case tar.TypeReg:
+			if mode != 0 {
+				header.Mode = int64(mode)
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		default:
+			return fmt.Errorf("cannot add %s: not a regular file, directory or symlink", p)
+		}
+	})
+}
+
+// copyBlob copies desc from src to dst unless dst already has it.
+func copyBlob(ctx context.Context, src content.Fetcher, dst content.Storage, desc ocispec.Descriptor) error {
+	if exists, err := dst.Exists(ctx, desc); err != nil || exists {
+		return err
+	}
+	rc, err := src.Fetch(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := dst.Push(ctx, desc, rc); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return err
+	}
+	return nil
+}
+
+// pushIfMissing pushes data to store unless it is already there, as
+// identical layers, configs and manifests are across platforms.
+func pushIfMissing(ctx context.Context, store content.Storage, mediaType string, data []byte) (ocispec.Descriptor, error) {
+	desc := content.NewDescriptorFromBytes(mediaType, data)
+	if err := store.Push(ctx, desc, newBlobReader(data)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return ocispec.Descriptor{}, err
+	}
+	return desc, nil
+}
+
+// ociLayerMediaType maps the Docker gzip layer media type to its OCI
+// equivalent.
+func ociLayerMediaType(mediaType string) string {
+	if mediaType == dockerLayerGzip {
+		return ocispec.MediaTypeImageLayerGzip
+	}
+	return mediaType
+}
+
+// renderSrc expands {{ .OS }}, {{ .Arch }} and {{ .Variant }} in a layer
+// source path.
+func renderSrc(src string, p ocispec.Platform) (string, error) {
+	if !strings.Contains(src, "{{") {
+		return src, nil
+	}
+	tmpl, err := template.New("src").Option("missingkey=error").Parse(src)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	vars := struct{ OS, Arch, Variant string }{p.OS, p.Architecture, p.Variant}
+	if err := tmpl.Execute(&sb, vars); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// parsePlatforms parses os/arch[/variant] platform names. No names gives
+// no platforms.
+func parsePlatforms(names []string) ([]ocispec.Platform, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return pull.ParsePlatforms(strings.Join(names, ","))
+}
+
+// platformMatches reports whether got satisfies want. An empty variant in
+// want matches any variant, and arm64 images often leave out their "v8".
+func platformMatches(want, got ocispec.Platform) bool {
+	if got.OS != want.OS || got.Architecture != want.Architecture {
+		return false
+	}
+	if want.Variant == "" || got.Variant == want.Variant {
+		return true
+	}
+	return got.Architecture == "arm64" && got.Variant == "" && want.Variant == "v8"
+}
+
+// parseMode parses an octal file mode such as "0755". Empty gives 0, which
+// keeps the mode on disk.
+func parseMode(s string) (uint32, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0o7777 {
+		return 0, fmt.Errorf("invalid mode %q, expected octal permissions (e.g., \"0755\")", s)
+	}
+	return uint32(mode), nil
+}
+
+// parseOwner parses a numeric "uid[:gid]" owner. The gid defaults to the
+// uid, and an empty owner is root.
+func parseOwner(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	uidStr, gidStr, found := strings.Cut(s, ":")
+	if !found {
+		gidStr = uidStr
+	}
+	uid, err := strconv.Atoi(uidStr)
+	if err != nil || uid < 0 {
+		return 0, 0, fmt.Errorf("invalid owner %q, expected numeric uid[:gid] (e.g., \"65532:65532\")", s)
+	}
+	gid, err := strconv.Atoi(gidStr)
+	if err != nil || gid < 0 {
+		return 0, 0, fmt.Errorf("invalid owner %q, expected numeric uid[:gid] (e.g., \"65532:65532\")", s)
+	}
+	return uid, gid, nil
+}
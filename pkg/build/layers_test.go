@@ -0,0 +1,342 @@
+package build
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// fakeRegistry serves the given manifests and blobs for one repository.
+// Manifests are keyed by tag or digest. Pushed manifests and blobs are
+// added to manifests, under their tag, and blobs.
+func fakeRegistry(t *testing.T, repo string, manifests map[string][]byte, blobs map[digest.Digest][]byte) string {
+	t.Helper()
+	mediaType := func(data []byte) string {
+		var m struct {
+			MediaType string `json:"mediaType"`
+		}
+		json.Unmarshal(data, &m)
+		return m.MediaType
+	}
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		prefix := "/v2/" + repo + "/"
+		if r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, prefix+"manifests/") {
+			data, _ := io.ReadAll(r.Body)
+			manifests[strings.TrimPrefix(r.URL.Path, prefix+"manifests/")] = data
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(data).String())
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, prefix+"blobs/uploads/") {
+			switch r.Method {
+			case http.MethodPost:
+				w.Header().Set("Location", prefix+"blobs/uploads/upload")
+				w.WriteHeader(http.StatusAccepted)
+			case http.MethodPut:
+				data, _ := io.ReadAll(r.Body)
+				blobs[digest.Digest(r.URL.Query().Get("digest"))] = data
+				w.WriteHeader(http.StatusCreated)
+			}
+			return
+		}
+		var data []byte
+		var ok bool
+		switch {
+		case strings.HasPrefix(r.URL.Path, prefix+"manifests/"):
+			data, ok = manifests[strings.TrimPrefix(r.URL.Path, prefix+"manifests/")]
+			w.Header().Set("Content-Type", mediaType(data))
+		case strings.HasPrefix(r.URL.Path, prefix+"blobs/"):
+			data, ok = blobs[digest.Digest(strings.TrimPrefix(r.URL.Path, prefix+"blobs/"))]
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(data).String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method != http.MethodHead {
+			w.Write(data)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// layerFiles returns the tar headers and file contents of a gzipped layer.
+func layerFiles(t *testing.T, layout string, desc ocispec.Descriptor) (map[string]*tar.Header, map[string]string) {
+	t.Helper()
+	data, err := ociutil.ReadBlob(layout, desc.Digest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := map[string]*tar.Header{}
+	contents := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(tr)
+		headers[h.Name] = h
+		contents[h.Name] = string(body)
+	}
+	return headers, contents
+}
+
+// readJSON decodes the blob desc of the layout into v.
+func readJSON(t *testing.T, layout string, desc ocispec.Descriptor, v any) {
+	t.Helper()
+	data, err := ociutil.ReadBlob(layout, desc.Digest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rootDescriptor returns the tagged manifest of the layout.
+func rootDescriptor(t *testing.T, layout string) ocispec.Descriptor {
+	t.Helper()
+	index, err := ociutil.ReadOCIIndex(layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := index.TaggedManifest()
+	return ocispec.Descriptor{MediaType: root.MediaType, Digest: digest.Digest(root.Digest), Size: root.Size}
+}
+
+func writeFile(t *testing.T, path, data string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildLayersScratch(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "bin", "app-amd64"), "amd64 binary", 0644)
+	writeFile(t, filepath.Join(dir, "bin", "app-arm64"), "arm64 binary", 0644)
+	writeFile(t, filepath.Join(dir, "config", "app.yaml"), "port: 8080", 0644)
+	writeFile(t, filepath.Join(dir, "config", "certs", "ca.pem"), "ca", 0600)
+
+	artifact := &Artifact{
+		Type:      TypeLayers,
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+		Layers: []LayerEntry{
+			{Src: "bin/app-{{ .Arch }}", Dest: "/usr/bin/app", Mode: "0755"},
+			{Src: "config", Dest: "/etc/app", Owner: "65532"},
+		},
+		Entrypoint: []string{"/usr/bin/app"},
+		Env:        map[string]string{"APP_CONFIG": "/etc/app/app.yaml"},
+		User:       "65532:65532",
+		Labels:     map[string]string{"org.opencontainers.image.source": "https://example.com/app"},
+	}
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{Quiet: true})
+	layout, err := b.buildLayers(context.Background(), artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(layout)
+
+	root := rootDescriptor(t, layout)
+	if root.MediaType != ocispec.MediaTypeImageIndex {
+		t.Fatalf("root media type = %s, want an index", root.MediaType)
+	}
+	var index ocispec.Index
+	readJSON(t, layout, root, &index)
+	if len(index.Manifests) != 2 {
+		t.Fatalf("index has %d manifests, want 2", len(index.Manifests))
+	}
+
+	for _, desc := range index.Manifests {
+		arch := desc.Platform.Architecture
+		var manifest ocispec.Manifest
+		readJSON(t, layout, desc, &manifest)
+		if len(manifest.Layers) != 2 {
+			t.Fatalf("%s: %d layers, want 2", arch, len(manifest.Layers))
+		}
+
+		headers, contents := layerFiles(t, layout, manifest.Layers[0])
+		if contents["usr/bin/app"] != arch+" binary" {
+			t.Errorf("%s: usr/bin/app = %q", arch, contents["usr/bin/app"])
+		}
+		if h := headers["usr/bin/app"]; h.Mode != 0755 || h.Uid != 0 || h.ModTime.Unix() != 0 {
+			t.Errorf("%s: usr/bin/app mode %o uid %d mtime %v, want 0755, root, epoch", arch, h.Mode, h.Uid, h.ModTime)
+		}
+		if _, ok := headers["usr/bin/"]; ok {
+			t.Errorf("%s: layer has an entry for a parent of dest", arch)
+		}
+
+		headers, contents = layerFiles(t, layout, manifest.Layers[1])
+		var names []string
+		for name := range headers {
+			names = append(names, name)
+		}
+		for _, name := range []string{"etc/app/", "etc/app/app.yaml", "etc/app/certs/", "etc/app/certs/ca.pem"} {
+			h, ok := headers[name]
+			if !ok {
+				t.Fatalf("%s: config layer is missing %s (has %v)", arch, name, names)
+			}
+			if h.Uid != 65532 || h.Gid != 65532 {
+				t.Errorf("%s: %s owner %d:%d, want 65532:65532", arch, name, h.Uid, h.Gid)
+			}
+		}
+		if headers["etc/app/certs/ca.pem"].Mode != 0600 {
+			t.Errorf("%s: ca.pem mode %o, want the mode on disk", arch, headers["etc/app/certs/ca.pem"].Mode)
+		}
+
+		var config ocispec.Image
+		readJSON(t, layout, manifest.Config, &config)
+		if config.Architecture != arch || config.OS != "linux" {
+			t.Errorf("config platform = %s/%s, want linux/%s", config.OS, config.Architecture, arch)
+		}
+		if len(config.RootFS.DiffIDs) != 2 || len(config.History) != 2 {
+			t.Errorf("config has %d diff IDs and %d history entries, want 2", len(config.RootFS.DiffIDs), len(config.History))
+		}
+		if !reflect.DeepEqual(config.Config.Entrypoint, []string{"/usr/bin/app"}) ||
+			!reflect.DeepEqual(config.Config.Env, []string{"APP_CONFIG=/etc/app/app.yaml"}) ||
+			config.Config.User != "65532:65532" ||
+			config.Config.Labels["org.opencontainers.image.source"] != "https://example.com/app" {
+			t.Errorf("config = %+v", config.Config)
+		}
+	}
+
+	if index.Manifests[0].Digest == index.Manifests[1].Digest {
+		t.Error("platforms share a manifest despite different binaries")
+	}
+}
+
+func TestBuildLayersBase(t *testing.T) {
+	baseLayer := []byte("base layer")
+	baseConfig, _ := json.Marshal(ocispec.Image{
+		Platform: ocispec.Platform{OS: "linux", Architecture: "arm64"},
+		Config: ocispec.ImageConfig{
+			Env:        []string{"PATH=/usr/bin", "SSL_CERT_FILE=/etc/ssl/certs/ca-certificates.crt"},
+			Cmd:        []string{"/bin/sh"},
+			User:       "65532",
+			WorkingDir: "/home/nonroot",
+		},
+		RootFS:  ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{digest.FromString("base diff")}},
+		History: []ocispec.History{{CreatedBy: "base"}},
+	})
+	baseManifest, _ := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: "application/vnd.docker.distribution.manifest.v2+json",
+		Config:    ocispec.Descriptor{MediaType: "application/vnd.docker.container.image.v1+json", Digest: digest.FromBytes(baseConfig), Size: int64(len(baseConfig))},
+		Layers:    []ocispec.Descriptor{{MediaType: dockerLayerGzip, Digest: digest.FromBytes(baseLayer), Size: int64(len(baseLayer))}},
+	})
+	baseIndex, _ := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{{
+			MediaType: "application/vnd.docker.distribution.manifest.v2+json",
+			Digest:    digest.FromBytes(baseManifest),
+			Size:      int64(len(baseManifest)),
+			Platform:  &ocispec.Platform{OS: "linux", Architecture: "arm64"},
+		}},
+	})
+	host := fakeRegistry(t, "distroless/static",
+		map[string][]byte{
+			"nonroot":                               baseIndex,
+			digest.FromBytes(baseIndex).String():    baseIndex,
+			digest.FromBytes(baseManifest).String(): baseManifest,
+		},
+		map[digest.Digest][]byte{
+			digest.FromBytes(baseConfig): baseConfig,
+			digest.FromBytes(baseLayer):  baseLayer,
+		})
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "app"), "binary", 0755)
+	artifact := &Artifact{
+		Type:       TypeLayers,
+		Base:       host + "/distroless/static:nonroot",
+		Layers:     []LayerEntry{{Src: "app", Dest: "/usr/local/bin/"}},
+		Entrypoint: []string{"/usr/local/bin/app"},
+		Env:        map[string]string{"PATH": "/usr/local/bin:/usr/bin"},
+	}
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{Quiet: true, Insecure: true})
+
+	t.Run("missing platform", func(t *testing.T) {
+		b.opts.Platforms = []string{"linux/amd64"}
+		_, err := b.buildLayers(context.Background(), artifact)
+		if err == nil || !strings.Contains(err.Error(), "no linux/amd64 manifest (available: linux/arm64)") {
+			t.Errorf("buildLayers() error = %v, want the available platforms", err)
+		}
+	})
+
+	b.opts.Platforms = []string{"linux/arm64/v8"}
+	layout, err := b.buildLayers(context.Background(), artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(layout)
+
+	root := rootDescriptor(t, layout)
+	if root.MediaType != ocispec.MediaTypeImageManifest {
+		t.Fatalf("root media type = %s, want a single manifest", root.MediaType)
+	}
+	var manifest ocispec.Manifest
+	readJSON(t, layout, root, &manifest)
+	if len(manifest.Layers) != 2 || manifest.Layers[0].MediaType != ocispec.MediaTypeImageLayerGzip || manifest.Layers[0].Digest != digest.FromBytes(baseLayer) {
+		t.Fatalf("layers = %+v, want the base layer as an OCI layer, then ours", manifest.Layers)
+	}
+	if data, err := ociutil.ReadBlob(layout, manifest.Layers[0].Digest.String()); err != nil || string(data) != string(baseLayer) {
+		t.Errorf("base layer blob = %q, %v", data, err)
+	}
+	if _, contents := layerFiles(t, layout, manifest.Layers[1]); contents["usr/local/bin/app"] != "binary" {
+		t.Errorf("layer files = %v, want usr/local/bin/app", contents)
+	}
+	if manifest.Annotations[ocispec.AnnotationBaseImageName] != host+"/distroless/static:nonroot" ||
+		manifest.Annotations[ocispec.AnnotationBaseImageDigest] != digest.FromBytes(baseIndex).String() {
+		t.Errorf("annotations = %v, want the base image", manifest.Annotations)
+	}
+
+	var config ocispec.Image
+	readJSON(t, layout, manifest.Config, &config)
+	want := ocispec.ImageConfig{
+		Env:        []string{"PATH=/usr/local/bin:/usr/bin", "SSL_CERT_FILE=/etc/ssl/certs/ca-certificates.crt"},
+		Entrypoint: []string{"/usr/local/bin/app"},
+		User:       "65532",
+		WorkingDir: "/home/nonroot",
+	}
+	if !reflect.DeepEqual(config.Config, want) {
+		t.Errorf("config = %+v, want %+v", config.Config, want)
+	}
+	if len(config.RootFS.DiffIDs) != 2 || config.RootFS.DiffIDs[0] != digest.FromString("base diff") || len(config.History) != 2 {
+		t.Errorf("rootfs = %+v, history = %+v, want the base's followed by ours", config.RootFS, config.History)
+	}
+}
@@ -0,0 +1,139 @@
+package build
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// matrixKeyPattern matches matrix keys that can be used as
+// {{ .Matrix.<key> }} in a template.
+var matrixKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// expandMatrix replaces each artifact that has a matrix with its variants,
+// keeping config order, so that they build like separate artifacts.
+func expandMatrix(artifacts []Artifact) []Artifact {
+	var expanded []Artifact
+	for i := range artifacts {
+		expanded = append(expanded, artifacts[i].variants(i)...)
+	}
+	return expanded
+}
+
+// variants returns one copy of the artifact per combination of its matrix
+// values, or the artifact itself if it has no matrix. Keys vary in sorted
+// order, the last fastest, and values in the order they are listed. Each
+// copy is named after the artifact and its values, e.g.
+// "app[python=3.11,variant=slim]".
+func (a *Artifact) variants(index int) []Artifact {
+	if len(a.Matrix) == 0 {
+		return []Artifact{*a}
+	}
+	base := a.Name
+	if base == "" {
+		base = fmt.Sprintf("artifact[%d]", index)
+	}
+
+	keys := slices.Sorted(maps.Keys(a.Matrix))
+	combinations := []map[string]string{{}}
+	for _, k := range keys {
+		var next []map[string]string
+		for _, c := range combinations {
+			for _, v := range a.Matrix[k] {
+				combination := maps.Clone(c)
+				combination[k] = v
+				next = append(next, combination)
+			}
+		}
+		combinations = next
+	}
+
+	variants := make([]Artifact, len(combinations))
+	for i, c := range combinations {
+		pairs := make([]string, len(keys))
+		for j, k := range keys {
+			pairs[j] = k + "=" + c[k]
+		}
+		variants[i] = *a
+		variants[i].Name = base + "[" + strings.Join(pairs, ",") + "]"
+		variants[i].Matrix = nil
+		variants[i].variant = c
+		variants[i].matrixName = a.Name
+	}
+	return variants
+}
+
+// validateMatrix checks the matrix keys and values, and renders each
+// variant's templates, so that a misspelt key or variants pushing the same
+// tag are caught before anything is built.
+func (a *Artifact) validateMatrix(index int) error {
+	if len(a.Matrix) == 0 {
+		return nil
+	}
+	for _, k := range slices.Sorted(maps.Keys(a.Matrix)) {
+		if !matrixKeyPattern.MatchString(k) {
+			return fmt.Errorf("matrix key %q must be letters, digits and underscores, not starting with a digit", k)
+		}
+		values := a.Matrix[k]
+		if len(values) == 0 {
+			return fmt.Errorf("matrix.%s must have at least one value", k)
+		}
+		for i, v := range values {
+			if slices.Contains(values[:i], v) {
+				return fmt.Errorf("matrix.%s lists %q twice", k, v)
+			}
+		}
+	}
+
+	pushedBy := map[string]string{}
+	for _, v := range a.variants(index) {
+		vars := &TemplateVars{Matrix: v.variant}
+		if _, err := renderInputs(&v, vars); err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+		for i, t := range v.Targets {
+			reg, err := renderTemplate(t.Registry, vars)
+			if err != nil {
+				return fmt.Errorf("%s: target[%d].registry: failed to render %q: %w", v.Name, i, t.Registry, err)
+			}
+			for j, tagTmpl := range t.Tags {
+				tag, err := renderTemplate(tagTmpl, vars)
+				if err != nil {
+					return fmt.Errorf("%s: target[%d].tags[%d]: failed to render %q: %w", v.Name, i, j, tagTmpl, err)
+				}
+				ref := reg + ":" + tag
+				if other, ok := pushedBy[ref]; ok {
+					return fmt.Errorf("%s and %s push the same tag %q; use a matrix variable in target[%d]", other, v.Name, tagTmpl, i)
+				}
+				pushedBy[ref] = v.Name
+			}
+		}
+	}
+	return nil
+}
+
+// renderInputs returns a copy of artifact whose dockerfile, context and
+// build args are rendered with vars.
+func renderInputs(artifact *Artifact, vars *TemplateVars) (*Artifact, error) {
+	rendered := *artifact
+	var err error
+	if rendered.Dockerfile, err = renderTemplate(artifact.Dockerfile, vars); err != nil {
+		return nil, fmt.Errorf("dockerfile: failed to render %q: %w", artifact.Dockerfile, err)
+	}
+	if rendered.Context, err = renderTemplate(artifact.Context, vars); err != nil {
+		return nil, fmt.Errorf("context: failed to render %q: %w", artifact.Context, err)
+	}
+	if artifact.BuildArgs != nil {
+		rendered.BuildArgs = make(map[string]string, len(artifact.BuildArgs))
+		for k, v := range artifact.BuildArgs {
+			value, err := renderTemplate(v, vars)
+			if err != nil {
+				return nil, fmt.Errorf("buildArgs[%s]: failed to render %q: %w", k, v, err)
+			}
+			rendered.BuildArgs[k] = value
+		}
+	}
+	return &rendered, nil
+}
@@ -0,0 +1,136 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariants(t *testing.T) {
+	a := &Artifact{
+		Name:   "app",
+		Matrix: map[string][]string{"variant": {"slim", "full"}, "python": {"3.11", "3.12"}},
+	}
+	variants := a.variants(0)
+	var names []string
+	for _, v := range variants {
+		names = append(names, v.Name)
+		if v.Matrix != nil || v.matrixName != "app" {
+			t.Errorf("%s: matrix = %v, matrixName = %q", v.Name, v.Matrix, v.matrixName)
+		}
+	}
+	want := []string{
+		"app[python=3.11,variant=slim]",
+		"app[python=3.11,variant=full]",
+		"app[python=3.12,variant=slim]",
+		"app[python=3.12,variant=full]",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("variants = %q\nwant %q", names, want)
+	}
+	if want := map[string]string{"python": "3.12", "variant": "slim"}; !reflect.DeepEqual(variants[2].variant, want) {
+		t.Errorf("variant values = %v, want %v", variants[2].variant, want)
+	}
+
+	unnamed := &Artifact{Matrix: map[string][]string{"os": {"linux"}}}
+	if got := unnamed.variants(2)[0].Name; got != "artifact[2][os=linux]" {
+		t.Errorf("unnamed variant = %q", got)
+	}
+	plain := &Artifact{Name: "plain"}
+	if got := plain.variants(0); len(got) != 1 || got[0].Name != "plain" || got[0].variant != nil {
+		t.Errorf("variants without a matrix = %+v", got)
+	}
+}
+
+func TestRenderInputs(t *testing.T) {
+	artifact := &Artifact{
+		Dockerfile: "docker/{{ .Matrix.variant }}.Dockerfile",
+		Context:    "{{ .Matrix.variant }}",
+		BuildArgs:  map[string]string{"PYTHON": "{{ .Matrix.python }}", "TAG": "{{ .Tag }}"},
+	}
+	vars := &TemplateVars{Tag: "v1", Matrix: map[string]string{"variant": "slim", "python": "3.12"}}
+
+	rendered, err := renderInputs(artifact, vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Dockerfile != "docker/slim.Dockerfile" || rendered.Context != "slim" {
+		t.Errorf("dockerfile = %q, context = %q", rendered.Dockerfile, rendered.Context)
+	}
+	if want := map[string]string{"PYTHON": "3.12", "TAG": "v1"}; !reflect.DeepEqual(rendered.BuildArgs, want) {
+		t.Errorf("buildArgs = %v, want %v", rendered.BuildArgs, want)
+	}
+	if artifact.Context != "{{ .Matrix.variant }}" {
+		t.Error("renderInputs modified the artifact")
+	}
+
+	if _, err := renderInputs(artifact, &TemplateVars{}); err == nil || !strings.Contains(err.Error(), "dockerfile") {
+		t.Errorf("error without matrix values = %v", err)
+	}
+}
+
+func TestBuildMatrix(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "slim.json"), "{}", 0644)
+	cfg := &Config{Version: 1, Artifacts: []Artifact{
+		{
+			Name:      "docs",
+			Type:      TypeArtifact,
+			Files:     []FileEntry{{Path: "slim.json"}},
+			DependsOn: []string{"config"},
+			Targets:   []Target{{Registry: "registry.example.com/docs", Tags: []string{"v1"}}},
+		},
+		{
+			Name:    "config",
+			Type:    TypeArtifact,
+			Matrix:  map[string][]string{"variant": {"slim", "full"}},
+			Files:   []FileEntry{{Path: "slim.json"}},
+			Targets: []Target{{Registry: "registry.example.com/config", Tags: []string{"v1-{{ .Matrix.variant }}"}}},
+		},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	build := func(filter string) ([]string, string) {
+		t.Helper()
+		var out bytes.Buffer
+		b := NewBuilder(cfg, filepath.Join(dir, ".lazy"), BuilderOptions{ArtifactFilter: filter, DryRun: true, Output: &out})
+		results, err := b.Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range results {
+			if r.Error != "" {
+				t.Fatalf("%s: %s", r.Name, r.Error)
+			}
+			got = append(got, r.Name+" "+r.Targets[0].Reference)
+		}
+		return got, out.String()
+	}
+
+	got, log := build("")
+	want := []string{
+		"docs registry.example.com/docs:v1",
+		"config[variant=slim] registry.example.com/config:v1-slim",
+		"config[variant=full] registry.example.com/config:v1-full",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("results = %q\nwant %q", got, want)
+	}
+	// Depending on a matrix artifact waits for every variant
+	if strings.Index(log, "Building docs") < strings.Index(log, "Building config[variant=full]") {
+		t.Errorf("docs built before all config variants:\n%s", log)
+	}
+
+	if got, _ := build("config"); len(got) != 2 {
+		t.Errorf("filter by name = %q, want both variants", got)
+	}
+	if got, _ := build("config[variant=full]"); !reflect.DeepEqual(got, want[2:]) {
+		t.Errorf("filter by variant = %q, want %q", got, want[2:])
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
 	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
@@ -21,22 +22,9 @@ func (b *Builder) pushToTarget(ctx context.Context, ociLayoutPath, tag string, t
 		return nil, fmt.Errorf("failed to open OCI layout: %w", err)
 	}
 
-	// Parse the target as a reference
-	parsed, err := ociutil.ParseReference(ref)
-	if err != nil {
-		return nil, fmt.Errorf("invalid target reference %q: %w", ref, err)
-	}
-
-	// Resolve credentials for this registry
-	var credFn auth.CredentialFunc
-	if b.opts.CredentialFunc != nil {
-		credFn = b.opts.CredentialFunc(parsed.Registry)
-	}
-
-	// Create remote repository
-	remoteRepo, err := ociutil.NewRemoteRepository(parsed, b.opts.Insecure, credFn)
+	remoteRepo, err := b.remoteRepository(ref)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create remote repository: %w", err)
+		return nil, err
 	}
 
 	// We need to determine the source tag in the local OCI store.
@@ -54,8 +42,38 @@ func (b *Builder) pushToTarget(ctx context.Context, ociLayoutPath, tag string, t
 		return nil, fmt.Errorf("OCI layout has no manifests")
 	}
 
-	// Copy from local store to remote, using the first manifest's digest as source
-	srcDigest := index.Manifests[0].Digest
+	// Copy from local store to remote, using the tagged manifest's digest as
+	// source. Multi-platform layouts also list their platform manifests.
+	srcDigest := index.TaggedManifest().Digest
+	root, err := store.Resolve(ctx, srcDigest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s in OCI layout: %w", srcDigest, err)
+	}
+
+	// Skip the copy when the registry already has the manifest. Lookup
+	// errors fall through to the copy, which reports them.
+	if current, err := remoteRepo.Resolve(ctx, tag); err == nil && current.Digest == root.Digest {
+		b.logf("  %s is up to date (digest: %s)\n", ref, root.Digest)
+		return &TargetResult{
+			Reference: ref,
+			Digest:    root.Digest.String(),
+			Pushed:    true,
+			Skipped:   true,
+		}, nil
+	}
+	if exists, err := remoteRepo.Exists(ctx, root); err == nil && exists {
+		if err := remoteRepo.Tag(ctx, root, tag); err != nil {
+			return nil, fmt.Errorf("retag failed: %w", err)
+		}
+		b.logf("  Retagged %s (digest: %s)\n", ref, root.Digest)
+		return &TargetResult{
+			Reference: ref,
+			Digest:    root.Digest.String(),
+			Pushed:    true,
+			Retagged:  true,
+		}, nil
+	}
+
 	desc, err := oras.Copy(ctx, store, srcDigest, remoteRepo, tag, oras.CopyOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("push failed: %w", err)
@@ -69,3 +87,23 @@ func (b *Builder) pushToTarget(ctx context.Context, ociLayoutPath, tag string, t
 		Pushed:    true,
 	}, nil
 }
+
+// remoteRepository returns the repository of a target reference, with
+// credentials for its registry.
+func (b *Builder) remoteRepository(ref string) (*remote.Repository, error) {
+	parsed, err := ociutil.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target reference %q: %w", ref, err)
+	}
+
+	var credFn auth.CredentialFunc
+	if b.opts.CredentialFunc != nil {
+		credFn = b.opts.CredentialFunc(parsed.Registry)
+	}
+
+	repo, err := ociutil.NewRemoteRepository(parsed, b.opts.Insecure, credFn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote repository: %w", err)
+	}
+	return repo, nil
+}
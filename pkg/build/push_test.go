@@ -0,0 +1,64 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+func TestPushToTargetExisting(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "bin", "app"), "binary", 0755)
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{Quiet: true, Insecure: true})
+	layout, err := b.buildLayers(context.Background(), &Artifact{
+		Type:      TypeLayers,
+		Platforms: []string{"linux/amd64"},
+		Layers:    []LayerEntry{{Src: "bin/app", Dest: "/usr/bin/app"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(layout)
+
+	root := rootDescriptor(t, layout)
+	data, err := os.ReadFile(filepath.Join(layout, "blobs", "sha256", root.Digest.Encoded()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests := map[string][]byte{
+		root.Digest.String(): data,
+		"v1":                 data,
+	}
+	host := fakeRegistry(t, "app", manifests, map[digest.Digest][]byte{})
+	target := Target{Registry: host + "/app"}
+
+	tests := []struct {
+		tag      string
+		skipped  bool
+		retagged bool
+	}{
+		{tag: "v1", skipped: true},
+		{tag: "v2", retagged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			tr, err := b.pushToTarget(context.Background(), layout, tt.tag, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tr.Skipped != tt.skipped || tr.Retagged != tt.retagged || !tr.Pushed {
+				t.Errorf("result = %+v, want skipped %v retagged %v", tr, tt.skipped, tt.retagged)
+			}
+			if tr.Digest != root.Digest.String() {
+				t.Errorf("digest = %s, want %s", tr.Digest, root.Digest)
+			}
+			if !bytes.Equal(manifests[tt.tag], data) {
+				t.Errorf("registry tag %s does not point at the manifest", tt.tag)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package build
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/pull"
+	"github.com/mistergrinvalds/lazyoci/pkg/sbom"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// Docker media types of images in a layout, alongside dockerManifestList.
+const (
+	dockerManifest    = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerImageConfig = "application/vnd.docker.container.image.v1+json"
+)
+
+// sbomFileName returns the title of a generated SBOM's layer.
+func sbomFileName(f sbom.Format) string {
+	if f == sbom.FormatCycloneDX {
+		return "sbom.cdx.json"
+	}
+	return "sbom.spdx.json"
+}
+
+// packSBOM generates the artifact's SBOM from its OCI layout and packs it
+// into store as a referrer of the layout's root manifest.
+func (b *Builder) packSBOM(ctx context.Context, store content.Storage, name string, artifact *Artifact, ociLayoutPath string) (ocispec.Descriptor, error) {
+	subject, err := layoutSubject(ociLayoutPath)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	layout, err := oci.NewFromFS(ctx, os.DirFS(ociLayoutPath))
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to open OCI layout: %w", err)
+	}
+
+	pkgs, err := b.catalog(ctx, layout, subject)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to generate SBOM: %w", err)
+	}
+
+	// A fixed creation time keeps the document, and so the referrer,
+	// unchanged while the artifact is
+	format := artifact.SBOM.format()
+	doc := &sbom.Document{
+		Format:   format,
+		Name:     name,
+		Created:  time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Packages: pkgs,
+	}
+	data, err := sbom.Encode(doc)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to encode SBOM: %w", err)
+	}
+
+	desc, err := packReferrer(ctx, store, subject, format.MediaType(), format.MediaType(), sbomFileName(format), data, nil)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to pack SBOM: %w", err)
+	}
+	b.logf("  Generated SBOM (%s, %d packages, digest: %s)\n", format, len(pkgs), desc.Digest)
+	return desc, nil
+}
+
+// catalog lists the packages of the manifest or index desc. Images are
+// read layer by layer for OS packages and Go binaries; other artifacts list
+// their files. The platforms of an index are merged, leaving out
+// attestation manifests.
+func (b *Builder) catalog(ctx context.Context, store content.ReadOnlyStorage, desc ocispec.Descriptor) ([]sbom.Package, error) {
+	data, err := content.FetchAll(ctx, store, desc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", desc.Digest, err)
+	}
+
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageIndex, dockerManifestList:
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("failed to parse index: %w", err)
+		}
+		var pkgs []sbom.Package
+		for _, m := range index.Manifests {
+			if m.Platform != nil && m.Platform.OS == "unknown" {
+				continue
+			}
+			found, err := b.catalog(ctx, store, m)
+			if err != nil {
+				if m.Platform != nil {
+					return nil, fmt.Errorf("%s: %w", pull.PlatformString(*m.Platform), err)
+				}
+				return nil, err
+			}
+			pkgs = append(pkgs, found...)
+		}
+		return sbom.Unique(pkgs), nil
+
+	case ocispec.MediaTypeImageManifest, dockerManifest:
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		if manifest.Config.MediaType == ocispec.MediaTypeImageConfig || manifest.Config.MediaType == dockerImageConfig {
+			return b.catalogImage(ctx, store, manifest.Layers)
+		}
+		return catalogFiles(manifest.Layers), nil
+	}
+	return nil, fmt.Errorf("unsupported manifest type %s", desc.MediaType)
+}
+
+// catalogImage reads an image's layers into a Cataloger. Package databases
+// that cannot be read are reported as a warning, so the SBOM still lists
+// everything else.
+func (b *Builder) catalogImage(ctx context.Context, store content.ReadOnlyStorage, layers []ocispec.Descriptor) ([]sbom.Package, error) {
+	c := sbom.NewCataloger()
+	for _, layer := range layers {
+		if err := addLayer(ctx, c, store, layer); err != nil {
+			return nil, fmt.Errorf("layer %s: %w", layer.Digest, err)
+		}
+	}
+	pkgs, err := c.Packages()
+	if err != nil {
+		b.logf("  Warning: SBOM is incomplete: %v\n", err)
+	}
+	return pkgs, nil
+}
+
+// addLayer uncompresses a layer into c.
+func addLayer(ctx context.Context, c *sbom.Cataloger, store content.Fetcher, layer ocispec.Descriptor) error {
+	rc, err := store.Fetch(ctx, layer)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var r io.Reader
+	switch {
+	case strings.HasSuffix(layer.MediaType, "gzip"):
+		zr, err := gzip.NewReader(rc)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	case strings.HasSuffix(layer.MediaType, "tar"):
+		r = rc
+	default:
+		return fmt.Errorf("unsupported layer media type %s", layer.MediaType)
+	}
+	return c.AddLayer(r)
+}
+
+// catalogFiles lists the layers of a non-image artifact as files, by title
+// and digest.
+func catalogFiles(layers []ocispec.Descriptor) []sbom.Package {
+	var pkgs []sbom.Package
+	for _, layer := range layers {
+		name := layer.Annotations[ocispec.AnnotationTitle]
+		if name == "" {
+			name = layer.Digest.String()
+		}
+		pkgs = append(pkgs, sbom.Package{Name: name, Digest: layer.Digest.String()})
+	}
+	return pkgs
+}
@@ -0,0 +1,107 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/sbom"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// pushedSBOM builds artifact, pushing to the fake registry at host, and
+// returns the SBOM referrer and its decoded document.
+func pushedSBOM(t *testing.T, dir string, artifact *Artifact, manifests map[string][]byte, blobs map[digest.Digest][]byte) (ocispec.Manifest, *sbom.Document) {
+	t.Helper()
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{Quiet: true, Push: true, Insecure: true})
+	result, err := b.buildOne(context.Background(), artifact, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Referrers) != 1 || len(result.Targets) != 1 {
+		t.Fatalf("result = %+v, want one target and one referrer", result)
+	}
+
+	var referrer ocispec.Manifest
+	if err := json.Unmarshal(manifests[result.Referrers[0].Digest], &referrer); err != nil {
+		t.Fatalf("referrer %s: %v", result.Referrers[0].Digest, err)
+	}
+	if referrer.Subject == nil || referrer.Subject.Digest.String() != result.Targets[0].Digest {
+		t.Errorf("referrer subject = %v, want %s", referrer.Subject, result.Targets[0].Digest)
+	}
+	if referrer.ArtifactType != result.Referrers[0].ArtifactType || len(referrer.Layers) != 1 {
+		t.Fatalf("referrer = %+v", referrer)
+	}
+	doc, err := sbom.Parse(blobs[referrer.Layers[0].Digest])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return referrer, doc
+}
+
+func TestSBOMLayers(t *testing.T) {
+	dir := t.TempDir()
+	for _, arch := range []string{"amd64", "arm64"} {
+		writeFile(t, filepath.Join(dir, "rootfs-"+arch, "etc", "os-release"), "ID=debian\nVERSION_ID=\"12\"\n", 0644)
+		writeFile(t, filepath.Join(dir, "rootfs-"+arch, "var", "lib", "dpkg", "status"),
+			"Package: base-files\nStatus: install ok installed\nVersion: 12.4\nArchitecture: "+arch+"\n\n"+
+				"Package: tzdata\nStatus: install ok installed\nVersion: 2024a-0\nArchitecture: all\n", 0644)
+	}
+	manifests := map[string][]byte{}
+	blobs := map[digest.Digest][]byte{}
+	host := fakeRegistry(t, "app", manifests, blobs)
+
+	artifact := &Artifact{
+		Type:      TypeLayers,
+		Name:      "app",
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+		Layers:    []LayerEntry{{Src: "rootfs-{{ .Arch }}", Dest: "/"}},
+		Targets:   []Target{{Registry: host + "/app", Tags: []string{"v1"}}},
+		SBOM:      &SBOMConfig{},
+	}
+	referrer, doc := pushedSBOM(t, dir, artifact, manifests, blobs)
+
+	if referrer.ArtifactType != sbom.MediaTypeSPDX || referrer.Layers[0].Annotations[ocispec.AnnotationTitle] != "sbom.spdx.json" {
+		t.Errorf("referrer = %+v", referrer)
+	}
+	if doc.Format != sbom.FormatSPDX || doc.Name != "app" {
+		t.Errorf("document = %+v", doc)
+	}
+	// tzdata is the same package on both platforms
+	want := []sbom.Package{
+		{Name: "base-files", Version: "12.4", PURL: "pkg:deb/debian/base-files@12.4?arch=amd64&distro=debian-12"},
+		{Name: "base-files", Version: "12.4", PURL: "pkg:deb/debian/base-files@12.4?arch=arm64&distro=debian-12"},
+		{Name: "tzdata", Version: "2024a-0", PURL: "pkg:deb/debian/tzdata@2024a-0?arch=all&distro=debian-12"},
+	}
+	if !reflect.DeepEqual(doc.Packages, want) {
+		t.Errorf("Packages = %+v\nwant %+v", doc.Packages, want)
+	}
+}
+
+func TestSBOMArtifact(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.yaml"), "replicas: 3", 0644)
+	manifests := map[string][]byte{}
+	blobs := map[digest.Digest][]byte{}
+	host := fakeRegistry(t, "config", manifests, blobs)
+
+	artifact := &Artifact{
+		Type:    TypeArtifact,
+		Name:    "config",
+		Files:   []FileEntry{{Path: "config.yaml", MediaType: "application/yaml"}},
+		Targets: []Target{{Registry: host + "/config", Tags: []string{"v1"}}},
+		SBOM:    &SBOMConfig{Format: "cyclonedx"},
+	}
+	referrer, doc := pushedSBOM(t, dir, artifact, manifests, blobs)
+
+	if referrer.ArtifactType != sbom.MediaTypeCycloneDX || referrer.Layers[0].Annotations[ocispec.AnnotationTitle] != "sbom.cdx.json" {
+		t.Errorf("referrer = %+v", referrer)
+	}
+	want := []sbom.Package{{Name: "config.yaml", Digest: digest.FromString("replicas: 3").String()}}
+	if doc.Format != sbom.FormatCycloneDX || !reflect.DeepEqual(doc.Packages, want) {
+		t.Errorf("document = %+v, want packages %+v", doc, want)
+	}
+}
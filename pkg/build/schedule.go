@@ -0,0 +1,193 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// buildAll builds artifacts, each after the ones it depends on, and returns
+// their results in the same order. Dependencies that are not among
+// artifacts (filtered out with --artifact) are assumed to be built already.
+func (b *Builder) buildAll(ctx context.Context, artifacts []Artifact) []BuildResult {
+	parallel := b.opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	byName := artifactIndexes(artifacts)
+
+	results := make([]BuildResult, len(artifacts))
+	failed := make([]bool, len(artifacts))
+	done := make([]chan struct{}, len(artifacts))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var logMu sync.Mutex
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+
+	run := func(i int) {
+		defer close(done[i])
+		a := &artifacts[i]
+		name := a.Name
+		if name == "" {
+			name = fmt.Sprintf("artifact[%d]", i)
+		}
+
+		ab := b
+		if parallel > 1 {
+			pw := &prefixWriter{w: b.opts.Output, prefix: "[" + name + "] ", mu: &logMu}
+			defer pw.Flush()
+			copied := *b
+			copied.opts.Output = pw
+			ab = &copied
+		}
+
+		for _, dep := range a.DependsOn {
+			for _, j := range byName[dep] {
+				<-done[j]
+				if !failed[j] {
+					continue
+				}
+				ab.logf("Skipping %s: dependency %q failed\n", name, dep)
+				results[i] = BuildResult{
+					Name:    a.Name,
+					Type:    a.Type,
+					Error:   fmt.Sprintf("dependency %q failed", dep),
+					Skipped: true,
+				}
+				failed[i] = true
+				return
+			}
+		}
+
+		sem <- struct{}{}
+		defer func() { <-sem }()
+
+		result, err := ab.buildOne(ctx, a, i)
+		if err != nil {
+			results[i] = BuildResult{
+				Name:  a.Name,
+				Type:  a.Type,
+				Error: err.Error(),
+			}
+			failed[i] = true
+			return
+		}
+		results[i] = *result
+	}
+
+	for _, i := range buildOrder(artifacts) {
+		if parallel == 1 {
+			run(i)
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// buildOrder returns the indexes of artifacts ordered so that each comes
+// after the artifacts among them it depends on, and otherwise in config
+// order. Artifacts left in a cycle, which Validate rejects, come last.
+func buildOrder(artifacts []Artifact) []int {
+	byName := artifactIndexes(artifacts)
+
+	placed := make([]bool, len(artifacts))
+	ready := func(i int) bool {
+		for _, dep := range artifacts[i].DependsOn {
+			for _, j := range byName[dep] {
+				if !placed[j] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	var order []int
+	for len(order) < len(artifacts) {
+		next := -1
+		for i := range artifacts {
+			if !placed[i] && ready(i) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			for i := range artifacts {
+				if !placed[i] {
+					order = append(order, i)
+				}
+			}
+			break
+		}
+		placed[next] = true
+		order = append(order, next)
+	}
+	return order
+}
+
+// artifactIndexes maps the names dependsOn may refer to onto the indexes
+// of artifacts: each artifact's name and, for matrix variants, the name of
+// the artifact they were expanded from, which depends on all of them.
+func artifactIndexes(artifacts []Artifact) map[string][]int {
+	byName := map[string][]int{}
+	for i, a := range artifacts {
+		if a.matrixName != "" {
+			byName[a.matrixName] = append(byName[a.matrixName], i)
+		} else if a.Name != "" {
+			byName[a.Name] = []int{i}
+		}
+	}
+	return byName
+}
+
+// prefixWriter prefixes every line written to w, so that the output of
+// artifacts building in parallel can be told apart. Whole lines are written
+// under mu, which all of them share.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(p.w, "%s%s", p.prefix, p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush writes a final line that did not end in a newline.
+func (p *prefixWriter) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buf) > 0 {
+		fmt.Fprintf(p.w, "%s%s\n", p.prefix, p.buf)
+		p.buf = nil
+	}
+}
@@ -0,0 +1,117 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		artifacts []Artifact
+		want      []int
+	}{
+		{
+			name:      "config order without dependencies",
+			artifacts: []Artifact{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			want:      []int{0, 1, 2},
+		},
+		{
+			name: "dependencies first",
+			artifacts: []Artifact{
+				{Name: "app", DependsOn: []string{"lib"}},
+				{Name: "docs"},
+				{Name: "lib", DependsOn: []string{"base"}},
+				{Name: "base"},
+			},
+			want: []int{1, 3, 2, 0},
+		},
+		{
+			name:      "filtered-out dependency",
+			artifacts: []Artifact{{Name: "app", DependsOn: []string{"lib"}}, {Name: "docs"}},
+			want:      []int{0, 1},
+		},
+		{
+			name: "cycle last",
+			artifacts: []Artifact{
+				{Name: "a", DependsOn: []string{"b"}},
+				{Name: "b", DependsOn: []string{"a"}},
+				{Name: "c"},
+			},
+			want: []int{2, 0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildOrder(tt.artifacts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildDependencies(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "data.json"), "{}", 0644)
+	target := []Target{{Registry: "registry.example.com/data", Tags: []string{"v1"}}}
+	file := []FileEntry{{Path: "data.json", MediaType: "application/json"}}
+	cfg := &Config{Version: 1, Artifacts: []Artifact{
+		{Name: "broken", Type: TypeArtifact, Files: []FileEntry{{Path: "missing.json", MediaType: "application/json"}}, Targets: target},
+		{Name: "dependent", Type: TypeArtifact, Files: file, DependsOn: []string{"broken"}, Targets: target},
+		{Name: "transitive", Type: TypeArtifact, Files: file, DependsOn: []string{"dependent"}, Targets: target},
+		{Name: "app", Type: TypeArtifact, Files: file, DependsOn: []string{"lib"}, Targets: target},
+		{Name: "lib", Type: TypeArtifact, Files: file, Targets: target},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, parallel := range []int{1, 3} {
+		var out bytes.Buffer
+		b := NewBuilder(cfg, filepath.Join(dir, ".lazy"), BuilderOptions{Parallel: parallel, Output: &out})
+		results, err := b.Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, r := range results {
+			status := "ok"
+			switch {
+			case r.Skipped:
+				status = "skipped: " + r.Error
+			case r.Error != "":
+				status = "failed"
+			}
+			got = append(got, r.Name+" "+status)
+		}
+		want := []string{
+			"broken failed",
+			`dependent skipped: dependency "broken" failed`,
+			`transitive skipped: dependency "dependent" failed`,
+			"app ok",
+			"lib ok",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parallel %d: results = %q, want %q", parallel, got, want)
+		}
+
+		log := out.String()
+		if strings.Index(log, "Building lib") > strings.Index(log, "Building app") {
+			t.Errorf("parallel %d: app built before lib:\n%s", parallel, log)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(log), "\n") {
+			if prefixed := strings.HasPrefix(line, "["); prefixed != (parallel > 1) {
+				t.Errorf("parallel %d: line %q, want a prefix only when building in parallel", parallel, line)
+			}
+		}
+		if parallel > 1 && !strings.Contains(log, "[lib] Building lib") {
+			t.Errorf("parallel %d: log lacks the artifact prefix:\n%s", parallel, log)
+		}
+	}
+}
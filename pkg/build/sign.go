@@ -0,0 +1,42 @@
+package build
+
+import (
+	"context"
+	"os"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/sign"
+)
+
+// signTarget signs the root manifest of an OCI layout, as pushed to
+// target, with the target's cosign key. It returns the digest of the
+// manifest holding the signature.
+func (b *Builder) signTarget(ctx context.Context, ociLayoutPath string, target Target) (string, error) {
+	mode, err := sign.ParseMode(target.Sign.Mode)
+	if err != nil {
+		return "", err
+	}
+	signer, err := sign.LoadKey(b.resolvePath(target.Sign.Key), []byte(os.Getenv(sign.PasswordEnv)))
+	if err != nil {
+		return "", err
+	}
+	subject, err := layoutSubject(ociLayoutPath)
+	if err != nil {
+		return "", err
+	}
+	remoteRepo, err := b.remoteRepository(target.Registry)
+	if err != nil {
+		return "", err
+	}
+
+	identity := sign.DockerReference(remoteRepo.Reference.Registry, remoteRepo.Reference.Repository)
+	result, err := sign.Sign(ctx, remoteRepo, identity, subject, signer, mode)
+	if err != nil {
+		return "", err
+	}
+	if result.Unchanged {
+		b.logf("  %s@%s is already signed\n", target.Registry, subject.Digest)
+	} else {
+		b.logf("  Signed %s@%s (%s, signature: %s)\n", target.Registry, subject.Digest, mode, result.Signature.Digest)
+	}
+	return result.Signature.Digest.String(), nil
+}
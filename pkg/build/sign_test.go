@@ -0,0 +1,79 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/sign"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestSignTarget(t *testing.T) {
+	dir := t.TempDir()
+	key, err := os.ReadFile("../../testdata/fixtures/cosign.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "keys", "cosign.key"), string(key), 0600)
+	writeFile(t, filepath.Join(dir, "config.yaml"), "replicas: 3", 0644)
+	t.Setenv(sign.PasswordEnv, "lazyoci")
+
+	manifests := map[string][]byte{}
+	blobs := map[digest.Digest][]byte{}
+	host := fakeRegistry(t, "config", manifests, blobs)
+	artifact := &Artifact{
+		Type:  TypeArtifact,
+		Name:  "config",
+		Files: []FileEntry{{Path: "config.yaml", MediaType: "application/yaml"}},
+		Targets: []Target{{
+			Registry: host + "/config",
+			Tags:     []string{"v1", "latest"},
+			Sign:     &SignConfig{Key: "keys/cosign.key"},
+		}},
+	}
+	b := NewBuilder(&Config{}, filepath.Join(dir, ".lazy"), BuilderOptions{Quiet: true, Push: true, Insecure: true})
+
+	result, err := b.buildOne(context.Background(), artifact, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Targets) != 2 {
+		t.Fatalf("targets = %+v", result.Targets)
+	}
+	subject := digest.Digest(result.Targets[0].Digest)
+	data, ok := manifests[sign.SignatureTag(subject)]
+	if !ok {
+		t.Fatalf("no %s tag", sign.SignatureTag(subject))
+	}
+	signature := digest.FromBytes(data).String()
+	for _, tr := range result.Targets {
+		if tr.Signature != signature {
+			t.Errorf("%s signature = %q, want %s", tr.Reference, tr.Signature, signature)
+		}
+	}
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Layers) != 1 || manifest.Layers[0].MediaType != sign.MediaTypeSimpleSigning {
+		t.Fatalf("signature layers = %+v", manifest.Layers)
+	}
+	want, _ := sign.Payload(host+"/config", subject)
+	if got := blobs[manifest.Layers[0].Digest]; string(got) != string(want) {
+		t.Errorf("payload = %s, want %s", got, want)
+	}
+
+	// Building again finds the signature and leaves it alone
+	again, err := b.buildOne(context.Background(), artifact, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Targets[0].Signature != signature {
+		t.Errorf("second build signature = %s, want %s", again.Targets[0].Signature, signature)
+	}
+}
@@ -42,6 +42,10 @@ type Config struct {
 
 	// Mode controls dark/light mode: "auto", "dark", or "light"
 	Mode string `yaml:"mode,omitempty"`
+
+	// VulnDB is a directory of OSV vulnerability records used by
+	// `lazyoci scan` and the TUI vulnerability summary
+	VulnDB string `yaml:"vulnDB,omitempty"`
 }
 
 // Registry represents an OCI registry configuration
@@ -225,6 +229,15 @@ func (c *Config) GetArtifactDir() string {
 	return DefaultArtifactDir()
 }
 
+// GetVulnDB returns the OSV database directory, with $LAZYOCI_VULN_DB
+// taking priority over the config file. It is empty when unset.
+func (c *Config) GetVulnDB() string {
+	if envDir := os.Getenv("LAZYOCI_VULN_DB"); envDir != "" {
+		return ExpandPath(envDir)
+	}
+	return ExpandPath(c.VulnDB)
+}
+
 // DefaultArtifactDir returns the default artifact directory.
 func DefaultArtifactDir() string {
 	homeDir, _ := os.UserHomeDir()
@@ -229,6 +229,25 @@ func TestGetArtifactDirPriority(t *testing.T) {
 	})
 }
 
+func TestGetVulnDB(t *testing.T) {
+	t.Setenv("LAZYOCI_VULN_DB", "")
+
+	if got := (&Config{}).GetVulnDB(); got != "" {
+		t.Errorf("GetVulnDB() unset = %q, want empty", got)
+	}
+
+	cfg := &Config{VulnDB: "~/osv"}
+	homeDir, _ := os.UserHomeDir()
+	if got, want := cfg.GetVulnDB(), filepath.Join(homeDir, "osv"); got != want {
+		t.Errorf("GetVulnDB() = %q, want %q", got, want)
+	}
+
+	t.Setenv("LAZYOCI_VULN_DB", "/env/osv")
+	if got := cfg.GetVulnDB(); got != "/env/osv" {
+		t.Errorf("GetVulnDB() with env = %q, want %q", got, "/env/osv")
+	}
+}
+
 func TestRegistryCRUD(t *testing.T) {
 	cfg := &Config{
 		Registries: []Registry{
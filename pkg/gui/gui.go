@@ -1,16 +1,24 @@
 package gui
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"strings"
+	"sync"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mistergrinvalds/lazyoci/pkg/artifacts"
 	"github.com/mistergrinvalds/lazyoci/pkg/cache"
 	"github.com/mistergrinvalds/lazyoci/pkg/config"
 	"github.com/mistergrinvalds/lazyoci/pkg/gui/keybindings"
 	"github.com/mistergrinvalds/lazyoci/pkg/gui/theme"
 	"github.com/mistergrinvalds/lazyoci/pkg/gui/views"
+	"github.com/mistergrinvalds/lazyoci/pkg/osv"
+	"github.com/mistergrinvalds/lazyoci/pkg/preview"
 	"github.com/mistergrinvalds/lazyoci/pkg/pull"
 	"github.com/mistergrinvalds/lazyoci/pkg/registry"
 	"github.com/rivo/tview"
@@ -23,6 +31,7 @@ type GUI struct {
 	registry *registry.Client
 	cache    *cache.Cache
 	config   *config.Config
+	handlers *artifacts.Registry
 
 	// Views
 	registryView  *views.RegistryView
@@ -50,6 +59,10 @@ func New(reg *registry.Client, c *cache.Cache, cfg *config.Config) (*GUI, error)
 		registry: reg,
 		cache:    c,
 		config:   cfg,
+		handlers: artifacts.NewRegistry(artifacts.Options{
+			Fetcher: reg,
+			VulnDB:  vulnDBLoader(cfg),
+		}),
 	}
 
 	g.setupViews()
@@ -59,6 +72,18 @@ func New(reg *registry.Client, c *cache.Cache, cfg *config.Config) (*GUI, error)
 	return g, nil
 }
 
+// vulnDBLoader returns a loader for the configured OSV database, or nil when
+// none is configured. The database is read once, on first use.
+func vulnDBLoader(cfg *config.Config) artifacts.VulnDBFunc {
+	dir := cfg.GetVulnDB()
+	if dir == "" {
+		return nil
+	}
+	return sync.OnceValues(func() (*osv.DB, error) {
+		return osv.Load(dir)
+	})
+}
+
 // Run starts the GUI event loop
 func (g *GUI) Run() error {
 	return g.app.SetRoot(g.pages, true).EnableMouse(true).Run()
@@ -103,6 +128,10 @@ func (g *GUI) setupViews() {
 	g.detailsView.SetOnPull(g.showPullModal)
 	g.detailsView.SetOnPullDirect(g.executePullDirect)
 
+	// Keys bound by artifact handlers run their actions from the details
+	// panel; in the artifact list, typing filters
+	g.detailsView.SetOnActionKey(g.onArtifactActionKey)
+
 	g.statusBar = tview.NewTextView().
 		SetDynamicColors(true)
 	g.applyStatusBarTheme()
@@ -316,7 +345,7 @@ func (g *GUI) ShowHelp() {
 		AddItem(nil, 0, 1, false).
 		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
 			AddItem(nil, 0, 1, false).
-			AddItem(textView, 26, 1, true).
+			AddItem(textView, 27, 1, true).
 			AddItem(nil, 0, 1, false), 55, 1, true).
 		AddItem(nil, 0, 1, false)
 	flex.SetBackgroundColor(theme.BackgroundColor())
@@ -475,6 +504,9 @@ func (g *GUI) onRegistrySelected(registryURL string) {
 	g.searchView.SetRegistry(registryURL)
 	g.detailsView.ShowRegistryInfo(registryURL)
 	g.app.SetFocus(g.searchView.InputField)
+	if registryURL == registry.LocalRegistryURL {
+		g.searchView.Search("")
+	}
 }
 
 // onSearchResultSelected - Enter in (2) → load artifacts and move to (3)
@@ -492,7 +524,208 @@ func (g *GUI) onArtifactSelected(artifact *registry.Artifact) {
 
 // onArtifactSelectedWithInfo - called when artifact info is resolved
 func (g *GUI) onArtifactSelectedWithInfo(artifact *registry.Artifact, info *registry.ArtifactInfo) {
-	g.detailsView.ShowArtifactWithInfo(artifact, info)
+	if info == nil {
+		g.detailsView.ShowArtifactWithInfo(artifact, nil, nil)
+		return
+	}
+
+	resolved := artifacts.WithInfo(artifact, info)
+	handler := g.handlers.Get(resolved)
+	g.detailsView.ShowArtifactWithInfo(artifact, info, handler.GetActions(resolved))
+	g.loadDetails(artifact, handler, resolved)
+}
+
+// loadDetails asks the artifact's handler for details in the background
+// and hands the result to the details view
+func (g *GUI) loadDetails(artifact *registry.Artifact, handler artifacts.Handler, resolved *registry.Artifact) {
+	go func() {
+		details, err := handler.GetDetails(resolved)
+
+		g.app.QueueUpdateDraw(func() {
+			g.detailsView.SetDetails(artifact, details, err)
+		})
+	}()
+}
+
+// onArtifactActionKey runs the handler action bound to key, if any.
+// Artifacts whose type is not yet resolved have no bound actions.
+func (g *GUI) onArtifactActionKey(artifact *registry.Artifact, info *registry.ArtifactInfo, key rune) bool {
+	if info == nil {
+		return false
+	}
+
+	resolved := artifacts.WithInfo(artifact, info)
+	handler := g.handlers.Get(resolved)
+	for _, action := range handler.GetActions(resolved) {
+		if action.Key != key || strings.TrimSpace(action.Command) == "" {
+			continue
+		}
+		// File previews open in the TUI rather than in a suspended terminal
+		if lister, ok := handler.(artifacts.FileLister); ok && strings.HasPrefix(action.Command, "lazyoci cat ") {
+			g.showFilePicker(resolved, lister)
+			return true
+		}
+		if action.Dangerous {
+			g.confirmAction(action)
+		} else {
+			g.runAction(action)
+		}
+		return true
+	}
+	return false
+}
+
+// previewLimit caps how much of a file the TUI preview renders
+const previewLimit = 1 << 20
+
+// showFilePicker lists an artifact's files in the background and opens a
+// picker; choosing a file previews it
+func (g *GUI) showFilePicker(artifact *registry.Artifact, lister artifacts.FileLister) {
+	g.statusBar.SetText(fmt.Sprintf("%sLoading files...%s", theme.Tag("info"), theme.ResetTag()))
+
+	go func() {
+		files, err := lister.ListFiles(artifact)
+
+		g.app.QueueUpdateDraw(func() {
+			if err != nil {
+				g.statusBar.SetText(fmt.Sprintf("%sError: %v%s", theme.Tag("error"), err, theme.ResetTag()))
+				return
+			}
+			if len(files) == 0 {
+				g.statusBar.SetText(fmt.Sprintf("%sNo files in %s%s", theme.Tag("warning"), artifact.Tag, theme.ResetTag()))
+				return
+			}
+			g.updateStatus()
+
+			closePicker := func() {
+				g.modalOpen = false
+				g.pages.RemovePage("file-picker")
+				g.app.SetFocus(g.artifactView.GetTable())
+			}
+			picker := views.NewFilePicker("Files: "+artifact.Tag, files, func(file artifacts.File) {
+				g.showFilePreview(artifact, lister, file)
+			}, closePicker)
+
+			g.modalOpen = true
+			g.pages.AddPage("file-picker", picker.Flex, true, true)
+			g.app.SetFocus(picker.List)
+		})
+	}()
+}
+
+// showFilePreview streams a file into a full-screen preview, rendering at
+// most previewLimit bytes
+func (g *GUI) showFilePreview(artifact *registry.Artifact, lister artifacts.FileLister, file artifacts.File) {
+	pv := views.NewPreviewView(file, func() {
+		g.pages.RemovePage("file-preview")
+		if g.pages.HasPage("file-picker") {
+			g.app.SetFocus(g.pages)
+		} else {
+			g.modalOpen = false
+			g.app.SetFocus(g.artifactView.GetTable())
+		}
+	})
+
+	g.pages.AddPage("file-preview", pv.TextView, true, true)
+	g.app.SetFocus(pv.TextView)
+
+	go func() {
+		text, err := renderPreview(artifact, lister, file)
+
+		g.app.QueueUpdateDraw(func() {
+			if err != nil {
+				text += fmt.Sprintf("\n%sError: %s%s", theme.Tag("error"), tview.Escape(err.Error()), theme.ResetTag())
+			}
+			pv.SetContent(text)
+		})
+	}()
+}
+
+// renderPreview renders up to previewLimit bytes of a file with theme colors
+func renderPreview(artifact *registry.Artifact, lister artifacts.FileLister, file artifacts.File) (string, error) {
+	rc, err := lister.OpenFile(artifact, file)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var sb strings.Builder
+	if _, err := preview.Render(&sb, io.LimitReader(rc, previewLimit), file.Name, file.MediaType, views.ThemeStyle{}); err != nil {
+		return sb.String(), err
+	}
+
+	var probe [1]byte
+	if n, _ := rc.Read(probe[:]); n > 0 {
+		fmt.Fprintf(&sb, "\n%s(preview truncated at %d KiB; use lazyoci cat for the full file)%s",
+			theme.Tag("muted"), previewLimit>>10, theme.ResetTag())
+	}
+	return sb.String(), nil
+}
+
+// confirmAction asks before running a dangerous action
+func (g *GUI) confirmAction(action artifacts.Action) {
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("%s?\n\n%s", action.Name, action.Command)).
+		AddButtons([]string{"Run", "Cancel"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			g.modalOpen = false
+			g.pages.RemovePage("confirm-action")
+			g.app.SetFocus(g.artifactView.GetTable())
+
+			if buttonLabel == "Run" {
+				g.runAction(action)
+			}
+		})
+
+	// Apply theme to modal
+	modal.SetBackgroundColor(theme.ModalBgColor())
+	modal.SetBorderColor(theme.BorderNormalColor())
+	modal.SetTitleColor(theme.TitleColor())
+	modal.SetTextColor(theme.TextColor())
+	modal.SetButtonBackgroundColor(theme.ButtonBgColor())
+	modal.SetButtonTextColor(theme.ButtonTextColor())
+
+	g.modalOpen = true
+	g.pages.AddPage("confirm-action", modal, true, true)
+}
+
+// runAction suspends the TUI and runs an action's command in the terminal,
+// waiting for Enter before returning so its output can be read
+func (g *GUI) runAction(action artifacts.Action) {
+	var runErr error
+	g.app.Suspend(func() {
+		fmt.Printf("$ %s\n\n", action.Command)
+		runErr = actionCommand(action.Command).Run()
+		if runErr != nil {
+			fmt.Printf("\n%s failed: %v\n", action.Name, runErr)
+		}
+		fmt.Print("\nPress Enter to return to lazyoci...")
+		_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+	})
+
+	if runErr != nil {
+		g.statusBar.SetText(fmt.Sprintf("%s%s failed: %v%s", theme.Tag("error"), action.Name, runErr, theme.ResetTag()))
+	} else {
+		g.statusBar.SetText(fmt.Sprintf("%s%s finished%s", theme.Tag("success"), action.Name, theme.ResetTag()))
+	}
+}
+
+// actionCommand builds the process for an action command line. The command
+// is split on whitespace and run without a shell; a leading "lazyoci" runs
+// the current executable so actions work when lazyoci is not on PATH.
+func actionCommand(command string) *exec.Cmd {
+	args := strings.Fields(command)
+	if args[0] == "lazyoci" {
+		if exe, err := os.Executable(); err == nil {
+			args[0] = exe
+		}
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
 }
 
 // showPullModal shows a modal to confirm pulling an artifact
@@ -501,16 +734,27 @@ func (g *GUI) showPullModal(artifact *registry.Artifact) {
 		return
 	}
 
-	ref := artifact.Repository + ":" + artifact.Tag
+	ref := pullReference(artifact)
 
 	// Determine available options based on artifact type
-	// Only images can be loaded to Docker
+	// Only images can be loaded into a container engine. Docker is always
+	// offered; Podman and containerd only when their socket is present.
 	artifactType := g.detailsView.GetCurrentArtifactType()
 	isImage := artifactType == registry.ArtifactTypeImage || artifactType == registry.ArtifactTypeUnknown
 
 	var buttons []string
+	loadButtons := map[string]pull.LoadTarget{}
 	if isImage {
-		buttons = []string{"To Disk", "To Docker", "Cancel"}
+		buttons = []string{"To Disk"}
+		for _, target := range pull.LoadTargets {
+			if target != pull.LoadDocker && !pull.LoadTargetAvailable(target) {
+				continue
+			}
+			label := "To " + target.Name()
+			loadButtons[label] = target
+			buttons = append(buttons, label)
+		}
+		buttons = append(buttons, "Cancel")
 	} else {
 		buttons = []string{"Pull", "Cancel"}
 	}
@@ -529,11 +773,10 @@ func (g *GUI) showPullModal(artifact *registry.Artifact) {
 			g.pages.RemovePage("pull-modal")
 			g.app.SetFocus(g.artifactView.GetTable())
 
-			switch buttonLabel {
-			case "To Disk", "Pull":
-				g.executePull(artifact, false)
-			case "To Docker":
-				g.executePull(artifact, true)
+			if target, ok := loadButtons[buttonLabel]; ok {
+				g.executePull(artifact, target)
+			} else if buttonLabel == "To Disk" || buttonLabel == "Pull" {
+				g.executePull(artifact, "")
 			}
 		})
 
@@ -549,9 +792,10 @@ func (g *GUI) showPullModal(artifact *registry.Artifact) {
 	g.pages.AddPage("pull-modal", modal, true, true)
 }
 
-// executePull pulls an artifact in the background
-func (g *GUI) executePull(artifact *registry.Artifact, toDocker bool) {
-	ref := artifact.Repository + ":" + artifact.Tag
+// executePull pulls an artifact in the background, loading it into the
+// load target's engine unless load is empty
+func (g *GUI) executePull(artifact *registry.Artifact, load pull.LoadTarget) {
+	ref := pullReference(artifact)
 
 	g.statusBar.SetText(fmt.Sprintf("%sPulling %s...%s", theme.Tag("warning"), ref, theme.ResetTag()))
 
@@ -561,7 +805,7 @@ func (g *GUI) executePull(artifact *registry.Artifact, toDocker bool) {
 		// Check if registry is insecure
 		insecure := false
 		registryURL := ""
-		if parts := splitRepoPath(artifact.Repository); len(parts) >= 1 {
+		if parts := splitRepoPath(ref); len(parts) >= 1 {
 			registryURL = parts[0]
 			for _, r := range g.registry.GetRegistries() {
 				if r.URL == registryURL && r.Insecure {
@@ -574,9 +818,10 @@ func (g *GUI) executePull(artifact *registry.Artifact, toDocker bool) {
 		opts := pull.PullOptions{
 			Reference:    ref,
 			ArtifactBase: g.config.GetArtifactDir(),
-			ToDocker:     toDocker,
-			Quiet:        true, // No progress bars in TUI
+			Load:         load,
+			Quiet:        true, // Progress is shown in the status bar instead
 			Insecure:     insecure,
+			Progress:     g.pullProgress(ref),
 		}
 
 		// Resolve credentials for the registry
@@ -592,8 +837,8 @@ func (g *GUI) executePull(artifact *registry.Artifact, toDocker bool) {
 				g.statusBar.SetText(fmt.Sprintf("%sPull failed: %v%s", theme.Tag("error"), err, theme.ResetTag()))
 			} else {
 				msg := fmt.Sprintf("%sPulled %s to %s%s", theme.Tag("success"), ref, result.Destination, theme.ResetTag())
-				if result.LoadedToDocker {
-					msg = fmt.Sprintf("%sPulled %s and loaded into Docker%s", theme.Tag("success"), ref, theme.ResetTag())
+				if result.LoadedInto != "" {
+					msg = fmt.Sprintf("%sPulled %s and loaded into %s%s", theme.Tag("success"), ref, result.LoadedInto.Name(), theme.ResetTag())
 				}
 				g.statusBar.SetText(msg)
 			}
@@ -601,6 +846,19 @@ func (g *GUI) executePull(artifact *registry.Artifact, toDocker bool) {
 	}()
 }
 
+// pullProgress shows overall pull progress for ref in the status bar
+func (g *GUI) pullProgress(ref string) pull.ProgressListener {
+	return pull.ProgressFunc(func(e pull.ProgressEvent) {
+		if e.Kind == pull.PullFinished {
+			return
+		}
+		text := fmt.Sprintf("%sPulling %s... %s%s", theme.Tag("warning"), ref, e.Overall, theme.ResetTag())
+		g.app.QueueUpdateDraw(func() {
+			g.statusBar.SetText(text)
+		})
+	})
+}
+
 // executePullDirect pulls an artifact directly without showing a modal
 func (g *GUI) executePullDirect(artifact *registry.Artifact, toDocker bool) {
 	if artifact == nil {
@@ -616,7 +874,23 @@ func (g *GUI) executePullDirect(artifact *registry.Artifact, toDocker bool) {
 		}
 	}
 
-	g.executePull(artifact, toDocker)
+	var load pull.LoadTarget
+	if toDocker {
+		load = pull.LoadDocker
+	}
+	g.executePull(artifact, load)
+}
+
+// pullReference returns the reference that pulls artifact. Artifacts in
+// the Local registry are pulled again from the registry they came from,
+// which only fetches what changed and lets them be loaded into an engine.
+func pullReference(artifact *registry.Artifact) string {
+	repo := strings.TrimPrefix(artifact.Repository, registry.LocalRegistryURL+"/")
+	// Artifacts pulled by digest are tagged "@<digest>"
+	if strings.HasPrefix(artifact.Tag, "@") {
+		return repo + artifact.Tag
+	}
+	return repo + ":" + artifact.Tag
 }
 
 // splitRepoPath splits a repository path into registry and repo parts
@@ -138,6 +138,8 @@ func GetHelpText() string {
 %sArtifact Actions%s
   p           Pull artifact (shows options)
   d           Pull & load to Docker directly
+  f           Preview a file (generic artifacts, in details)
+  i, v, t...  Type-specific actions listed in details, run from there
 
 %sSettings%s
   S           Open settings modal
@@ -226,7 +226,9 @@ func (av *ArtifactView) SetApp(app *tview.Application) {
 				}
 				return nil
 			default:
-				// Typing in table redirects to filter
+				// Typing in table redirects to filter. Handler action keys
+				// are only bound in the details panel, so they never
+				// swallow a filter's characters.
 				app.SetFocus(av.FilterInput)
 				av.FilterInput.SetText(string(event.Rune()))
 				return nil
@@ -0,0 +1,70 @@
+package views
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mistergrinvalds/lazyoci/pkg/artifacts"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/rivo/tview"
+)
+
+// typeKeys sends each rune to the focused primitive, as the application's
+// event loop would.
+func typeKeys(app *tview.Application, keys string) {
+	for _, r := range keys {
+		event := tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone)
+		app.GetFocus().InputHandler()(event, func(p tview.Primitive) { app.SetFocus(p) })
+	}
+}
+
+func TestArtifactFilterRunsNoAction(t *testing.T) {
+	chart := &registry.Artifact{Repository: "localhost/charts/app", Tag: "v1.2.0", Type: registry.ArtifactTypeHelmChart}
+	info := &registry.ArtifactInfo{Type: registry.ArtifactTypeHelmChart}
+	actions := (&artifacts.HelmHandler{}).GetActions(chart)
+
+	var ran []rune
+	onActionKey := func(_ *registry.Artifact, _ *registry.ArtifactInfo, key rune) bool {
+		for _, a := range actions {
+			if a.Key == key {
+				ran = append(ran, key)
+				return true
+			}
+		}
+		return false
+	}
+
+	app := tview.NewApplication()
+	av := NewArtifactView(nil, nil)
+	av.SetApp(app)
+	av.currentRepo = chart.Repository
+	av.artifacts = []*registry.Artifact{chart}
+	av.setCachedInfo(chart.Tag, info)
+	// A load in flight keeps filtering off the network
+	av.loading = true
+
+	dv := NewDetailsView()
+	dv.SetOnActionKey(onActionKey)
+	dv.ShowArtifactWithInfo(chart, info, actions)
+
+	// 'v' and 't' are the chart's Show Values and Template keys
+	app.SetFocus(av.Table)
+	av.Table.Select(1, 0)
+	typeKeys(app, "v1.2")
+	if len(ran) != 0 {
+		t.Errorf("typing a filter ran actions %q", ran)
+	}
+	if got := av.FilterInput.GetText(); got != "v1.2" {
+		t.Errorf("filter = %q, want v1.2", got)
+	}
+	if av.filter != "v1.2" {
+		t.Errorf("applied filter = %q, want v1.2", av.filter)
+	}
+
+	// The details panel runs them
+	app.SetFocus(dv.TextView)
+	typeKeys(app, "v")
+	if string(ran) != "v" {
+		t.Errorf("actions run from details = %q, want v", ran)
+	}
+}
@@ -2,9 +2,11 @@ package views
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mistergrinvalds/lazyoci/pkg/artifacts"
 	"github.com/mistergrinvalds/lazyoci/pkg/gui/theme"
 	"github.com/mistergrinvalds/lazyoci/pkg/registry"
 	"github.com/rivo/tview"
@@ -16,9 +18,17 @@ type DetailsView struct {
 	currentInfo     *registry.ArtifactInfo
 	currentArtifact *registry.Artifact
 
+	// Handler output for the current artifact. Actions are known as soon as
+	// the type is resolved; details are loaded asynchronously.
+	actions        []artifacts.Action
+	details        *artifacts.Details
+	detailsErr     error
+	detailsLoading bool
+
 	// Callbacks for actions
 	onPull       func(*registry.Artifact)       // Shows pull modal
 	onPullDirect func(*registry.Artifact, bool) // Direct pull: bool = toDocker
+	onActionKey  func(*registry.Artifact, *registry.ArtifactInfo, rune) bool
 }
 
 // NewDetailsView creates a new details view
@@ -72,6 +82,12 @@ func NewDetailsView() *DetailsView {
 				// Scroll to beginning (single 'g' for simplicity)
 				dv.TextView.ScrollToBeginning()
 				return nil
+			default:
+				// Handler action keys
+				if dv.onActionKey != nil && dv.currentArtifact != nil &&
+					dv.onActionKey(dv.currentArtifact, dv.currentInfo, event.Rune()) {
+					return nil
+				}
 			}
 		}
 		return event
@@ -100,6 +116,13 @@ func (dv *DetailsView) SetOnPullDirect(fn func(*registry.Artifact, bool)) {
 	dv.onPullDirect = fn
 }
 
+// SetOnActionKey sets the callback for unreserved keys pressed while the
+// panel shows an artifact. The callback reports whether the key triggered
+// an action.
+func (dv *DetailsView) SetOnActionKey(fn func(*registry.Artifact, *registry.ArtifactInfo, rune) bool) {
+	dv.onActionKey = fn
+}
+
 // GetCurrentArtifact returns the currently displayed artifact
 func (dv *DetailsView) GetCurrentArtifact() *registry.Artifact {
 	return dv.currentArtifact
@@ -169,6 +192,22 @@ func (dv *DetailsView) ShowRegistryInfo(registryURL string) {
 	success := t("success")
 
 	var sb strings.Builder
+	if registryURL == registry.LocalRegistryURL {
+		fmt.Fprintf(&sb, "%sLocal%s\n\n", emphasis, text)
+		sb.WriteString("Artifacts already pulled to the artifact\n")
+		sb.WriteString("directory, read from disk.\n\n")
+		fmt.Fprintf(&sb, "%sSearch:%s\n", success, text)
+		sb.WriteString("  Every pulled repository is listed; type\n")
+		sb.WriteString("  part of a name and press Enter to filter.\n\n")
+		fmt.Fprintf(&sb, "%sPull:%s\n", success, text)
+		sb.WriteString("  Pulls again from the source registry,\n")
+		sb.WriteString("  fetching only what changed.\n\n")
+		fmt.Fprintf(&sb, "%sManage:%s\n", success, text)
+		sb.WriteString("  lazyoci local ls|rm|gc|du\n")
+		dv.TextView.SetText(sb.String())
+		dv.TextView.ScrollToBeginning()
+		return
+	}
 	fmt.Fprintf(&sb, "%s%s%s\n\n", emphasis, registryURL, text)
 
 	fmt.Fprintf(&sb, "%sStatus:%s Connected\n\n", success, text)
@@ -229,11 +268,13 @@ func (dv *DetailsView) ShowRepository(repoPath string) {
 
 // ShowArtifact displays details for the given artifact
 func (dv *DetailsView) ShowArtifact(artifact *registry.Artifact) {
-	dv.ShowArtifactWithInfo(artifact, nil)
+	dv.ShowArtifactWithInfo(artifact, nil, nil)
 }
 
 // ShowArtifactWithInfo displays details for the artifact with resolved info
-func (dv *DetailsView) ShowArtifactWithInfo(artifact *registry.Artifact, info *registry.ArtifactInfo) {
+// and the actions its handler offers. Once info is resolved the handler
+// details section shows as loading until SetDetails is called.
+func (dv *DetailsView) ShowArtifactWithInfo(artifact *registry.Artifact, info *registry.ArtifactInfo, actions []artifacts.Action) {
 	if artifact == nil {
 		dv.ShowRegistryHelp()
 		return
@@ -241,6 +282,36 @@ func (dv *DetailsView) ShowArtifactWithInfo(artifact *registry.Artifact, info *r
 
 	dv.currentArtifact = artifact
 	dv.currentInfo = info
+	dv.actions = actions
+	dv.details = nil
+	dv.detailsErr = nil
+	dv.detailsLoading = info != nil
+
+	dv.renderArtifact()
+	dv.TextView.ScrollToBeginning()
+}
+
+// SetDetails attaches handler details to the displayed artifact.
+// Results for an artifact that is no longer shown are discarded.
+func (dv *DetailsView) SetDetails(artifact *registry.Artifact, details *artifacts.Details, err error) {
+	if artifact == nil || dv.currentArtifact == nil ||
+		artifact.Repository != dv.currentArtifact.Repository || artifact.Tag != dv.currentArtifact.Tag {
+		return
+	}
+
+	dv.details = details
+	dv.detailsErr = err
+	dv.detailsLoading = false
+
+	row, col := dv.TextView.GetScrollOffset()
+	dv.renderArtifact()
+	dv.TextView.ScrollTo(row, col)
+}
+
+// renderArtifact renders the current artifact, its info, and handler output.
+func (dv *DetailsView) renderArtifact() {
+	artifact := dv.currentArtifact
+	info := dv.currentInfo
 
 	dv.TextView.SetTitle(" [4] Artifact Details ")
 
@@ -307,75 +378,142 @@ func (dv *DetailsView) ShowArtifactWithInfo(artifact *registry.Artifact, info *r
 		}
 	}
 
-	// Type-specific actions section
+	// Handler-provided details
+	if dv.detailsLoading || dv.detailsErr != nil || dv.details != nil {
+		sb.WriteString("\n")
+		dv.writeDetailsSection(&sb)
+	}
+
+	// Handler-provided actions
 	sb.WriteString("\n")
-	dv.writeActionsSection(&sb, artifact, info)
+	dv.writeActionsSection(&sb)
 
 	dv.TextView.SetText(sb.String())
-	dv.TextView.ScrollToBeginning()
 }
 
-// writeActionsSection writes type-specific actions to the string builder
-func (dv *DetailsView) writeActionsSection(sb *strings.Builder, artifact *registry.Artifact, info *registry.ArtifactInfo) {
+// maxComponentsPerGroup limits how many components of one type are listed
+const maxComponentsPerGroup = 15
+
+// writeDetailsSection writes the handler's summary, properties, components,
+// and related artifacts to the string builder
+func (dv *DetailsView) writeDetailsSection(sb *strings.Builder) {
 	emphasis := t("emphasis")
 	text := t("text")
 	success := t("success")
 	muted := t("muted")
-	dim := t("dim")
 
-	fmt.Fprintf(sb, "%s━━━ Actions ━━━━━━━━━━━━━━━━━━━━━%s\n", emphasis, text)
+	switch {
+	case dv.detailsLoading:
+		fmt.Fprintf(sb, "%s━━━ Details ━━━━━━━━━━━━━━━━━━━━━%s\n", emphasis, text)
+		fmt.Fprintf(sb, "%sLoading...%s\n", muted, r())
+		return
+	case dv.detailsErr != nil:
+		fmt.Fprintf(sb, "%s━━━ Details ━━━━━━━━━━━━━━━━━━━━━%s\n", emphasis, text)
+		fmt.Fprintf(sb, "%s%v%s\n", t("error"), dv.detailsErr, r())
+		return
+	}
 
-	artifactType := registry.ArtifactTypeImage
-	if info != nil {
-		artifactType = info.Type
-	} else if artifact.Type != "" {
-		artifactType = artifact.Type
+	d := dv.details
+	fmt.Fprintf(sb, "%s━━━ %s ━━━━━━━━━━━━━━━━━%s\n", emphasis, d.Summary, text)
+
+	keys := make([]string, 0, len(d.Properties))
+	width := 0
+	for k, v := range d.Properties {
+		if v == "" {
+			continue
+		}
+		keys = append(keys, k)
+		width = max(width, len(k)+1)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(sb, "%s%-*s%s %s\n", success, width, k+":", text, d.Properties[k])
 	}
 
-	switch artifactType {
-	case registry.ArtifactTypeImage:
-		fmt.Fprintf(sb, "%sp%s Pull to disk\n", success, text)
-		fmt.Fprintf(sb, "%sd%s Pull & load to Docker\n", success, text)
-		fmt.Fprintf(sb, "\n%sPull commands:%s\n", muted, text)
-		fmt.Fprintf(sb, "  docker pull %s:%s\n", artifact.Repository, artifact.Tag)
-
-	case registry.ArtifactTypeHelmChart:
-		fmt.Fprintf(sb, "%sp%s Pull chart.tgz\n", success, text)
-		fmt.Fprintf(sb, "%st%s Template (helm template) %s(coming soon)%s\n", muted, text, dim, r())
-		fmt.Fprintf(sb, "%si%s Install (helm install) %s(coming soon)%s\n", muted, text, dim, r())
-		fmt.Fprintf(sb, "\n%sPull commands:%s\n", muted, text)
-		fmt.Fprintf(sb, "  helm pull oci://%s --version %s\n", artifact.Repository, artifact.Tag)
-
-	case registry.ArtifactTypeSBOM:
-		fmt.Fprintf(sb, "%sp%s Pull JSON\n", success, text)
-		fmt.Fprintf(sb, "%sv%s View summary %s(coming soon)%s\n", muted, text, dim, r())
-		detail := ""
-		if info != nil && info.TypeDetail != "" {
-			detail = fmt.Sprintf(" (%s)", info.TypeDetail)
+	// Group components by type, keeping the order types first appear in
+	var types []string
+	groups := make(map[string][]artifacts.Component)
+	for _, c := range d.Components {
+		if _, ok := groups[c.Type]; !ok {
+			types = append(types, c.Type)
+		}
+		groups[c.Type] = append(groups[c.Type], c)
+	}
+	for _, typ := range types {
+		comps := groups[typ]
+		fmt.Fprintf(sb, "\n%s%s (%d):%s\n", emphasis, typ, len(comps), text)
+		for i, c := range comps {
+			if i >= maxComponentsPerGroup {
+				fmt.Fprintf(sb, "  %s... and %d more%s\n", muted, len(comps)-maxComponentsPerGroup, text)
+				break
+			}
+			line := "  " + c.Name
+			if c.Size > 0 {
+				line += "  " + formatSize(c.Size)
+			}
+			if c.Description != "" {
+				line += "  " + muted + c.Description + text
+			}
+			sb.WriteString(line + "\n")
 		}
-		fmt.Fprintf(sb, "\n%sSBOM format:%s%s\n", muted, detail, text)
+	}
 
-	case registry.ArtifactTypeSignature:
-		fmt.Fprintf(sb, "%sp%s Pull signature\n", success, text)
-		fmt.Fprintf(sb, "%sv%s Verify %s(requires cosign)%s\n", muted, text, dim, r())
-		if info != nil && info.TypeDetail != "" {
-			fmt.Fprintf(sb, "\n%sSignature type: %s%s\n", muted, info.TypeDetail, text)
+	if len(d.RelatedArtifacts) > 0 {
+		fmt.Fprintf(sb, "\n%sRelated:%s\n", emphasis, text)
+		for _, ref := range d.RelatedArtifacts {
+			fmt.Fprintf(sb, "  %s\n", ref)
 		}
+	}
+}
+
+// writeActionsSection writes the built-in pull keys and the handler's actions
+// to the string builder
+func (dv *DetailsView) writeActionsSection(sb *strings.Builder) {
+	emphasis := t("emphasis")
+	text := t("text")
+	success := t("success")
+	muted := t("muted")
+
+	fmt.Fprintf(sb, "%s━━━ Actions ━━━━━━━━━━━━━━━━━━━━━%s\n", emphasis, text)
 
-	case registry.ArtifactTypeAttestation:
-		fmt.Fprintf(sb, "%sp%s Pull attestation\n", success, text)
-		fmt.Fprintf(sb, "%sv%s View %s(coming soon)%s\n", muted, text, dim, r())
-		if info != nil && info.TypeDetail != "" {
-			fmt.Fprintf(sb, "\n%sAttestation type: %s%s\n", muted, info.TypeDetail, text)
+	artifactType := dv.GetCurrentArtifactType()
+	fmt.Fprintf(sb, "%sp%s Pull to disk\n", success, text)
+	if artifactType == registry.ArtifactTypeImage || artifactType == registry.ArtifactTypeUnknown {
+		fmt.Fprintf(sb, "%sd%s Pull & load to Docker\n", success, text)
+	}
+
+	var commands []string
+	for _, a := range dv.actions {
+		key := " "
+		if a.Key != 0 {
+			key = string(a.Key)
+		}
+		name := a.Name
+		if a.Dangerous {
+			name = t("error") + name + text
 		}
+		line := fmt.Sprintf("%s%s%s %s", success, key, text, name)
+		if a.Description != "" {
+			line += fmt.Sprintf(" %s- %s%s", muted, a.Description, text)
+		}
+		sb.WriteString(line + "\n")
+		if a.Command != "" {
+			commands = append(commands, a.Command)
+		}
+	}
 
-	case registry.ArtifactTypeWasm:
-		fmt.Fprintf(sb, "%sp%s Pull .wasm\n", success, text)
-		fmt.Fprintf(sb, "%sr%s Run %s(requires wasmtime)%s\n", muted, text, dim, r())
+	for _, a := range dv.actions {
+		if a.Key != 0 {
+			fmt.Fprintf(sb, "%sAction keys run with this panel focused (4)%s\n", muted, text)
+			break
+		}
+	}
 
-	default:
-		fmt.Fprintf(sb, "%sp%s Pull (OCI layout)\n", success, text)
-		fmt.Fprintf(sb, "\n%sUnknown artifact type%s\n", muted, text)
+	if len(commands) > 0 {
+		fmt.Fprintf(sb, "\n%sCommands:%s\n", muted, text)
+		for _, c := range commands {
+			fmt.Fprintf(sb, "  %s\n", c)
+		}
 	}
 }
 
@@ -0,0 +1,141 @@
+package views
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mistergrinvalds/lazyoci/pkg/artifacts"
+	"github.com/mistergrinvalds/lazyoci/pkg/gui/theme"
+	"github.com/mistergrinvalds/lazyoci/pkg/preview"
+	"github.com/rivo/tview"
+)
+
+// FilePicker lists an artifact's files for preview
+type FilePicker struct {
+	List *tview.List
+	Flex *tview.Flex
+}
+
+// NewFilePicker creates a file list that calls onSelect with the chosen file
+// and onCancel on Esc or q
+func NewFilePicker(title string, files []artifacts.File, onSelect func(artifacts.File), onCancel func()) *FilePicker {
+	fp := &FilePicker{}
+
+	fp.List = tview.NewList().
+		ShowSecondaryText(true).
+		SetHighlightFullLine(true)
+	fp.List.SetBorder(true).SetTitle(" " + title + " ")
+
+	width := 40
+	for _, f := range files {
+		secondary := fmt.Sprintf("%s  %s", formatSize(f.Size), f.MediaType)
+		fp.List.AddItem(f.Name, secondary, 0, nil)
+		width = max(width, len(f.Name)+6, len(secondary)+6)
+	}
+	width = min(width, 100)
+
+	fp.List.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		if index >= 0 && index < len(files) {
+			onSelect(files[index])
+		}
+	})
+	fp.List.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' {
+			onCancel()
+			return nil
+		}
+		return event
+	})
+
+	fp.ApplyTheme()
+
+	// Center the list; each file takes two rows
+	fp.Flex = tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(fp.List, min(2*len(files)+2, 24), 1, true).
+			AddItem(nil, 0, 1, false), width, 1, true).
+		AddItem(nil, 0, 1, false)
+	fp.Flex.SetBackgroundColor(theme.BackgroundColor())
+
+	return fp
+}
+
+// ApplyTheme applies the current theme to the file list
+func (fp *FilePicker) ApplyTheme() {
+	fp.List.SetBackgroundColor(theme.BackgroundColor())
+	fp.List.SetBorderColor(theme.BorderNormalColor())
+	fp.List.SetTitleColor(theme.TitleColor())
+	fp.List.SetMainTextColor(theme.TextColor())
+	fp.List.SetSecondaryTextColor(theme.TextMutedColor())
+	fp.List.SetSelectedBackgroundColor(theme.SelectionBgColor())
+	fp.List.SetSelectedTextColor(theme.SelectionFgColor())
+}
+
+// PreviewView shows a file's content with syntax highlighting
+type PreviewView struct {
+	TextView *tview.TextView
+}
+
+// NewPreviewView creates a scrollable preview that calls onClose on Esc or q
+func NewPreviewView(file artifacts.File, onClose func()) *PreviewView {
+	pv := &PreviewView{}
+
+	pv.TextView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(false)
+	pv.TextView.SetBorder(true).
+		SetTitle(fmt.Sprintf(" %s (%s, %s) ", file.Name, formatSize(file.Size), file.MediaType))
+	pv.TextView.SetText(theme.Tag("muted") + "Loading..." + theme.ResetTag())
+
+	pv.TextView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' {
+			onClose()
+			return nil
+		}
+		return event
+	})
+
+	pv.ApplyTheme()
+	return pv
+}
+
+// ApplyTheme applies the current theme to the preview
+func (pv *PreviewView) ApplyTheme() {
+	pv.TextView.SetBackgroundColor(theme.BackgroundColor())
+	pv.TextView.SetTextColor(theme.TextColor())
+	pv.TextView.SetBorderColor(theme.BorderFocusedColor())
+	pv.TextView.SetTitleColor(theme.TitleColor())
+}
+
+// SetContent replaces the preview text, which must already be tagged
+// with ThemeStyle
+func (pv *PreviewView) SetContent(text string) {
+	pv.TextView.SetText(text)
+	pv.TextView.ScrollToBeginning()
+}
+
+// previewTags maps token kinds to theme tag names
+var previewTags = map[preview.Kind]string{
+	preview.KindKey:     "primary",
+	preview.KindString:  "success",
+	preview.KindNumber:  "warning",
+	preview.KindKeyword: "accent",
+	preview.KindComment: "muted",
+	preview.KindPunct:   "muted",
+	preview.KindOffset:  "info",
+}
+
+// ThemeStyle renders preview tokens with the current theme's color tags
+type ThemeStyle struct{}
+
+// Format escapes text for tview and colors it for its kind
+func (ThemeStyle) Format(kind preview.Kind, text string) string {
+	name, ok := previewTags[kind]
+	if !ok {
+		return tview.Escape(text)
+	}
+	return theme.Tag(name) + tview.Escape(text) + theme.ResetTag()
+}
@@ -1,6 +1,8 @@
 package views
 
 import (
+	"slices"
+
 	"github.com/gdamore/tcell/v2"
 	"github.com/mistergrinvalds/lazyoci/pkg/config"
 	"github.com/mistergrinvalds/lazyoci/pkg/gui/theme"
@@ -60,7 +62,7 @@ func NewRegistryView(reg *registry.Client, onSelect func(registryURL string)) *R
 		case 'e', 'E':
 			if rv.onEdit != nil {
 				url := rv.GetSelectedRegistry()
-				if url != "" {
+				if url != "" && url != registry.LocalRegistryURL {
 					rv.onEdit(url)
 				}
 			}
@@ -68,7 +70,7 @@ func NewRegistryView(reg *registry.Client, onSelect func(registryURL string)) *R
 		case 'd', 'D':
 			if rv.onDelete != nil {
 				url := rv.GetSelectedRegistry()
-				if url != "" {
+				if url != "" && url != registry.LocalRegistryURL {
 					rv.onDelete(url)
 				}
 			}
@@ -108,13 +110,20 @@ func (rv *RegistryView) SetOnDelete(fn func(registryURL string)) {
 
 func (rv *RegistryView) loadRegistries() {
 	rv.List.Clear()
-	rv.registries = rv.registry.GetRegistries()
+	// Local is always listed last; it can't be edited or deleted
+	rv.registries = append(slices.Clone(rv.registry.GetRegistries()), config.Registry{
+		Name: "Local",
+		URL:  registry.LocalRegistryURL,
+	})
 
 	for _, reg := range rv.registries {
 		name := reg.Name
-		if name == "" || name == reg.URL {
+		switch {
+		case reg.URL == registry.LocalRegistryURL:
+			// Shown by name alone
+		case name == "" || name == reg.URL:
 			name = reg.URL
-		} else {
+		default:
 			name = name + " (" + reg.URL + ")"
 		}
 		rv.List.AddItem(name, "", 0, nil)
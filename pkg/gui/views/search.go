@@ -190,7 +190,8 @@ func (sv *SearchView) SetRegistry(registryURL string) {
 
 // Search performs a search and updates the results
 func (sv *SearchView) Search(query string) {
-	if query == "" {
+	// An empty query lists everything pulled, which is never too much
+	if query == "" && sv.currentReg != registry.LocalRegistryURL {
 		sv.showWelcome()
 		return
 	}
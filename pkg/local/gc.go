@@ -0,0 +1,255 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// GCResult reports what garbage collecting a layout removed, or would
+// remove in a dry run.
+type GCResult struct {
+	// Layout is the name of the collected layout.
+	Layout string `json:"layout" yaml:"layout"`
+
+	// Manifests is the number of stale manifests dropped from index.json.
+	Manifests int `json:"manifests" yaml:"manifests"`
+
+	// Blobs is the number of unreferenced blobs.
+	Blobs int `json:"blobs" yaml:"blobs"`
+
+	// Partial is the number of files left in the ingest directory by
+	// interrupted pulls.
+	Partial int `json:"partial" yaml:"partial"`
+
+	// Bytes is the disk space they use.
+	Bytes int64 `json:"bytes" yaml:"bytes"`
+}
+
+// GC deletes the content of a layout that none of its tags lead to, along
+// with partial downloads in its ingest directory. Such content is left
+// behind when a tag is re-pulled after the artifact changed: the old
+// manifest stays in index.json, untagged, and keeps its blobs. Stale
+// manifests are dropped from index.json and the blobs nothing live refers
+// to are deleted. With dryRun, nothing changes and the result reports what
+// would be removed.
+func GC(ctx context.Context, l *Layout, dryRun bool) (*GCResult, error) {
+	reachable, live, err := reachableBlobs(ctx, l.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect %s: %w", l.Name, err)
+	}
+
+	result := &GCResult{Layout: l.Name, Manifests: len(l.Manifests) - len(live)}
+	// The index goes first, so an interrupted collection never leaves it
+	// listing a deleted manifest
+	if result.Manifests > 0 && !dryRun {
+		if err := writeIndex(l.Path, live); err != nil {
+			return nil, fmt.Errorf("failed to collect %s: %w", l.Name, err)
+		}
+	}
+	remove := func(path string, size int64) error {
+		result.Bytes += size
+		if dryRun {
+			return nil
+		}
+		return os.Remove(path)
+	}
+
+	blobs := filepath.Join(l.Path, ocispec.ImageBlobsDir)
+	err = filepath.WalkDir(blobs, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == blobs && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		alg := filepath.Base(filepath.Dir(path))
+		dgst := digest.NewDigestFromEncoded(digest.Algorithm(alg), d.Name())
+		if dgst.Validate() != nil || reachable[dgst] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		result.Blobs++
+		return remove(path, info.Size())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect %s: %w", l.Name, err)
+	}
+
+	ingest, err := os.ReadDir(filepath.Join(l.Path, "ingest"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to collect %s: %w", l.Name, err)
+	}
+	for _, entry := range ingest {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		result.Partial++
+		if err := remove(filepath.Join(l.Path, "ingest", entry.Name()), info.Size()); err != nil {
+			return nil, fmt.Errorf("failed to collect %s: %w", l.Name, err)
+		}
+	}
+
+	if !dryRun {
+		refreshed, err := Open(filepath.Dir(l.Path), l.Path)
+		if err != nil {
+			return nil, err
+		}
+		l.Manifests, l.Size = refreshed.Manifests, refreshed.Size
+	}
+	return result, nil
+}
+
+// reachableBlobs returns the digests of a layout's live content and the
+// index.json entries it keeps. Tagged manifests are live, as is everything
+// they refer to and the referrers (signatures, SBOMs) of anything live.
+// Untagged entries that are neither are stale: oras keeps a manifest in
+// index.json after its tag moves to another one. A layout with no tags at
+// all is not one lazyoci pulled, and every entry in it is kept.
+func reachableBlobs(ctx context.Context, path string) (map[digest.Digest]bool, []ocispec.Descriptor, error) {
+	store, err := oci.NewFromFS(ctx, os.DirFS(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	index, err := readIndex(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var roots, untagged []ocispec.Descriptor
+	for _, desc := range index.Manifests {
+		if desc.Annotations[ocispec.AnnotationRefName] != "" {
+			roots = append(roots, desc)
+		} else {
+			untagged = append(untagged, desc)
+		}
+	}
+	if len(roots) == 0 {
+		roots, untagged = index.Manifests, nil
+	}
+
+	reachable := make(map[digest.Digest]bool)
+	mark := func(queue []ocispec.Descriptor) error {
+		for len(queue) > 0 {
+			desc := queue[0]
+			queue = queue[1:]
+			if reachable[desc.Digest] {
+				continue
+			}
+			reachable[desc.Digest] = true
+
+			successors, err := content.Successors(ctx, store, desc)
+			if err != nil {
+				// A manifest that was never stored refers to nothing here
+				if errors.Is(err, errdef.ErrNotFound) {
+					continue
+				}
+				return err
+			}
+			queue = append(queue, successors...)
+		}
+		return nil
+	}
+	if err := mark(roots); err != nil {
+		return nil, nil, err
+	}
+
+	// Referrers point at their subject, not the other way round, so they
+	// are found by checking each untagged manifest's subject until no more
+	// turn up.
+	for found := true; found; {
+		found = false
+		for _, desc := range untagged {
+			if reachable[desc.Digest] {
+				continue
+			}
+			subject, err := subjectOf(ctx, store, desc)
+			if err != nil {
+				return nil, nil, err
+			}
+			if subject != "" && reachable[subject] {
+				if err := mark([]ocispec.Descriptor{desc}); err != nil {
+					return nil, nil, err
+				}
+				found = true
+			}
+		}
+	}
+
+	var live []ocispec.Descriptor
+	for _, desc := range index.Manifests {
+		if reachable[desc.Digest] {
+			live = append(live, desc)
+		}
+	}
+	return reachable, live, nil
+}
+
+// subjectOf returns the digest of the subject of the manifest desc, or ""
+// if it has none or isn't stored.
+func subjectOf(ctx context.Context, store content.Fetcher, desc ocispec.Descriptor) (digest.Digest, error) {
+	data, err := content.FetchAll(ctx, store, desc)
+	if err != nil {
+		if errors.Is(err, errdef.ErrNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	var manifest struct {
+		Subject *ocispec.Descriptor `json:"subject"`
+	}
+	if json.Unmarshal(data, &manifest) != nil || manifest.Subject == nil {
+		return "", nil
+	}
+	return manifest.Subject.Digest, nil
+}
+
+// writeIndex replaces the manifests listed in the index.json of the layout
+// at path, keeping the rest of the file as it is.
+func writeIndex(path string, manifests []ocispec.Descriptor) error {
+	indexPath := filepath.Join(path, ocispec.ImageIndexFile)
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	var index map[string]json.RawMessage
+	if err := json.Unmarshal(data, &index); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", indexPath, err)
+	}
+	if manifests == nil {
+		manifests = []ocispec.Descriptor{}
+	}
+	if index["manifests"], err = json.Marshal(manifests); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(index); err != nil {
+		return err
+	}
+	// Written beside and renamed over, so an interrupted write can't leave
+	// a truncated index
+	tmp := indexPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmp, indexPath); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
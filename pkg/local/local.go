@@ -0,0 +1,251 @@
+// Package local manages the OCI layouts lazyoci pulls into its artifact
+// directory. Pulls lay artifacts out as
+//
+//	<artifact dir>/<type>/<registry>/<repository>/<tag or @digest>
+//
+// with one OCI layout per pulled reference. The package finds those
+// layouts, reports what they hold and how much disk they use, removes them
+// and deletes blobs no manifest in a layout refers to.
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Layout is an OCI layout in the artifact directory.
+type Layout struct {
+	// Path is the layout's directory.
+	Path string `json:"path" yaml:"path"`
+
+	// Name is the layout's path relative to the artifact directory, with
+	// forward slashes (e.g., oci/docker.io/library/nginx/1.25).
+	Name string `json:"name" yaml:"name"`
+
+	// Type is the artifact type directory the layout is in (oci, helm,
+	// sbom, sig, att or wasm).
+	Type string `json:"type" yaml:"type"`
+
+	// Repository is the registry and repository the layout was pulled from
+	// (e.g., docker.io/library/nginx). Empty for layouts that are not laid
+	// out the way pulls do it.
+	Repository string `json:"repository,omitempty" yaml:"repository,omitempty"`
+
+	// Reference is the reference that was pulled into the layout.
+	Reference string `json:"reference,omitempty" yaml:"reference,omitempty"`
+
+	// Manifests are the entries of the layout's index.json, tagged ones
+	// first.
+	Manifests []Manifest `json:"manifests" yaml:"manifests"`
+
+	// Size is the disk space the layout uses, in bytes.
+	Size int64 `json:"size" yaml:"size"`
+}
+
+// Manifest is an entry of a layout's index.json.
+type Manifest struct {
+	// Tag is the entry's org.opencontainers.image.ref.name annotation.
+	// Manifests pulled as part of another, such as the platforms of an
+	// index or referrers, are untagged.
+	Tag          string `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Digest       string `json:"digest" yaml:"digest"`
+	MediaType    string `json:"mediaType" yaml:"mediaType"`
+	ArtifactType string `json:"artifactType,omitempty" yaml:"artifactType,omitempty"`
+	Size         int64  `json:"size" yaml:"size"`
+}
+
+// Tags returns the layout's tags.
+func (l *Layout) Tags() []string {
+	var tags []string
+	for _, m := range l.Manifests {
+		if m.Tag != "" {
+			tags = append(tags, m.Tag)
+		}
+	}
+	return tags
+}
+
+// List returns the layouts under base, sorted by name. A missing base
+// holds no layouts.
+func List(base string) ([]*Layout, error) {
+	var layouts []*Layout
+	err := filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == base && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, ocispec.ImageLayoutFile)); err != nil {
+			return nil
+		}
+		layout, err := Open(base, path)
+		if err != nil {
+			return err
+		}
+		layouts = append(layouts, layout)
+		// Layouts don't nest
+		return fs.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", base, err)
+	}
+
+	sort.Slice(layouts, func(i, j int) bool {
+		return layouts[i].Name < layouts[j].Name
+	})
+	return layouts, nil
+}
+
+// Open reads the layout at path, which is named relative to base.
+func Open(base, path string) (*Layout, error) {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return nil, err
+	}
+	layout := &Layout{Path: path, Name: filepath.ToSlash(rel), Manifests: []Manifest{}}
+
+	// <type>/<registry>/<repository...>/<tag or @digest>
+	parts := strings.Split(layout.Name, "/")
+	layout.Type = parts[0]
+	if len(parts) >= 4 {
+		layout.Repository = strings.Join(parts[1:len(parts)-1], "/")
+		ref := parts[len(parts)-1]
+		if strings.HasPrefix(ref, "@") {
+			layout.Reference = layout.Repository + ref
+		} else {
+			layout.Reference = layout.Repository + ":" + ref
+		}
+	}
+
+	index, err := readIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range index.Manifests {
+		layout.Manifests = append(layout.Manifests, Manifest{
+			Tag:          desc.Annotations[ocispec.AnnotationRefName],
+			Digest:       desc.Digest.String(),
+			MediaType:    desc.MediaType,
+			ArtifactType: desc.ArtifactType,
+			Size:         desc.Size,
+		})
+	}
+	sort.SliceStable(layout.Manifests, func(i, j int) bool {
+		return layout.Manifests[i].Tag != "" && layout.Manifests[j].Tag == ""
+	})
+
+	layout.Size, err = dirSize(path)
+	if err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// Find returns the layout under base that name identifies: its name, its
+// path, the reference it was pulled from (short forms such as nginx:1.25
+// are expanded the way pull does) or the digest of one of its manifests.
+func Find(base, name string) (*Layout, error) {
+	layouts, err := List(base)
+	if err != nil {
+		return nil, err
+	}
+
+	reference := name
+	if ref, err := ociutil.ParseReference(name); err == nil {
+		reference = ref.String()
+	}
+	abs, _ := filepath.Abs(name)
+
+	var matches []*Layout
+	for _, l := range layouts {
+		if l.Name == name || l.Path == abs || l.Reference == name || l.Reference == reference || l.hasDigest(name) {
+			matches = append(matches, l)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no local artifact matches %q", name)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, l := range matches {
+		names[i] = l.Name
+	}
+	return nil, fmt.Errorf("%q matches %d local artifacts (%s); use the name of one", name, len(matches), strings.Join(names, ", "))
+}
+
+// hasDigest reports whether one of the layout's manifests is dgst.
+func (l *Layout) hasDigest(dgst string) bool {
+	for _, m := range l.Manifests {
+		if m.Digest == dgst {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove deletes the layout and then any directories above it, up to
+// base, that it leaves empty.
+func Remove(base string, l *Layout) error {
+	if err := os.RemoveAll(l.Path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", l.Name, err)
+	}
+	base = filepath.Clean(base)
+	for dir := filepath.Dir(l.Path); dir != base && strings.HasPrefix(dir, base+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		// Fails, and stops, at the first directory that isn't empty
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// readIndex reads the index.json of the layout at path.
+func readIndex(path string) (*ocispec.Index, error) {
+	data, err := os.ReadFile(filepath.Join(path, ocispec.ImageIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(path, ocispec.ImageIndexFile), err)
+	}
+	return &index, nil
+}
+
+// dirSize returns the size of the regular files under path.
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure %s: %w", path, err)
+	}
+	return size, nil
+}
@@ -0,0 +1,274 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// pushImage stores an image with one layer in the layout at dir, tagged
+// tag, and returns its manifest descriptor.
+func pushImage(t *testing.T, dir, tag, layer string) ocispec.Descriptor {
+	t.Helper()
+	ctx := context.Background()
+	store, err := oci.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := func(mediaType string, data []byte) ocispec.Descriptor {
+		desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+		if err := store.Push(ctx, desc, bytes.NewReader(data)); err != nil && !strings.Contains(err.Error(), "already exists") {
+			t.Fatal(err)
+		}
+		return desc
+	}
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    push(ocispec.MediaTypeImageConfig, []byte(`{"architecture":"amd64","os":"linux"}`)),
+		Layers:    []ocispec.Descriptor{push(ocispec.MediaTypeImageLayer, []byte(layer))},
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := push(ocispec.MediaTypeImageManifest, data)
+	if err := store.Tag(ctx, desc, tag); err != nil {
+		t.Fatal(err)
+	}
+	return desc
+}
+
+func TestList(t *testing.T) {
+	base := t.TempDir()
+	nginx := pushImage(t, filepath.Join(base, "oci", "docker.io", "library", "nginx", "1.25"), "1.25", "nginx")
+	pushImage(t, filepath.Join(base, "helm", "ghcr.io", "org", "charts", "app", "@"+nginx.Digest.String()), "@"+nginx.Digest.String(), "chart")
+
+	layouts, err := List(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layouts) != 2 {
+		t.Fatalf("List() found %d layouts, want 2", len(layouts))
+	}
+
+	helm, oci := layouts[0], layouts[1]
+	if helm.Type != "helm" || helm.Repository != "ghcr.io/org/charts/app" || helm.Reference != "ghcr.io/org/charts/app@"+nginx.Digest.String() {
+		t.Errorf("helm layout = %+v", helm)
+	}
+	if oci.Type != "oci" || oci.Name != "oci/docker.io/library/nginx/1.25" || oci.Reference != "docker.io/library/nginx:1.25" {
+		t.Errorf("oci layout = %+v", oci)
+	}
+	if !reflect.DeepEqual(oci.Tags(), []string{"1.25"}) || oci.Manifests[0].Digest != nginx.Digest.String() {
+		t.Errorf("oci manifests = %+v", oci.Manifests)
+	}
+	if oci.Size == 0 {
+		t.Error("oci size = 0, want the size on disk")
+	}
+
+	if layouts, err := List(filepath.Join(base, "missing")); err != nil || len(layouts) != 0 {
+		t.Errorf("List(missing) = %v, %v; want nothing", layouts, err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	base := t.TempDir()
+	desc := pushImage(t, filepath.Join(base, "oci", "docker.io", "library", "nginx", "1.25"), "1.25", "nginx")
+	pushImage(t, filepath.Join(base, "oci", "docker.io", "library", "nginx", "stable"), "stable", "nginx")
+	pushImage(t, filepath.Join(base, "oci", "ghcr.io", "org", "app", "v1"), "v1", "app")
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr string
+	}{
+		{name: "oci/ghcr.io/org/app/v1", want: "oci/ghcr.io/org/app/v1"},
+		{name: "ghcr.io/org/app:v1", want: "oci/ghcr.io/org/app/v1"},
+		{name: "nginx:1.25", want: "oci/docker.io/library/nginx/1.25"},
+		{name: filepath.Join(base, "oci", "docker.io", "library", "nginx", "stable"), want: "oci/docker.io/library/nginx/stable"},
+		{name: "ghcr.io/org/app:v2", wantErr: "no local artifact"},
+		// The same image is pulled under two tags
+		{name: desc.Digest.String(), wantErr: "matches 2 local artifacts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Find(base, tt.name)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Find() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if got.Name != tt.want {
+				t.Errorf("Find() = %s, want %s", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	base := t.TempDir()
+	pushImage(t, filepath.Join(base, "oci", "ghcr.io", "org", "app", "v1"), "v1", "app")
+	pushImage(t, filepath.Join(base, "oci", "ghcr.io", "org", "other", "v1"), "v1", "other")
+
+	l, err := Find(base, "ghcr.io/org/app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(base, l); err != nil {
+		t.Fatal(err)
+	}
+
+	// The emptied repository directory goes; the shared parents stay
+	if _, err := os.Stat(filepath.Join(base, "oci", "ghcr.io", "org", "app")); !os.IsNotExist(err) {
+		t.Errorf("repository directory left behind: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "oci", "ghcr.io", "org", "other", "v1")); err != nil {
+		t.Errorf("other layout removed: %v", err)
+	}
+	if layouts, _ := List(base); len(layouts) != 1 {
+		t.Errorf("List() after Remove = %d layouts, want 1", len(layouts))
+	}
+}
+
+func TestGC(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "oci", "ghcr.io", "org", "app", "v1")
+	// Re-pulling a moved tag leaves the old image's blobs behind
+	pushImage(t, dir, "v1", "old layer")
+	current := pushImage(t, dir, "v1", "new layer")
+	signature := pushReferrer(t, dir, current)
+	if err := os.MkdirAll(filepath.Join(dir, "ingest"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ingest", "abc.partial"), []byte("half"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Open(base, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldLayer := digest.FromString("old layer")
+	want := GCResult{Layout: l.Name, Manifests: 1, Blobs: 2, Partial: 1, Bytes: int64(len("old layer") + len("half"))}
+	want.Bytes += blobSize(t, dir, oldManifest(t, dir, current))
+
+	dry, err := GC(context.Background(), l, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *dry != want {
+		t.Errorf("GC(dry run) = %+v, want %+v", *dry, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blobs", "sha256", oldLayer.Encoded())); err != nil {
+		t.Fatalf("dry run removed a blob: %v", err)
+	}
+
+	sizeBefore := l.Size
+	got, err := GC(context.Background(), l, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != want {
+		t.Errorf("GC() = %+v, want %+v", *got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blobs", "sha256", oldLayer.Encoded())); !os.IsNotExist(err) {
+		t.Errorf("old layer not removed: %v", err)
+	}
+	// index.json shrinks too
+	if l.Size > sizeBefore-want.Bytes || len(l.Manifests) != 2 {
+		t.Errorf("after GC size = %d, manifests = %d; want at most %d and 2", l.Size, len(l.Manifests), sizeBefore-want.Bytes)
+	}
+
+	// What's still tagged, and its signature, are intact
+	reachable, live, err := reachableBlobs(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(live) != 2 || !reachable[current.Digest] || !reachable[signature.Digest] {
+		t.Errorf("live manifests = %v, want the tagged image and its signature", live)
+	}
+	for dgst := range reachable {
+		if _, err := os.Stat(filepath.Join(dir, "blobs", "sha256", dgst.Encoded())); err != nil {
+			t.Errorf("reachable blob %s removed", dgst)
+		}
+	}
+	if again, _ := GC(context.Background(), l, false); again.Blobs != 0 || again.Bytes != 0 {
+		t.Errorf("second GC = %+v, want nothing left", again)
+	}
+}
+
+// pushReferrer stores an untagged signature of subject in the layout at dir.
+func pushReferrer(t *testing.T, dir string, subject ocispec.Descriptor) ocispec.Descriptor {
+	t.Helper()
+	ctx := context.Background()
+	store, err := oci.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := []byte("{}")
+	configDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeEmptyJSON, Digest: digest.FromBytes(config), Size: int64(len(config))}
+	if err := store.Push(ctx, configDesc, bytes.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(ocispec.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.dev.cosign.artifact.sig.v1+json",
+		Config:       configDesc,
+		Layers:       []ocispec.Descriptor{configDesc},
+		Subject:      &subject,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	if err := store.Push(ctx, desc, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	return desc
+}
+
+// oldManifest returns the digest of the manifest blob in the layout at dir
+// other than current and its config.
+func oldManifest(t *testing.T, dir string, current ocispec.Descriptor) digest.Digest {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(dir, "blobs", "sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, "blobs", "sha256", e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Name() != current.Digest.Encoded() && strings.Contains(string(data), ocispec.MediaTypeImageLayer) {
+			return digest.NewDigestFromEncoded(digest.SHA256, e.Name())
+		}
+	}
+	t.Fatal("no old manifest")
+	return ""
+}
+
+func blobSize(t *testing.T, dir string, dgst digest.Digest) int64 {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(dir, "blobs", "sha256", dgst.Encoded()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
@@ -21,9 +21,22 @@ type OCIIndex struct {
 
 // OCIDescriptor is a content-addressable descriptor in an OCI layout.
 type OCIDescriptor struct {
-	MediaType string `json:"mediaType"`
-	Digest    string `json:"digest"`
-	Size      int64  `json:"size"`
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// TaggedManifest returns the first manifest of the index with a ref name,
+// or its first manifest when none is tagged. Layouts can also hold
+// untagged manifests, such as referrers or an index's children.
+func (i *OCIIndex) TaggedManifest() OCIDescriptor {
+	for _, m := range i.Manifests {
+		if m.Annotations["org.opencontainers.image.ref.name"] != "" {
+			return m
+		}
+	}
+	return i.Manifests[0]
 }
 
 // OCIManifest represents an OCI image manifest.
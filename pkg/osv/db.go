@@ -0,0 +1,184 @@
+package osv
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DB is an in-memory index of OSV records keyed by ecosystem and package
+// name. It is safe for concurrent reads once loaded.
+type DB struct {
+	index map[string][]*Vulnerability
+	ids   map[string]bool
+
+	// Skipped counts files that could not be decoded while loading.
+	Skipped int
+}
+
+// NewDB returns an empty database.
+func NewDB() *DB {
+	return &DB{
+		index: make(map[string][]*Vulnerability),
+		ids:   make(map[string]bool),
+	}
+}
+
+// Load reads every *.json and *.zip file under dir. JSON files may hold a
+// single record or an array of records. Withdrawn records are ignored.
+func Load(dir string) (*DB, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vulnerability database: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("vulnerability database %s is not a directory", dir)
+	}
+
+	db := NewDB()
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			db.addJSON(data)
+		case ".zip":
+			if err := db.addZip(path); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load vulnerability database: %w", err)
+	}
+	return db, nil
+}
+
+// Len returns the number of records in the database.
+func (db *DB) Len() int {
+	return len(db.ids)
+}
+
+// Add indexes a record under every package it affects. Records with an ID
+// already present, or that have been withdrawn, are ignored.
+func (db *DB) Add(v *Vulnerability) {
+	if v.ID == "" || v.Withdrawn != "" || db.ids[v.ID] {
+		return
+	}
+	db.ids[v.ID] = true
+
+	seen := make(map[string]bool)
+	for _, a := range v.Affected {
+		key := indexKey(a.Package.Ecosystem, a.Package.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		db.index[key] = append(db.index[key], v)
+	}
+}
+
+// lookup returns the records affecting a package.
+func (db *DB) lookup(ecosystem, name string) []*Vulnerability {
+	return db.index[indexKey(ecosystem, name)]
+}
+
+// addJSON decodes one record or an array of records.
+func (db *DB) addJSON(data []byte) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var vulns []*Vulnerability
+		if err := json.Unmarshal(data, &vulns); err != nil {
+			db.Skipped++
+			return
+		}
+		for _, v := range vulns {
+			db.Add(v)
+		}
+		return
+	}
+
+	var v Vulnerability
+	if err := json.Unmarshal(data, &v); err != nil || v.ID == "" {
+		db.Skipped++
+		return
+	}
+	db.Add(&v)
+}
+
+// addZip decodes every JSON entry of an OSV all.zip archive.
+func (db *DB) addZip(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if !strings.EqualFold(filepath.Ext(f.Name), ".json") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		db.addJSON(data)
+	}
+	return nil
+}
+
+// indexKey builds the lookup key for a package. The ecosystem release suffix
+// ("Debian:12" → "Debian") is dropped and names are normalized where the
+// ecosystem treats them case- or separator-insensitively.
+func indexKey(ecosystem, name string) string {
+	base := baseEcosystem(ecosystem)
+	return base + "\x00" + normalizeName(base, name)
+}
+
+// baseEcosystem strips the release suffix from an ecosystem name.
+func baseEcosystem(ecosystem string) string {
+	base, _, _ := strings.Cut(ecosystem, ":")
+	return base
+}
+
+// ecosystemRelease returns the release suffix of an ecosystem name, if any.
+func ecosystemRelease(ecosystem string) string {
+	_, release, _ := strings.Cut(ecosystem, ":")
+	return release
+}
+
+// normalizeName canonicalizes a package name for comparison.
+func normalizeName(ecosystem, name string) string {
+	switch ecosystem {
+	case "PyPI":
+		// PEP 503: runs of -, _ and . are equivalent, case-insensitive.
+		name = strings.ToLower(name)
+		return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+			return r == '-' || r == '_' || r == '.'
+		}), "-")
+	case "npm", "NuGet", "Packagist", "Hex":
+		return strings.ToLower(name)
+	default:
+		return name
+	}
+}
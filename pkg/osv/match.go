@@ -0,0 +1,261 @@
+package osv
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/attestation"
+	"github.com/mistergrinvalds/lazyoci/pkg/sbom"
+)
+
+// Finding is a vulnerability affecting one SBOM package.
+type Finding struct {
+	ID        string   `json:"id" yaml:"id"`
+	Aliases   []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Summary   string   `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Severity  string   `json:"severity" yaml:"severity"`
+	Score     float64  `json:"score,omitempty" yaml:"score,omitempty"`
+	Package   string   `json:"package" yaml:"package"`
+	Version   string   `json:"version" yaml:"version"`
+	Ecosystem string   `json:"ecosystem" yaml:"ecosystem"`
+	FixedIn   string   `json:"fixedIn,omitempty" yaml:"fixedIn,omitempty"`
+	PURL      string   `json:"purl,omitempty" yaml:"purl,omitempty"`
+}
+
+// Report is the result of matching an SBOM against the database.
+type Report struct {
+	// Packages is the number of SBOM packages checked against the database.
+	Packages int `json:"packages" yaml:"packages"`
+
+	// Unsupported is the number of packages skipped because they have no
+	// purl, no version, or a purl type with no OSV ecosystem.
+	Unsupported int `json:"unsupported" yaml:"unsupported"`
+
+	// Counts maps upper-case severity to the number of findings.
+	Counts map[string]int `json:"counts" yaml:"counts"`
+
+	// Findings lists matched vulnerabilities, most severe first.
+	Findings []Finding `json:"findings" yaml:"findings"`
+}
+
+// Total returns the number of findings across all severities.
+func (r *Report) Total() int {
+	return len(r.Findings)
+}
+
+// Match checks every package against the database. Packages are identified
+// by purl; the purl version is used, falling back to the package version.
+func (db *DB) Match(packages []sbom.Package) *Report {
+	report := &Report{Counts: make(map[string]int), Findings: []Finding{}}
+	seen := make(map[string]bool)
+
+	for _, pkg := range packages {
+		p, err := parsePURL(pkg.PURL)
+		if err != nil {
+			report.Unsupported++
+			continue
+		}
+		ecosystem, name, release, ok := p.ecosystem()
+		version := p.Version
+		if version == "" {
+			version = pkg.Version
+		}
+		if !ok || name == "" || version == "" {
+			report.Unsupported++
+			continue
+		}
+		report.Packages++
+
+		for _, v := range db.lookup(ecosystem, name) {
+			key := v.ID + "\x00" + name + "\x00" + version
+			if seen[key] {
+				continue
+			}
+			for i := range v.Affected {
+				a := &v.Affected[i]
+				if !affectsPackage(a, ecosystem, name, release) {
+					continue
+				}
+				affected, fixed := affectsVersion(a, ecosystem, version)
+				if !affected {
+					continue
+				}
+				seen[key] = true
+				sev, score := severity(v, a)
+				report.Findings = append(report.Findings, Finding{
+					ID:        v.ID,
+					Aliases:   v.Aliases,
+					Summary:   v.Summary,
+					Severity:  sev,
+					Score:     score,
+					Package:   name,
+					Version:   version,
+					Ecosystem: ecosystem,
+					FixedIn:   fixed,
+					PURL:      pkg.PURL,
+				})
+				report.Counts[sev]++
+				break
+			}
+		}
+	}
+
+	rank := make(map[string]int)
+	for i, s := range attestation.Severities() {
+		rank[s] = i
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if rank[a.Severity] != rank[b.Severity] {
+			return rank[a.Severity] < rank[b.Severity]
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.ID < b.ID
+	})
+	return report
+}
+
+// affectsPackage reports whether an affected entry names the package. An
+// entry for a specific distribution release ("Debian:12") only matches
+// packages from that release, when the SBOM records one.
+func affectsPackage(a *Affected, ecosystem, name, release string) bool {
+	if baseEcosystem(a.Package.Ecosystem) != ecosystem {
+		return false
+	}
+	if normalizeName(ecosystem, a.Package.Name) != normalizeName(ecosystem, name) {
+		return false
+	}
+	affectedRelease := ecosystemRelease(a.Package.Ecosystem)
+	if release == "" || affectedRelease == "" {
+		return true
+	}
+	return affectedRelease == release || strings.HasPrefix(affectedRelease, release+":")
+}
+
+// affectsVersion reports whether version falls in an affected entry, and the
+// first fixed version above it, if known.
+func affectsVersion(a *Affected, ecosystem, version string) (bool, string) {
+	listed := false
+	for _, v := range a.Versions {
+		if compareVersions(ecosystem, v, version) == 0 {
+			listed = true
+			break
+		}
+	}
+
+	for _, r := range a.Ranges {
+		scheme := ecosystem
+		switch r.Type {
+		case "SEMVER":
+			scheme = "SEMVER"
+		case "ECOSYSTEM":
+		default:
+			continue
+		}
+		if affected, fixed := inRange(r.Events, scheme, version); affected {
+			return true, fixed
+		}
+	}
+	return listed, ""
+}
+
+// eventVersion returns the version an event refers to.
+func eventVersion(e Event) string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	case e.LastAffected != "":
+		return e.LastAffected
+	default:
+		return e.Limit
+	}
+}
+
+// inRange evaluates OSV range events against version. Events are sorted
+// (introduced "0" first) and replayed; the last event at or below version
+// decides whether it is affected.
+func inRange(events []Event, scheme, version string) (bool, string) {
+	sorted := make([]Event, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := eventVersion(sorted[i]), eventVersion(sorted[j])
+		if a == "0" || b == "0" {
+			return a == "0" && b != "0"
+		}
+		return compareVersions(scheme, a, b) < 0
+	})
+
+	affected := false
+	for _, e := range sorted {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || compareVersions(scheme, version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if compareVersions(scheme, version, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if compareVersions(scheme, version, e.LastAffected) > 0 {
+				affected = false
+			}
+		case e.Limit != "":
+			if e.Limit != "*" && compareVersions(scheme, version, e.Limit) >= 0 {
+				affected = false
+			}
+		}
+	}
+	if !affected {
+		return false, ""
+	}
+
+	for _, e := range sorted {
+		if e.Fixed != "" && compareVersions(scheme, e.Fixed, version) > 0 {
+			return true, e.Fixed
+		}
+	}
+	return true, ""
+}
+
+// severity determines a finding's severity. Labels assigned by the database
+// (e.g. GitHub's "MODERATE") take precedence; otherwise the CVSS v3 base
+// score is rated. score is zero when no CVSS v3 vector is recorded.
+func severity(v *Vulnerability, a *Affected) (label string, score float64) {
+	for _, s := range append(append([]Severity{}, a.Severity...), v.Severity...) {
+		if strings.HasPrefix(s.Type, "CVSS_V3") {
+			if sc, err := CVSS3Score(s.Score); err == nil {
+				score = sc
+				break
+			}
+		}
+	}
+
+	for _, m := range []map[string]any{a.EcosystemSpecific, a.DatabaseSpecific, v.DatabaseSpecific} {
+		if s, ok := m["severity"].(string); ok {
+			if sev := attestation.NormalizeSeverity(s); sev != "UNKNOWN" {
+				return sev, score
+			}
+		}
+	}
+	// Distribution feeds such as Ubuntu's record a priority label in
+	// place of a vector.
+	for _, s := range append(append([]Severity{}, a.Severity...), v.Severity...) {
+		if !strings.HasPrefix(s.Type, "CVSS") {
+			if sev := attestation.NormalizeSeverity(s.Score); sev != "UNKNOWN" {
+				return sev, score
+			}
+		}
+	}
+	if score > 0 {
+		return scoreSeverity(score), score
+	}
+	return "UNKNOWN", 0
+}
@@ -0,0 +1,59 @@
+// Package osv matches SBOM packages against a local copy of the OSV
+// vulnerability database (https://osv.dev).
+//
+// A database directory holds OSV JSON records, either as individual files
+// or as the per-ecosystem all.zip archives published at
+// https://osv-vulnerabilities.storage.googleapis.com. Packages are matched
+// by package URL: the purl type selects the OSV ecosystem, and the version
+// is compared against each record's affected ranges using that ecosystem's
+// version ordering (semver, PEP 440, Maven, dpkg, apk, ...).
+package osv
+
+// Vulnerability is the subset of an OSV record used for matching.
+type Vulnerability struct {
+	ID               string         `json:"id"`
+	Aliases          []string       `json:"aliases,omitempty"`
+	Summary          string         `json:"summary,omitempty"`
+	Withdrawn        string         `json:"withdrawn,omitempty"`
+	Severity         []Severity     `json:"severity,omitempty"`
+	Affected         []Affected     `json:"affected,omitempty"`
+	DatabaseSpecific map[string]any `json:"database_specific,omitempty"`
+}
+
+// Severity is a scored severity, e.g. a CVSS vector.
+type Severity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// Affected describes the versions of one package a vulnerability affects.
+type Affected struct {
+	Package           Package        `json:"package"`
+	Ranges            []Range        `json:"ranges,omitempty"`
+	Versions          []string       `json:"versions,omitempty"`
+	Severity          []Severity     `json:"severity,omitempty"`
+	EcosystemSpecific map[string]any `json:"ecosystem_specific,omitempty"`
+	DatabaseSpecific  map[string]any `json:"database_specific,omitempty"`
+}
+
+// Package identifies a package within an OSV ecosystem.
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	PURL      string `json:"purl,omitempty"`
+}
+
+// Range is a sequence of version events. Type is SEMVER, ECOSYSTEM or GIT;
+// GIT ranges (commit hashes) are not evaluated.
+type Range struct {
+	Type   string  `json:"type"`
+	Events []Event `json:"events"`
+}
+
+// Event is one boundary of an affected range. Exactly one field is set.
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
@@ -0,0 +1,303 @@
+package osv
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/sbom"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		ecosystem string
+		a, b      string
+		want      int
+	}{
+		{"Go", "1.2.3", "v1.2.3", 0},
+		{"Go", "1.10.0", "1.9.9", 1},
+		{"npm", "1.0.0-alpha", "1.0.0", -1},
+		{"npm", "1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"npm", "1.0.0-rc.1", "1.0.0-beta.11", 1},
+		{"npm", "1.0.0+build.1", "1.0.0", 0},
+		{"crates.io", "0.2", "0.2.0", 0},
+		{"PyPI", "1.0.dev1", "1.0a1", -1},
+		{"PyPI", "1.0a1", "1.0rc1", -1},
+		{"PyPI", "1.0rc1", "1.0", -1},
+		{"PyPI", "1.0", "1.0.post1", -1},
+		{"PyPI", "1.0-1", "1.0.post1", 0},
+		{"PyPI", "1!0.5", "2.0", 1},
+		{"PyPI", "2.0.0", "2.0", 0},
+		{"Maven", "1.0-alpha-1", "1.0", -1},
+		{"Maven", "1.0-rc1", "1.0-beta2", 1},
+		{"Maven", "1.0-SNAPSHOT", "1.0", -1},
+		{"Maven", "1.0", "1.0-sp1", -1},
+		{"Maven", "2.12.7.1", "2.12.7", 1},
+		{"Debian", "1.0~rc1-1", "1.0-1", -1},
+		{"Debian", "1:0.9", "2.0", 1},
+		{"Debian", "3.0.11-1~deb12u2", "3.0.11-1", -1},
+		{"Debian", "2.36-9+deb12u3", "2.36-9+deb12u4", -1},
+		{"Debian:12", "1.2.10-1", "1.2.9-1", 1},
+		{"Ubuntu", "1.0-1ubuntu1", "1.0-1", 1},
+		{"Alpine", "1.36.1-r2", "1.36.1-r10", -1},
+		{"Alpine", "3.1.4_rc1-r0", "3.1.4-r0", -1},
+		{"Alpine", "3.1.4_p1-r0", "3.1.4-r0", 1},
+		{"Alpine", "1.2a-r0", "1.2-r0", 1},
+		{"RubyGems", "1.0.0.pre", "1.0.0", -1},
+		{"RubyGems", "1.10", "1.9", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ecosystem+" "+tt.a+" vs "+tt.b, func(t *testing.T) {
+			if got := compareVersions(tt.ecosystem, tt.a, tt.b); got != tt.want {
+				t.Errorf("compareVersions(%q, %q, %q) = %d, want %d", tt.ecosystem, tt.a, tt.b, got, tt.want)
+			}
+			if got := compareVersions(tt.ecosystem, tt.b, tt.a); got != -tt.want {
+				t.Errorf("compareVersions(%q, %q, %q) = %d, want %d", tt.ecosystem, tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestPURLEcosystem(t *testing.T) {
+	tests := []struct {
+		purl                          string
+		ecosystem, name, release, ver string
+		ok                            bool
+	}{
+		{"pkg:golang/golang.org/x/net@v0.17.0", "Go", "golang.org/x/net", "", "v0.17.0", true},
+		{"pkg:npm/%40babel/core@7.0.0", "npm", "@babel/core", "", "7.0.0", true},
+		{"pkg:pypi/Django@4.2", "PyPI", "Django", "", "4.2", true},
+		{"pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1?type=jar", "Maven", "org.apache.logging.log4j:log4j-core", "", "2.14.1", true},
+		{"pkg:deb/debian/libssl3@3.0.11-1~deb12u1?arch=amd64&upstream=openssl&distro=debian-12", "Debian", "openssl", "12", "3.0.11-1~deb12u1", true},
+		{"pkg:deb/ubuntu/libc6@2.35-0ubuntu3?distro=ubuntu-22.04", "Ubuntu", "libc6", "22.04", "2.35-0ubuntu3", true},
+		{"pkg:apk/alpine/busybox-binsh@1.36.1-r2?upstream=busybox&distro=alpine-3.18.4", "Alpine", "busybox", "v3.18", "1.36.1-r2", true},
+		{"pkg:generic/thing@1.0", "", "", "", "1.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.purl, func(t *testing.T) {
+			p, err := parsePURL(tt.purl)
+			if err != nil {
+				t.Fatalf("parsePURL() error = %v", err)
+			}
+			if p.Version != tt.ver {
+				t.Errorf("Version = %q, want %q", p.Version, tt.ver)
+			}
+			eco, name, release, ok := p.ecosystem()
+			if ok != tt.ok || eco != tt.ecosystem || name != tt.name || release != tt.release {
+				t.Errorf("ecosystem() = (%q, %q, %q, %v), want (%q, %q, %q, %v)",
+					eco, name, release, ok, tt.ecosystem, tt.name, tt.release, tt.ok)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "golang/x@1", "pkg:npm"} {
+		if _, err := parsePURL(bad); err == nil {
+			t.Errorf("parsePURL(%q) error = nil, want error", bad)
+		}
+	}
+}
+
+func TestCVSS3Score(t *testing.T) {
+	tests := []struct {
+		vector string
+		want   float64
+	}{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1},
+		{"CVSS:3.0/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N", 5.5},
+		{"CVSS:3.1/AV:N/AC:H/PR:H/UI:R/S:C/C:H/I:H/A:H", 7.6},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", 0},
+	}
+	for _, tt := range tests {
+		got, err := CVSS3Score(tt.vector)
+		if err != nil {
+			t.Errorf("CVSS3Score(%q) error = %v", tt.vector, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("CVSS3Score(%q) = %v, want %v", tt.vector, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"AV:N/AC:L", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/C:H/I:H/A:H", "CVSS:3.1/AV:X/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"} {
+		if _, err := CVSS3Score(bad); err == nil {
+			t.Errorf("CVSS3Score(%q) error = nil, want error", bad)
+		}
+	}
+}
+
+func TestInRange(t *testing.T) {
+	events := []Event{
+		{Fixed: "1.2.0"},
+		{Introduced: "0"},
+		{Introduced: "2.0.0"},
+		{LastAffected: "2.3.0"},
+	}
+	tests := []struct {
+		version   string
+		want      bool
+		wantFixed string
+	}{
+		{"1.0.0", true, "1.2.0"},
+		{"1.2.0", false, ""},
+		{"1.9.0", false, ""},
+		{"2.0.0", true, ""},
+		{"2.3.0", true, ""},
+		{"2.3.1", false, ""},
+	}
+	for _, tt := range tests {
+		got, fixed := inRange(events, "SEMVER", tt.version)
+		if got != tt.want || fixed != tt.wantFixed {
+			t.Errorf("inRange(%q) = (%v, %q), want (%v, %q)", tt.version, got, fixed, tt.want, tt.wantFixed)
+		}
+	}
+}
+
+const ghsaRecord = `{
+  "id": "GHSA-xxxx-yyyy-zzzz",
+  "aliases": ["CVE-2023-0001"],
+  "summary": "Request smuggling in x/net",
+  "database_specific": {"severity": "MODERATE"},
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "golang.org/x/net"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "0.17.0"}]}]
+  }]
+}`
+
+const debianRecords = `[
+  {
+    "id": "DSA-0001-1",
+    "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}],
+    "affected": [{
+      "package": {"ecosystem": "Debian:12", "name": "openssl"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.0.11-1~deb12u2"}]}]
+    }]
+  },
+  {
+    "id": "DSA-0002-1",
+    "affected": [{
+      "package": {"ecosystem": "Debian:11", "name": "openssl"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "9.9"}]}]
+    }]
+  },
+  {
+    "id": "DSA-0003-1",
+    "withdrawn": "2024-01-01T00:00:00Z",
+    "affected": [{"package": {"ecosystem": "Debian:12", "name": "openssl"}, "versions": ["3.0.11-1~deb12u1"]}]
+  }
+]`
+
+const pypiRecord = `{
+  "id": "PYSEC-2024-1",
+  "affected": [{
+    "package": {"ecosystem": "PyPI", "name": "django"},
+    "versions": ["4.2"],
+    "ecosystem_specific": {"severity": "LOW"}
+  }]
+}`
+
+func writeDB(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "GHSA-xxxx-yyyy-zzzz.json"), []byte(ghsaRecord), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "debian"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "debian", "all.json"), []byte(debianRecords), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(filepath.Join(dir, "PyPI.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("PYSEC-2024-1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(pypiRecord)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadAndMatch(t *testing.T) {
+	db, err := Load(writeDB(t))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if db.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", db.Len())
+	}
+	if db.Skipped != 1 {
+		t.Errorf("Skipped = %d, want 1", db.Skipped)
+	}
+
+	report := db.Match([]sbom.Package{
+		{Name: "golang.org/x/net", PURL: "pkg:golang/golang.org/x/net@v0.15.0"},
+		{Name: "golang.org/x/net", PURL: "pkg:golang/golang.org/x/net@v0.15.0"},
+		{Name: "libssl3", PURL: "pkg:deb/debian/libssl3@3.0.11-1~deb12u1?upstream=openssl&distro=debian-12"},
+		{Name: "Django", Version: "4.2", PURL: "pkg:pypi/Django"},
+		{Name: "fixed", PURL: "pkg:golang/golang.org/x/net@v0.17.0"},
+		{Name: "no-purl", Version: "1.0"},
+		{Name: "generic", PURL: "pkg:generic/thing@1.0"},
+	})
+
+	if report.Packages != 5 || report.Unsupported != 2 {
+		t.Errorf("Packages, Unsupported = %d, %d, want 5, 2", report.Packages, report.Unsupported)
+	}
+
+	wantIDs := []string{"DSA-0001-1", "GHSA-xxxx-yyyy-zzzz", "PYSEC-2024-1"}
+	if len(report.Findings) != len(wantIDs) {
+		t.Fatalf("Findings = %+v, want %v", report.Findings, wantIDs)
+	}
+	for i, id := range wantIDs {
+		if report.Findings[i].ID != id {
+			t.Errorf("Findings[%d].ID = %q, want %q", i, report.Findings[i].ID, id)
+		}
+	}
+
+	dsa := report.Findings[0]
+	if dsa.Severity != "CRITICAL" || dsa.Score != 9.8 || dsa.FixedIn != "3.0.11-1~deb12u2" || dsa.Package != "openssl" {
+		t.Errorf("DSA finding = %+v", dsa)
+	}
+	if ghsa := report.Findings[1]; ghsa.Severity != "MEDIUM" || ghsa.FixedIn != "0.17.0" {
+		t.Errorf("GHSA finding = %+v", ghsa)
+	}
+	if report.Counts["CRITICAL"] != 1 || report.Counts["MEDIUM"] != 1 || report.Counts["LOW"] != 1 {
+		t.Errorf("Counts = %v", report.Counts)
+	}
+	if report.Total() != 3 {
+		t.Errorf("Total() = %d, want 3", report.Total())
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Load(missing) error = nil, want error")
+	}
+
+	file := filepath.Join(t.TempDir(), "file.json")
+	if err := os.WriteFile(file, []byte(ghsaRecord), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(file); err == nil {
+		t.Error("Load(file) error = nil, want error")
+	}
+}
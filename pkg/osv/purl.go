@@ -0,0 +1,139 @@
+package osv
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// purl is a parsed package URL (https://github.com/package-url/purl-spec).
+type purl struct {
+	Type       string
+	Namespace  string
+	Name       string
+	Version    string
+	Qualifiers map[string]string
+}
+
+// parsePURL parses "pkg:type/namespace/name@version?qualifiers#subpath".
+func parsePURL(s string) (purl, error) {
+	rest, ok := strings.CutPrefix(s, "pkg:")
+	if !ok {
+		return purl{}, fmt.Errorf("invalid purl %q: missing pkg: scheme", s)
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+
+	p := purl{Qualifiers: make(map[string]string)}
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		q, err := url.ParseQuery(rest[i+1:])
+		if err != nil {
+			return purl{}, fmt.Errorf("invalid purl %q: %w", s, err)
+		}
+		for k, v := range q {
+			if len(v) > 0 {
+				p.Qualifiers[strings.ToLower(k)] = v[0]
+			}
+		}
+		rest = rest[:i]
+	}
+	if i := strings.LastIndexByte(rest, '@'); i >= 0 {
+		p.Version = unescape(rest[i+1:])
+		rest = rest[:i]
+	}
+
+	typ, path, ok := strings.Cut(strings.Trim(rest, "/"), "/")
+	if !ok || typ == "" || path == "" {
+		return purl{}, fmt.Errorf("invalid purl %q: missing type or name", s)
+	}
+	p.Type = strings.ToLower(typ)
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		p.Namespace = unescape(path[:i])
+		path = path[i+1:]
+	}
+	p.Name = unescape(path)
+	return p, nil
+}
+
+// unescape percent-decodes a purl component, returning it unchanged when
+// it is not valid escaping.
+func unescape(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// ecosystem maps the purl onto an OSV ecosystem, package name and release
+// (for distribution packages, e.g. "12" for Debian 12). ok is false for purl
+// types with no OSV ecosystem.
+func (p purl) ecosystem() (ecosystem, name, release string, ok bool) {
+	switch p.Type {
+	case "golang":
+		return "Go", joinNonEmpty("/", p.Namespace, p.Name), "", true
+	case "npm":
+		return "npm", joinNonEmpty("/", p.Namespace, p.Name), "", true
+	case "pypi":
+		return "PyPI", p.Name, "", true
+	case "maven":
+		return "Maven", joinNonEmpty(":", p.Namespace, p.Name), "", true
+	case "cargo":
+		return "crates.io", p.Name, "", true
+	case "gem":
+		return "RubyGems", p.Name, "", true
+	case "nuget":
+		return "NuGet", p.Name, "", true
+	case "composer":
+		return "Packagist", joinNonEmpty("/", p.Namespace, p.Name), "", true
+	case "hex":
+		return "Hex", p.Name, "", true
+	case "pub":
+		return "Pub", p.Name, "", true
+	case "deb":
+		name = p.sourceName()
+		distro, version, _ := strings.Cut(p.Qualifiers["distro"], "-")
+		switch strings.ToLower(p.Namespace) {
+		case "debian":
+			major, _, _ := strings.Cut(version, ".")
+			return "Debian", name, major, true
+		case "ubuntu":
+			return "Ubuntu", name, version, true
+		}
+		if strings.EqualFold(distro, "ubuntu") {
+			return "Ubuntu", name, version, true
+		}
+		return "Debian", name, "", true
+	case "apk":
+		release = ""
+		if _, version, ok := strings.Cut(p.Qualifiers["distro"], "-"); ok {
+			parts := strings.SplitN(version, ".", 3)
+			if len(parts) >= 2 {
+				release = "v" + parts[0] + "." + parts[1]
+			}
+		}
+		return "Alpine", p.sourceName(), release, true
+	}
+	return "", "", "", false
+}
+
+// sourceName returns the source package a distribution package was built
+// from, which is what distribution advisories are keyed by. SBOM tools record
+// it in the "upstream" qualifier, optionally with a version.
+func (p purl) sourceName() string {
+	if upstream := p.Qualifiers["upstream"]; upstream != "" {
+		name, _, _ := strings.Cut(upstream, "@")
+		name, _, _ = strings.Cut(name, " ")
+		return name
+	}
+	return p.Name
+}
+
+// joinNonEmpty joins the non-empty elements with sep.
+func joinNonEmpty(sep string, elems ...string) string {
+	var parts []string
+	for _, e := range elems {
+		if e != "" {
+			parts = append(parts, e)
+		}
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,509 @@
+package osv
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// compareVersions orders two versions of a package in the given ecosystem.
+// It returns -1, 0 or +1. Versions that do not follow the ecosystem's
+// scheme fall back to a generic numeric/alphabetic comparison.
+func compareVersions(ecosystem, a, b string) int {
+	switch baseEcosystem(ecosystem) {
+	case "Go", "npm", "crates.io", "Hex", "Pub", "NuGet", "SEMVER":
+		return compareSemver(a, b)
+	case "PyPI":
+		return comparePEP440(a, b)
+	case "Maven":
+		return compareMaven(a, b)
+	case "Debian", "Ubuntu":
+		return compareDpkg(a, b)
+	case "Alpine":
+		return compareAPK(a, b)
+	default:
+		return compareGeneric(a, b)
+	}
+}
+
+// sign clamps n to -1, 0 or +1.
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compareNumeric compares two digit strings of any length.
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+// compareDotted compares dot-separated release numbers; missing trailing
+// components count as zero, so "1.2" equals "1.2.0".
+func compareDotted(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		x, y := "0", "0"
+		if i < len(as) && as[i] != "" {
+			x = as[i]
+		}
+		if i < len(bs) && bs[i] != "" {
+			y = bs[i]
+		}
+		var c int
+		if isDigits(x) && isDigits(y) {
+			c = compareNumeric(x, y)
+		} else {
+			c = strings.Compare(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareSemver implements Semantic Versioning 2.0 precedence. A leading
+// "v" and build metadata are ignored.
+func compareSemver(a, b string) int {
+	a, _, _ = strings.Cut(strings.TrimPrefix(a, "v"), "+")
+	b, _, _ = strings.Cut(strings.TrimPrefix(b, "v"), "+")
+	aMain, aPre, _ := strings.Cut(a, "-")
+	bMain, bPre, _ := strings.Cut(b, "-")
+
+	if c := compareDotted(aMain, bMain); c != 0 {
+		return c
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+
+	as, bs := strings.Split(aPre, "."), strings.Split(bPre, ".")
+	for i := 0; i < min(len(as), len(bs)); i++ {
+		x, y := as[i], bs[i]
+		xNum, yNum := isDigits(x), isDigits(y)
+		var c int
+		switch {
+		case xNum && yNum:
+			c = compareNumeric(x, y)
+		case xNum:
+			c = -1
+		case yNum:
+			c = 1
+		default:
+			c = strings.Compare(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return sign(len(as) - len(bs))
+}
+
+// versionTokens splits a version into alternating digit and letter runs,
+// dropping separators: "1.0rc2" → [1 0 rc 2].
+func versionTokens(v string) []string {
+	var tokens []string
+	start := -1
+	digit := false
+	flush := func(i int) {
+		if start >= 0 {
+			tokens = append(tokens, v[start:i])
+			start = -1
+		}
+	}
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		isDigit := c >= '0' && c <= '9'
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		switch {
+		case !isDigit && !isLetter:
+			flush(i)
+		case start < 0:
+			start, digit = i, isDigit
+		case isDigit != digit:
+			flush(i)
+			start, digit = i, isDigit
+		}
+	}
+	flush(len(v))
+	return tokens
+}
+
+// compareGeneric compares token by token: numbers numerically, words
+// case-insensitively, numbers above words. A trailing word marks a
+// pre-release, so "1.0.rc1" sorts before "1.0".
+func compareGeneric(a, b string) int {
+	as, bs := versionTokens(a), versionTokens(b)
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		if i >= len(as) {
+			if isDigits(bs[i]) {
+				return -1
+			}
+			return 1
+		}
+		if i >= len(bs) {
+			if isDigits(as[i]) {
+				return 1
+			}
+			return -1
+		}
+		x, y := as[i], bs[i]
+		xNum, yNum := isDigits(x), isDigits(y)
+		var c int
+		switch {
+		case xNum && yNum:
+			c = compareNumeric(x, y)
+		case xNum:
+			c = 1
+		case yNum:
+			c = -1
+		default:
+			c = strings.Compare(strings.ToLower(x), strings.ToLower(y))
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareDpkg implements Debian version ordering: [epoch:]upstream[-revision].
+func compareDpkg(a, b string) int {
+	aEpoch, aUp, aRev := splitDpkg(a)
+	bEpoch, bUp, bRev := splitDpkg(b)
+	if c := compareNumeric(aEpoch, bEpoch); c != 0 {
+		return c
+	}
+	if c := dpkgVerrevcmp(aUp, bUp); c != 0 {
+		return c
+	}
+	return dpkgVerrevcmp(aRev, bRev)
+}
+
+// splitDpkg splits a Debian version into epoch, upstream and revision.
+func splitDpkg(v string) (epoch, upstream, revision string) {
+	epoch = "0"
+	if e, rest, ok := strings.Cut(v, ":"); ok && isDigits(e) {
+		epoch, v = e, rest
+	}
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+// dpkgOrder weights a character for dpkgVerrevcmp: "~" sorts before
+// everything (even the end of the string), letters before other symbols.
+func dpkgOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	c := s[i]
+	switch {
+	case c >= '0' && c <= '9':
+		return 0
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+// dpkgVerrevcmp is dpkg's verrevcmp: alternating non-digit and digit runs.
+func dpkgVerrevcmp(a, b string) int {
+	isDigit := func(s string, i int) bool { return i < len(s) && s[i] >= '0' && s[i] <= '9' }
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		for (i < len(a) && !isDigit(a, i)) || (j < len(b) && !isDigit(b, j)) {
+			if ac, bc := dpkgOrder(a, i), dpkgOrder(b, j); ac != bc {
+				return sign(ac - bc)
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		firstDiff := 0
+		for isDigit(a, i) && isDigit(b, j) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if isDigit(a, i) {
+			return 1
+		}
+		if isDigit(b, j) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+// apkSuffixRank orders Alpine version suffixes; a missing suffix ranks
+// between pre-release (_rc) and post-release (_cvs, _p) suffixes.
+var apkSuffixRank = map[string]int{
+	"alpha": 0,
+	"beta":  1,
+	"pre":   2,
+	"rc":    3,
+	"":      4,
+	"cvs":   5,
+	"svn":   6,
+	"git":   7,
+	"hg":    8,
+	"p":     9,
+}
+
+// apkVersion is a parsed Alpine package version: 1.2.3a_rc1-r4.
+type apkVersion struct {
+	release  string
+	letter   string
+	suffixes [][2]string
+	revision string
+}
+
+// parseAPK splits an Alpine version into its components.
+func parseAPK(v string) apkVersion {
+	var av apkVersion
+	av.revision = "0"
+	if i := strings.LastIndex(v, "-r"); i >= 0 && isDigits(v[i+2:]) {
+		v, av.revision = v[:i], v[i+2:]
+	}
+	parts := strings.Split(v, "_")
+	av.release = parts[0]
+	if n := len(av.release); n > 0 && av.release[n-1] >= 'a' && av.release[n-1] <= 'z' {
+		av.release, av.letter = av.release[:n-1], av.release[n-1:]
+	}
+	for _, s := range parts[1:] {
+		name := strings.TrimRight(s, "0123456789")
+		av.suffixes = append(av.suffixes, [2]string{name, s[len(name):]})
+	}
+	return av
+}
+
+// compareAPK implements Alpine (apk-tools) version ordering.
+func compareAPK(a, b string) int {
+	av, bv := parseAPK(a), parseAPK(b)
+	if c := compareDotted(av.release, bv.release); c != 0 {
+		return c
+	}
+	if c := strings.Compare(av.letter, bv.letter); c != 0 {
+		return c
+	}
+	for i := 0; i < max(len(av.suffixes), len(bv.suffixes)); i++ {
+		var x, y [2]string
+		if i < len(av.suffixes) {
+			x = av.suffixes[i]
+		}
+		if i < len(bv.suffixes) {
+			y = bv.suffixes[i]
+		}
+		if c := sign(apkSuffixRank[x[0]] - apkSuffixRank[y[0]]); c != 0 {
+			return c
+		}
+		if c := compareNumeric(x[1], y[1]); c != 0 {
+			return c
+		}
+	}
+	return compareNumeric(av.revision, bv.revision)
+}
+
+// pep440Pattern matches PEP 440 versions, including the permitted
+// alternative spellings (e.g. "1.0-rc.1", "1.0.post", "1.0-1").
+var pep440Pattern = regexp.MustCompile(`^v?(?:(\d+)!)?(\d+(?:\.\d+)*)` +
+	`(?:[-_.]?(a|alpha|b|beta|c|rc|pre|preview)[-_.]?(\d*))?` +
+	`(?:-(\d+)|[-_.]?(post|rev|r)[-_.]?(\d*))?` +
+	`(?:[-_.]?(dev)[-_.]?(\d*))?` +
+	`(?:\+[a-z0-9.]*)?$`)
+
+// pep440Phase ranks pre-release phases.
+var pep440Phase = map[string]int{
+	"a": 0, "alpha": 0, "b": 1, "beta": 1, "c": 2, "rc": 2, "pre": 2, "preview": 2,
+}
+
+// pep440Version is a parsed PEP 440 version. Absent segments use sentinel
+// ranks so that plain tuple comparison gives the PEP ordering:
+// 1.0.dev1 < 1.0a1 < 1.0 < 1.0.post1.
+type pep440Version struct {
+	epoch   int
+	release string
+	pre     [2]int // phase, number; {3, 0} when absent, {-1, 0} for dev-only
+	post    int    // -1 when absent
+	dev     int    // MaxInt when absent
+}
+
+// parsePEP440 parses v, reporting whether it is a valid PEP 440 version.
+func parsePEP440(v string) (pep440Version, bool) {
+	m := pep440Pattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(v)))
+	if m == nil {
+		return pep440Version{}, false
+	}
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	pv := pep440Version{
+		epoch:   atoi(m[1]),
+		release: m[2],
+		pre:     [2]int{3, 0},
+		post:    -1,
+		dev:     int(^uint(0) >> 1),
+	}
+	if m[3] != "" {
+		pv.pre = [2]int{pep440Phase[m[3]], atoi(m[4])}
+	}
+	switch {
+	case m[5] != "":
+		pv.post = atoi(m[5])
+	case m[6] != "":
+		pv.post = atoi(m[7])
+	}
+	if m[8] != "" {
+		pv.dev = atoi(m[9])
+		if m[3] == "" && pv.post < 0 {
+			pv.pre = [2]int{-1, 0}
+		}
+	}
+	return pv, true
+}
+
+// comparePEP440 implements Python (PEP 440) version ordering.
+func comparePEP440(a, b string) int {
+	av, aok := parsePEP440(a)
+	bv, bok := parsePEP440(b)
+	if !aok || !bok {
+		return compareGeneric(a, b)
+	}
+	if c := sign(av.epoch - bv.epoch); c != 0 {
+		return c
+	}
+	if c := compareDotted(av.release, bv.release); c != 0 {
+		return c
+	}
+	if c := sign(av.pre[0] - bv.pre[0]); c != 0 {
+		return c
+	}
+	if c := sign(av.pre[1] - bv.pre[1]); c != 0 {
+		return c
+	}
+	if c := sign(av.post - bv.post); c != 0 {
+		return c
+	}
+	return sign(av.dev - bv.dev)
+}
+
+// mavenQualifiers ranks well-known Maven qualifiers. Unknown qualifiers
+// sort after all of these, alphabetically.
+var mavenQualifiers = map[string]int{
+	"alpha":     0,
+	"a":         0,
+	"beta":      1,
+	"b":         1,
+	"milestone": 2,
+	"m":         2,
+	"rc":        3,
+	"cr":        3,
+	"snapshot":  4,
+	"":          5,
+	"ga":        5,
+	"final":     5,
+	"release":   5,
+	"sp":        6,
+}
+
+// compareMavenQualifier compares two qualifier words.
+func compareMavenQualifier(x, y string) int {
+	xr, xok := mavenQualifiers[x]
+	yr, yok := mavenQualifiers[y]
+	switch {
+	case xok && yok:
+		return sign(xr - yr)
+	case xok:
+		return -1
+	case yok:
+		return 1
+	}
+	return strings.Compare(x, y)
+}
+
+// compareMaven implements a simplified Maven ComparableVersion ordering:
+// numbers compare numerically, qualifiers by rank, and numbers sort after
+// qualifiers at the same position.
+func compareMaven(a, b string) int {
+	as := versionTokens(strings.ToLower(a))
+	bs := versionTokens(strings.ToLower(b))
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		x, y := "", ""
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xNum, yNum := isDigits(x), isDigits(y)
+		var c int
+		switch {
+		case xNum && yNum:
+			c = compareNumeric(x, y)
+		case xNum:
+			if y == "" {
+				c = compareNumeric(x, "0")
+			} else {
+				c = 1
+			}
+		case yNum:
+			if x == "" {
+				c = compareNumeric("0", y)
+			} else {
+				c = -1
+			}
+		default:
+			c = compareMavenQualifier(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
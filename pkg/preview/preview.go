@@ -0,0 +1,259 @@
+// Package preview renders artifact file content for display.
+//
+// Content is streamed line by line, so arbitrarily large files can be
+// previewed without reading them into memory. Lines longer than 64 KiB,
+// such as minified JSON, are read and highlighted in pieces of that size,
+// so memory stays bounded whatever the content. Text is syntax highlighted
+// according to its detected format (JSON, YAML, Rego, or plain text) and
+// binary content is rendered as a hexdump. Highlighting is expressed as
+// token kinds; a Style maps them to terminal escapes, tview color tags, or
+// nothing at all.
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format is how content is rendered.
+type Format string
+
+const (
+	FormatText   Format = "text"
+	FormatJSON   Format = "json"
+	FormatYAML   Format = "yaml"
+	FormatRego   Format = "rego"
+	FormatBinary Format = "binary"
+)
+
+// sniffLen is how much content Render passes to Detect.
+const sniffLen = 8 << 10
+
+// maxLineLen is the most of a line Render holds at once. Longer lines are
+// rendered in pieces, each highlighted on its own.
+const maxLineLen = 64 << 10
+
+// Detect chooses a format from a file name, media type, and the first
+// bytes of content. Content that is not valid UTF-8, or contains NUL bytes,
+// is binary regardless of its name or media type.
+func Detect(name, mediaType string, head []byte) Format {
+	if isBinary(head) {
+		return FormatBinary
+	}
+
+	mt := strings.ToLower(mediaType)
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".rego":
+		return FormatRego
+	}
+	switch {
+	case strings.Contains(mt, "json"):
+		return FormatJSON
+	case strings.Contains(mt, "yaml"):
+		return FormatYAML
+	case strings.Contains(mt, "rego"):
+		return FormatRego
+	}
+
+	if trimmed := bytes.TrimSpace(head); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return FormatJSON
+	}
+	return FormatText
+}
+
+// isBinary reports whether head looks like binary content. A multi-byte
+// rune cut off at the end of head is not counted against it.
+func isBinary(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	if len(head) == sniffLen {
+		for i := 0; i < utf8.UTFMax-1 && !utf8.Valid(head); i++ {
+			head = head[:len(head)-1]
+		}
+	}
+	return !utf8.Valid(head)
+}
+
+// Style decorates a token for output. Implementations must escape text as
+// their output medium requires.
+type Style interface {
+	Format(kind Kind, text string) string
+}
+
+// Plain is a Style that emits text unchanged.
+type Plain struct{}
+
+// Format returns text unchanged.
+func (Plain) Format(kind Kind, text string) string {
+	return text
+}
+
+// ANSI is a Style that colors tokens with terminal escape sequences.
+type ANSI struct{}
+
+// ansiColors maps token kinds to SGR parameters.
+var ansiColors = map[Kind]string{
+	KindKey:     "34",   // blue
+	KindString:  "32",   // green
+	KindNumber:  "33",   // yellow
+	KindKeyword: "35",   // magenta
+	KindComment: "2;37", // dim
+	KindPunct:   "2",    // dim
+	KindOffset:  "2;36", // dim cyan
+}
+
+// Format wraps text in the escape sequence for kind.
+func (ANSI) Format(kind Kind, text string) string {
+	code, ok := ansiColors[kind]
+	if !ok || text == "" {
+		return text
+	}
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
+
+// Render streams r to w, highlighted with style, and returns the detected
+// format. name and mediaType guide format detection. At most maxLineLen
+// bytes of a line are held at a time; a longer line is written in pieces,
+// which can break a token's highlighting where one piece ends. Control characters
+// other than tabs are shown in caret notation (^[ for ESC), so content from
+// a registry cannot send escape sequences to a terminal.
+func Render(w io.Writer, r io.Reader, name, mediaType string, style Style) (Format, error) {
+	br := bufio.NewReaderSize(r, maxLineLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", err
+	}
+
+	format := Detect(name, mediaType, head)
+	if format == FormatBinary {
+		return format, renderHex(w, br, style)
+	}
+
+	// carry holds the end of a piece that belongs with the next one: a
+	// rune cut in two, or a \r that may start a \r\n.
+	var carry []byte
+	midLine := false
+	for {
+		piece, err := br.ReadSlice('\n')
+		line := append(carry, piece...)
+		carry = nil
+		if err == bufio.ErrBufferFull {
+			cut := len(line) - incompleteSuffix(line)
+			carry = append(carry, line[cut:]...)
+			if werr := writeLine(w, format, line[:cut], style); werr != nil {
+				return format, werr
+			}
+			midLine = true
+			continue
+		}
+		if len(line) > 0 || midLine {
+			line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+			if werr := writeLine(w, format, line, style); werr != nil {
+				return format, werr
+			}
+			if _, werr := io.WriteString(w, "\n"); werr != nil {
+				return format, werr
+			}
+		}
+		midLine = false
+		if err == io.EOF {
+			return format, nil
+		}
+		if err != nil {
+			return format, err
+		}
+	}
+}
+
+// writeLine writes a line, or a piece of one, highlighted as format.
+func writeLine(w io.Writer, format Format, line []byte, style Style) error {
+	return writeTokens(w, Tokenize(format, escapeControl(string(line))), style)
+}
+
+// incompleteSuffix returns how many bytes at the end of b must wait for
+// more of the line: a trailing \r, or the start of a rune cut off.
+func incompleteSuffix(b []byte) int {
+	if len(b) > 0 && b[len(b)-1] == '\r' {
+		return 1
+	}
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// escapeControl replaces the C0 and C1 control characters in line, except
+// tabs, with printable text: ^X caret notation for C0 and DEL, as `cat -v`
+// shows them, and \u escapes for C1.
+func escapeControl(line string) string {
+	if strings.IndexFunc(line, isControl) < 0 {
+		return line
+	}
+	var b strings.Builder
+	for _, r := range line {
+		switch {
+		case !isControl(r):
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			b.WriteByte('^')
+			b.WriteByte(byte(r) ^ 0x40)
+		default:
+			fmt.Fprintf(&b, "\\u%04x", r)
+		}
+	}
+	return b.String()
+}
+
+// isControl reports whether r is a control character other than a tab.
+func isControl(r rune) bool {
+	return r != '\t' && (r < 0x20 || r >= 0x7f && r <= 0x9f)
+}
+
+// renderHex writes a hexdump in the layout of `hexdump -C`.
+func renderHex(w io.Writer, r io.Reader, style Style) error {
+	buf := make([]byte, 16)
+	var offset int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if werr := writeTokens(w, hexLine(offset, buf[:n]), style); werr != nil {
+				return werr
+			}
+			if _, werr := io.WriteString(w, "\n"); werr != nil {
+				return werr
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeTokens writes styled tokens to w.
+func writeTokens(w io.Writer, tokens []Token, style Style) error {
+	for _, t := range tokens {
+		if _, err := io.WriteString(w, style.Format(t.Kind, t.Text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		mediaType string
+		head      string
+		want      Format
+	}{
+		{name: "json extension", file: "config.json", head: "{}", want: FormatJSON},
+		{name: "json media type", file: "config", mediaType: "application/vnd.example.config.v1+json", head: "{}", want: FormatJSON},
+		{name: "json content", file: "data", head: "  [1, 2]", want: FormatJSON},
+		{name: "yaml extension", file: "values.yml", head: "a: 1", want: FormatYAML},
+		{name: "yaml media type", file: "", mediaType: "application/yaml", head: "a: 1", want: FormatYAML},
+		{name: "rego", file: "policy.rego", mediaType: "application/vnd.openpolicyagent.policy.layer.v1+rego", head: "package x", want: FormatRego},
+		{name: "rego media type", file: "sha256:abc", mediaType: "application/vnd.openpolicyagent.policy.layer.v1+rego", head: "package x", want: FormatRego},
+		{name: "text", file: "README", head: "hello", want: FormatText},
+		{name: "empty", file: "empty.txt", head: "", want: FormatText},
+		{name: "nul byte", file: "config.json", head: "{\x00}", want: FormatBinary},
+		{name: "invalid utf8", file: "notes.txt", head: "\xff\xfe", want: FormatBinary},
+		{name: "gzip", file: "layer.tar.gz", mediaType: "application/vnd.oci.image.layer.v1.tar+gzip", head: "\x1f\x8b\x08\x00", want: FormatBinary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.file, tt.mediaType, []byte(tt.head)); got != tt.want {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectTruncatedRune(t *testing.T) {
+	// A multi-byte rune split by the sniff boundary is still text.
+	head := []byte(strings.Repeat("a", sniffLen-1) + "é")[:sniffLen]
+	if got := Detect("notes.txt", "", head); got != FormatText {
+		t.Errorf("Detect() = %q, want %q", got, FormatText)
+	}
+}
+
+// kinds renders tokens as "kind:text" pairs for comparison.
+func kinds(ts []Token) []string {
+	names := map[Kind]string{
+		KindPlain: "plain", KindKey: "key", KindString: "string", KindNumber: "number",
+		KindKeyword: "keyword", KindComment: "comment", KindPunct: "punct", KindOffset: "offset",
+	}
+	var out []string
+	for _, t := range ts {
+		text := t.Text
+		if t.Kind == KindPlain {
+			if text = strings.TrimSpace(text); text == "" {
+				continue
+			}
+		}
+		out = append(out, names[t.Kind]+":"+text)
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		line   string
+		want   []string
+	}{
+		{
+			name:   "json key and string",
+			format: FormatJSON,
+			line:   `  "name": "a \"quoted\" value",`,
+			want:   []string{`key:"name"`, "punct::", `string:"a \"quoted\" value"`, "punct:,"},
+		},
+		{
+			name:   "json literals",
+			format: FormatJSON,
+			line:   `[1.5e-3, -2, true, null]`,
+			want:   []string{"punct:[", "number:1.5e-3", "punct:,", "number:-2", "punct:,", "keyword:true", "punct:,", "keyword:null", "punct:]"},
+		},
+		{
+			name:   "yaml key value",
+			format: FormatYAML,
+			line:   `  image: "nginx:1.25" # pinned`,
+			want:   []string{"key:image", "punct::", `string:"nginx:1.25"`, "comment:# pinned"},
+		},
+		{
+			name:   "yaml sequence",
+			format: FormatYAML,
+			line:   `- name: web`,
+			want:   []string{"punct:-", "key:name", "punct::", "plain:web"},
+		},
+		{
+			name:   "yaml scalar types",
+			format: FormatYAML,
+			line:   `replicas: 3`,
+			want:   []string{"key:replicas", "punct::", "number:3"},
+		},
+		{
+			name:   "yaml url is not a key",
+			format: FormatYAML,
+			line:   `- http://example.com`,
+			want:   []string{"punct:-", "plain:http://example.com"},
+		},
+		{
+			name:   "yaml hash in string",
+			format: FormatYAML,
+			line:   `color: "#fff"`,
+			want:   []string{"key:color", "punct::", `string:"#fff"`},
+		},
+		{
+			name:   "yaml comment line",
+			format: FormatYAML,
+			line:   `# top: level`,
+			want:   []string{"comment:# top: level"},
+		},
+		{
+			name:   "rego rule",
+			format: FormatRego,
+			line:   `deny contains msg if { input.replicas > 3 } # limit`,
+			want: []string{
+				"plain:deny", "keyword:contains", "plain:msg", "keyword:if", "punct:{",
+				"keyword:input", "punct:.", "plain:replicas", "punct:>", "number:3", "punct:}",
+				"comment:# limit",
+			},
+		},
+		{
+			name:   "rego raw string",
+			format: FormatRego,
+			line:   "msg := `no \\escape`",
+			want:   []string{"plain:msg", "punct::=", "string:`no \\escape`"},
+		},
+		{
+			name:   "text",
+			format: FormatText,
+			line:   `{"not": "highlighted"}`,
+			want:   []string{`plain:{"not": "highlighted"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := Tokenize(tt.format, tt.line)
+
+			var joined strings.Builder
+			for _, tok := range ts {
+				joined.WriteString(tok.Text)
+			}
+			if joined.String() != tt.line {
+				t.Errorf("tokens join to %q, want %q", joined.String(), tt.line)
+			}
+
+			got := kinds(ts)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Tokenize() =\n  %q\nwant\n  %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		input      string
+		wantFormat Format
+		want       string
+	}{
+		{
+			name:       "text",
+			file:       "notes.txt",
+			input:      "line one\r\nline two",
+			wantFormat: FormatText,
+			want:       "line one\nline two\n",
+		},
+		{
+			name:       "long line",
+			file:       "data.json",
+			input:      `"` + strings.Repeat("x", 100000) + `"` + "\n",
+			wantFormat: FormatJSON,
+			want:       `"` + strings.Repeat("x", 100000) + `"` + "\n",
+		},
+		{
+			name:       "binary",
+			file:       "blob",
+			input:      "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x03\x00",
+			wantFormat: FormatBinary,
+			want: "00000000  7f 45 4c 46 02 01 01 00  00 00 00 00 00 00 00 00  |.ELF............|\n" +
+				"00000010  03 00                                             |..|\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			format, err := Render(&buf, strings.NewReader(tt.input), tt.file, "", Plain{})
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if format != tt.wantFormat {
+				t.Errorf("Render() format = %q, want %q", format, tt.wantFormat)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Render() output =\n%q\nwant\n%q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderANSI(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := Render(&buf, strings.NewReader(`{"a": 1}`), "x.json", "", ANSI{}); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "\x1b[34m\"a\"\x1b[0m") {
+		t.Errorf("Render() output %q does not color the key", buf.String())
+	}
+}
+
+func TestRenderEscapesControl(t *testing.T) {
+	input := "title: \x1b]0;pwned\x07\x1b[2J\tok\rover\u009b31m\x7f\n"
+	for _, style := range []Style{Plain{}, ANSI{}} {
+		var buf bytes.Buffer
+		if _, err := Render(&buf, strings.NewReader(input), "x.txt", "", style); err != nil {
+			t.Fatalf("Render() error = %v", err)
+		}
+		out := buf.String()
+		if style == (Plain{}) && out != "title: ^[]0;pwned^G^[[2J\tok^Mover\\u009b31m^?\n" {
+			t.Errorf("Render() = %q", out)
+		}
+		// Only the style's own SGR sequences reach the output
+		for _, seq := range []string{"\x1b]", "\x1b[2J", "\x07", "\r", "\u009b", "\x7f"} {
+			if strings.Contains(out, seq) {
+				t.Errorf("%T: Render() output %q contains %q", style, out, seq)
+			}
+		}
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestRenderReadError(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := Render(&buf, errReader{}, "x.txt", "", Plain{}); err == nil {
+		t.Error("Render() error = nil, want read error")
+	}
+}
+
+// maxWriter records the largest single write.
+type maxWriter struct {
+	bytes.Buffer
+	max int
+}
+
+func (w *maxWriter) Write(p []byte) (int, error) {
+	w.max = max(w.max, len(p))
+	return w.Buffer.Write(p)
+}
+
+func (w *maxWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func TestRenderLongLine(t *testing.T) {
+	// A line many times maxLineLen, with two-byte runes and a \r\n that
+	// straddle piece boundaries.
+	line := "x" + strings.Repeat("é", 4*maxLineLen)
+	line = line[:maxLineLen-1] + "\r\ny" + line[maxLineLen-1:]
+	input := line + "\nshort\n"
+	want := strings.Replace(input, "\r\n", "\n", 1)
+
+	var out maxWriter
+	if _, err := Render(&out, strings.NewReader(input), "x.txt", "", Plain{}); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if out.String() != want {
+		t.Errorf("Render() output differs from input (%d bytes, want %d)", out.Len(), len(want))
+	}
+	if out.max > maxLineLen {
+		t.Errorf("largest write = %d bytes, want at most %d", out.max, maxLineLen)
+	}
+}
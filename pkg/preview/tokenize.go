@@ -0,0 +1,334 @@
+package preview
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind classifies a token for highlighting.
+type Kind int
+
+const (
+	KindPlain Kind = iota
+	KindKey
+	KindString
+	KindNumber
+	KindKeyword
+	KindComment
+	KindPunct
+	KindOffset
+)
+
+// Token is a run of text with a single kind.
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// Tokenize splits one line of text into highlighted tokens. Lines are
+// tokenized independently, so constructs spanning lines (block strings,
+// raw strings) are highlighted as plain text after their first line.
+func Tokenize(format Format, line string) []Token {
+	switch format {
+	case FormatJSON:
+		return tokenizeJSON(line)
+	case FormatYAML:
+		return tokenizeYAML(line)
+	case FormatRego:
+		return tokenizeRego(line)
+	default:
+		return []Token{{Kind: KindPlain, Text: line}}
+	}
+}
+
+// tokens accumulates tokens, merging adjacent runs of the same kind.
+type tokens []Token
+
+func (ts *tokens) add(kind Kind, text string) {
+	if text == "" {
+		return
+	}
+	if n := len(*ts); n > 0 && (*ts)[n-1].Kind == kind {
+		(*ts)[n-1].Text += text
+		return
+	}
+	*ts = append(*ts, Token{Kind: kind, Text: text})
+}
+
+func tokenizeJSON(line string) []Token {
+	var ts tokens
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == '"':
+			end := quotedEnd(line, i, '"')
+			kind := KindString
+			if strings.HasPrefix(strings.TrimLeft(line[end:], " \t"), ":") {
+				kind = KindKey
+			}
+			ts.add(kind, line[i:end])
+			i = end
+		case c == '-' || isDigit(c):
+			end := numberEnd(line, i)
+			ts.add(KindNumber, line[i:end])
+			i = end
+		case isWordStart(c):
+			end := wordEnd(line, i)
+			word := line[i:end]
+			if word == "true" || word == "false" || word == "null" {
+				ts.add(KindKeyword, word)
+			} else {
+				ts.add(KindPlain, word)
+			}
+			i = end
+		case strings.IndexByte("{}[],:", c) >= 0:
+			ts.add(KindPunct, line[i:i+1])
+			i++
+		default:
+			ts.add(KindPlain, line[i:i+1])
+			i++
+		}
+	}
+	return ts
+}
+
+// yamlKeywords are the scalars YAML treats as booleans or null.
+var yamlKeywords = map[string]bool{
+	"true": true, "false": true, "null": true, "~": true,
+	"yes": true, "no": true, "on": true, "off": true,
+	"True": true, "False": true, "Null": true,
+	"TRUE": true, "FALSE": true, "NULL": true,
+}
+
+func tokenizeYAML(line string) []Token {
+	var ts tokens
+
+	body := strings.TrimLeft(line, " \t")
+	ts.add(KindPlain, line[:len(line)-len(body)])
+	if strings.HasPrefix(body, "#") {
+		ts.add(KindComment, body)
+		return ts
+	}
+	if body == "---" || body == "..." {
+		ts.add(KindPunct, body)
+		return ts
+	}
+
+	// Sequence entries: "- value" or "- key: value".
+	for strings.HasPrefix(body, "- ") || body == "-" {
+		ts.add(KindPunct, "-")
+		rest := strings.TrimLeft(body[1:], " ")
+		ts.add(KindPlain, body[1:len(body)-len(rest)])
+		body = rest
+	}
+
+	if key, rest, ok := yamlKey(body); ok {
+		ts.add(KindKey, key)
+		ts.add(KindPunct, ":")
+		body = rest
+	}
+
+	value, comment := yamlComment(body)
+	trimmed := strings.TrimSpace(value)
+	lead := value[:len(value)-len(strings.TrimLeft(value, " \t"))]
+	trail := value[len(lead)+len(trimmed):]
+	ts.add(KindPlain, lead)
+	switch {
+	case trimmed == "":
+	case trimmed[0] == '"' || trimmed[0] == '\'':
+		ts.add(KindString, trimmed)
+	case yamlKeywords[trimmed]:
+		ts.add(KindKeyword, trimmed)
+	case isNumber(trimmed):
+		ts.add(KindNumber, trimmed)
+	case trimmed[0] == '&' || trimmed[0] == '*' || trimmed[0] == '!' || trimmed == "|" || trimmed == ">" ||
+		strings.HasPrefix(trimmed, "|-") || strings.HasPrefix(trimmed, ">-"):
+		ts.add(KindKeyword, trimmed)
+	default:
+		ts.add(KindPlain, trimmed)
+	}
+	ts.add(KindPlain, trail)
+	ts.add(KindComment, comment)
+	return ts
+}
+
+// yamlKey splits "key: rest" into its key and the text after the colon.
+// Quoted keys are supported; flow collections are not treated as keys.
+func yamlKey(s string) (key, rest string, ok bool) {
+	if s == "" || strings.IndexByte("{[#", s[0]) >= 0 {
+		return "", "", false
+	}
+	end := 0
+	if s[0] == '"' || s[0] == '\'' {
+		end = quotedEnd(s, 0, s[0])
+	}
+	for i := end; i < len(s); i++ {
+		if s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t') {
+			return s[:i], s[i+1:], true
+		}
+		if s[i] == ' ' && i+1 < len(s) && s[i+1] == '#' {
+			break
+		}
+	}
+	return "", "", false
+}
+
+// yamlComment splits a trailing " # comment" off a value, ignoring '#'
+// inside quotes.
+func yamlComment(s string) (value, comment string) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i], s[i:]
+		}
+	}
+	return s, ""
+}
+
+// regoKeywords are Rego's keywords and built-in literals.
+var regoKeywords = map[string]bool{
+	"package": true, "import": true, "default": true, "not": true,
+	"some": true, "every": true, "in": true, "if": true, "contains": true,
+	"else": true, "with": true, "as": true, "true": true, "false": true,
+	"null": true, "data": true, "input": true,
+}
+
+func tokenizeRego(line string) []Token {
+	var ts tokens
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == '#':
+			ts.add(KindComment, line[i:])
+			return ts
+		case c == '"' || c == '`':
+			end := quotedEnd(line, i, c)
+			ts.add(KindString, line[i:end])
+			i = end
+		case isDigit(c):
+			end := numberEnd(line, i)
+			ts.add(KindNumber, line[i:end])
+			i = end
+		case isWordStart(c):
+			end := wordEnd(line, i)
+			word := line[i:end]
+			if regoKeywords[word] {
+				ts.add(KindKeyword, word)
+			} else {
+				ts.add(KindPlain, word)
+			}
+			i = end
+		case strings.IndexByte("{}[]().,;:=|&!<>+-*/%", c) >= 0:
+			ts.add(KindPunct, line[i:i+1])
+			i++
+		default:
+			ts.add(KindPlain, line[i:i+1])
+			i++
+		}
+	}
+	return ts
+}
+
+// hexLine formats up to 16 bytes at offset in the layout of `hexdump -C`:
+//
+//	00000000  7f 45 4c 46 02 01 01 00  00 00 00 00 00 00 00 00  |.ELF............|
+func hexLine(offset int64, b []byte) []Token {
+	var hex strings.Builder
+	for i := 0; i < 16; i++ {
+		if i == 8 {
+			hex.WriteByte(' ')
+		}
+		if i < len(b) {
+			fmt.Fprintf(&hex, "%02x ", b[i])
+		} else {
+			hex.WriteString("   ")
+		}
+	}
+
+	ascii := make([]byte, len(b))
+	for i, c := range b {
+		if c >= 0x20 && c < 0x7f {
+			ascii[i] = c
+		} else {
+			ascii[i] = '.'
+		}
+	}
+
+	return []Token{
+		{Kind: KindOffset, Text: fmt.Sprintf("%08x", offset)},
+		{Kind: KindPlain, Text: "  " + hex.String() + " "},
+		{Kind: KindPunct, Text: "|"},
+		{Kind: KindString, Text: string(ascii)},
+		{Kind: KindPunct, Text: "|"},
+	}
+}
+
+// quotedEnd returns the index just past the string starting at s[start],
+// or len(s) if it is unterminated. Backslash escapes are honored except in
+// single-quoted and backquoted strings.
+func quotedEnd(s string, start int, quote byte) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// numberEnd returns the index just past the number starting at s[start].
+func numberEnd(s string, start int) int {
+	i := start
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	for i < len(s) && (isDigit(s[i]) || strings.IndexByte(".eE+-", s[i]) >= 0) {
+		if (s[i] == '+' || s[i] == '-') && s[i-1] != 'e' && s[i-1] != 'E' {
+			break
+		}
+		i++
+	}
+	return i
+}
+
+// wordEnd returns the index just past the identifier starting at s[start].
+func wordEnd(s string, start int) int {
+	i := start
+	for i < len(s) && (isWordStart(s[i]) || isDigit(s[i])) {
+		i++
+	}
+	return i
+}
+
+// isNumber reports whether s is entirely a number.
+func isNumber(s string) bool {
+	if s == "" || s == "-" {
+		return false
+	}
+	if s[0] != '-' && !isDigit(s[0]) {
+		return false
+	}
+	return numberEnd(s, 0) == len(s)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,212 @@
+package pull
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// BatchEntry is one artifact of a batch pull.
+type BatchEntry struct {
+	// Reference is the artifact to pull.
+	Reference string `yaml:"reference"`
+
+	// Platform is a comma-separated list of platforms to pull, or "all"
+	// for every platform of an image index. Defaults to the batch's
+	// platforms.
+	Platform string `yaml:"platform,omitempty"`
+
+	// Destination is the OCI layout to pull into. Defaults to the batch's
+	// shared layout.
+	Destination string `yaml:"destination,omitempty"`
+}
+
+// UnmarshalYAML accepts a plain reference as well as a mapping.
+func (e *BatchEntry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		e.Reference = node.Value
+		return nil
+	}
+	type plain BatchEntry
+	return node.Decode((*plain)(e))
+}
+
+// ParseBatchFile reads the references of a batch pull. Files ending in
+// .yaml or .yml hold a list of references or entries:
+//
+//   - nginx:1.25
+//   - reference: ghcr.io/org/app:v2
+//     platform: linux/amd64,linux/arm64
+//     destination: ./app-layout
+//
+// Other files list one reference per line; blank lines and lines starting
+// with # are ignored.
+func ParseBatchFile(path string) ([]BatchEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+
+	var entries []BatchEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse batch file: %w", err)
+		}
+	default:
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			entries = append(entries, BatchEntry{Reference: line})
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read batch file: %w", err)
+		}
+	}
+
+	for i, e := range entries {
+		if e.Reference == "" {
+			return nil, fmt.Errorf("batch entry %d has no reference", i+1)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("batch file %s lists no references", path)
+	}
+	return entries, nil
+}
+
+// BatchOptions configures a batch pull.
+type BatchOptions struct {
+	// Entries are the artifacts to pull.
+	Entries []BatchEntry
+
+	// Destination is the OCI layout shared by entries without their own.
+	// Blobs common to several entries are stored in it once.
+	Destination string
+
+	// Platforms and AllPlatforms select platforms for entries without a
+	// platform of their own, as in PullOptions.
+	Platforms    []ocispec.Platform
+	AllPlatforms bool
+
+	// WithReferrers and ReferrerTypes pull referrers along with each
+	// entry, as in PullOptions.
+	WithReferrers bool
+	ReferrerTypes []string
+
+	// Parallel is the number of references pulled at once. Defaults to 4.
+	Parallel int
+
+	// Concurrency is the number of layers each pull downloads at once.
+	Concurrency int
+
+	// Access returns whether a registry host is insecure and its
+	// credentials. If nil, registries are reached over HTTPS anonymously.
+	Access func(host string) (bool, auth.CredentialFunc)
+
+	// Log is where a line is written as each reference finishes. If nil,
+	// output is discarded.
+	Log io.Writer
+}
+
+// PullBatch pulls every entry, up to opts.Parallel at a time, and returns
+// their results in entry order. A failed pull does not stop the others;
+// its result carries the error. Each manifest is tagged with its full
+// reference, so entries with the same tag in different repositories do not
+// collide in a shared layout.
+func (p *Puller) PullBatch(ctx context.Context, opts BatchOptions) []*PullResult {
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = 4
+	}
+
+	results := make([]*PullResult, len(opts.Entries))
+	var logMu sync.Mutex
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(parallel)
+	for i, entry := range opts.Entries {
+		g.Go(func() error {
+			result, err := p.pullEntry(gctx, entry, opts)
+			if err != nil {
+				if result == nil {
+					result = &PullResult{Reference: entry.Reference}
+				}
+				result.Error = err.Error()
+			}
+			results[i] = result
+
+			if opts.Log != nil {
+				logMu.Lock()
+				if err != nil {
+					fmt.Fprintf(opts.Log, "  %s → FAILED (%s)\n", entry.Reference, err)
+				} else {
+					fmt.Fprintf(opts.Log, "  %s → %s\n", entry.Reference, shortDigest(result.Digest))
+				}
+				logMu.Unlock()
+			}
+			// Don't return the error — the other pulls continue.
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return results
+}
+
+// pullEntry pulls one entry of a batch.
+func (p *Puller) pullEntry(ctx context.Context, entry BatchEntry, opts BatchOptions) (*PullResult, error) {
+	ref, err := ociutil.ParseReference(entry.Reference)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference: %w", err)
+	}
+
+	pullOpts := PullOptions{
+		Reference:     entry.Reference,
+		Destination:   entry.Destination,
+		Platforms:     opts.Platforms,
+		AllPlatforms:  opts.AllPlatforms,
+		Tag:           ref.String(),
+		WithReferrers: opts.WithReferrers,
+		ReferrerTypes: opts.ReferrerTypes,
+		Quiet:         true,
+		Concurrency:   opts.Concurrency,
+	}
+	if pullOpts.Destination == "" {
+		pullOpts.Destination = opts.Destination
+	}
+	if pullOpts.Destination == "" {
+		return nil, fmt.Errorf("no destination layout")
+	}
+	switch entry.Platform {
+	case "":
+	case "all":
+		pullOpts.Platforms, pullOpts.AllPlatforms = nil, true
+	default:
+		pullOpts.Platforms, err = ParsePlatforms(entry.Platform)
+		if err != nil {
+			return nil, err
+		}
+		pullOpts.AllPlatforms = false
+	}
+	if opts.Access != nil {
+		pullOpts.Insecure, pullOpts.CredentialFunc = opts.Access(ref.Registry)
+	}
+
+	return p.Pull(ctx, pullOpts)
+}
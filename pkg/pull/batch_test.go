@@ -0,0 +1,305 @@
+package pull
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+func TestParseBatchFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []BatchEntry
+		wantErr bool
+	}{
+		{
+			name: "text",
+			file: "images.txt",
+			content: `# base images
+nginx:1.25
+
+  ghcr.io/org/app:v2
+`,
+			want: []BatchEntry{{Reference: "nginx:1.25"}, {Reference: "ghcr.io/org/app:v2"}},
+		},
+		{
+			name: "yaml",
+			file: "images.yaml",
+			content: `- nginx:1.25
+- reference: ghcr.io/org/app:v2
+  platform: linux/amd64,linux/arm64
+  destination: ./app
+`,
+			want: []BatchEntry{
+				{Reference: "nginx:1.25"},
+				{Reference: "ghcr.io/org/app:v2", Platform: "linux/amd64,linux/arm64", Destination: "./app"},
+			},
+		},
+		{name: "yaml entry without reference", file: "images.yml", content: "- platform: linux/amd64\n", wantErr: true},
+		{name: "empty", file: "images.txt", content: "# nothing\n", wantErr: true},
+		{name: "invalid yaml", file: "images.yaml", content: "reference: [", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseBatchFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBatchFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseBatchFile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlobFlights(t *testing.T) {
+	var f blobFlights
+	ctx := context.Background()
+	dgst := digest.FromString("layer")
+
+	// fn stands in for a download: the first call blocks until released,
+	// and calls after it finished find the blob in place.
+	release := make(chan struct{})
+	var stored atomic.Bool
+	fn := func() (bool, error) {
+		if stored.Load() {
+			return false, nil
+		}
+		<-release
+		stored.Store(true)
+		return true, nil
+	}
+
+	var wg sync.WaitGroup
+	fetched := make([]bool, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fetched[0], _ = f.do(ctx, dgst, fn)
+	}()
+	for {
+		f.mu.Lock()
+		inFlight := f.calls[dgst] != nil
+		f.mu.Unlock()
+		if inFlight {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i := 1; i < len(fetched); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetched[i], _ = f.do(ctx, dgst, fn)
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	if !reflect.DeepEqual(fetched, []bool{true, false, false, false, false}) {
+		t.Errorf("fetched = %v, want only the first caller to download", fetched)
+	}
+
+	// A failed download is retried by the next caller
+	failed := errors.New("cancelled")
+	stored.Store(false)
+	if _, err := f.do(ctx, dgst, func() (bool, error) { return false, failed }); err != failed {
+		t.Errorf("do() error = %v, want %v", err, failed)
+	}
+	if ok, err := f.do(ctx, dgst, func() (bool, error) { return true, nil }); !ok || err != nil {
+		t.Errorf("do() after failure = %v, %v; want a new download", ok, err)
+	}
+}
+
+// fakeRegistry serves manifests, blobs and referrers over the
+// distribution API and counts blob downloads by digest.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	content   map[digest.Digest][]byte
+	mediaType map[digest.Digest]string
+	tags      map[string]digest.Digest // "<repository>:<tag>"
+	referrers map[digest.Digest][]ocispec.Descriptor
+	downloads map[digest.Digest]int
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		content:   map[digest.Digest][]byte{},
+		mediaType: map[digest.Digest]string{},
+		tags:      map[string]digest.Digest{},
+		referrers: map[digest.Digest][]ocispec.Descriptor{},
+		downloads: map[digest.Digest]int{},
+	}
+}
+
+func (r *fakeRegistry) push(mediaType string, data []byte) ocispec.Descriptor {
+	d := digest.FromBytes(data)
+	r.content[d] = data
+	r.mediaType[d] = mediaType
+	return ocispec.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(data))}
+}
+
+// image stores an image with the given layer contents as repo:tag.
+func (r *fakeRegistry) image(t *testing.T, repo, tag string, layers ...string) ocispec.Descriptor {
+	t.Helper()
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    r.push(ocispec.MediaTypeImageConfig, []byte(`{"architecture":"amd64","os":"linux","repo":"`+repo+`"}`)),
+	}
+	for _, l := range layers {
+		manifest.Layers = append(manifest.Layers, r.push(ocispec.MediaTypeImageLayer, []byte(l)))
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := r.push(ocispec.MediaTypeImageManifest, data)
+	r.tags[repo+":"+tag] = desc.Digest
+	return desc
+}
+
+// artifact stores an artifact of artifactType with one layer, referring
+// to subject.
+func (r *fakeRegistry) artifact(t *testing.T, subject ocispec.Descriptor, artifactType, layer string) ocispec.Descriptor {
+	t.Helper()
+	manifest := ocispec.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: artifactType,
+		Config:       r.push(ocispec.MediaTypeEmptyJSON, []byte("{}")),
+		Layers:       []ocispec.Descriptor{r.push("application/octet-stream", []byte(layer))},
+		Subject:      &subject,
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := r.push(ocispec.MediaTypeImageManifest, data)
+	desc.ArtifactType = artifactType
+	r.referrers[subject.Digest] = append(r.referrers[subject.Digest], desc)
+	return desc
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if _, ref, ok := strings.Cut(path, "/referrers/"); ok {
+		r.mu.Lock()
+		referrers := r.referrers[digest.Digest(ref)]
+		r.mu.Unlock()
+		if referrers == nil {
+			referrers = []ocispec.Descriptor{}
+		}
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+		json.NewEncoder(w).Encode(ocispec.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageIndex,
+			Manifests: referrers,
+		})
+		return
+	}
+	repo, ref, ok := strings.Cut(path, "/manifests/")
+	if !ok {
+		repo, ref, ok = strings.Cut(path, "/blobs/")
+		if !ok {
+			return // API version check
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, err := digest.Parse(ref)
+	if err != nil {
+		d = r.tags[repo+":"+ref]
+	}
+	data, found := r.content[d]
+	if !found {
+		http.NotFound(w, req)
+		return
+	}
+	if strings.Contains(path, "/blobs/") && req.Method == http.MethodGet {
+		r.downloads[d]++
+	}
+	w.Header().Set("Content-Type", r.mediaType[d])
+	w.Header().Set("Docker-Content-Digest", d.String())
+	http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(data))
+}
+
+func TestPullBatch(t *testing.T) {
+	reg := newFakeRegistry()
+	reg.image(t, "lib/a", "v1", "shared base", "layer of a")
+	reg.image(t, "lib/b", "v1", "shared base", "layer of b")
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	dest := t.TempDir()
+	var log bytes.Buffer
+	results := NewPuller(true).PullBatch(context.Background(), BatchOptions{
+		Entries: []BatchEntry{
+			{Reference: host + "/lib/a:v1"},
+			{Reference: host + "/lib/missing:v1"},
+			{Reference: host + "/lib/b:v1"},
+		},
+		Destination: dest,
+		Parallel:    3,
+		Access: func(string) (bool, auth.CredentialFunc) {
+			return true, nil
+		},
+		Log: &log,
+	})
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for i, r := range results {
+		failed := r.Error != ""
+		if failed != (i == 1) {
+			t.Errorf("results[%d] (%s) error = %q", i, r.Reference, r.Error)
+		}
+	}
+	if !strings.Contains(log.String(), "lib/missing:v1 → FAILED") {
+		t.Errorf("log = %q, want the failure reported", log.String())
+	}
+
+	// Both images share the layout, tagged by full reference
+	store, err := oci.New(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, 2} {
+		desc, err := store.Resolve(context.Background(), results[i].Reference)
+		if err != nil || desc.Digest.String() != results[i].Digest {
+			t.Errorf("Resolve(%s) = %s, %v; want %s", results[i].Reference, desc.Digest, err, results[i].Digest)
+		}
+	}
+
+	shared := digest.FromString("shared base")
+	if got := reg.downloads[shared]; got != 1 {
+		t.Errorf("shared layer downloaded %d times, want 1", got)
+	}
+}
@@ -0,0 +1,348 @@
+package pull
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+)
+
+// containerd's gRPC services used for an import.
+const (
+	ctrdContentWrite  = "/containerd.services.content.v1.Content/Write"
+	ctrdContentUpdate = "/containerd.services.content.v1.Content/Update"
+	ctrdImagesCreate  = "/containerd.services.images.v1.Images/Create"
+	ctrdImagesUpdate  = "/containerd.services.images.v1.Images/Update"
+	ctrdLeasesCreate  = "/containerd.services.leases.v1.Leases/Create"
+	ctrdLeasesDelete  = "/containerd.services.leases.v1.Leases/Delete"
+)
+
+// Content.Write actions.
+const (
+	ctrdWrite  = 1
+	ctrdCommit = 2
+)
+
+// grpcAlreadyExists is the gRPC status containerd returns for content and
+// images it already has.
+const grpcAlreadyExists = 6
+
+// ctrdChunkSize is the size of the blob chunks sent to Content.Write, well
+// under containerd's 16 MiB message limit.
+const ctrdChunkSize = 1 << 20
+
+// containerdLoader imports images into a containerd namespace through its
+// socket. containerd only speaks gRPC, and its client would bring in most
+// of containerd, so the handful of calls an import needs are made here:
+// gRPC is HTTP/2 carrying length-prefixed protobuf messages, which net/http
+// and a small encoder cover.
+//
+// An import writes the config, layers and manifest to the content store,
+// labelled so containerd's garbage collector keeps them with the manifest,
+// then points an image at the manifest. A lease holds the blobs until the
+// image does. Layers are not unpacked into a snapshotter; containerd does
+// that when a container is first created from the image.
+type containerdLoader struct {
+	address   string
+	namespace string
+	http      *http.Client
+}
+
+// newContainerdLoader returns a loader for the containerd socket at address.
+func newContainerdLoader(address, namespace string) *containerdLoader {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	dialer := &net.Dialer{}
+	return &containerdLoader{
+		address:   address,
+		namespace: namespace,
+		http: &http.Client{
+			Transport: &http.Transport{
+				Protocols: &protocols,
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", address)
+				},
+			},
+		},
+	}
+}
+
+func (c *containerdLoader) Load(ctx context.Context, ociLayoutPath, reference string) error {
+	index, err := ociutil.ReadOCIIndex(ociLayoutPath)
+	if err != nil {
+		return fmt.Errorf("failed to read OCI index: %w", err)
+	}
+	if len(index.Manifests) == 0 {
+		return fmt.Errorf("OCI index contains no manifests")
+	}
+	manifestDesc := index.TaggedManifest()
+	if isIndex(manifestDesc.MediaType) {
+		return fmt.Errorf("containerd is loaded with a single platform; pull one platform with --platform")
+	}
+	manifest, err := ociutil.ReadOCIManifest(ociLayoutPath, manifestDesc.Digest)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	lease := fmt.Sprintf("lazyoci-%d", time.Now().UnixNano())
+	expire := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	err = c.unary(ctx, "", ctrdLeasesCreate, protoMessage{}.
+		string(1, lease).
+		labels(3, map[string]string{"containerd.io/gc.expire": expire}))
+	if err != nil {
+		return c.loadError(err)
+	}
+	defer c.unary(context.WithoutCancel(ctx), "", ctrdLeasesDelete, protoMessage{}.string(1, lease))
+
+	// Children first, so the manifest's GC labels never name missing blobs.
+	gcLabels := map[string]string{"containerd.io/gc.ref.content.config": manifest.Config.Digest}
+	if err := c.writeBlob(ctx, lease, ociLayoutPath, manifest.Config, nil); err != nil {
+		return c.loadError(err)
+	}
+	for i, layer := range manifest.Layers {
+		if err := c.writeBlob(ctx, lease, ociLayoutPath, layer, nil); err != nil {
+			return c.loadError(err)
+		}
+		gcLabels["containerd.io/gc.ref.content.l."+strconv.Itoa(i)] = layer.Digest
+	}
+	if err := c.writeBlob(ctx, lease, ociLayoutPath, manifestDesc, gcLabels); err != nil {
+		return c.loadError(err)
+	}
+
+	image := protoMessage{}.
+		string(1, reference).
+		message(3, protoMessage{}.
+			string(1, manifestDesc.MediaType).
+			string(2, manifestDesc.Digest).
+			int(3, manifestDesc.Size))
+	err = c.unary(ctx, lease, ctrdImagesCreate, protoMessage{}.message(1, image))
+	if isGRPCCode(err, grpcAlreadyExists) {
+		// Re-tag, as ctr import does for a name that already exists
+		err = c.unary(ctx, lease, ctrdImagesUpdate, protoMessage{}.message(1, image))
+	}
+	if err != nil {
+		return c.loadError(err)
+	}
+	return nil
+}
+
+// loadError wraps an error from an import step.
+func (c *containerdLoader) loadError(err error) error {
+	return fmt.Errorf("failed to load into containerd at %s: %w", c.address, err)
+}
+
+// writeBlob streams a blob from the layout to Content.Write and commits it
+// with labels. Blobs containerd already has are kept, with labels added.
+func (c *containerdLoader) writeBlob(ctx context.Context, lease, ociLayoutPath string, desc ociutil.OCIDescriptor, labels map[string]string) error {
+	f, err := os.Open(ociutil.BlobPath(ociLayoutPath, desc.Digest))
+	if err != nil {
+		return fmt.Errorf("failed to open blob %s: %w", shortDigest(desc.Digest), err)
+	}
+	defer f.Close()
+
+	ref := "lazyoci-" + desc.Digest
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, ctrdChunkSize)
+		var offset int64
+		for {
+			n, err := io.ReadFull(f, buf)
+			if n > 0 {
+				msg := protoMessage{}.
+					int(1, ctrdWrite).
+					string(2, ref).
+					int(3, desc.Size).
+					string(4, desc.Digest).
+					int(5, offset).
+					bytes(6, buf[:n])
+				if werr := writeGRPCFrame(pw, msg); werr != nil {
+					return
+				}
+				offset += int64(n)
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		commit := protoMessage{}.
+			int(1, ctrdCommit).
+			string(2, ref).
+			int(3, desc.Size).
+			string(4, desc.Digest).
+			int(5, offset).
+			labels(7, labels)
+		if err := writeGRPCFrame(pw, commit); err != nil {
+			return
+		}
+		pw.Close()
+	}()
+
+	err = c.call(ctx, lease, ctrdContentWrite, pr)
+	// Stops the writer if containerd ended the stream early
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+	if isGRPCCode(err, grpcAlreadyExists) {
+		if len(labels) == 0 {
+			return nil
+		}
+		paths := make([]string, 0, len(labels))
+		for k := range labels {
+			paths = append(paths, "labels."+k)
+		}
+		sort.Strings(paths)
+		mask := protoMessage{}
+		for _, p := range paths {
+			mask = mask.string(1, p)
+		}
+		info := protoMessage{}.string(1, desc.Digest).labels(5, labels)
+		err = c.unary(ctx, lease, ctrdContentUpdate, protoMessage{}.message(1, info).message(2, mask))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write blob %s: %w", shortDigest(desc.Digest), err)
+	}
+	return nil
+}
+
+// unary makes a gRPC call with a single request message.
+func (c *containerdLoader) unary(ctx context.Context, lease, method string, msg protoMessage) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeGRPCFrame(pw, msg))
+	}()
+	err := c.call(ctx, lease, method, pr)
+	pr.Close()
+	return err
+}
+
+// call makes a gRPC call with the framed request messages read from body,
+// discards the responses and returns the call's status as an error. The
+// namespace, and lease when set, go in the headers containerd reads them
+// from.
+func (c *containerdLoader) call(ctx context.Context, lease, method string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://containerd"+method, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("containerd-namespace", c.namespace)
+	if lease != "" {
+		req.Header.Set("containerd-lease", lease)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("containerd returned %s", resp.Status)
+	}
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+
+	// A call failing before any response sends its status in the headers
+	status, message := resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("containerd sent no gRPC status for %s", method)
+	}
+	if code == 0 {
+		return nil
+	}
+	if m, err := url.PathUnescape(message); err == nil {
+		message = m
+	}
+	return &grpcError{code: code, message: message}
+}
+
+// grpcError is a failed gRPC call.
+type grpcError struct {
+	code    int
+	message string
+}
+
+func (e *grpcError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("gRPC status %d", e.code)
+	}
+	return e.message
+}
+
+// isGRPCCode reports whether err is a gRPC status with the given code.
+func isGRPCCode(err error, code int) bool {
+	e, ok := err.(*grpcError)
+	return ok && e.code == code
+}
+
+// writeGRPCFrame writes msg with gRPC's uncompressed length prefix.
+func writeGRPCFrame(w io.Writer, msg protoMessage) error {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
+
+// protoMessage builds a protobuf message field by field. Zero values are
+// omitted, as proto3 does.
+type protoMessage []byte
+
+func (m protoMessage) tag(field, wireType int) protoMessage {
+	return binary.AppendUvarint(m, uint64(field<<3|wireType))
+}
+
+func (m protoMessage) int(field int, v int64) protoMessage {
+	if v == 0 {
+		return m
+	}
+	return binary.AppendUvarint(m.tag(field, 0), uint64(v))
+}
+
+func (m protoMessage) bytes(field int, b []byte) protoMessage {
+	if len(b) == 0 {
+		return m
+	}
+	m = binary.AppendUvarint(m.tag(field, 2), uint64(len(b)))
+	return append(m, b...)
+}
+
+func (m protoMessage) string(field int, s string) protoMessage {
+	return m.bytes(field, []byte(s))
+}
+
+func (m protoMessage) message(field int, sub protoMessage) protoMessage {
+	m = binary.AppendUvarint(m.tag(field, 2), uint64(len(sub)))
+	return append(m, sub...)
+}
+
+// labels encodes a map<string, string> field, in key order.
+func (m protoMessage) labels(field int, labels map[string]string) protoMessage {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m = m.message(field, protoMessage{}.string(1, k).string(2, labels[k]))
+	}
+	return m
+}
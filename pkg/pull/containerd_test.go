@@ -0,0 +1,407 @@
+package pull
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+)
+
+// protoFields is a decoded protobuf message: varint fields, and
+// length-delimited fields in order.
+type protoFields struct {
+	ints  map[int]int64
+	bytes map[int][][]byte
+}
+
+func decodeProto(t *testing.T, b []byte) protoFields {
+	t.Helper()
+	m := protoFields{ints: map[int]int64{}, bytes: map[int][][]byte{}}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad protobuf tag")
+		}
+		b = b[n:]
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad protobuf field")
+		}
+		b = b[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			m.ints[field] = int64(v)
+		case 2:
+			m.bytes[field] = append(m.bytes[field], b[:v])
+			b = b[v:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return m
+}
+
+func (m protoFields) str(field int) string {
+	if len(m.bytes[field]) == 0 {
+		return ""
+	}
+	return string(m.bytes[field][0])
+}
+
+func (m protoFields) sub(t *testing.T, field int) protoFields {
+	t.Helper()
+	if len(m.bytes[field]) == 0 {
+		return decodeProto(t, nil)
+	}
+	return decodeProto(t, m.bytes[field][0])
+}
+
+func (m protoFields) labels(t *testing.T, field int) map[string]string {
+	t.Helper()
+	labels := map[string]string{}
+	for _, entry := range m.bytes[field] {
+		e := decodeProto(t, entry)
+		labels[e.str(1)] = e.str(2)
+	}
+	return labels
+}
+
+// fakeContainerd serves the gRPC calls of an import on a unix socket, over
+// HTTP/2 without TLS as containerd does.
+type fakeContainerd struct {
+	t       *testing.T
+	address string
+
+	mu       sync.Mutex
+	blobs    map[string][]byte
+	labels   map[string]map[string]string
+	images   map[string]string
+	calls    []string
+	leases   map[string]bool
+	unleased []string
+	writes   int
+	// fail maps a method to the gRPC status it fails with.
+	fail map[string]string
+}
+
+func newFakeContainerd(t *testing.T) *fakeContainerd {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets")
+	}
+	dir, err := os.MkdirTemp("", "ctrd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	f := &fakeContainerd{
+		t:       t,
+		address: filepath.Join(dir, "containerd.sock"),
+		blobs:   map[string][]byte{},
+		labels:  map[string]map[string]string{},
+		images:  map[string]string{},
+		leases:  map[string]bool{},
+		fail:    map[string]string{},
+	}
+	l, err := net.Listen("unix", f.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(f.serve))
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv.Config.Protocols = &protocols
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return f
+}
+
+func (f *fakeContainerd) serve(w http.ResponseWriter, r *http.Request) {
+	t := f.t
+	if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc" {
+		http.Error(w, "not gRPC", http.StatusBadRequest)
+		return
+	}
+	method := r.URL.Path
+	f.mu.Lock()
+	f.calls = append(f.calls, r.Header.Get("containerd-namespace")+" "+method)
+	failure := f.fail[method]
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	status := func(code int, message string) {
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+		w.Header().Set("Grpc-Message", message)
+	}
+	if failure != "" {
+		code, message, _ := strings.Cut(failure, " ")
+		n, _ := strconv.Atoi(code)
+		status(n, strings.ReplaceAll(message, " ", "%20"))
+		return
+	}
+
+	lease := r.Header.Get("containerd-lease")
+	if method != ctrdLeasesCreate && method != ctrdLeasesDelete {
+		f.mu.Lock()
+		if !f.leases[lease] {
+			f.unleased = append(f.unleased, method)
+		}
+		f.mu.Unlock()
+	}
+
+	if method == ctrdContentWrite {
+		var data bytes.Buffer
+		for {
+			msg, err := readGRPCFrame(r.Body)
+			if err == io.EOF {
+				status(13, "stream ended before commit")
+				return
+			}
+			if err != nil {
+				t.Errorf("Write: %v", err)
+				return
+			}
+			req := decodeProto(t, msg)
+			expected := req.str(4)
+			f.mu.Lock()
+			_, exists := f.blobs[expected]
+			f.mu.Unlock()
+			if exists {
+				status(grpcAlreadyExists, expected+"%3A%20already%20exists")
+				return
+			}
+			if req.ints[5] != int64(data.Len()) {
+				status(11, "bad offset")
+				return
+			}
+			data.WriteString(req.str(6))
+			if req.ints[1] == ctrdCommit {
+				if fmt.Sprintf("sha256:%x", sha256.Sum256(data.Bytes())) != expected || req.ints[3] != int64(data.Len()) {
+					status(9, "unexpected commit digest")
+					return
+				}
+				f.mu.Lock()
+				f.blobs[expected] = data.Bytes()
+				f.labels[expected] = req.labels(t, 7)
+				f.mu.Unlock()
+				writeGRPCFrame(w, protoMessage{}.int(1, ctrdCommit))
+				status(0, "")
+				return
+			}
+			f.mu.Lock()
+			f.writes++
+			f.mu.Unlock()
+			writeGRPCFrame(w, protoMessage{}.int(1, ctrdWrite))
+			w.(http.Flusher).Flush()
+		}
+	}
+
+	msg, err := readGRPCFrame(r.Body)
+	if err != nil {
+		t.Errorf("%s: %v", method, err)
+		return
+	}
+	req := decodeProto(t, msg)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch method {
+	case ctrdLeasesCreate:
+		if req.labels(t, 3)["containerd.io/gc.expire"] == "" {
+			t.Error("lease has no expiry")
+		}
+		f.leases[req.str(1)] = true
+	case ctrdLeasesDelete:
+		delete(f.leases, req.str(1))
+	case ctrdContentUpdate:
+		info := req.sub(t, 1)
+		for k, v := range info.labels(t, 5) {
+			f.labels[info.str(1)][k] = v
+		}
+	case ctrdImagesCreate, ctrdImagesUpdate:
+		image := req.sub(t, 1)
+		target := image.sub(t, 3)
+		if _, ok := f.blobs[target.str(2)]; !ok {
+			status(5, "target not found")
+			return
+		}
+		if _, ok := f.images[image.str(1)]; ok && method == ctrdImagesCreate {
+			status(grpcAlreadyExists, "image already exists")
+			return
+		}
+		f.images[image.str(1)] = target.str(2)
+	default:
+		status(12, "unknown method")
+		return
+	}
+	writeGRPCFrame(w, protoMessage{})
+	status(0, "")
+}
+
+func readGRPCFrame(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	_, err := io.ReadFull(r, msg)
+	return msg, err
+}
+
+func TestContainerdLoad(t *testing.T) {
+	layout, layers := testLayout(t)
+	ctrd := newFakeContainerd(t)
+
+	t.Setenv("CONTAINERD_ADDRESS", "unix://"+ctrd.address)
+	loader, err := NewLoader(LoadContainerd, "k8s.io")
+	if err != nil {
+		t.Fatalf("NewLoader() error = %v", err)
+	}
+	const ref = "localhost:5050/test/hello:v1"
+	if err := loader.Load(context.Background(), layout, ref); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	index, err := ociutil.ReadOCIIndex(layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestDigest := index.TaggedManifest().Digest
+	manifest, err := ociutil.ReadOCIManifest(layout, manifestDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ctrd.images[ref]; got != manifestDigest {
+		t.Errorf("image %s = %q, want the manifest %s", ref, got, manifestDigest)
+	}
+	// Layers are stored as they are, compressed or not.
+	if got := string(ctrd.blobs[manifest.Layers[1].Digest]); got != layers[1] {
+		t.Errorf("plain layer = %q, want %q", got, layers[1])
+	}
+	if got := ctrd.blobs[manifest.Layers[0].Digest]; !bytes.HasPrefix(got, []byte("\x1f\x8b")) {
+		t.Errorf("gzipped layer = %q, want it stored compressed", got)
+	}
+	if _, ok := ctrd.blobs[manifest.Config.Digest]; !ok {
+		t.Error("config was not written")
+	}
+	wantLabels := map[string]string{
+		"containerd.io/gc.ref.content.config": manifest.Config.Digest,
+		"containerd.io/gc.ref.content.l.0":    manifest.Layers[0].Digest,
+		"containerd.io/gc.ref.content.l.1":    manifest.Layers[1].Digest,
+	}
+	if got := ctrd.labels[manifestDigest]; fmt.Sprint(got) != fmt.Sprint(wantLabels) {
+		t.Errorf("manifest labels = %v, want %v", got, wantLabels)
+	}
+	if len(ctrd.unleased) != 0 {
+		t.Errorf("calls without a lease: %v", ctrd.unleased)
+	}
+	if len(ctrd.leases) != 0 {
+		t.Errorf("leases left behind: %v", ctrd.leases)
+	}
+	for _, call := range ctrd.calls {
+		if !strings.HasPrefix(call, "k8s.io ") {
+			t.Errorf("call %q is not in namespace k8s.io", call)
+		}
+	}
+
+	// Loading again keeps the blobs containerd has and re-tags the image.
+	delete(ctrd.labels[manifestDigest], "containerd.io/gc.ref.content.l.1")
+	ctrd.calls = nil
+	if err := loader.Load(context.Background(), layout, ref); err != nil {
+		t.Fatalf("second Load() error = %v", err)
+	}
+	if got := ctrd.labels[manifestDigest]; fmt.Sprint(got) != fmt.Sprint(wantLabels) {
+		t.Errorf("manifest labels after reload = %v, want %v", got, wantLabels)
+	}
+	if !strings.Contains(strings.Join(ctrd.calls, "\n"), ctrdImagesUpdate) {
+		t.Errorf("calls = %v, want the existing image updated", ctrd.calls)
+	}
+}
+
+func TestContainerdLoadChunks(t *testing.T) {
+	ctrd := newFakeContainerd(t)
+	layout := t.TempDir()
+
+	// A blob spanning several Write messages
+	data := make([]byte, 2*ctrdChunkSize+100)
+	rand.Read(data)
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	blobPath := ociutil.BlobPath(layout, digest)
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(blobPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctrd.leases["test"] = true
+
+	loader := newContainerdLoader(ctrd.address, "default")
+	desc := ociutil.OCIDescriptor{Digest: digest, Size: int64(len(data))}
+	if err := loader.writeBlob(context.Background(), "test", layout, desc, nil); err != nil {
+		t.Fatalf("writeBlob() error = %v", err)
+	}
+	if !bytes.Equal(ctrd.blobs[digest], data) {
+		t.Error("stored blob differs from the layout's")
+	}
+	if ctrd.writes != 3 {
+		t.Errorf("Write messages before the commit = %d, want 3", ctrd.writes)
+	}
+}
+
+func TestContainerdLoadErrors(t *testing.T) {
+	layout, _ := testLayout(t)
+	ctrd := newFakeContainerd(t)
+	ctrd.fail[ctrdLeasesCreate] = "9 namespace k8s.io: not found"
+
+	loader := newContainerdLoader(ctrd.address, "k8s.io")
+	err := loader.Load(context.Background(), layout, "test:v1")
+	if err == nil || !strings.Contains(err.Error(), "namespace k8s.io: not found") {
+		t.Errorf("Load() error = %v, want containerd's message", err)
+	}
+
+	loader = newContainerdLoader(filepath.Join(t.TempDir(), "missing.sock"), "default")
+	err = loader.Load(context.Background(), layout, "test:v1")
+	if err == nil || !strings.Contains(err.Error(), "failed to load into containerd") {
+		t.Errorf("Load() error = %v, want unreachable containerd", err)
+	}
+}
+
+func TestContainerdAvailable(t *testing.T) {
+	ctrd := newFakeContainerd(t)
+	t.Setenv("CONTAINERD_ADDRESS", ctrd.address)
+	if !LoadTargetAvailable(LoadContainerd) {
+		t.Error("LoadTargetAvailable(containerd) = false with its socket present")
+	}
+	t.Setenv("CONTAINERD_NAMESPACE", "")
+	loader, err := NewLoader(LoadContainerd, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loader.(*containerdLoader).namespace; got != "default" {
+		t.Errorf("namespace = %q, want default", got)
+	}
+
+	t.Setenv("CONTAINERD_ADDRESS", filepath.Join(t.TempDir(), "missing.sock"))
+	if LoadTargetAvailable(LoadContainerd) {
+		t.Error("LoadTargetAvailable(containerd) = true without its socket")
+	}
+}
@@ -0,0 +1,228 @@
+package pull
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Format is the on-disk form a pull is written in.
+type Format string
+
+const (
+	// FormatOCIDir is an OCI image layout directory (the default).
+	FormatOCIDir Format = "oci-dir"
+	// FormatOCITar is an OCI image layout packed in a tarball.
+	FormatOCITar Format = "oci-tar"
+	// FormatDockerArchive is a `docker save` tarball, as read by `docker load`.
+	FormatDockerArchive Format = "docker-archive"
+)
+
+// Formats lists the supported pull formats.
+var Formats = []Format{FormatOCIDir, FormatOCITar, FormatDockerArchive}
+
+// ParseFormat parses a pull format name. An empty name is FormatOCIDir.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return FormatOCIDir, nil
+	}
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown format %q (supported: %s)", s, strings.Join(names, ", "))
+}
+
+// IsArchive reports whether f is written as a single tarball.
+func (f Format) IsArchive() bool {
+	return f == FormatOCITar || f == FormatDockerArchive
+}
+
+// ExportOCITar writes the OCI layout at ociLayoutPath to a tarball at path.
+// Partial downloads in the layout's ingest directory are left out.
+func ExportOCITar(ociLayoutPath, path string) error {
+	return writeArchive(path, func(tw *tar.Writer) error {
+		for _, name := range []string{ocispec.ImageLayoutFile, "index.json"} {
+			if err := addTarFile(tw, filepath.Join(ociLayoutPath, name), name); err != nil {
+				return err
+			}
+		}
+		blobs := filepath.Join(ociLayoutPath, "blobs")
+		return filepath.WalkDir(blobs, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(ociLayoutPath, p)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			if d.IsDir() {
+				return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0755})
+			}
+			return addTarFile(tw, p, name)
+		})
+	})
+}
+
+// ExportDockerArchive writes the image in the OCI layout at ociLayoutPath
+// to a tarball at path in the Docker save format, tagged as reference:
+//
+//	manifest.json            — [{Config: "<sha>.json", RepoTags: [...], Layers: ["<sha>/layer.tar", ...]}]
+//	<config-sha>.json        — image config blob
+//	<layer-sha>/layer.tar    — each layer (decompressed)
+func ExportDockerArchive(ociLayoutPath, reference, path string) error {
+	return writeArchive(path, func(tw *tar.Writer) error {
+		return writeDockerArchive(tw, ociLayoutPath, reference, true)
+	})
+}
+
+// writeDockerArchive writes the entries of a Docker save tarball to tw.
+// Layers are decompressed when decompress is set; otherwise they are
+// written as stored, which docker load and containerd also accept, so the
+// archive can be streamed without temp files.
+func writeDockerArchive(tw *tar.Writer, ociLayoutPath, reference string, decompress bool) error {
+	// Read the OCI index to find the manifest descriptor
+	index, err := ociutil.ReadOCIIndex(ociLayoutPath)
+	if err != nil {
+		return fmt.Errorf("failed to read OCI index: %w", err)
+	}
+	if len(index.Manifests) == 0 {
+		return fmt.Errorf("OCI index contains no manifests")
+	}
+
+	// Use the tagged manifest; referrers pulled alongside it are untagged.
+	manifestDesc := index.TaggedManifest()
+	if isIndex(manifestDesc.MediaType) {
+		return fmt.Errorf("a Docker archive holds a single platform; pull one platform with --platform")
+	}
+
+	manifest, err := ociutil.ReadOCIManifest(ociLayoutPath, manifestDesc.Digest)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	// Add config blob as <sha256>.json
+	configData, err := ociutil.ReadBlob(ociLayoutPath, manifest.Config.Digest)
+	if err != nil {
+		return fmt.Errorf("failed to read config blob: %w", err)
+	}
+	configName := ociutil.StripDigestPrefix(manifest.Config.Digest) + ".json"
+	if err := ociutil.AddTarEntry(tw, configName, configData); err != nil {
+		return fmt.Errorf("failed to add config to tarball: %w", err)
+	}
+
+	// Add each layer as <sha256>/layer.tar
+	var layerPaths []string
+	for _, layer := range manifest.Layers {
+		layerPath := ociutil.StripDigestPrefix(layer.Digest) + "/layer.tar"
+		if err := addDockerLayer(tw, ociLayoutPath, layer, layerPath, decompress); err != nil {
+			return err
+		}
+		layerPaths = append(layerPaths, layerPath)
+	}
+
+	// Write manifest.json (Docker save format)
+	manifestJSON, err := json.Marshal([]ociutil.DockerSaveManifest{{
+		Config:   configName,
+		RepoTags: []string{reference},
+		Layers:   layerPaths,
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Docker manifest: %w", err)
+	}
+	if err := ociutil.AddTarEntry(tw, "manifest.json", manifestJSON); err != nil {
+		return fmt.Errorf("failed to add manifest.json to tarball: %w", err)
+	}
+	return nil
+}
+
+// addDockerLayer adds a layer blob to tw as name. When decompress is set,
+// gzipped layers are decompressed to a temp file first, since tar requires
+// the size up front.
+func addDockerLayer(tw *tar.Writer, ociLayoutPath string, layer ociutil.OCIDescriptor, name string, decompress bool) error {
+	layerFile, err := os.Open(ociutil.BlobPath(ociLayoutPath, layer.Digest))
+	if err != nil {
+		return fmt.Errorf("failed to open layer %s: %w", layer.Digest, err)
+	}
+	defer layerFile.Close()
+
+	if !decompress || !strings.Contains(layer.MediaType, "gzip") {
+		stat, err := layerFile.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat layer %s: %w", layer.Digest, err)
+		}
+		if err := ociutil.AddTarEntryFromReader(tw, name, layerFile, stat.Size()); err != nil {
+			return fmt.Errorf("failed to add layer to tarball: %w", err)
+		}
+		return nil
+	}
+
+	decompressed, size, err := ociutil.DecompressToTemp(layerFile)
+	if err != nil {
+		return fmt.Errorf("failed to decompress layer %s: %w", layer.Digest, err)
+	}
+	defer os.Remove(decompressed.Name())
+	defer decompressed.Close()
+
+	if err := ociutil.AddTarEntryFromReader(tw, name, decompressed, size); err != nil {
+		return fmt.Errorf("failed to add layer to tarball: %w", err)
+	}
+	return nil
+}
+
+// writeArchive creates a tarball at path with the entries fill writes. A
+// failed archive is removed rather than left truncated.
+func writeArchive(path string, fill func(tw *tar.Writer) error) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create tarball: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(path)
+		}
+	}()
+
+	tw := tar.NewWriter(f)
+	if err := fill(tw); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize tarball: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close tarball: %w", err)
+	}
+	return nil
+}
+
+// addTarFile adds the regular file at path to tw as name.
+func addTarFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+	if err := ociutil.AddTarEntryFromReader(tw, name, f, info.Size()); err != nil {
+		return fmt.Errorf("failed to add %s to tarball: %w", name, err)
+	}
+	return nil
+}
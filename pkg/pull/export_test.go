@@ -0,0 +1,252 @@
+package pull
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Format
+		wantErr bool
+	}{
+		{input: "", want: FormatOCIDir},
+		{input: "oci-dir", want: FormatOCIDir},
+		{input: "oci-tar", want: FormatOCITar},
+		{input: "docker-archive", want: FormatDockerArchive},
+		{input: "tar", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseFormat(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormat(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFormat(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// testLayout writes an image with one gzipped and one plain layer to an OCI
+// layout and returns its directory and the uncompressed layer contents.
+func testLayout(t *testing.T) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	src := memory.New()
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("gzipped layer"))
+	zw.Close()
+
+	config := pushBlob(t, src, ocispec.MediaTypeImageConfig, []byte(`{"architecture":"amd64","os":"linux"}`))
+	layers := []ocispec.Descriptor{
+		pushBlob(t, src, ocispec.MediaTypeImageLayerGzip, gz.Bytes()),
+		pushBlob(t, src, ocispec.MediaTypeImageLayer, []byte("plain layer")),
+	}
+	data, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    layers,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := pushBlob(t, src, ocispec.MediaTypeImageManifest, data)
+	if err := src.Tag(ctx, manifest, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	dst, err := oci.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oras.Copy(ctx, src, "v1", dst, "v1", oras.DefaultCopyOptions); err != nil {
+		t.Fatal(err)
+	}
+	return dir, []string{"gzipped layer", "plain layer"}
+}
+
+// readTar returns the regular files in the tarball at path by name, and
+// the names of all entries in order.
+func readTar(t *testing.T, path string) (map[string]string, []string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	files := map[string]string{}
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if hdr.Typeflag == tar.TypeReg {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[hdr.Name] = string(data)
+		}
+	}
+	return files, names
+}
+
+func TestExportOCITar(t *testing.T) {
+	dir, _ := testLayout(t)
+	// A partial download must not end up in the archive.
+	if err := os.MkdirAll(filepath.Join(dir, "ingest"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ingest", "abc.partial"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "image.tar")
+	if err := ExportOCITar(dir, out); err != nil {
+		t.Fatalf("ExportOCITar() error = %v", err)
+	}
+	files, names := readTar(t, out)
+
+	if names[0] != ocispec.ImageLayoutFile || names[1] != "index.json" {
+		t.Errorf("archive starts with %v, want oci-layout and index.json", names[:2])
+	}
+	blobs := 0
+	for _, name := range names {
+		if strings.HasPrefix(name, "ingest") {
+			t.Errorf("archive contains %s", name)
+		}
+		if strings.HasPrefix(name, "blobs/sha256/") && !strings.HasSuffix(name, "/") {
+			blobs++
+		}
+	}
+	if blobs != 4 {
+		t.Errorf("archive has %d blobs, want 4 (manifest, config, 2 layers)", blobs)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files["index.json"] != string(index) {
+		t.Error("index.json differs from the layout's")
+	}
+}
+
+func TestExportDockerArchive(t *testing.T) {
+	dir, layers := testLayout(t)
+
+	out := filepath.Join(t.TempDir(), "image.tar")
+	if err := ExportDockerArchive(dir, "example.com/app:v1", out); err != nil {
+		t.Fatalf("ExportDockerArchive() error = %v", err)
+	}
+	files, _ := readTar(t, out)
+
+	var manifests []ociutil.DockerSaveManifest
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifests); err != nil {
+		t.Fatalf("manifest.json: %v", err)
+	}
+	if len(manifests) != 1 {
+		t.Fatalf("manifest.json has %d entries, want 1", len(manifests))
+	}
+	m := manifests[0]
+	if !reflect.DeepEqual(m.RepoTags, []string{"example.com/app:v1"}) {
+		t.Errorf("RepoTags = %v", m.RepoTags)
+	}
+	if !strings.Contains(files[m.Config], `"architecture":"amd64"`) {
+		t.Errorf("config %s = %q", m.Config, files[m.Config])
+	}
+	if len(m.Layers) != len(layers) {
+		t.Fatalf("got %d layers, want %d", len(m.Layers), len(layers))
+	}
+	for i, want := range layers {
+		if got := files[m.Layers[i]]; got != want {
+			t.Errorf("layer %d = %q, want %q (decompressed)", i, got, want)
+		}
+	}
+}
+
+func TestExportDockerArchiveIndex(t *testing.T) {
+	ctx := context.Background()
+	src, _ := testIndex(t, "linux/amd64", "linux/arm64")
+	dir := t.TempDir()
+	dst, err := oci.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oras.Copy(ctx, src, "v1", dst, "v1", oras.DefaultCopyOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "image.tar")
+	if err := ExportDockerArchive(dir, "example.com/app:v1", out); err == nil {
+		t.Fatal("ExportDockerArchive() error = nil, want single-platform error")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("archive left behind: %v", err)
+	}
+}
+
+func TestExportDockerArchiveUntagged(t *testing.T) {
+	dir, _ := testLayout(t)
+
+	// An untagged manifest listed first, as a referrer pulled along
+	// with the image may be
+	path := filepath.Join(dir, "index.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	referrer := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromString("missing referrer"),
+		Size:      16,
+	}
+	index.Manifests = append([]ocispec.Descriptor{referrer}, index.Manifests...)
+	if data, err = json.Marshal(index); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "image.tar")
+	if err := ExportDockerArchive(dir, "example.com/app:v1", out); err != nil {
+		t.Fatalf("ExportDockerArchive() error = %v, want the tagged manifest exported", err)
+	}
+}
@@ -0,0 +1,335 @@
+package pull
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	helmChartMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	helmProvMediaType  = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+
+	// annotationUnpack marks a layer holding a gzipped tarball of a
+	// directory, as written by `oras push <dir>`.
+	annotationUnpack = "io.deis.oras.content.unpack"
+)
+
+// Extract writes the payload of the artifact with the given manifest
+// digest, stored in the OCI layout at ociLayoutPath, to dir, and returns the
+// paths written. Helm charts are written as <name>-<version>.tgz, or
+// unpacked into <name>/ when untar is set. Other artifacts have each layer
+// written under its org.opencontainers.image.title; layers without a title
+// are skipped. Names come from the registry, so any that would escape dir
+// are rejected.
+func Extract(ociLayoutPath, manifestDigest string, artifactType registry.ArtifactType, dir string, untar bool) ([]string, error) {
+	if artifactType == registry.ArtifactTypeImage {
+		return nil, fmt.Errorf("images have no files to extract; use lazyoci unpack for a root filesystem")
+	}
+
+	data, err := ociutil.ReadBlob(ociLayoutPath, manifestDigest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	if artifactType == registry.ArtifactTypeHelmChart {
+		return extractChart(ociLayoutPath, manifest, dir, untar)
+	}
+	return extractFiles(ociLayoutPath, manifest, dir)
+}
+
+// extractChart writes a Helm chart and its provenance file.
+func extractChart(ociLayoutPath string, manifest ocispec.Manifest, dir string, untar bool) ([]string, error) {
+	var meta struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	configData, err := ociutil.ReadBlob(ociLayoutPath, manifest.Config.Digest.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart config: %w", err)
+	}
+	if err := json.Unmarshal(configData, &meta); err != nil || meta.Name == "" || meta.Version == "" {
+		return nil, fmt.Errorf("chart config has no name and version")
+	}
+	archive := meta.Name + "-" + meta.Version + ".tgz"
+
+	var written []string
+	for _, layer := range manifest.Layers {
+		switch layer.MediaType {
+		case helmChartMediaType:
+			if untar {
+				target, err := safeJoin(dir, meta.Name)
+				if err != nil {
+					return nil, err
+				}
+				if err := untarBlob(ociLayoutPath, layer, dir); err != nil {
+					return nil, fmt.Errorf("failed to unpack chart: %w", err)
+				}
+				written = append(written, target)
+				continue
+			}
+			target, err := safeJoin(dir, archive)
+			if err != nil {
+				return nil, err
+			}
+			if err := copyBlob(ociLayoutPath, layer, target); err != nil {
+				return nil, err
+			}
+			written = append(written, target)
+		case helmProvMediaType:
+			target, err := safeJoin(dir, archive+".prov")
+			if err != nil {
+				return nil, err
+			}
+			if err := copyBlob(ociLayoutPath, layer, target); err != nil {
+				return nil, err
+			}
+			written = append(written, target)
+		}
+	}
+	if len(written) == 0 {
+		return nil, fmt.Errorf("chart has no %s layer", helmChartMediaType)
+	}
+	return written, nil
+}
+
+// extractFiles writes each titled layer under its title.
+func extractFiles(ociLayoutPath string, manifest ocispec.Manifest, dir string) ([]string, error) {
+	var written []string
+	for _, layer := range manifest.Layers {
+		title := layer.Annotations[ocispec.AnnotationTitle]
+		if title == "" {
+			continue
+		}
+		target, err := safeJoin(dir, title)
+		if err != nil {
+			return nil, err
+		}
+
+		if layer.Annotations[annotationUnpack] == "true" {
+			// The tarball's entries are named from the title, as
+			// oras writes them, so it is unpacked into dir.
+			if err := untarBlob(ociLayoutPath, layer, dir); err != nil {
+				return nil, fmt.Errorf("failed to unpack %s: %w", title, err)
+			}
+		} else if err := copyBlob(ociLayoutPath, layer, target); err != nil {
+			return nil, err
+		}
+		written = append(written, target)
+	}
+	if len(written) == 0 {
+		return nil, fmt.Errorf("no layer has an %s annotation to name its file", ocispec.AnnotationTitle)
+	}
+	return written, nil
+}
+
+// safeJoin joins name, a slash-separated relative path from the registry,
+// to dir. Absolute names, names with ".." components and names under a
+// symlink inside dir, which an earlier layer may have unpacked, are
+// rejected.
+func safeJoin(dir, name string) (string, error) {
+	if name == "" || path.IsAbs(name) || filepath.IsAbs(name) || strings.Contains(name, "\\") {
+		return "", fmt.Errorf("unsafe file name %q", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("unsafe file name %q", name)
+		}
+	}
+	clean := path.Clean(name)
+	if clean == "." {
+		return "", fmt.Errorf("unsafe file name %q", name)
+	}
+
+	// Writes follow symlinks in the parent directories, so none may be one
+	parent := dir
+	parts := strings.Split(clean, "/")
+	for _, part := range parts[:len(parts)-1] {
+		parent = filepath.Join(parent, part)
+		fi, err := os.Lstat(parent)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("unsafe file name %q: %s is a symlink", name, part)
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// copyBlob writes a blob from the layout to target, replacing any file
+// already there. Parent directories are created as needed.
+func copyBlob(ociLayoutPath string, desc ocispec.Descriptor, target string) error {
+	src, err := os.Open(ociutil.BlobPath(ociLayoutPath, desc.Digest.String()))
+	if err != nil {
+		return fmt.Errorf("failed to open blob %s: %w", shortDigest(desc.Digest.String()), err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".lazyoci-*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", target, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return nil
+}
+
+// untarBlob unpacks a gzipped tarball blob into dir. Unlike image layers,
+// the tarball lands in a directory of the user's, so it is extracted
+// plainly: whiteout entries are rejected rather than deleting anything,
+// owners are not applied, and setuid, setgid and sticky bits are dropped.
+// Existing directories are merged into and existing regular files
+// overwritten; an entry that would replace anything else is an error.
+// Names are checked as safeJoin checks layer titles, so nothing is written
+// outside dir or through a symlink.
+func untarBlob(ociLayoutPath string, desc ocispec.Descriptor, dir string) error {
+	f, err := os.Open(ociutil.BlobPath(ociLayoutPath, desc.Digest.String()))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tarball: %w", err)
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if name == "" || name == "." || name == "./" {
+			continue
+		}
+		if strings.HasPrefix(path.Base(name), ".wh.") {
+			return fmt.Errorf("unsupported whiteout entry %q", hdr.Name)
+		}
+		target, err := safeJoin(dir, strings.TrimSuffix(name, "/"))
+		if err != nil {
+			return err
+		}
+		if err := untarEntry(tr, hdr, dir, target); err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+	}
+}
+
+// untarEntry writes the tar entry hdr to target, inside dir.
+func untarEntry(tr *tar.Reader, hdr *tar.Header, dir, target string) error {
+	existing, err := os.Lstat(target)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	exists := err == nil
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if exists {
+			if !existing.IsDir() {
+				return fmt.Errorf("%s already exists and is not a directory", target)
+			}
+			return nil
+		}
+		return os.MkdirAll(target, 0755)
+
+	case tar.TypeReg:
+		if exists && !existing.Mode().IsRegular() {
+			return fmt.Errorf("%s already exists and is not a regular file", target)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return os.Chmod(target, hdr.FileInfo().Mode().Perm())
+
+	case tar.TypeSymlink:
+		// Never followed here: safeJoin rejects names under a symlink
+		if exists {
+			return fmt.Errorf("%s already exists", target)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Symlink(hdr.Linkname, target)
+
+	case tar.TypeLink:
+		if exists {
+			return fmt.Errorf("%s already exists", target)
+		}
+		source, err := safeJoin(dir, strings.TrimPrefix(hdr.Linkname, "./"))
+		if err != nil {
+			return err
+		}
+		fi, err := os.Lstat(source)
+		if err != nil {
+			return fmt.Errorf("hardlink target %s: %w", hdr.Linkname, err)
+		}
+		if !fi.Mode().IsRegular() {
+			return fmt.Errorf("hardlink target %s is not a regular file", hdr.Linkname)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Link(source, target)
+	}
+	// Devices, FIFOs and other entries a chart or directory has no use for
+	return nil
+}
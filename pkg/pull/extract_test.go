@@ -0,0 +1,357 @@
+package pull
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestSafeJoin(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "config.json", want: "config.json"},
+		{name: "policies/policy.rego", want: "policies/policy.rego"},
+		{name: "./a/./b", want: "a/b"},
+		{name: "../etc/passwd", wantErr: true},
+		{name: "a/../../b", wantErr: true},
+		{name: "a/../b", wantErr: true},
+		{name: "/etc/passwd", wantErr: true},
+		{name: `..\evil`, wantErr: true},
+		{name: "", wantErr: true},
+		{name: ".", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := safeJoin("/dest", tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("safeJoin(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != filepath.Join("/dest", filepath.FromSlash(tt.want)) {
+				t.Errorf("safeJoin(%q) = %q, want /dest/%s", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+// layoutBlob is a blob of a test artifact.
+type layoutBlob struct {
+	mediaType   string
+	data        []byte
+	annotations map[string]string
+}
+
+// writeArtifactLayout stores an artifact with the given config and layers
+// in a new OCI layout and returns its directory and manifest digest.
+func writeArtifactLayout(t *testing.T, config layoutBlob, layers ...layoutBlob) (string, string) {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := oci.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := func(b layoutBlob) ocispec.Descriptor {
+		desc := ocispec.Descriptor{
+			MediaType:   b.mediaType,
+			Digest:      digest.FromBytes(b.data),
+			Size:        int64(len(b.data)),
+			Annotations: b.annotations,
+		}
+		if err := store.Push(ctx, desc, bytes.NewReader(b.data)); err != nil {
+			t.Fatal(err)
+		}
+		return desc
+	}
+
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    push(config),
+	}
+	for _, l := range layers {
+		manifest.Layers = append(manifest.Layers, push(l))
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := push(layoutBlob{mediaType: ocispec.MediaTypeImageManifest, data: data})
+	return dir, desc.Digest.String()
+}
+
+// tgz builds a gzipped tarball of files.
+func tgz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[name]))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(files[name]))
+	}
+	tw.Close()
+	zw.Close()
+	return buf.Bytes()
+}
+
+// relPaths returns paths relative to dir, slash-separated.
+func relPaths(t *testing.T, dir string, paths []string) []string {
+	t.Helper()
+	var rel []string
+	for _, p := range paths {
+		r, err := filepath.Rel(dir, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	return rel
+}
+
+func TestExtractChart(t *testing.T) {
+	chart := tgz(t, map[string]string{"app/Chart.yaml": "name: app\nversion: 1.2.0\n", "app/values.yaml": "replicas: 1\n"})
+	layout, manifest := writeArtifactLayout(t,
+		layoutBlob{mediaType: "application/vnd.cncf.helm.config.v1+json", data: []byte(`{"name":"app","version":"1.2.0"}`)},
+		layoutBlob{mediaType: helmChartMediaType, data: chart},
+		layoutBlob{mediaType: helmProvMediaType, data: []byte("signature")},
+	)
+
+	t.Run("archive", func(t *testing.T) {
+		dir := t.TempDir()
+		files, err := Extract(layout, manifest, registry.ArtifactTypeHelmChart, dir, false)
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if got, want := relPaths(t, dir, files), []string{"app-1.2.0.tgz", "app-1.2.0.tgz.prov"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("files = %v, want %v", got, want)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "app-1.2.0.tgz"))
+		if err != nil || !bytes.Equal(data, chart) {
+			t.Errorf("chart archive differs from the layer (err = %v)", err)
+		}
+	})
+
+	t.Run("untar", func(t *testing.T) {
+		dir := t.TempDir()
+		files, err := Extract(layout, manifest, registry.ArtifactTypeHelmChart, dir, true)
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if got, want := relPaths(t, dir, files), []string{"app", "app-1.2.0.tgz.prov"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("files = %v, want %v", got, want)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "app", "values.yaml"))
+		if err != nil || string(data) != "replicas: 1\n" {
+			t.Errorf("app/values.yaml = %q, %v", data, err)
+		}
+	})
+}
+
+func TestExtractChartKeepsExistingFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry string
+	}{
+		{"opaque whiteout", ".wh..wh..opq"},
+		{"whiteout", ".wh.keep.txt"},
+		{"nested whiteout", "app/.wh.values.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := tgz(t, map[string]string{"app/Chart.yaml": "name: app\n", tt.entry: ""})
+			layout, manifest := writeArtifactLayout(t,
+				layoutBlob{mediaType: "application/vnd.cncf.helm.config.v1+json", data: []byte(`{"name":"app","version":"1.0.0"}`)},
+				layoutBlob{mediaType: helmChartMediaType, data: chart},
+			)
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, "keep.txt"), "mine")
+			writeTestFile(t, filepath.Join(dir, "app", "values.yaml"), "mine")
+
+			_, err := Extract(layout, manifest, registry.ArtifactTypeHelmChart, dir, true)
+			if err == nil || !strings.Contains(err.Error(), "whiteout") {
+				t.Errorf("Extract() error = %v, want a whiteout error", err)
+			}
+			for _, name := range []string{"keep.txt", "app/values.yaml"} {
+				if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err != nil || string(data) != "mine" {
+					t.Errorf("%s = %q, %v; want it kept", name, data, err)
+				}
+			}
+		})
+	}
+
+	// Without whiteouts, existing files beside the chart are left alone
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "keep.txt"), "mine")
+	layout, manifest := writeArtifactLayout(t,
+		layoutBlob{mediaType: "application/vnd.cncf.helm.config.v1+json", data: []byte(`{"name":"app","version":"1.0.0"}`)},
+		layoutBlob{mediaType: helmChartMediaType, data: tgz(t, map[string]string{"app/Chart.yaml": "name: app\n"})},
+	)
+	if _, err := Extract(layout, manifest, registry.ArtifactTypeHelmChart, dir, true); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "keep.txt")); err != nil || string(data) != "mine" {
+		t.Errorf("keep.txt = %q, %v; want it kept", data, err)
+	}
+}
+
+// writeTestFile writes body to name, creating its directory.
+func writeTestFile(t *testing.T, name, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractChartUnsafeName(t *testing.T) {
+	layout, manifest := writeArtifactLayout(t,
+		layoutBlob{mediaType: "application/vnd.cncf.helm.config.v1+json", data: []byte(`{"name":"../../evil","version":"1.0.0"}`)},
+		layoutBlob{mediaType: helmChartMediaType, data: tgz(t, map[string]string{"evil/Chart.yaml": ""})},
+	)
+	dir := t.TempDir()
+	if _, err := Extract(layout, manifest, registry.ArtifactTypeHelmChart, filepath.Join(dir, "out"), false); err == nil {
+		t.Fatal("Extract() error = nil, want unsafe name")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil-1.0.0.tgz")); !os.IsNotExist(err) {
+		t.Errorf("chart written outside the destination: %v", err)
+	}
+}
+
+func TestExtractFiles(t *testing.T) {
+	titled := func(title, body string) layoutBlob {
+		return layoutBlob{
+			mediaType:   "application/vnd.example.file",
+			data:        []byte(body),
+			annotations: map[string]string{ocispec.AnnotationTitle: title},
+		}
+	}
+	config := layoutBlob{mediaType: ocispec.MediaTypeEmptyJSON, data: []byte("{}")}
+
+	t.Run("titled layers", func(t *testing.T) {
+		layout, manifest := writeArtifactLayout(t, config,
+			titled("config.json", `{"a":1}`),
+			titled("policies/policy.rego", "package x"),
+			layoutBlob{mediaType: "application/vnd.example.untitled", data: []byte("skipped")},
+			layoutBlob{
+				mediaType: ocispec.MediaTypeImageLayerGzip,
+				data:      tgz(t, map[string]string{"site/index.html": "<h1>hi</h1>"}),
+				annotations: map[string]string{
+					ocispec.AnnotationTitle: "site",
+					annotationUnpack:        "true",
+				},
+			},
+			layoutBlob{
+				mediaType: ocispec.MediaTypeImageLayerGzip,
+				data:      tgz(t, map[string]string{"models/bert/config.json": "{}"}),
+				annotations: map[string]string{
+					ocispec.AnnotationTitle: "models/bert",
+					annotationUnpack:        "true",
+				},
+			},
+		)
+		dir := t.TempDir()
+		files, err := Extract(layout, manifest, registry.ArtifactTypeUnknown, dir, false)
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		if got, want := relPaths(t, dir, files), []string{"config.json", "policies/policy.rego", "site", "models/bert"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("files = %v, want %v", got, want)
+		}
+		for name, want := range map[string]string{
+			"config.json":             `{"a":1}`,
+			"policies/policy.rego":    "package x",
+			"site/index.html":         "<h1>hi</h1>",
+			"models/bert/config.json": "{}",
+		} {
+			data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			if err != nil || string(data) != want {
+				t.Errorf("%s = %q, %v; want %q", name, data, err, want)
+			}
+		}
+	})
+
+	t.Run("path traversal", func(t *testing.T) {
+		layout, manifest := writeArtifactLayout(t, config, titled("../escape.txt", "x"))
+		parent := t.TempDir()
+		_, err := Extract(layout, manifest, registry.ArtifactTypeUnknown, filepath.Join(parent, "out"), false)
+		if err == nil || !strings.Contains(err.Error(), "unsafe file name") {
+			t.Fatalf("Extract() error = %v, want unsafe file name", err)
+		}
+		if _, err := os.Stat(filepath.Join(parent, "escape.txt")); !os.IsNotExist(err) {
+			t.Errorf("file written outside the destination: %v", err)
+		}
+	})
+
+	t.Run("symlink from an earlier layer", func(t *testing.T) {
+		outside := t.TempDir()
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(zw)
+		if err := tw.WriteHeader(&tar.Header{Name: "x", Typeflag: tar.TypeSymlink, Linkname: outside}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Close()
+		zw.Close()
+
+		layout, manifest := writeArtifactLayout(t, config,
+			layoutBlob{
+				mediaType: ocispec.MediaTypeImageLayerGzip,
+				data:      buf.Bytes(),
+				annotations: map[string]string{
+					ocispec.AnnotationTitle: "x",
+					annotationUnpack:        "true",
+				},
+			},
+			titled("x/pwn", "x"),
+		)
+		_, err := Extract(layout, manifest, registry.ArtifactTypeUnknown, t.TempDir(), false)
+		if err == nil || !strings.Contains(err.Error(), "is a symlink") {
+			t.Fatalf("Extract() error = %v, want symlink rejected", err)
+		}
+		if _, err := os.Stat(filepath.Join(outside, "pwn")); !os.IsNotExist(err) {
+			t.Errorf("file written through the symlink: %v", err)
+		}
+	})
+
+	t.Run("no titles", func(t *testing.T) {
+		layout, manifest := writeArtifactLayout(t, config, layoutBlob{mediaType: "application/octet-stream", data: []byte("x")})
+		if _, err := Extract(layout, manifest, registry.ArtifactTypeUnknown, t.TempDir(), false); err == nil {
+			t.Error("Extract() error = nil, want no titled layers")
+		}
+	})
+
+	t.Run("image", func(t *testing.T) {
+		layout, manifest := writeArtifactLayout(t, config, titled("a", "a"))
+		if _, err := Extract(layout, manifest, registry.ArtifactTypeImage, t.TempDir(), false); err == nil {
+			t.Error("Extract() error = nil, want images rejected")
+		}
+	})
+}
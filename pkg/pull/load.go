@@ -0,0 +1,148 @@
+package pull
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadTarget is a container engine that pulled images can be loaded into.
+type LoadTarget string
+
+const (
+	LoadDocker     LoadTarget = "docker"
+	LoadPodman     LoadTarget = "podman"
+	LoadContainerd LoadTarget = "containerd"
+)
+
+// LoadTargets lists the supported load targets.
+var LoadTargets = []LoadTarget{LoadDocker, LoadPodman, LoadContainerd}
+
+// ParseLoadTarget parses a load target name.
+func ParseLoadTarget(s string) (LoadTarget, error) {
+	for _, t := range LoadTargets {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown load target %q (supported: docker, podman, containerd)", s)
+}
+
+// Name returns the display name of the engine.
+func (t LoadTarget) Name() string {
+	switch t {
+	case LoadDocker:
+		return "Docker"
+	case LoadPodman:
+		return "Podman"
+	}
+	return string(t)
+}
+
+// Loader loads an image from an OCI layout into a container engine.
+type Loader interface {
+	// Load imports the single-platform image in the OCI layout at
+	// ociLayoutPath, tagged as reference.
+	Load(ctx context.Context, ociLayoutPath, reference string) error
+}
+
+// NewLoader returns a Loader for target. Engines are found the way their
+// own CLIs find them: Docker through DOCKER_HOST, Podman through
+// CONTAINER_HOST or its rootless socket, and containerd through
+// CONTAINERD_ADDRESS. namespace selects the containerd namespace and
+// defaults to CONTAINERD_NAMESPACE or "default"; other engines ignore it.
+func NewLoader(target LoadTarget, namespace string) (Loader, error) {
+	switch target {
+	case LoadDocker:
+		return newEngineClient(target, dockerHost())
+	case LoadPodman:
+		return newEngineClient(target, podmanHost())
+	case LoadContainerd:
+		if namespace == "" {
+			namespace = os.Getenv("CONTAINERD_NAMESPACE")
+		}
+		if namespace == "" {
+			namespace = "default"
+		}
+		return newContainerdLoader(containerdAddress(), namespace), nil
+	}
+	return nil, fmt.Errorf("unknown load target %q", target)
+}
+
+// LoadTargetAvailable reports whether target looks reachable from this
+// host, without contacting it: its socket exists. Engines reached over TCP
+// are assumed available.
+func LoadTargetAvailable(target LoadTarget) bool {
+	var host string
+	switch target {
+	case LoadDocker:
+		host = dockerHost()
+	case LoadPodman:
+		host = podmanHost()
+	case LoadContainerd:
+		_, err := os.Stat(containerdAddress())
+		return err == nil
+	default:
+		return false
+	}
+	network, address, err := engineEndpoint(host)
+	if err != nil {
+		return false
+	}
+	if network != "unix" {
+		return true
+	}
+	_, err = os.Stat(address)
+	return err == nil
+}
+
+// dockerHost returns the Docker Engine API URL.
+func dockerHost() string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	return "unix:///var/run/docker.sock"
+}
+
+// podmanHost returns the URL of Podman's Docker-compatible API, preferring
+// the rootless socket of the current user when it exists.
+func podmanHost() string {
+	if host := os.Getenv("CONTAINER_HOST"); host != "" {
+		return host
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		sock := filepath.Join(runtimeDir, "podman", "podman.sock")
+		if _, err := os.Stat(sock); err == nil {
+			return "unix://" + sock
+		}
+	}
+	return "unix:///run/podman/podman.sock"
+}
+
+// containerdAddress returns the path of the containerd socket.
+func containerdAddress() string {
+	if addr := os.Getenv("CONTAINERD_ADDRESS"); addr != "" {
+		return strings.TrimPrefix(addr, "unix://")
+	}
+	return "/run/containerd/containerd.sock"
+}
+
+// streamDockerArchive returns a reader streaming the image in the OCI
+// layout as a Docker save tarball, with layers as stored. Closing the
+// reader early stops the writer.
+func streamDockerArchive(ociLayoutPath, reference string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := writeDockerArchive(tw, ociLayoutPath, reference, false)
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
@@ -0,0 +1,220 @@
+package pull
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+)
+
+func TestParseLoadTarget(t *testing.T) {
+	for _, target := range LoadTargets {
+		got, err := ParseLoadTarget(string(target))
+		if err != nil || got != target {
+			t.Errorf("ParseLoadTarget(%q) = %q, %v", target, got, err)
+		}
+	}
+	if _, err := ParseLoadTarget("cri-o"); err == nil {
+		t.Error("ParseLoadTarget(cri-o) error = nil, want unknown target")
+	}
+}
+
+func TestEngineEndpoint(t *testing.T) {
+	tests := []struct {
+		host        string
+		wantNetwork string
+		wantAddress string
+		wantErr     bool
+	}{
+		{host: "unix:///var/run/docker.sock", wantNetwork: "unix", wantAddress: "/var/run/docker.sock"},
+		{host: "tcp://127.0.0.1:2375", wantNetwork: "tcp", wantAddress: "127.0.0.1:2375"},
+		{host: "ssh://user@host", wantErr: true},
+		{host: "/var/run/docker.sock", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			network, address, err := engineEndpoint(tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("engineEndpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if network != tt.wantNetwork || address != tt.wantAddress {
+				t.Errorf("engineEndpoint() = %q, %q, want %q, %q", network, address, tt.wantNetwork, tt.wantAddress)
+			}
+		})
+	}
+}
+
+func TestEngineHosts(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "")
+	if got := dockerHost(); got != "unix:///var/run/docker.sock" {
+		t.Errorf("dockerHost() = %q, want the default socket", got)
+	}
+	t.Setenv("DOCKER_HOST", "tcp://10.0.0.1:2375")
+	if got := dockerHost(); got != "tcp://10.0.0.1:2375" {
+		t.Errorf("dockerHost() = %q, want DOCKER_HOST", got)
+	}
+
+	runtimeDir := t.TempDir()
+	t.Setenv("CONTAINER_HOST", "")
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	if got := podmanHost(); got != "unix:///run/podman/podman.sock" {
+		t.Errorf("podmanHost() = %q, want the system socket without a rootless one", got)
+	}
+	sock := filepath.Join(runtimeDir, "podman", "podman.sock")
+	if err := os.MkdirAll(filepath.Dir(sock), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := podmanHost(); got != "unix://"+sock {
+		t.Errorf("podmanHost() = %q, want the rootless socket", got)
+	}
+	t.Setenv("CONTAINER_HOST", "unix:///custom.sock")
+	if got := podmanHost(); got != "unix:///custom.sock" {
+		t.Errorf("podmanHost() = %q, want CONTAINER_HOST", got)
+	}
+
+	t.Setenv("CONTAINERD_ADDRESS", "unix:///run/k3s/containerd/containerd.sock")
+	if got := containerdAddress(); got != "/run/k3s/containerd/containerd.sock" {
+		t.Errorf("containerdAddress() = %q, want CONTAINERD_ADDRESS", got)
+	}
+}
+
+// fakeEngine serves handler on a unix socket, as the Docker Engine API
+// does, and returns the socket's URL.
+func fakeEngine(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets")
+	}
+	// Socket paths are limited to ~100 bytes, which t.TempDir can exceed.
+	dir, err := os.MkdirTemp("", "engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	sock := filepath.Join(dir, "engine.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return "unix://" + sock
+}
+
+func TestEngineLoad(t *testing.T) {
+	layout, layers := testLayout(t)
+	received := filepath.Join(t.TempDir(), "received.tar")
+
+	host := fakeEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/images/load" {
+			http.Error(w, `{"message":"unexpected request"}`, http.StatusNotFound)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-tar" {
+			t.Errorf("Content-Type = %q, want application/x-tar", ct)
+		}
+		f, err := os.Create(received)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(f, r.Body)
+		f.Close()
+		w.Write([]byte(`{"stream":"Loaded image: localhost:5050/test/hello:v1\n"}` + "\n"))
+	})
+
+	client, err := newEngineClient(LoadDocker, host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Load(context.Background(), layout, "localhost:5050/test/hello:v1"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	files, _ := readTar(t, received)
+	var manifest []ociutil.DockerSaveManifest
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("manifest.json: %v", err)
+	}
+	if len(manifest) != 1 || len(manifest[0].RepoTags) != 1 || manifest[0].RepoTags[0] != "localhost:5050/test/hello:v1" {
+		t.Fatalf("manifest.json = %+v", manifest)
+	}
+	if len(manifest[0].Layers) != len(layers) {
+		t.Fatalf("layers = %v, want %d", manifest[0].Layers, len(layers))
+	}
+	// Layers are streamed as stored, so the gzipped one stays compressed.
+	if got := files[manifest[0].Layers[1]]; got != layers[1] {
+		t.Errorf("plain layer = %q, want %q", got, layers[1])
+	}
+	if got := files[manifest[0].Layers[0]]; got == layers[0] || !strings.HasPrefix(got, "\x1f\x8b") {
+		t.Errorf("gzipped layer = %q, want it sent compressed", got)
+	}
+}
+
+func TestEngineLoadErrors(t *testing.T) {
+	layout, _ := testLayout(t)
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "error in message stream",
+			status:  http.StatusOK,
+			body:    `{"errorDetail":{"message":"no space left on device"},"error":"no space left on device"}`,
+			wantErr: "Docker load failed: no space left on device",
+		},
+		{
+			name:    "error status",
+			status:  http.StatusInternalServerError,
+			body:    `{"message":"daemon is shutting down"}`,
+			wantErr: "daemon is shutting down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := fakeEngine(t, func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			client, err := newEngineClient(LoadDocker, host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = client.Load(context.Background(), layout, "test:v1")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEngineLoadUnreachable(t *testing.T) {
+	layout, _ := testLayout(t)
+	client, err := newEngineClient(LoadPodman, "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Load(context.Background(), layout, "test:v1")
+	if err == nil || !strings.Contains(err.Error(), "failed to load into Podman") {
+		t.Errorf("Load() error = %v, want unreachable engine", err)
+	}
+}
@@ -0,0 +1,155 @@
+package pull
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+)
+
+// ParsePlatforms parses a comma-separated list of os/arch[/variant]
+// platforms, e.g. "linux/amd64,linux/arm64/v8".
+func ParsePlatforms(s string) ([]ocispec.Platform, error) {
+	var platforms []ocispec.Platform
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		parts := strings.Split(field, "/")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid platform format %q, expected os/arch (e.g., linux/amd64)", field)
+		}
+		p := ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+		if len(parts) == 3 {
+			p.Variant = parts[2]
+		}
+		platforms = append(platforms, p)
+	}
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf("no platform given")
+	}
+	return platforms, nil
+}
+
+// PlatformString formats p as os/arch[/variant].
+func PlatformString(p ocispec.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// defaultPlatform is the platform pulled from an image index when none is
+// requested. Container images do not target macOS, so on a Mac this is the
+// Linux platform Docker Desktop would run.
+func defaultPlatform() ocispec.Platform {
+	os := runtime.GOOS
+	if os == "darwin" {
+		os = "linux"
+	}
+	return ocispec.Platform{OS: os, Architecture: runtime.GOARCH}
+}
+
+// matchPlatform reports whether got satisfies want. An empty variant in
+// want matches any variant.
+func matchPlatform(want ocispec.Platform, got *ocispec.Platform) bool {
+	if got == nil {
+		return false
+	}
+	return got.OS == want.OS && got.Architecture == want.Architecture &&
+		(want.Variant == "" || got.Variant == want.Variant)
+}
+
+// isIndex reports whether mediaType is an OCI index or Docker manifest list.
+func isIndex(mediaType string) bool {
+	return mediaType == ocispec.MediaTypeImageIndex ||
+		mediaType == "application/vnd.docker.distribution.manifest.list.v2+json"
+}
+
+// selectManifests returns the children of the index root that match
+// platforms, in index order. Every platform must match a child.
+func selectManifests(ctx context.Context, fetcher content.Fetcher, root ocispec.Descriptor, platforms []ocispec.Platform) ([]ocispec.Descriptor, error) {
+	data, err := content.FetchAll(ctx, fetcher, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch index: %w", err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse index: %w", err)
+	}
+
+	selected := make([]bool, len(index.Manifests))
+	for _, want := range platforms {
+		found := false
+		for i, m := range index.Manifests {
+			if matchPlatform(want, m.Platform) {
+				selected[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no manifest for platform %s (available: %s)",
+				PlatformString(want), strings.Join(indexPlatforms(index), ", "))
+		}
+	}
+
+	var children []ocispec.Descriptor
+	for i, m := range index.Manifests {
+		if selected[i] {
+			children = append(children, m)
+		}
+	}
+	return children, nil
+}
+
+// indexPlatforms lists the platforms an index provides.
+func indexPlatforms(index ocispec.Index) []string {
+	var platforms []string
+	for _, m := range index.Manifests {
+		if m.Platform != nil && m.Platform.OS != "unknown" {
+			platforms = append(platforms, PlatformString(*m.Platform))
+		}
+	}
+	return platforms
+}
+
+// copyIndex copies the index root with only the given children and tags it
+// in dst. The index is stored byte for byte, so its digest is preserved even
+// though the children it lists for other platforms are left out.
+func copyIndex(ctx context.Context, src oras.ReadOnlyTarget, dst oras.Target, root ocispec.Descriptor, children []ocispec.Descriptor, tag string, opts oras.CopyGraphOptions) error {
+	keep := make(map[string]bool, len(children))
+	for _, c := range children {
+		keep[c.Digest.String()] = true
+	}
+
+	findSuccessors := opts.FindSuccessors
+	if findSuccessors == nil {
+		findSuccessors = content.Successors
+	}
+	opts.FindSuccessors = func(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		successors, err := findSuccessors(ctx, fetcher, desc)
+		if err != nil || desc.Digest != root.Digest {
+			return successors, err
+		}
+		var selected []ocispec.Descriptor
+		for _, s := range successors {
+			if keep[s.Digest.String()] {
+				selected = append(selected, s)
+			}
+		}
+		return selected, nil
+	}
+
+	if err := oras.CopyGraph(ctx, src, dst, root, opts); err != nil {
+		return err
+	}
+	return dst.Tag(ctx, root, tag)
+}
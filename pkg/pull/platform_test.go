@@ -0,0 +1,208 @@
+package pull
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestParsePlatforms(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "single", input: "linux/amd64", want: []string{"linux/amd64"}},
+		{name: "several", input: "linux/amd64,linux/arm64", want: []string{"linux/amd64", "linux/arm64"}},
+		{name: "variant and spaces", input: "linux/arm/v7, linux/arm64/v8", want: []string{"linux/arm/v7", "linux/arm64/v8"}},
+		{name: "missing arch", input: "linux", wantErr: true},
+		{name: "too many parts", input: "linux/arm/v7/x", wantErr: true},
+		{name: "empty os", input: "/amd64", wantErr: true},
+		{name: "empty", input: ",", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePlatforms(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePlatforms(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			var names []string
+			for _, p := range got {
+				names = append(names, PlatformString(p))
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("ParsePlatforms(%q) = %v, want %v", tt.input, names, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchPlatform(t *testing.T) {
+	arm64v8 := &ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+	tests := []struct {
+		name string
+		want ocispec.Platform
+		got  *ocispec.Platform
+		ok   bool
+	}{
+		{name: "any variant", want: ocispec.Platform{OS: "linux", Architecture: "arm64"}, got: arm64v8, ok: true},
+		{name: "same variant", want: ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, got: arm64v8, ok: true},
+		{name: "other variant", want: ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v7"}, got: arm64v8, ok: false},
+		{name: "other arch", want: ocispec.Platform{OS: "linux", Architecture: "amd64"}, got: arm64v8, ok: false},
+		{name: "no platform", want: ocispec.Platform{OS: "linux", Architecture: "amd64"}, got: nil, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchPlatform(tt.want, tt.got); got != tt.ok {
+				t.Errorf("matchPlatform() = %v, want %v", got, tt.ok)
+			}
+		})
+	}
+}
+
+// testIndex returns a memory store holding an index tagged v1 with one
+// single-layer image per platform.
+func testIndex(t *testing.T, platforms ...string) (*memory.Store, ocispec.Descriptor) {
+	t.Helper()
+	src := memory.New()
+
+	var manifests []ocispec.Descriptor
+	for _, name := range platforms {
+		p, err := ParsePlatforms(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config := pushBlob(t, src, ocispec.MediaTypeImageConfig, []byte(`{"os":"`+p[0].OS+`","architecture":"`+p[0].Architecture+`"}`))
+		layer := pushBlob(t, src, ocispec.MediaTypeImageLayerGzip, []byte("layer for "+name))
+		data, err := json.Marshal(ocispec.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    config,
+			Layers:    []ocispec.Descriptor{layer},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc := pushBlob(t, src, ocispec.MediaTypeImageManifest, data)
+		desc.Platform = &p[0]
+		manifests = append(manifests, desc)
+	}
+
+	data, err := json.Marshal(ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: manifests,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := pushBlob(t, src, ocispec.MediaTypeImageIndex, data)
+	if err := src.Tag(context.Background(), root, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	return src, root
+}
+
+func TestSelectManifests(t *testing.T) {
+	src, root := testIndex(t, "linux/amd64", "linux/arm64/v8", "linux/s390x")
+
+	tests := []struct {
+		name    string
+		want    string
+		got     []string
+		wantErr string
+	}{
+		{name: "one", want: "linux/arm64", got: []string{"linux/arm64/v8"}},
+		{name: "index order", want: "linux/s390x,linux/amd64", got: []string{"linux/amd64", "linux/s390x"}},
+		{name: "duplicate", want: "linux/amd64,linux/amd64", got: []string{"linux/amd64"}},
+		{name: "missing", want: "linux/amd64,windows/amd64", wantErr: "no manifest for platform windows/amd64 (available: linux/amd64, linux/arm64/v8, linux/s390x)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := ParsePlatforms(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			children, err := selectManifests(context.Background(), src, root, want)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("selectManifests() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectManifests() error = %v", err)
+			}
+			var got []string
+			for _, c := range children {
+				got = append(got, PlatformString(*c.Platform))
+			}
+			if !reflect.DeepEqual(got, tt.got) {
+				t.Errorf("selectManifests() = %v, want %v", got, tt.got)
+			}
+		})
+	}
+}
+
+func TestCopyIndex(t *testing.T) {
+	ctx := context.Background()
+	src, root := testIndex(t, "linux/amd64", "linux/arm64", "linux/s390x")
+
+	want, _ := ParsePlatforms("linux/amd64,linux/arm64")
+	children, err := selectManifests(ctx, src, root, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	dst, err := oci.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := NewProgressTracker(true)
+	d := &layoutDownloader{src: src, root: dir, tracker: tracker}
+	if err := copyIndex(ctx, src, dst, root, children, "v1", copyGraphOptions(d, 2)); err != nil {
+		t.Fatalf("copyIndex() error = %v", err)
+	}
+
+	tagged, err := dst.Resolve(ctx, "v1")
+	if err != nil {
+		t.Fatalf("Resolve(v1) error = %v", err)
+	}
+	if tagged.Digest != root.Digest {
+		t.Errorf("tagged digest = %s, want the original index %s", tagged.Digest, root.Digest)
+	}
+	if got := tracker.Overall().Layers; got != 2 {
+		t.Errorf("layers = %d, want 2", got)
+	}
+
+	data, err := content.FetchAll(ctx, dst, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range index.Manifests {
+		exists, err := dst.Exists(ctx, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantExists := m.Platform.Architecture != "s390x"
+		if exists != wantExists {
+			t.Errorf("%s in layout = %v, want %v", PlatformString(*m.Platform), exists, wantExists)
+		}
+	}
+}
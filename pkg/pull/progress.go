@@ -3,25 +3,140 @@ package pull
 import (
 	"fmt"
 	"io"
+	"os"
 	"sync"
+	"time"
+)
+
+// ProgressEventKind identifies what a ProgressEvent reports.
+type ProgressEventKind int
 
-	"github.com/schollz/progressbar/v3"
+const (
+	// LayerStarted is sent when a layer download begins.
+	LayerStarted ProgressEventKind = iota + 1
+	// LayerProgressed is sent as layer bytes arrive, at most every
+	// progressInterval across all layers.
+	LayerProgressed
+	// LayerCompleted is sent when a layer has been downloaded and verified.
+	LayerCompleted
+	// LayerSkipped is sent for layers already present at the destination.
+	LayerSkipped
+	// PullFinished is sent once when the copy completes.
+	PullFinished
 )
 
-// ProgressTracker manages progress bars for layer downloads.
+// progressInterval limits how often LayerProgressed events are sent.
+const progressInterval = 100 * time.Millisecond
+
+// LayerProgress is the download state of one layer.
+type LayerProgress struct {
+	Digest   string
+	Size     int64
+	Bytes    int64
+	Started  time.Time
+	Finished time.Time
+}
+
+// OverallProgress aggregates the state of every layer in a pull. Layers and
+// Total grow as manifests are read, so they may increase while a pull runs.
+type OverallProgress struct {
+	Layers     int           // layers expected so far
+	Completed  int           // layers downloaded or already present
+	Skipped    int           // layers already present
+	Bytes      int64         // bytes downloaded or already present
+	Downloaded int64         // bytes transferred from the registry
+	Total      int64         // size of all expected layers
+	Elapsed    time.Duration // time since the first layer started
+}
+
+// Rate returns the download throughput in bytes per second.
+func (o OverallProgress) Rate() float64 {
+	if o.Elapsed <= 0 {
+		return 0
+	}
+	return float64(o.Downloaded) / o.Elapsed.Seconds()
+}
+
+// ETA estimates the time remaining at the current rate, or 0 when unknown.
+func (o OverallProgress) ETA() time.Duration {
+	rate := o.Rate()
+	if rate <= 0 || o.Bytes >= o.Total {
+		return 0
+	}
+	return time.Duration(float64(o.Total-o.Bytes) / rate * float64(time.Second))
+}
+
+// String summarizes progress,
+// e.g. "2/7 layers, 45.2 MB / 120.0 MB, 12.3 MB/s, ETA 6s".
+func (o OverallProgress) String() string {
+	s := fmt.Sprintf("%d/%d layers, %s / %s", o.Completed, o.Layers, formatBytes(o.Bytes), formatBytes(o.Total))
+	if rate := o.Rate(); rate > 0 {
+		s += fmt.Sprintf(", %s/s", formatBytes(int64(rate)))
+	}
+	if eta := o.ETA(); eta > 0 {
+		s += ", ETA " + formatDuration(eta)
+	}
+	return s
+}
+
+// ProgressEvent reports a change in pull progress.
+type ProgressEvent struct {
+	Kind ProgressEventKind
+
+	// Layer is the layer the event is about; zero for PullFinished.
+	Layer LayerProgress
+
+	// Active lists the layers being downloaded, in the order they started.
+	Active []LayerProgress
+
+	Overall OverallProgress
+}
+
+// ProgressListener receives pull progress. Calls are serialized, and a
+// listener must not block for long or call back into the tracker.
+type ProgressListener interface {
+	OnProgress(ProgressEvent)
+}
+
+// ProgressFunc adapts a function to a ProgressListener.
+type ProgressFunc func(ProgressEvent)
+
+// OnProgress calls f(e).
+func (f ProgressFunc) OnProgress(e ProgressEvent) {
+	f(e)
+}
+
+// ProgressTracker aggregates layer download progress and forwards it to
+// listeners as ProgressEvents.
 type ProgressTracker struct {
-	mu    sync.Mutex
-	bars  map[string]*progressbar.ProgressBar
-	quiet bool
+	mu        sync.Mutex
+	listeners []ProgressListener
+	layers    map[string]*LayerProgress
+	active    []string
+	overall   OverallProgress
+	start     time.Time
+	lastEvent time.Time
+	now       func() time.Time
 }
 
 // NewProgressTracker creates a new progress tracker.
-// If quiet is true, no progress output is shown.
+// Unless quiet is true, progress is rendered to stdout.
 func NewProgressTracker(quiet bool) *ProgressTracker {
-	return &ProgressTracker{
-		bars:  make(map[string]*progressbar.ProgressBar),
-		quiet: quiet,
+	t := &ProgressTracker{
+		layers: make(map[string]*LayerProgress),
+		now:    time.Now,
+	}
+	if !quiet {
+		t.AddListener(NewTerminalRenderer(os.Stdout))
 	}
+	return t
+}
+
+// AddListener registers l for all subsequent events.
+func (t *ProgressTracker) AddListener(l ProgressListener) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners = append(t.listeners, l)
 }
 
 // shortDigest returns a shortened digest for display (first 12 chars after sha256:).
@@ -32,78 +147,152 @@ func shortDigest(digest string) string {
 	return digest
 }
 
-// StartLayer creates a new progress bar for a layer download.
+// ExpectLayer adds a layer to the overall total before it starts.
+func (t *ProgressTracker) ExpectLayer(digest string, size int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expect(digest, size)
+}
+
+// expect records a layer once; t.mu must be held.
+func (t *ProgressTracker) expect(digest string, size int64) *LayerProgress {
+	if l, ok := t.layers[digest]; ok {
+		return l
+	}
+	l := &LayerProgress{Digest: digest, Size: size}
+	t.layers[digest] = l
+	t.overall.Layers++
+	t.overall.Total += size
+	return l
+}
+
+// StartLayer marks a layer download as started.
 func (t *ProgressTracker) StartLayer(digest string, size int64) {
-	if t.quiet {
-		return
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if t.start.IsZero() {
+		t.start = now
 	}
+	l := t.expect(digest, size)
+	l.Started = now
+	t.active = append(t.active, digest)
+	t.emit(LayerStarted, *l)
+}
 
+// ResumeLayer credits the offset bytes of a layer already on disk from an
+// interrupted pull. It is called before StartLayer.
+func (t *ProgressTracker) ResumeLayer(digest string, size, offset int64) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	// Create progress bar with schollz/progressbar
-	bar := progressbar.NewOptions64(
-		size,
-		progressbar.OptionSetDescription(fmt.Sprintf("Pulling %s", shortDigest(digest))),
-		progressbar.OptionSetWidth(30),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionShowCount(),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "=",
-			SaucerHead:    ">",
-			SaucerPadding: " ",
-			BarStart:      "[",
-			BarEnd:        "]",
-		}),
-		progressbar.OptionOnCompletion(func() {
-			fmt.Println()
-		}),
-	)
-
-	t.bars[digest] = bar
-}
-
-// UpdateLayer updates the progress for a layer.
+	l := t.expect(digest, size)
+	t.overall.Bytes += offset - l.Bytes
+	l.Bytes = offset
+}
+
+// UpdateLayer records that bytesRead bytes of a layer have been downloaded.
 func (t *ProgressTracker) UpdateLayer(digest string, bytesRead int64) {
-	if t.quiet {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	l, ok := t.layers[digest]
+	if !ok {
 		return
 	}
+	delta := bytesRead - l.Bytes
+	l.Bytes = bytesRead
+	t.overall.Bytes += delta
+	t.overall.Downloaded += delta
 
-	t.mu.Lock()
-	bar, ok := t.bars[digest]
-	t.mu.Unlock()
-
-	if ok {
-		bar.Set64(bytesRead)
+	if t.now().Sub(t.lastEvent) >= progressInterval {
+		t.emit(LayerProgressed, *l)
 	}
 }
 
 // FinishLayer marks a layer as complete.
 func (t *ProgressTracker) FinishLayer(digest string) {
-	if t.quiet {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	l, ok := t.layers[digest]
+	if !ok {
 		return
 	}
+	// Credit anything the reader did not report, such as bytes consumed
+	// by the destination after the last Read.
+	if rest := l.Size - l.Bytes; rest > 0 {
+		l.Bytes = l.Size
+		t.overall.Bytes += rest
+		t.overall.Downloaded += rest
+	}
+	l.Finished = t.now()
+	t.overall.Completed++
+	for i, d := range t.active {
+		if d == digest {
+			t.active = append(t.active[:i], t.active[i+1:]...)
+			break
+		}
+	}
+	t.emit(LayerCompleted, *l)
+}
 
+// SkipLayer records a layer that is already present at the destination.
+func (t *ProgressTracker) SkipLayer(digest string, size int64) {
 	t.mu.Lock()
-	bar, ok := t.bars[digest]
-	t.mu.Unlock()
+	defer t.mu.Unlock()
 
-	if ok {
-		bar.Finish()
-	}
+	l := t.expect(digest, size)
+	l.Bytes = l.Size
+	t.overall.Bytes += l.Size
+	t.overall.Completed++
+	t.overall.Skipped++
+	t.emit(LayerSkipped, *l)
 }
 
-// Finish completes all progress bars.
+// Finish reports that the pull has completed.
 func (t *ProgressTracker) Finish() {
-	if t.quiet {
-		return
-	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.emit(PullFinished, LayerProgress{})
+}
 
+// Overall returns the current aggregate progress.
+func (t *ProgressTracker) Overall() OverallProgress {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	return t.snapshot()
+}
 
-	for _, bar := range t.bars {
-		bar.Finish()
+// snapshot returns the aggregate progress; t.mu must be held.
+func (t *ProgressTracker) snapshot() OverallProgress {
+	o := t.overall
+	if !t.start.IsZero() {
+		o.Elapsed = t.now().Sub(t.start)
+	}
+	return o
+}
+
+// emit sends an event to every listener; t.mu must be held.
+func (t *ProgressTracker) emit(kind ProgressEventKind, layer LayerProgress) {
+	if len(t.listeners) == 0 {
+		return
+	}
+	t.lastEvent = t.now()
+
+	active := make([]LayerProgress, 0, len(t.active))
+	for _, d := range t.active {
+		active = append(active, *t.layers[d])
+	}
+	e := ProgressEvent{
+		Kind:    kind,
+		Layer:   layer,
+		Active:  active,
+		Overall: t.snapshot(),
+	}
+	for _, l := range t.listeners {
+		l.OnProgress(e)
 	}
 }
 
@@ -135,3 +324,26 @@ func NewTrackingReader(r io.Reader, digest string, size int64, tracker *Progress
 		total:   size,
 	}
 }
+
+// formatBytes formats a byte count for display.
+func formatBytes(b int64) string {
+	switch {
+	case b >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(b)/(1<<30))
+	case b >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(b)/(1<<20))
+	case b >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(b)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", b)
+	}
+}
+
+// formatDuration rounds a duration for display: tenths of a second below
+// a minute, whole seconds above.
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
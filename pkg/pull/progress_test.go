@@ -0,0 +1,299 @@
+package pull
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// fakeClock advances by step on every call.
+func fakeClock(step time.Duration) func() time.Time {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+// recorder collects events.
+type recorder struct {
+	events []ProgressEvent
+}
+
+func (r *recorder) OnProgress(e ProgressEvent) {
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) kinds() []ProgressEventKind {
+	var kinds []ProgressEventKind
+	for _, e := range r.events {
+		kinds = append(kinds, e.Kind)
+	}
+	return kinds
+}
+
+func TestProgressTracker(t *testing.T) {
+	tracker := NewProgressTracker(true)
+	tracker.now = fakeClock(time.Second)
+	rec := &recorder{}
+	tracker.AddListener(rec)
+
+	tracker.ExpectLayer("sha256:aaa", 1000)
+	tracker.ExpectLayer("sha256:bbb", 3000)
+	tracker.ExpectLayer("sha256:aaa", 1000) // duplicate
+	tracker.StartLayer("sha256:aaa", 1000)
+	tracker.StartLayer("sha256:bbb", 3000)
+	tracker.UpdateLayer("sha256:aaa", 500)
+	tracker.UpdateLayer("sha256:bbb", 1000)
+	tracker.FinishLayer("sha256:aaa")
+	tracker.SkipLayer("sha256:ccc", 2000)
+	tracker.Finish()
+
+	want := []ProgressEventKind{
+		LayerStarted, LayerStarted, LayerProgressed, LayerProgressed,
+		LayerCompleted, LayerSkipped, PullFinished,
+	}
+	if got := rec.kinds(); len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	} else {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("events = %v, want %v", got, want)
+			}
+		}
+	}
+
+	started := rec.events[1]
+	if len(started.Active) != 2 || started.Active[0].Digest != "sha256:aaa" {
+		t.Errorf("Active after second start = %+v", started.Active)
+	}
+
+	completed := rec.events[4]
+	if len(completed.Active) != 1 || completed.Active[0].Digest != "sha256:bbb" {
+		t.Errorf("Active after completion = %+v", completed.Active)
+	}
+	if completed.Layer.Bytes != 1000 {
+		t.Errorf("completed layer Bytes = %d, want full size credited", completed.Layer.Bytes)
+	}
+
+	o := tracker.Overall()
+	if o.Layers != 3 || o.Completed != 2 || o.Skipped != 1 {
+		t.Errorf("Overall layers = %d/%d (%d skipped), want 2/3 (1 skipped)", o.Completed, o.Layers, o.Skipped)
+	}
+	if o.Total != 6000 || o.Bytes != 4000 || o.Downloaded != 2000 {
+		t.Errorf("Overall bytes = %d/%d (%d downloaded), want 4000/6000 (2000 downloaded)", o.Bytes, o.Total, o.Downloaded)
+	}
+}
+
+func TestProgressTrackerThrottle(t *testing.T) {
+	tracker := NewProgressTracker(true)
+	tracker.now = fakeClock(10 * time.Millisecond)
+	rec := &recorder{}
+	tracker.AddListener(rec)
+
+	tracker.StartLayer("sha256:aaa", 100)
+	for i := int64(1); i <= 50; i++ {
+		tracker.UpdateLayer("sha256:aaa", i)
+	}
+
+	progressed := 0
+	for _, k := range rec.kinds() {
+		if k == LayerProgressed {
+			progressed++
+		}
+	}
+	// 50 updates 10ms apart span 500ms: about one event per 100ms.
+	if progressed < 3 || progressed > 6 {
+		t.Errorf("LayerProgressed events = %d, want about 5", progressed)
+	}
+	if got := tracker.Overall().Bytes; got != 50 {
+		t.Errorf("Overall().Bytes = %d, want 50 despite throttling", got)
+	}
+}
+
+func TestOverallProgress(t *testing.T) {
+	o := OverallProgress{
+		Layers:     7,
+		Completed:  2,
+		Bytes:      40 << 20,
+		Downloaded: 30 << 20,
+		Total:      100 << 20,
+		Elapsed:    3 * time.Second,
+	}
+	if got := o.Rate(); got != float64(10<<20) {
+		t.Errorf("Rate() = %v, want 10 MiB/s", got)
+	}
+	if got := o.ETA(); got != 6*time.Second {
+		t.Errorf("ETA() = %v, want 6s", got)
+	}
+	if got, want := o.String(), "2/7 layers, 40.0 MB / 100.0 MB, 10.0 MB/s, ETA 6s"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	if got := (OverallProgress{Total: 10}).ETA(); got != 0 {
+		t.Errorf("ETA() before any download = %v, want 0", got)
+	}
+}
+
+func TestTerminalRendererLog(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewTerminalRenderer(&buf)
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	layer := LayerProgress{Digest: "sha256:0123456789abcdef0123", Size: 2 << 20, Started: start}
+	r.OnProgress(ProgressEvent{Kind: LayerStarted, Layer: layer})
+	r.OnProgress(ProgressEvent{Kind: LayerProgressed, Layer: layer})
+	layer.Finished = start.Add(1500 * time.Millisecond)
+	r.OnProgress(ProgressEvent{Kind: LayerCompleted, Layer: layer})
+	r.OnProgress(ProgressEvent{Kind: LayerSkipped, Layer: LayerProgress{Digest: "sha256:fedcba9876543210fedc"}})
+	r.OnProgress(ProgressEvent{Kind: PullFinished, Overall: OverallProgress{
+		Layers: 2, Completed: 2, Skipped: 1, Bytes: 3 << 20, Downloaded: 2 << 20, Elapsed: 2 * time.Second,
+	}})
+
+	want := "Pulling sha256:0123456789ab (2.0 MB)\n" +
+		"Pulled sha256:0123456789ab (2.0 MB) in 1.5s\n" +
+		"Layer sha256:fedcba987654 already exists, skipping\n" +
+		"Pulled 2 layers (3.0 MB) in 2s, 1.0 MB/s, 1 already present\n"
+	if buf.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestTerminalRendererLive(t *testing.T) {
+	var buf bytes.Buffer
+	r := &TerminalRenderer{w: &buf, tty: true}
+
+	a := LayerProgress{Digest: "sha256:aaaaaaaaaaaaaaaa", Size: 100, Bytes: 50}
+	b := LayerProgress{Digest: "sha256:bbbbbbbbbbbbbbbb", Size: 100}
+	r.OnProgress(ProgressEvent{Kind: LayerProgressed, Active: []LayerProgress{a, b}, Overall: OverallProgress{Layers: 2, Bytes: 50, Total: 200}})
+
+	first := buf.String()
+	if strings.Count(first, "\n") != 3 {
+		t.Fatalf("first frame = %q, want 2 layer bars and a total", first)
+	}
+	if !strings.Contains(first, "[===============>              ]  50 B / 100 B") {
+		t.Errorf("first frame = %q, want a half-full bar", first)
+	}
+	if !strings.Contains(first, "Total 0/2") {
+		t.Errorf("first frame = %q, want the overall bar", first)
+	}
+
+	buf.Reset()
+	a.Bytes = 100
+	r.OnProgress(ProgressEvent{Kind: LayerCompleted, Layer: a, Active: []LayerProgress{b}, Overall: OverallProgress{Layers: 2, Completed: 1, Bytes: 100, Total: 200}})
+	second := buf.String()
+	if !strings.HasPrefix(second, "\x1b[3A\x1b[J") {
+		t.Errorf("second frame = %q, want it to erase the 3 previous lines", second)
+	}
+	if !strings.Contains(second, "sha256:aaaaaaaaaaaa  done") {
+		t.Errorf("second frame = %q, want a done line", second)
+	}
+
+	buf.Reset()
+	r.OnProgress(ProgressEvent{Kind: PullFinished, Overall: OverallProgress{Layers: 2, Completed: 2, Bytes: 200, Total: 200}})
+	if got := buf.String(); !strings.HasPrefix(got, "\x1b[2A\x1b[J") || !strings.Contains(got, "Pulled 2 layers") {
+		t.Errorf("final frame = %q", got)
+	}
+}
+
+// pushBlob adds content to a memory store and returns its descriptor.
+func pushBlob(t *testing.T, store *memory.Store, mediaType string, data []byte) ocispec.Descriptor {
+	t.Helper()
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	if err := store.Push(context.Background(), desc, bytes.NewReader(data)); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	return desc
+}
+
+// testImage returns a memory store holding an image tagged v1 with three
+// layers of 100000, 50000 and 25000 bytes.
+func testImage(t *testing.T) (*memory.Store, []ocispec.Descriptor) {
+	t.Helper()
+	ctx := context.Background()
+	src := memory.New()
+
+	config := pushBlob(t, src, ocispec.MediaTypeImageConfig, []byte("{}"))
+	layers := []ocispec.Descriptor{
+		pushBlob(t, src, ocispec.MediaTypeImageLayerGzip, bytes.Repeat([]byte("a"), 100000)),
+		pushBlob(t, src, ocispec.MediaTypeImageLayerGzip, bytes.Repeat([]byte("b"), 50000)),
+		pushBlob(t, src, ocispec.MediaTypeImageLayerGzip, bytes.Repeat([]byte("c"), 25000)),
+	}
+	manifestJSON, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    layers,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := pushBlob(t, src, ocispec.MediaTypeImageManifest, manifestJSON)
+	if err := src.Tag(ctx, manifest, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	return src, layers
+}
+
+func TestCopyProgress(t *testing.T) {
+	ctx := context.Background()
+	src, layers := testImage(t)
+
+	root := t.TempDir()
+	dst, err := oci.New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pre-seed one layer so it is skipped.
+	if err := dst.Push(ctx, layers[2], bytes.NewReader(bytes.Repeat([]byte("c"), 25000))); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := NewProgressTracker(true)
+	rec := &recorder{}
+	tracker.AddListener(rec)
+
+	d := &layoutDownloader{src: src, root: root, tracker: tracker}
+	opts := oras.CopyOptions{CopyGraphOptions: copyGraphOptions(d, 2)}
+	if _, err := oras.Copy(ctx, src, "v1", dst, "v1", opts); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+	tracker.Finish()
+
+	o := tracker.Overall()
+	if o.Layers != 3 || o.Completed != 3 || o.Skipped != 1 {
+		t.Errorf("layers = %d/%d (%d skipped), want 3/3 (1 skipped)", o.Completed, o.Layers, o.Skipped)
+	}
+	if o.Total != 175000 || o.Bytes != 175000 || o.Downloaded != 150000 {
+		t.Errorf("bytes = %d/%d (%d downloaded), want 175000/175000 (150000 downloaded)", o.Bytes, o.Total, o.Downloaded)
+	}
+
+	for _, e := range rec.events {
+		if len(e.Active) > 2 {
+			t.Errorf("%d layers active, want at most the concurrency of 2", len(e.Active))
+		}
+	}
+	if last := rec.events[len(rec.events)-1]; last.Kind != PullFinished {
+		t.Errorf("last event = %v, want PullFinished", last.Kind)
+	}
+
+	for _, layer := range layers {
+		exists, err := dst.Exists(ctx, layer)
+		if err != nil || !exists {
+			t.Errorf("layer %s not in layout: %v", layer.Digest, err)
+		}
+	}
+	if _, err := dst.Resolve(ctx, "v1"); err != nil {
+		t.Errorf("Resolve(v1) error = %v", err)
+	}
+}
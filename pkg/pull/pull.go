@@ -6,13 +6,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
 	"github.com/mistergrinvalds/lazyoci/pkg/registry"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/content/oci"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
@@ -25,22 +26,67 @@ type PullOptions struct {
 
 	// Destination is the explicit directory for storage.
 	// If empty, uses ArtifactBase with type-aware subdirectories.
+	// For archive formats it is the tarball to write instead, and the
+	// layout is kept under ArtifactBase.
 	Destination string
 
+	// Format is the form the pull is written in. Empty means FormatOCIDir.
+	Format Format
+
+	// Extract writes the artifact's payload instead of an OCI layout: the
+	// chart archive for Helm charts, and each titled layer for other
+	// artifacts. Files go to Destination, or the current directory, and the
+	// layout is kept under ArtifactBase.
+	Extract bool
+
+	// Untar unpacks an extracted Helm chart into a directory.
+	Untar bool
+
 	// ArtifactBase is the base directory for artifacts when Destination is empty.
 	// Defaults to ~/.cache/lazyoci/artifacts if not specified.
 	ArtifactBase string
 
-	// Platform filters to a specific platform (e.g., linux/amd64).
-	// If nil, defaults to the current OS/arch for images.
-	Platform *ocispec.Platform
+	// Platforms selects which platforms of an image index to pull (e.g.,
+	// linux/amd64). With a single platform the pulled tag points at that
+	// platform's manifest. With several, the index itself is pulled with
+	// only the selected children, keeping its original digest; the others
+	// stay listed in it but are absent from the layout. If empty,
+	// defaults to the current OS/arch for images.
+	Platforms []ocispec.Platform
+
+	// AllPlatforms pulls an image index with every child manifest.
+	AllPlatforms bool
+
+	// WithReferrers also pulls the referrers of the pulled manifests, such
+	// as signatures, SBOMs and attestations, into the same layout.
+	WithReferrers bool
+
+	// ReferrerTypes limits WithReferrers to referrers of these artifact
+	// types. Empty means all.
+	ReferrerTypes []string
 
-	// ToDocker loads the pulled image into the Docker daemon after pulling.
-	ToDocker bool
+	// Tag names the pulled manifest in the OCI layout. Defaults to the
+	// reference's tag or digest.
+	Tag string
+
+	// Load loads the pulled image into a container engine after pulling.
+	Load LoadTarget
+
+	// LoadNamespace is the containerd namespace to load into. Defaults to
+	// CONTAINERD_NAMESPACE or "default".
+	LoadNamespace string
 
 	// Quiet suppresses progress output.
 	Quiet bool
 
+	// Concurrency is the maximum number of layers downloaded in parallel.
+	// Zero or less uses the default of 3.
+	Concurrency int
+
+	// Progress, when set, receives layer progress events. It is called
+	// whether or not Quiet is set, so a TUI can render progress itself.
+	Progress ProgressListener
+
 	// Insecure allows pulling over HTTP.
 	Insecure bool
 
@@ -55,22 +101,63 @@ type PullResult struct {
 	Digest         string                `json:"digest" yaml:"digest"`
 	Size           int64                 `json:"size" yaml:"size"`
 	Destination    string                `json:"destination" yaml:"destination"`
+	Format         Format                `json:"format" yaml:"format"`
+	Files          []string              `json:"files,omitempty" yaml:"files,omitempty"`
 	Layers         int                   `json:"layers" yaml:"layers"`
 	ArtifactType   registry.ArtifactType `json:"artifactType" yaml:"artifactType"`
 	TypeDetail     string                `json:"typeDetail,omitempty" yaml:"typeDetail,omitempty"`
+	Platforms      []string              `json:"platforms,omitempty" yaml:"platforms,omitempty"`
+	Referrers      []Referrer            `json:"referrers,omitempty" yaml:"referrers,omitempty"`
+	LoadedInto     LoadTarget            `json:"loadedInto,omitempty" yaml:"loadedInto,omitempty"`
 	LoadedToDocker bool                  `json:"loadedToDocker" yaml:"loadedToDocker"`
+	Error          string                `json:"error,omitempty" yaml:"error,omitempty"`
 }
 
-// Puller handles pulling OCI artifacts from registries.
+// Puller handles pulling OCI artifacts from registries. Pulls into the
+// same layout through one Puller share its store, so concurrent pulls can
+// write to it safely and download each blob once.
 type Puller struct {
-	tracker *ProgressTracker
+	quiet bool
+
+	mu      sync.Mutex
+	layouts map[string]*sharedLayout
 }
 
-// NewPuller creates a new Puller with the given options.
+// NewPuller creates a new Puller. Unless quiet is true, layer progress is
+// rendered to stdout.
 func NewPuller(quiet bool) *Puller {
-	return &Puller{
-		tracker: NewProgressTracker(quiet),
+	return &Puller{quiet: quiet}
+}
+
+// sharedLayout is an OCI layout open for pulls.
+type sharedLayout struct {
+	store   *oci.Store
+	flights blobFlights
+}
+
+// openLayout returns the store for the layout at dir, opening it on first
+// use.
+func (p *Puller) openLayout(dir string) (*sharedLayout, error) {
+	key, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
 	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if l, ok := p.layouts[key]; ok {
+		return l, nil
+	}
+	store, err := oci.New(dir)
+	if err != nil {
+		return nil, err
+	}
+	if p.layouts == nil {
+		p.layouts = make(map[string]*sharedLayout)
+	}
+	l := &sharedLayout{store: store}
+	p.layouts[key] = l
+	return l, nil
 }
 
 // Pull downloads an OCI artifact from a registry to local storage.
@@ -90,8 +177,46 @@ func (p *Puller) Pull(ctx context.Context, opts PullOptions) (*PullResult, error
 	// Detect artifact type by inspecting manifest
 	artifactType, typeDetail, configMediaType := p.detectArtifactType(ctx, repo, ref.Ref())
 
+	format := opts.Format
+	if format == "" {
+		format = FormatOCIDir
+	}
+	if format.IsArchive() && opts.Destination == "" {
+		return nil, fmt.Errorf("%s format needs a destination file", format)
+	}
+	if format == FormatDockerArchive && artifactType != registry.ArtifactTypeImage {
+		return nil, fmt.Errorf("cannot export %s artifact as a Docker archive (only images supported)", artifactType)
+	}
+	if format == FormatDockerArchive && opts.WithReferrers {
+		return nil, fmt.Errorf("a Docker archive cannot hold referrers; use the oci-dir or oci-tar format")
+	}
+	if opts.Extract {
+		if format.IsArchive() || opts.Load != "" {
+			return nil, fmt.Errorf("extract cannot be combined with an archive format or loading")
+		}
+		if artifactType == registry.ArtifactTypeImage {
+			return nil, fmt.Errorf("images have no files to extract; use lazyoci unpack for a root filesystem")
+		}
+	}
+
+	// Find the engine to load into (only for images) before downloading,
+	// so that a bad engine host fails the pull rather than follows it
+	var loader Loader
+	if opts.Load != "" {
+		if artifactType != registry.ArtifactTypeImage {
+			return nil, fmt.Errorf("cannot load %s artifact into %s (only images supported)", artifactType, opts.Load.Name())
+		}
+		loader, err = NewLoader(opts.Load, opts.LoadNamespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Determine destination based on artifact type
 	dest := opts.Destination
+	if format.IsArchive() || opts.Extract {
+		dest = ""
+	}
 	if dest == "" {
 		// Use ArtifactBase or default
 		artifactBase := opts.ArtifactBase
@@ -106,21 +231,66 @@ func (p *Puller) Pull(ctx context.Context, opts PullOptions) (*PullResult, error
 
 	// Route to type-specific pull based on artifact type
 	// (For now, all types use OCI layout; type-specific extraction will be added in Phase 5)
+	var result *PullResult
 	switch artifactType {
 	case registry.ArtifactTypeHelmChart:
-		return p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
+		result, err = p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
 	case registry.ArtifactTypeSBOM:
-		return p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
+		result, err = p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
 	case registry.ArtifactTypeSignature:
-		return p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
+		result, err = p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
 	case registry.ArtifactTypeAttestation:
-		return p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
+		result, err = p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
 	case registry.ArtifactTypeWasm:
-		return p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
+		result, err = p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
 	default:
 		// Images and unknown artifacts use standard OCI layout
-		return p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
+		result, err = p.pullOCILayout(ctx, repo, ref, dest, opts, artifactType, typeDetail, configMediaType)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	// Load into a container engine if requested
+	if loader != nil {
+		// Build a full reference — engines require "repo:tag" or "repo@digest" format.
+		if err := loader.Load(ctx, result.Destination, ref.String()); err != nil {
+			return result, fmt.Errorf("pulled but failed to load into %s: %w", opts.Load.Name(), err)
+		}
+		result.LoadedInto = opts.Load
+		result.LoadedToDocker = opts.Load == LoadDocker
+	}
+
+	// Pack the layout into the requested archive
+	switch format {
+	case FormatOCITar:
+		err = ExportOCITar(result.Destination, opts.Destination)
+	case FormatDockerArchive:
+		err = ExportDockerArchive(result.Destination, ref.String(), opts.Destination)
+	}
+	if err != nil {
+		return result, fmt.Errorf("pulled but failed to write %s: %w", format, err)
 	}
+	if format.IsArchive() {
+		result.Destination = opts.Destination
+	}
+	result.Format = format
+
+	// Write the payload out of the layout
+	if opts.Extract {
+		dir := opts.Destination
+		if dir == "" {
+			dir = "."
+		}
+		files, err := Extract(result.Destination, result.Digest, artifactType, dir, opts.Untar)
+		if err != nil {
+			return result, fmt.Errorf("pulled but failed to extract: %w", err)
+		}
+		result.Destination = dir
+		result.Files = files
+	}
+
+	return result, nil
 }
 
 // detectArtifactType fetches the manifest and determines the artifact type.
@@ -183,93 +353,154 @@ func (p *Puller) pullOCILayout(
 		return nil, fmt.Errorf("failed to create destination: %w", err)
 	}
 
-	// Create local OCI store
-	store, err := oci.New(dest)
+	// Open the local OCI store, shared with other pulls into dest
+	layout, err := p.openLayout(dest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OCI store: %w", err)
 	}
+	store := layout.store
 
-	// Set up platform filter (only for images)
-	platform := opts.Platform
-	if platform == nil && artifactType == registry.ArtifactTypeImage {
-		platform = &ocispec.Platform{
-			OS:           runtime.GOOS,
-			Architecture: runtime.GOARCH,
-		}
+	tag := opts.Tag
+	if tag == "" {
+		tag = ref.Ref()
 	}
 
-	// Track layers for result
-	var layerCount int
-
-	// Set up copy options with progress tracking
-	copyOpts := oras.CopyOptions{
-		CopyGraphOptions: oras.CopyGraphOptions{
-			PreCopy: func(ctx context.Context, desc ocispec.Descriptor) error {
-				// Only show progress for layers (blobs), not manifests/configs
-				if isLayer(desc.MediaType) {
-					p.tracker.StartLayer(desc.Digest.String(), desc.Size)
-					layerCount++
-				}
-				return nil
-			},
-			PostCopy: func(ctx context.Context, desc ocispec.Descriptor) error {
-				if isLayer(desc.MediaType) {
-					p.tracker.FinishLayer(desc.Digest.String())
-				}
-				return nil
-			},
-			OnCopySkipped: func(ctx context.Context, desc ocispec.Descriptor) error {
-				// Layer already exists locally
-				if isLayer(desc.MediaType) {
-					if !opts.Quiet {
-						fmt.Printf("Layer %s already exists, skipping\n", shortDigest(desc.Digest.String()))
-					}
-					layerCount++
-				}
-				return nil
-			},
-		},
+	tracker := NewProgressTracker(p.quiet || opts.Quiet)
+	if opts.Progress != nil {
+		tracker.AddListener(opts.Progress)
 	}
 
-	// Only apply platform filter for images with explicit platform
-	if opts.Platform != nil && artifactType == registry.ArtifactTypeImage {
-		copyOpts.WithTargetPlatform(platform)
+	// Interrupted layer downloads are kept in the layout's ingest
+	// directory and resumed by the next pull.
+	downloader := &layoutDownloader{src: repo, root: dest, tracker: tracker, flights: &layout.flights}
+	graphOpts := copyGraphOptions(downloader, opts.Concurrency)
+
+	root, err := repo.Resolve(ctx, ref.Ref())
+	if err != nil {
+		return nil, fmt.Errorf("pull failed: %w", err)
 	}
 
-	// Perform the copy
-	desc, err := oras.Copy(ctx, repo, ref.Ref(), store, ref.Ref(), copyOpts)
+	// Platform selection only applies to images
+	selectPlatforms := artifactType == registry.ArtifactTypeImage && !opts.AllPlatforms
+
+	desc := root
+	var platforms []string
+	switch {
+	case selectPlatforms && isIndex(root.MediaType):
+		want := opts.Platforms
+		if len(want) == 0 {
+			want = []ocispec.Platform{defaultPlatform()}
+		}
+		var children []ocispec.Descriptor
+		children, err = selectManifests(ctx, repo, root, want)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range children {
+			platforms = append(platforms, PlatformString(*c.Platform))
+		}
+		if len(want) == 1 {
+			// The tag points straight at the platform's manifest
+			desc = children[0]
+			err = oras.CopyGraph(ctx, repo, store, desc, graphOpts)
+			if err == nil {
+				err = store.Tag(ctx, desc, tag)
+			}
+		} else {
+			err = copyIndex(ctx, repo, store, root, children, tag, graphOpts)
+		}
+	case selectPlatforms && len(opts.Platforms) > 1:
+		return nil, fmt.Errorf("%s is a single-platform image; pulling several platforms needs an image index", opts.Reference)
+	case selectPlatforms && len(opts.Platforms) == 1:
+		// Let oras check the manifest's config matches the platform
+		copyOpts := oras.CopyOptions{CopyGraphOptions: graphOpts}
+		copyOpts.WithTargetPlatform(&opts.Platforms[0])
+		desc, err = oras.Copy(ctx, repo, ref.Ref(), store, tag, copyOpts)
+		platforms = []string{PlatformString(opts.Platforms[0])}
+	default:
+		// Pull the whole graph
+		err = oras.CopyGraph(ctx, repo, store, root, graphOpts)
+		if err == nil {
+			err = store.Tag(ctx, root, tag)
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("pull failed: %w", err)
 	}
 
-	p.tracker.Finish()
+	var referrers []Referrer
+	if opts.WithReferrers {
+		referrers, err = copyReferrers(ctx, repo, store, desc, opts.ReferrerTypes, graphOpts)
+		if err != nil {
+			return nil, fmt.Errorf("pull failed: %w", err)
+		}
+	}
+
+	tracker.Finish()
 
 	result := &PullResult{
 		Reference:    opts.Reference,
 		Digest:       desc.Digest.String(),
 		Size:         desc.Size,
 		Destination:  dest,
-		Layers:       layerCount,
+		Layers:       tracker.Overall().Layers,
 		ArtifactType: artifactType,
 		TypeDetail:   typeDetail,
-	}
-
-	// Load into Docker if requested (only for images)
-	if opts.ToDocker {
-		if artifactType != registry.ArtifactTypeImage {
-			return result, fmt.Errorf("cannot load %s artifact into Docker (only images supported)", artifactType)
-		}
-		// Build a full reference — Docker requires "repo:tag" or "repo@digest" format.
-		dockerRef := ref.String()
-		if err := LoadToDocker(dest, dockerRef); err != nil {
-			return result, fmt.Errorf("pulled but failed to load into Docker: %w", err)
-		}
-		result.LoadedToDocker = true
+		Platforms:    platforms,
+		Referrers:    referrers,
 	}
 
 	return result, nil
 }
 
+// copyGraphOptions downloads layers with d and reports them to its
+// tracker, fetching up to concurrency blobs at once. Layers are added to the
+// tracker's total as each manifest is read, so the overall bar covers layers
+// not yet started.
+func copyGraphOptions(d *layoutDownloader, concurrency int) oras.CopyGraphOptions {
+	tracker := d.tracker
+	return oras.CopyGraphOptions{
+		Concurrency: concurrency,
+		FindSuccessors: func(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+			successors, err := content.Successors(ctx, fetcher, desc)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range successors {
+				if isLayer(s.MediaType) {
+					tracker.ExpectLayer(s.Digest.String(), s.Size)
+				}
+			}
+			return successors, nil
+		},
+		PreCopy: func(ctx context.Context, desc ocispec.Descriptor) error {
+			// Manifests and configs are small and copied by oras; layers
+			// go through resumable partial files
+			if !isLayer(desc.MediaType) {
+				return nil
+			}
+			fetched, err := d.fetch(ctx, desc)
+			if err != nil {
+				return err
+			}
+			if fetched {
+				tracker.FinishLayer(desc.Digest.String())
+			} else {
+				// Another pull into the same layout downloaded it
+				tracker.SkipLayer(desc.Digest.String(), desc.Size)
+			}
+			return oras.SkipNode
+		},
+		OnCopySkipped: func(ctx context.Context, desc ocispec.Descriptor) error {
+			// Layer already exists locally
+			if isLayer(desc.MediaType) {
+				tracker.SkipLayer(desc.Digest.String(), desc.Size)
+			}
+			return nil
+		},
+	}
+}
+
 // getTypeDirectory returns the subdirectory name for an artifact type.
 func getTypeDirectory(t registry.ArtifactType) string {
 	switch t {
@@ -0,0 +1,138 @@
+package pull
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/sign"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+)
+
+// cosignTags are the tags cosign stores signatures and attestations of a
+// digest under, sha256-<hex><suffix>, by default, with the artifact type
+// they are listed and filtered as. Their manifests have no subject, so the
+// referrers API does not find them.
+var cosignTags = []struct {
+	suffix       string
+	artifactType string
+}{
+	{".sig", sign.ArtifactTypeSignature},
+	{".att", "application/vnd.dsse.envelope.v1+json"},
+}
+
+// Referrer is a manifest pulled because it refers to a pulled manifest,
+// such as a signature, SBOM or attestation.
+type Referrer struct {
+	Digest       string `json:"digest" yaml:"digest"`
+	ArtifactType string `json:"artifactType,omitempty" yaml:"artifactType,omitempty"`
+	Subject      string `json:"subject" yaml:"subject"`
+	// Tag is the cosign tag the referrer was found under, if it was not
+	// found through the referrers API.
+	Tag string `json:"tag,omitempty" yaml:"tag,omitempty"`
+}
+
+// referrerSource is a repository that can list referrers.
+type referrerSource interface {
+	oras.ReadOnlyTarget
+	registry.ReferrerLister
+}
+
+// copyReferrers copies the referrers of the manifest desc from src into
+// dst, along with the referrers of the children dst holds when desc is an
+// index, and the referrers of each referrer in turn, so a signature on an
+// SBOM comes along with the SBOM. The signatures and attestations cosign
+// stores under sha256-<hex>.sig and .att tags count as referrers too; they
+// are stored untagged, like the rest, so the pulled tag stays the layout's
+// only one. When artifactTypes is not empty, only referrers of those types
+// are copied, at every level.
+func copyReferrers(ctx context.Context, src referrerSource, dst oras.Target, desc ocispec.Descriptor, artifactTypes []string, opts oras.CopyGraphOptions) ([]Referrer, error) {
+	subjects := []ocispec.Descriptor{desc}
+	if isIndex(desc.MediaType) {
+		data, err := content.FetchAll(ctx, dst, desc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read index: %w", err)
+		}
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("failed to parse index: %w", err)
+		}
+		// Only the children that were pulled
+		for _, child := range index.Manifests {
+			if exists, err := dst.Exists(ctx, child); err == nil && exists {
+				subjects = append(subjects, child)
+			}
+		}
+	}
+
+	wanted := func(artifactType string) bool {
+		if len(artifactTypes) == 0 {
+			return true
+		}
+		for _, t := range artifactTypes {
+			if t == artifactType {
+				return true
+			}
+		}
+		return false
+	}
+
+	var copied []Referrer
+	seen := make(map[string]bool)
+	for len(subjects) > 0 {
+		subject := subjects[0]
+		subjects = subjects[1:]
+
+		var referrers []ocispec.Descriptor
+		err := src.Referrers(ctx, subject, "", func(descs []ocispec.Descriptor) error {
+			referrers = append(referrers, descs...)
+			return nil
+		})
+		if err != nil {
+			return copied, fmt.Errorf("failed to list referrers of %s: %w", shortDigest(subject.Digest.String()), err)
+		}
+
+		tags := make(map[string]string)
+		if subject.Digest.Algorithm() == "sha256" {
+			for _, ct := range cosignTags {
+				if !wanted(ct.artifactType) {
+					continue
+				}
+				tag := "sha256-" + subject.Digest.Encoded() + ct.suffix
+				desc, err := src.Resolve(ctx, tag)
+				if errors.Is(err, errdef.ErrNotFound) {
+					continue
+				}
+				if err != nil {
+					return copied, fmt.Errorf("failed to resolve %s: %w", tag, err)
+				}
+				desc.ArtifactType = ct.artifactType
+				referrers = append(referrers, desc)
+				tags[desc.Digest.String()] = tag
+			}
+		}
+
+		for _, r := range referrers {
+			if seen[r.Digest.String()] || !wanted(r.ArtifactType) {
+				continue
+			}
+			seen[r.Digest.String()] = true
+			if err := oras.CopyGraph(ctx, src, dst, r, opts); err != nil {
+				return copied, fmt.Errorf("failed to copy referrer %s: %w", shortDigest(r.Digest.String()), err)
+			}
+			copied = append(copied, Referrer{
+				Digest:       r.Digest.String(),
+				ArtifactType: r.ArtifactType,
+				Subject:      subject.Digest.String(),
+				Tag:          tags[r.Digest.String()],
+			})
+			subjects = append(subjects, r)
+		}
+	}
+	return copied, nil
+}
@@ -0,0 +1,110 @@
+package pull
+
+import (
+	"context"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"oras.land/oras-go/v2/content/oci"
+)
+
+func TestPullWithReferrers(t *testing.T) {
+	const (
+		sbomType      = "application/spdx+json"
+		signatureType = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	)
+	reg := newFakeRegistry()
+	image := reg.image(t, "lib/app", "v1", "app layer")
+	sbom := reg.artifact(t, image, sbomType, "sbom")
+	imageSig := reg.artifact(t, image, signatureType, "image signature")
+	sbomSig := reg.artifact(t, sbom, signatureType, "sbom signature")
+	// Signed the way cosign and lazyoci sign do by default: a manifest
+	// without a subject, under a tag named after the digest
+	sigTag := "sha256-" + image.Digest.Encoded() + ".sig"
+	tagSig := reg.image(t, "lib/app", sigTag, "tag signature")
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+	reference := strings.TrimPrefix(srv.URL, "http://") + "/lib/app:v1"
+
+	tests := []struct {
+		name  string
+		types []string
+		want  []string // referrer -> subject
+	}{
+		{
+			name: "all",
+			want: []string{
+				imageSig.Digest.String() + " -> " + image.Digest.String(),
+				sbom.Digest.String() + " -> " + image.Digest.String(),
+				sbomSig.Digest.String() + " -> " + sbom.Digest.String(),
+				tagSig.Digest.String() + " -> " + image.Digest.String() + " (" + sigTag + ")",
+			},
+		},
+		{
+			name:  "artifact type",
+			types: []string{sbomType},
+			want:  []string{sbom.Digest.String() + " -> " + image.Digest.String()},
+		},
+		{
+			name:  "signatures",
+			types: []string{signatureType},
+			want: []string{
+				imageSig.Digest.String() + " -> " + image.Digest.String(),
+				tagSig.Digest.String() + " -> " + image.Digest.String() + " (" + sigTag + ")",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+			result, err := NewPuller(true).Pull(context.Background(), PullOptions{
+				Reference:     reference,
+				Destination:   dest,
+				WithReferrers: true,
+				ReferrerTypes: tt.types,
+				Insecure:      true,
+			})
+			if err != nil {
+				t.Fatalf("Pull() error = %v", err)
+			}
+
+			var got []string
+			for _, r := range result.Referrers {
+				line := r.Digest + " -> " + r.Subject
+				if r.Tag != "" {
+					line += " (" + r.Tag + ")"
+				}
+				got = append(got, line)
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Referrers =\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(tt.want, "\n  "))
+			}
+
+			store, err := oci.New(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range result.Referrers {
+				desc, err := store.Resolve(context.Background(), r.Digest)
+				if err != nil {
+					t.Errorf("referrer %s not in the layout: %v", r.Digest, err)
+					continue
+				}
+				if exists, _ := store.Exists(context.Background(), desc); !exists {
+					t.Errorf("referrer %s not stored", r.Digest)
+				}
+			}
+			if desc, err := store.Resolve(context.Background(), "v1"); err != nil || desc.Digest != image.Digest {
+				t.Errorf("tag v1 = %s, %v; want the image", desc.Digest, err)
+			}
+			if _, err := store.Resolve(context.Background(), sigTag); err == nil {
+				t.Errorf("layout has tag %s, want only the pulled tag", sigTag)
+			}
+		})
+	}
+}
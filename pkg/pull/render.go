@@ -0,0 +1,125 @@
+package pull
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// barWidth is the width of a progress bar, excluding its brackets.
+const barWidth = 30
+
+// TerminalRenderer draws pull progress. On a terminal it shows a live bar
+// per layer being downloaded and an overall bar with throughput and ETA;
+// otherwise it writes one log line per layer event.
+type TerminalRenderer struct {
+	w     io.Writer
+	tty   bool
+	lines int // bar lines currently drawn below the cursor's start
+}
+
+// NewTerminalRenderer creates a renderer writing to w. Live bars are used
+// when w is a terminal.
+func NewTerminalRenderer(w io.Writer) *TerminalRenderer {
+	tty := false
+	if f, ok := w.(*os.File); ok {
+		if info, err := f.Stat(); err == nil {
+			tty = info.Mode()&os.ModeCharDevice != 0
+		}
+	}
+	return &TerminalRenderer{w: w, tty: tty}
+}
+
+// OnProgress renders an event.
+func (r *TerminalRenderer) OnProgress(e ProgressEvent) {
+	if r.tty {
+		r.renderLive(e)
+	} else {
+		r.renderLog(e)
+	}
+}
+
+// renderLog writes a line for each layer event, skipping byte updates.
+func (r *TerminalRenderer) renderLog(e ProgressEvent) {
+	switch e.Kind {
+	case LayerStarted:
+		if e.Layer.Bytes > 0 {
+			fmt.Fprintf(r.w, "Resuming %s at %s of %s\n", shortDigest(e.Layer.Digest), formatBytes(e.Layer.Bytes), formatBytes(e.Layer.Size))
+		} else {
+			fmt.Fprintf(r.w, "Pulling %s (%s)\n", shortDigest(e.Layer.Digest), formatBytes(e.Layer.Size))
+		}
+	case LayerCompleted:
+		fmt.Fprintf(r.w, "Pulled %s (%s) in %s\n", shortDigest(e.Layer.Digest), formatBytes(e.Layer.Size),
+			formatDuration(e.Layer.Finished.Sub(e.Layer.Started)))
+	case LayerSkipped:
+		fmt.Fprintf(r.w, "Layer %s already exists, skipping\n", shortDigest(e.Layer.Digest))
+	case PullFinished:
+		r.writeSummary(e.Overall)
+	}
+}
+
+// renderLive redraws the bars below any permanent lines the event adds.
+func (r *TerminalRenderer) renderLive(e ProgressEvent) {
+	r.clear()
+	switch e.Kind {
+	case LayerCompleted:
+		fmt.Fprintf(r.w, "%-19s  done      %s\n", shortDigest(e.Layer.Digest), formatBytes(e.Layer.Size))
+	case LayerSkipped:
+		fmt.Fprintf(r.w, "%-19s  exists    %s\n", shortDigest(e.Layer.Digest), formatBytes(e.Layer.Size))
+	case PullFinished:
+		r.writeSummary(e.Overall)
+		return
+	}
+
+	for _, l := range e.Active {
+		fmt.Fprintf(r.w, "%-19s  %s  %s / %s\n",
+			shortDigest(l.Digest), bar(l.Bytes, l.Size), formatBytes(l.Bytes), formatBytes(l.Size))
+	}
+	o := e.Overall
+	line := fmt.Sprintf("%-19s  %s  %s / %s", fmt.Sprintf("Total %d/%d", o.Completed, o.Layers),
+		bar(o.Bytes, o.Total), formatBytes(o.Bytes), formatBytes(o.Total))
+	if rate := o.Rate(); rate > 0 {
+		line += fmt.Sprintf("  %s/s", formatBytes(int64(rate)))
+	}
+	if eta := o.ETA(); eta > 0 {
+		line += "  ETA " + formatDuration(eta)
+	}
+	fmt.Fprintln(r.w, line)
+	r.lines = len(e.Active) + 1
+}
+
+// clear erases the bars drawn by the previous event.
+func (r *TerminalRenderer) clear() {
+	if r.lines > 0 {
+		fmt.Fprintf(r.w, "\x1b[%dA\x1b[J", r.lines)
+		r.lines = 0
+	}
+}
+
+// writeSummary writes the final line of a pull that transferred layers.
+func (r *TerminalRenderer) writeSummary(o OverallProgress) {
+	if o.Layers == 0 {
+		return
+	}
+	line := fmt.Sprintf("Pulled %d layers (%s) in %s", o.Completed, formatBytes(o.Bytes), formatDuration(o.Elapsed))
+	if rate := o.Rate(); rate > 0 {
+		line += fmt.Sprintf(", %s/s", formatBytes(int64(rate)))
+	}
+	if o.Skipped > 0 {
+		line += fmt.Sprintf(", %d already present", o.Skipped)
+	}
+	fmt.Fprintln(r.w, line)
+}
+
+// bar draws a fixed-width bar for current out of total.
+func bar(current, total int64) string {
+	filled := barWidth
+	if total > 0 && current < total {
+		filled = int(current * barWidth / total)
+	}
+	if filled >= barWidth {
+		return "[" + strings.Repeat("=", barWidth) + "]"
+	}
+	return "[" + strings.Repeat("=", filled) + ">" + strings.Repeat(" ", barWidth-filled-1) + "]"
+}
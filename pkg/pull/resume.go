@@ -0,0 +1,234 @@
+package pull
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// partialSuffix marks the resumable download files lazyoci keeps in an OCI
+// layout's ingest directory. oras's own ingest files use a random suffix,
+// so the two never collide.
+const partialSuffix = ".partial"
+
+// layoutDownloader downloads layers straight into an OCI layout. Each blob
+// is written to a partial file in the layout's ingest directory that
+// survives a failed pull; the next pull resumes it with a Range request
+// when the registry supports one, and restarts it otherwise. The digest is
+// verified over the whole file before it is moved into place.
+type layoutDownloader struct {
+	src     content.Fetcher
+	root    string
+	tracker *ProgressTracker
+
+	// flights, when set, is shared by every downloader writing to root,
+	// so a blob needed by several concurrent pulls is downloaded once.
+	flights *blobFlights
+}
+
+// fetch downloads desc unless it is already in the layout or another pull
+// is downloading it into the same layout, in which case it waits for that
+// download. It reports whether this call downloaded the blob.
+func (d *layoutDownloader) fetch(ctx context.Context, desc ocispec.Descriptor) (bool, error) {
+	if d.flights == nil {
+		return true, d.download(ctx, desc)
+	}
+	return d.flights.do(ctx, desc.Digest, func() (bool, error) {
+		if _, err := os.Stat(d.blobPath(desc)); err == nil {
+			return false, nil
+		}
+		return true, d.download(ctx, desc)
+	})
+}
+
+// blobFlights tracks the blob downloads in flight into one layout.
+type blobFlights struct {
+	mu    sync.Mutex
+	calls map[digest.Digest]*blobFlight
+}
+
+type blobFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// do runs fn for dgst unless a call for it is in flight, in which case it
+// waits for that call and reports false. If the call it waited for failed,
+// for instance because its pull was cancelled, it tries again itself.
+func (f *blobFlights) do(ctx context.Context, dgst digest.Digest, fn func() (bool, error)) (bool, error) {
+	for {
+		f.mu.Lock()
+		if c, ok := f.calls[dgst]; ok {
+			f.mu.Unlock()
+			select {
+			case <-c.done:
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+			if c.err == nil {
+				return false, nil
+			}
+			continue
+		}
+		if f.calls == nil {
+			f.calls = make(map[digest.Digest]*blobFlight)
+		}
+		c := &blobFlight{done: make(chan struct{})}
+		f.calls[dgst] = c
+		f.mu.Unlock()
+
+		fetched, err := fn()
+		c.err = err
+
+		f.mu.Lock()
+		delete(f.calls, dgst)
+		f.mu.Unlock()
+		close(c.done)
+		return fetched, err
+	}
+}
+
+// partialPath returns where the partial download of desc is kept.
+func (d *layoutDownloader) partialPath(desc ocispec.Descriptor) string {
+	return filepath.Join(d.root, "ingest", desc.Digest.Encoded()+partialSuffix)
+}
+
+// blobPath returns where the OCI layout stores desc.
+func (d *layoutDownloader) blobPath(desc ocispec.Descriptor) string {
+	return filepath.Join(d.root, "blobs", desc.Digest.Algorithm().String(), desc.Digest.Encoded())
+}
+
+// open fetches desc from offset, or from the start when the registry
+// cannot serve that range, and returns the reader and the offset it
+// starts at.
+func (d *layoutDownloader) open(ctx context.Context, desc ocispec.Descriptor, offset int64) (io.ReadCloser, int64, error) {
+	rc, err := d.src.Fetch(ctx, desc)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset == 0 {
+		return rc, 0, nil
+	}
+	seeker, ok := rc.(io.Seeker)
+	if !ok {
+		return rc, 0, nil
+	}
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		// The registry advertised ranges but refused this one; the
+		// original response is still at the start of the blob.
+		return rc, 0, nil
+	}
+	if offset == desc.Size {
+		return rc, offset, nil
+	}
+
+	// A refused range may only fail once it is read, for instance when
+	// the registry answers 200 rather than 206, so the first bytes are
+	// read here and the blob is fetched again from the start if that fails.
+	first := make([]byte, 32*1024)
+	n, err := io.ReadAtLeast(rc, first, 1)
+	if err != nil {
+		rc.Close()
+		if rc, err = d.src.Fetch(ctx, desc); err != nil {
+			return nil, 0, err
+		}
+		return rc, 0, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(first[:n]), rc), rc}, offset, nil
+}
+
+// writePartial truncates f, the partial file, to offset, hashes what is
+// left into verifier and appends the rest of the blob from rc. It returns
+// how many bytes it appended.
+func (d *layoutDownloader) writePartial(f *os.File, rc io.Reader, verifier digest.Digester, desc ocispec.Descriptor, offset int64) (int64, error) {
+	if err := f.Truncate(offset); err != nil {
+		return 0, fmt.Errorf("failed to truncate partial file: %w", err)
+	}
+
+	// Hash what is already on disk, then everything appended after it.
+	if _, err := io.Copy(verifier.Hash(), io.LimitReader(f, offset)); err != nil {
+		return 0, fmt.Errorf("failed to read partial file: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek partial file: %w", err)
+	}
+
+	if offset > 0 {
+		d.tracker.ResumeLayer(desc.Digest.String(), desc.Size, offset)
+	}
+	d.tracker.StartLayer(desc.Digest.String(), desc.Size)
+	reader := &trackingReader{
+		reader:  io.LimitReader(rc, desc.Size-offset),
+		digest:  desc.Digest.String(),
+		tracker: d.tracker,
+		total:   desc.Size,
+		current: offset,
+	}
+	return io.Copy(io.MultiWriter(f, verifier.Hash()), reader)
+}
+
+// download fetches desc into the layout, resuming any partial file. The
+// layer is started in the tracker once the resume offset is known.
+func (d *layoutDownloader) download(ctx context.Context, desc ocispec.Descriptor) error {
+	if err := desc.Digest.Validate(); err != nil {
+		return fmt.Errorf("invalid digest %q: %w", desc.Digest, err)
+	}
+
+	partial := d.partialPath(desc)
+	if err := os.MkdirAll(filepath.Dir(partial), 0755); err != nil {
+		return fmt.Errorf("failed to create ingest directory: %w", err)
+	}
+
+	var offset int64
+	if info, err := os.Stat(partial); err == nil && info.Size() <= desc.Size {
+		offset = info.Size()
+	}
+
+	rc, offset, err := d.open(ctx, desc, offset)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open partial file: %w", err)
+	}
+	verifier := desc.Digest.Algorithm().Digester()
+	n, err := d.writePartial(f, rc, verifier, desc, offset)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to write partial file: %w", cerr)
+	}
+	if err != nil {
+		// Keep what was written so the next pull can resume it.
+		return err
+	}
+
+	if offset+n != desc.Size {
+		return fmt.Errorf("blob %s: got %d bytes, want %d", shortDigest(desc.Digest.String()), offset+n, desc.Size)
+	}
+	if got := verifier.Digest(); got != desc.Digest {
+		os.Remove(partial)
+		return fmt.Errorf("blob %s: %w: got %s", shortDigest(desc.Digest.String()), content.ErrMismatchedDigest, got)
+	}
+
+	target := d.blobPath(desc)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	if err := os.Rename(partial, target); err != nil {
+		return fmt.Errorf("failed to move blob into place: %w", err)
+	}
+	return nil
+}
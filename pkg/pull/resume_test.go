@@ -0,0 +1,200 @@
+package pull
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// rangeFetcher serves one blob. When seekable, its readers support Seek
+// like oras's remote blob readers do with a registry that accepts ranges.
+type rangeFetcher struct {
+	data     []byte
+	seekable bool
+	failAt   int64 // when non-zero, reads fail after this absolute offset
+	// refuseOnRead makes a seeked reader fail on its first read, like a
+	// registry answering a Range request with 200.
+	refuseOnRead bool
+	offsets      []int64
+}
+
+type rangeReader struct {
+	f      *rangeFetcher
+	offset int64
+	seeked bool
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.seeked && r.f.refuseOnRead {
+		return 0, errors.New("unexpected status code 200")
+	}
+	if r.f.failAt > 0 && r.offset >= r.f.failAt {
+		return 0, errors.New("connection reset")
+	}
+	if r.offset >= int64(len(r.f.data)) {
+		return 0, io.EOF
+	}
+	end := int64(len(r.f.data))
+	if r.f.failAt > 0 && end > r.f.failAt {
+		end = r.f.failAt
+	}
+	n := copy(p, r.f.data[r.offset:end])
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *rangeReader) Close() error { return nil }
+
+type seekableRangeReader struct{ *rangeReader }
+
+func (r seekableRangeReader) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, errors.New("unsupported whence")
+	}
+	r.offset = offset
+	r.seeked = true
+	r.f.offsets[len(r.f.offsets)-1] = offset
+	return offset, nil
+}
+
+func (f *rangeFetcher) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	f.offsets = append(f.offsets, 0)
+	r := &rangeReader{f: f}
+	if f.seekable {
+		return seekableRangeReader{r}, nil
+	}
+	return r, nil
+}
+
+func TestLayoutDownloaderResume(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789", 10000))
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}
+
+	tests := []struct {
+		name           string
+		seekable       bool
+		refuseOnRead   bool
+		partial        []byte
+		wantOffset     int64
+		wantDownloaded int64
+	}{
+		{name: "fresh", seekable: true, wantOffset: 0, wantDownloaded: 100000},
+		{name: "resume with range", seekable: true, partial: data[:60000], wantOffset: 60000, wantDownloaded: 40000},
+		{name: "restart without range", seekable: false, partial: data[:60000], wantOffset: 0, wantDownloaded: 100000},
+		{name: "restart when range read fails", seekable: true, refuseOnRead: true, partial: data[:60000], wantOffset: 0, wantDownloaded: 100000},
+		{name: "complete partial", seekable: true, partial: data, wantOffset: 100000, wantDownloaded: 0},
+		{name: "oversized partial", seekable: true, partial: append(append([]byte{}, data...), 'x'), wantOffset: 0, wantDownloaded: 100000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			tracker := NewProgressTracker(true)
+			fetcher := &rangeFetcher{data: data, seekable: tt.seekable, refuseOnRead: tt.refuseOnRead}
+			d := &layoutDownloader{src: fetcher, root: root, tracker: tracker}
+
+			if tt.partial != nil {
+				if err := os.MkdirAll(filepath.Join(root, "ingest"), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(d.partialPath(desc), tt.partial, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := d.download(context.Background(), desc); err != nil {
+				t.Fatalf("download() error = %v", err)
+			}
+			tracker.FinishLayer(desc.Digest.String())
+
+			if last := fetcher.offsets[len(fetcher.offsets)-1]; last != tt.wantOffset {
+				t.Errorf("fetched from offset %d, want %d", last, tt.wantOffset)
+			}
+			got, err := os.ReadFile(d.blobPath(desc))
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("blob content wrong (err = %v)", err)
+			}
+			if _, err := os.Stat(d.partialPath(desc)); !os.IsNotExist(err) {
+				t.Errorf("partial file left behind: %v", err)
+			}
+			o := tracker.Overall()
+			if o.Bytes != desc.Size || o.Downloaded != tt.wantDownloaded {
+				t.Errorf("progress = %d bytes (%d downloaded), want %d (%d downloaded)", o.Bytes, o.Downloaded, desc.Size, tt.wantDownloaded)
+			}
+		})
+	}
+}
+
+func TestLayoutDownloaderInterrupted(t *testing.T) {
+	data := []byte(strings.Repeat("abcdefghij", 10000))
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}
+	root := t.TempDir()
+	fetcher := &rangeFetcher{data: data, seekable: true, failAt: 90000}
+	d := &layoutDownloader{src: fetcher, root: root, tracker: NewProgressTracker(true)}
+
+	if err := d.download(context.Background(), desc); err == nil {
+		t.Fatal("download() error = nil, want interruption")
+	}
+	info, err := os.Stat(d.partialPath(desc))
+	if err != nil || info.Size() != 90000 {
+		t.Fatalf("partial file = %v, %v; want 90000 bytes kept", info, err)
+	}
+
+	// The retry resumes at 90%.
+	fetcher.failAt = 0
+	if err := d.download(context.Background(), desc); err != nil {
+		t.Fatalf("retry error = %v", err)
+	}
+	if fetcher.offsets[1] != 90000 {
+		t.Errorf("retry fetched from %d, want 90000", fetcher.offsets[1])
+	}
+	if _, err := os.Stat(d.blobPath(desc)); err != nil {
+		t.Errorf("blob not in place: %v", err)
+	}
+}
+
+func TestLayoutDownloaderCorruptPartial(t *testing.T) {
+	data := []byte(strings.Repeat("abcdefghij", 1000))
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}
+	root := t.TempDir()
+	d := &layoutDownloader{src: &rangeFetcher{data: data, seekable: true}, root: root, tracker: NewProgressTracker(true)}
+
+	if err := os.MkdirAll(filepath.Join(root, "ingest"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(d.partialPath(desc), bytes.Repeat([]byte("x"), 5000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := d.download(context.Background(), desc)
+	if !errors.Is(err, content.ErrMismatchedDigest) {
+		t.Fatalf("download() error = %v, want digest mismatch", err)
+	}
+	if _, err := os.Stat(d.partialPath(desc)); !os.IsNotExist(err) {
+		t.Errorf("corrupt partial file kept: %v", err)
+	}
+	if _, err := os.Stat(d.blobPath(desc)); !os.IsNotExist(err) {
+		t.Errorf("corrupt blob moved into place: %v", err)
+	}
+}
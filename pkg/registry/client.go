@@ -302,34 +302,12 @@ func (c *Client) ListArtifacts(repoPath string) ([]*Artifact, error) {
 
 // ListArtifactsWithOptions lists artifacts with pagination and filtering
 func (c *Client) ListArtifactsWithOptions(repoPath string, opts ListArtifactsOptions) ([]*Artifact, error) {
-	parts := strings.SplitN(repoPath, "/", 2)
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid repository path: %s", repoPath)
-	}
-
-	registryURL := parts[0]
-	repoName := parts[1]
-
-	// Handle Docker Hub special cases
-	if registryURL == "docker.io" {
-		registryURL = "registry-1.docker.io"
-		// Official images need "library/" prefix
-		if !strings.Contains(repoName, "/") {
-			repoName = "library/" + repoName
-		}
-	}
-
-	reg, err := c.getRegistry(registryURL)
-	if err != nil {
-		return nil, err
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	repo, err := reg.Repository(ctx, repoName)
+	repo, err := c.repository(ctx, repoPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get repository: %w", err)
+		return nil, err
 	}
 
 	// Collect all tags first (for sorting before pagination)
@@ -378,30 +356,10 @@ func (c *Client) ListArtifactsWithOptions(repoPath string, opts ListArtifactsOpt
 
 // CountArtifacts returns the total number of tags in a repository
 func (c *Client) CountArtifacts(repoPath string, filter string) (int, error) {
-	parts := strings.SplitN(repoPath, "/", 2)
-	if len(parts) != 2 {
-		return 0, fmt.Errorf("invalid repository path: %s", repoPath)
-	}
-
-	registryURL := parts[0]
-	repoName := parts[1]
-
-	if registryURL == "docker.io" {
-		registryURL = "registry-1.docker.io"
-		if !strings.Contains(repoName, "/") {
-			repoName = "library/" + repoName
-		}
-	}
-
-	reg, err := c.getRegistry(registryURL)
-	if err != nil {
-		return 0, err
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	repo, err := reg.Repository(ctx, repoName)
+	repo, err := c.repository(ctx, repoPath)
 	if err != nil {
 		return 0, err
 	}
@@ -424,32 +382,12 @@ func (c *Client) CountArtifacts(repoPath string, filter string) (int, error) {
 // GetArtifactDetails resolves a tag to its full artifact details (digest, size, type).
 // repoPath should be in the form "registry/namespace/repo" (e.g. "localhost:5050/test/hello").
 func (c *Client) GetArtifactDetails(repoPath, tag string) (*Artifact, error) {
-	parts := strings.SplitN(repoPath, "/", 2)
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid repository path: %s", repoPath)
-	}
-
-	registryURL := parts[0]
-	repoName := parts[1]
-
-	if registryURL == "docker.io" {
-		registryURL = "registry-1.docker.io"
-		if !strings.Contains(repoName, "/") {
-			repoName = "library/" + repoName
-		}
-	}
-
-	reg, err := c.getRegistry(registryURL)
-	if err != nil {
-		return nil, err
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	repo, err := reg.Repository(ctx, repoName)
+	repo, err := c.repository(ctx, repoPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get repository: %w", err)
+		return nil, err
 	}
 
 	return c.getArtifactDetails(ctx, repo, repoPath, tag)
@@ -476,32 +414,12 @@ func (c *Client) getArtifactDetails(ctx context.Context, repo registry.Repositor
 // This performs a deeper inspection than GetArtifactDetails, looking at config media type
 // and layer media types to accurately determine the artifact type.
 func (c *Client) GetArtifactInfo(repoPath, tag string) (*ArtifactInfo, error) {
-	parts := strings.SplitN(repoPath, "/", 2)
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid repository path: %s", repoPath)
-	}
-
-	registryURL := parts[0]
-	repoName := parts[1]
-
-	if registryURL == "docker.io" {
-		registryURL = "registry-1.docker.io"
-		if !strings.Contains(repoName, "/") {
-			repoName = "library/" + repoName
-		}
-	}
-
-	reg, err := c.getRegistry(registryURL)
-	if err != nil {
-		return nil, err
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	repo, err := reg.Repository(ctx, repoName)
+	repo, err := c.repository(ctx, repoPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get repository: %w", err)
+		return nil, err
 	}
 
 	// Resolve the tag to get the manifest descriptor
@@ -645,6 +563,17 @@ func detectArtifactType(manifestMediaType, configMediaType string, layerMediaTyp
 		}
 	}
 
+	// Generic artifact detection
+	// Artifacts packed by `oras push` or lazyoci's "artifact" build type use
+	// the image manifest media type with an empty or custom config
+	// (application/vnd.oci.empty.v1+json), so a non-image config rules out
+	// an image.
+	if config != "" &&
+		!strings.Contains(config, "oci.image.config") &&
+		!strings.Contains(config, "docker.container.image") {
+		return ArtifactTypeUnknown, ""
+	}
+
 	// Container Image detection
 	// OCI: application/vnd.oci.image.*
 	// Docker: application/vnd.docker.distribution.manifest.*
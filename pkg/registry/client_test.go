@@ -128,6 +128,26 @@ func TestDetectArtifactType(t *testing.T) {
 			wantType: ArtifactTypeImage,
 		},
 
+		// --- Generic artifacts ---
+		{
+			name:     "oras artifact with empty config",
+			manifest: "application/vnd.oci.image.manifest.v1+json",
+			config:   "application/vnd.oci.empty.v1+json",
+			layers:   []string{"application/vnd.openpolicyagent.policy.layer.v1+rego"},
+			wantType: ArtifactTypeUnknown,
+		},
+		{
+			name:     "artifact with custom config",
+			manifest: "application/vnd.oci.image.manifest.v1+json",
+			config:   "application/vnd.example.config.v1+json",
+			wantType: ArtifactTypeUnknown,
+		},
+		{
+			name:     "image index has no config",
+			manifest: "application/vnd.oci.image.index.v1+json",
+			wantType: ArtifactTypeImage,
+		},
+
 		// --- Unknown ---
 		{
 			name:     "completely unknown types",
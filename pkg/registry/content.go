@@ -0,0 +1,227 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry"
+)
+
+// MaxBlobFetchSize caps how much of a blob FetchBlob will read into memory.
+// Blobs larger than this are rejected rather than truncated.
+const MaxBlobFetchSize = 32 << 20
+
+// Fetcher retrieves manifests and blobs from a registry. *Client
+// satisfies it; packages that read artifact content take a Fetcher so they
+// can be tested without a registry.
+type Fetcher interface {
+	GetManifest(repoPath, reference string) (*Manifest, *Descriptor, error)
+	FetchBlob(repoPath string, desc Descriptor) ([]byte, error)
+}
+
+// ReferrerLister lists manifests that declare a subject. *Client satisfies
+// it.
+type ReferrerLister interface {
+	Referrers(repoPath string, subject Descriptor, artifactType string) ([]Descriptor, error)
+}
+
+// repository returns an oras repository for a "registry/namespace/repo" path.
+// Docker Hub paths are normalized, and paths under LocalRegistryURL are
+// served from the artifact directory.
+func (c *Client) repository(ctx context.Context, repoPath string) (registry.Repository, error) {
+	parts := strings.SplitN(repoPath, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid repository path: %s", repoPath)
+	}
+
+	registryURL := parts[0]
+	repoName := parts[1]
+
+	if registryURL == LocalRegistryURL {
+		return c.localRepository(ctx, repoName)
+	}
+	if registryURL == "docker.io" {
+		registryURL = "registry-1.docker.io"
+		if !strings.Contains(repoName, "/") {
+			repoName = "library/" + repoName
+		}
+	}
+
+	reg, err := c.getRegistry(registryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := reg.Repository(ctx, repoName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+	return repo, nil
+}
+
+// GetManifest resolves a tag or digest and returns the parsed manifest along
+// with its descriptor. Index manifests are returned as-is (Manifests populated).
+func (c *Client) GetManifest(repoPath, reference string) (*Manifest, *Descriptor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	repo, err := c.repository(ctx, repoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desc, rc, err := repo.FetchReference(ctx, reference)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	defer rc.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = desc.MediaType
+	}
+
+	return &manifest, fromOCIDescriptor(desc), nil
+}
+
+// FetchBlob downloads a blob referenced by desc and verifies its digest.
+// Blobs larger than MaxBlobFetchSize are rejected.
+func (c *Client) FetchBlob(repoPath string, desc Descriptor) ([]byte, error) {
+	if desc.Size > MaxBlobFetchSize {
+		return nil, fmt.Errorf("blob %s is too large to fetch (%d bytes, limit %d)", desc.Digest, desc.Size, MaxBlobFetchSize)
+	}
+
+	rc, err := c.OpenBlob(repoPath, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, MaxBlobFetchSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", desc.Digest, err)
+	}
+	if int64(len(data)) > MaxBlobFetchSize {
+		return nil, fmt.Errorf("blob %s exceeds %d bytes", desc.Digest, MaxBlobFetchSize)
+	}
+
+	expected, err := digest.Parse(desc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob digest %q: %w", desc.Digest, err)
+	}
+	if actual := expected.Algorithm().FromBytes(data); actual != expected {
+		return nil, fmt.Errorf("blob digest mismatch: expected %s, got %s", expected, actual)
+	}
+
+	return data, nil
+}
+
+// OpenBlob opens a streaming reader for the blob referenced by desc.
+// The caller must close the returned reader. No digest verification is done
+// beyond what the transport performs; use FetchBlob for small, verified reads.
+func (c *Client) OpenBlob(repoPath string, desc Descriptor) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+
+	repo, err := c.repository(ctx, repoPath)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	rc, err := repo.Blobs().Fetch(ctx, toOCIDescriptor(desc))
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to fetch blob %s: %w", desc.Digest, err)
+	}
+
+	return &cancelReadCloser{ReadCloser: rc, cancel: cancel}, nil
+}
+
+// Referrers lists the manifests that declare subject as their subject,
+// optionally filtered by artifactType. Registries without the referrers API
+// are queried through the "sha256-<hex>" referrers tag schema.
+func (c *Client) Referrers(repoPath string, subject Descriptor, artifactType string) ([]Descriptor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	repo, err := c.repository(ctx, repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	lister, ok := repo.(registry.ReferrerLister)
+	if !ok {
+		return nil, fmt.Errorf("repository %s does not support referrers", repoPath)
+	}
+
+	var referrers []Descriptor
+	err = lister.Referrers(ctx, toOCIDescriptor(subject), artifactType, func(descs []ocispec.Descriptor) error {
+		for _, d := range descs {
+			referrers = append(referrers, *fromOCIDescriptor(d))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrers: %w", err)
+	}
+	return referrers, nil
+}
+
+// cancelReadCloser releases the request context when the body is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
+
+// toOCIDescriptor converts a Descriptor into the oras/image-spec form.
+func toOCIDescriptor(d Descriptor) ocispec.Descriptor {
+	desc := ocispec.Descriptor{
+		MediaType:    d.MediaType,
+		ArtifactType: d.ArtifactType,
+		Digest:       digest.Digest(d.Digest),
+		Size:         d.Size,
+		Annotations:  d.Annotations,
+	}
+	if d.Platform != nil {
+		desc.Platform = &ocispec.Platform{
+			Architecture: d.Platform.Architecture,
+			OS:           d.Platform.OS,
+			Variant:      d.Platform.Variant,
+		}
+	}
+	return desc
+}
+
+// fromOCIDescriptor converts an image-spec descriptor into a Descriptor.
+func fromOCIDescriptor(d ocispec.Descriptor) *Descriptor {
+	desc := &Descriptor{
+		MediaType:    d.MediaType,
+		ArtifactType: d.ArtifactType,
+		Digest:       d.Digest.String(),
+		Size:         d.Size,
+		Annotations:  d.Annotations,
+	}
+	if d.Platform != nil {
+		desc.Platform = &Platform{
+			Architecture: d.Platform.Architecture,
+			OS:           d.Platform.OS,
+			Variant:      d.Platform.Variant,
+		}
+	}
+	return desc
+}
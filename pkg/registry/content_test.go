@@ -0,0 +1,61 @@
+package registry
+
+import "testing"
+
+func TestDescriptorConversionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		desc Descriptor
+	}{
+		{
+			name: "layer with annotations",
+			desc: Descriptor{
+				MediaType:   "application/vnd.in-toto+json",
+				Digest:      "sha256:abc123",
+				Size:        1024,
+				Annotations: map[string]string{"org.opencontainers.image.title": "attestation.json"},
+			},
+		},
+		{
+			name: "platform manifest",
+			desc: Descriptor{
+				MediaType:    "application/vnd.oci.image.manifest.v1+json",
+				ArtifactType: "application/vnd.example+type",
+				Digest:       "sha256:def456",
+				Size:         512,
+				Platform:     &Platform{Architecture: "arm64", OS: "linux", Variant: "v8"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fromOCIDescriptor(toOCIDescriptor(tt.desc))
+
+			if got.MediaType != tt.desc.MediaType || got.ArtifactType != tt.desc.ArtifactType ||
+				got.Digest != tt.desc.Digest || got.Size != tt.desc.Size {
+				t.Errorf("round trip = %+v, want %+v", got, tt.desc)
+			}
+			if len(got.Annotations) != len(tt.desc.Annotations) {
+				t.Errorf("Annotations = %v, want %v", got.Annotations, tt.desc.Annotations)
+			}
+			if (got.Platform == nil) != (tt.desc.Platform == nil) {
+				t.Fatalf("Platform = %v, want %v", got.Platform, tt.desc.Platform)
+			}
+			if got.Platform != nil && *got.Platform != *tt.desc.Platform {
+				t.Errorf("Platform = %+v, want %+v", *got.Platform, *tt.desc.Platform)
+			}
+		})
+	}
+}
+
+func TestFetchBlobRejectsOversized(t *testing.T) {
+	c := &Client{}
+	_, err := c.FetchBlob("localhost:5050/test/app", Descriptor{
+		Digest: "sha256:abc",
+		Size:   MaxBlobFetchSize + 1,
+	})
+	if err == nil {
+		t.Error("FetchBlob() expected error for oversized blob")
+	}
+}
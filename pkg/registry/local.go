@@ -0,0 +1,206 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/local"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+)
+
+// LocalRegistryURL is the pseudo-registry of artifacts already pulled into
+// the artifact directory. Its repositories are the registries and
+// repositories they were pulled from, such as
+// local/docker.io/library/nginx, and its tags are those of the pulled
+// layouts. It is read-only.
+const LocalRegistryURL = "local"
+
+// localRepository returns a read-only repository over the layouts pulled
+// from repoName, which is "<registry>/<repository>".
+func (c *Client) localRepository(ctx context.Context, repoName string) (registry.Repository, error) {
+	layouts, err := local.List(c.config.GetArtifactDir())
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &localRepository{name: repoName}
+	for _, l := range layouts {
+		if l.Repository != repoName {
+			continue
+		}
+		store, err := oci.NewFromFS(ctx, os.DirFS(l.Path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", l.Name, err)
+		}
+		repo.stores = append(repo.stores, store)
+	}
+	if len(repo.stores) == 0 {
+		return nil, fmt.Errorf("no local artifacts from %s", repoName)
+	}
+	return repo, nil
+}
+
+// searchLocal lists the repositories with pulled artifacts whose name
+// contains query.
+func (c *Client) searchLocal(query string) ([]*SearchResult, error) {
+	layouts, err := local.List(c.config.GetArtifactDir())
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var results []*SearchResult
+	for _, l := range layouts {
+		if l.Repository == "" || seen[l.Repository] {
+			continue
+		}
+		if query != "" && !containsIgnoreCase(l.Repository, query) {
+			continue
+		}
+		seen[l.Repository] = true
+		results = append(results, &SearchResult{
+			Name:        l.Repository,
+			RegistryURL: LocalRegistryURL,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results, nil
+}
+
+// localRepository serves the layouts pulled from one repository as a
+// registry.Repository, so the TUI browses them the way it browses a
+// registry. Each pulled tag is its own layout; reads try each in turn.
+type localRepository struct {
+	name   string
+	stores []*oci.ReadOnlyStore
+}
+
+var _ registry.Repository = (*localRepository)(nil)
+
+func (r *localRepository) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	for _, s := range r.stores {
+		if exists, err := s.Exists(ctx, target); err == nil && exists {
+			return s.Fetch(ctx, target)
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", target.Digest, errdef.ErrNotFound)
+}
+
+func (r *localRepository) Exists(ctx context.Context, target ocispec.Descriptor) (bool, error) {
+	for _, s := range r.stores {
+		if exists, err := s.Exists(ctx, target); err == nil && exists {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *localRepository) Resolve(ctx context.Context, reference string) (ocispec.Descriptor, error) {
+	for _, s := range r.stores {
+		if desc, err := s.Resolve(ctx, reference); err == nil {
+			return desc, nil
+		}
+	}
+	return ocispec.Descriptor{}, fmt.Errorf("%s:%s: %w", r.name, reference, errdef.ErrNotFound)
+}
+
+func (r *localRepository) FetchReference(ctx context.Context, reference string) (ocispec.Descriptor, io.ReadCloser, error) {
+	desc, err := r.Resolve(ctx, reference)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	rc, err := r.Fetch(ctx, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	return desc, rc, nil
+}
+
+// Tags lists the tags of every layout, in ascending order.
+func (r *localRepository) Tags(ctx context.Context, last string, fn func(tags []string) error) error {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, s := range r.stores {
+		err := s.Tags(ctx, "", func(page []string) error {
+			for _, tag := range page {
+				if !seen[tag] && (last == "" || tag > last) {
+					seen[tag] = true
+					tags = append(tags, tag)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	sort.Strings(tags)
+	return fn(tags)
+}
+
+// Referrers lists the manifests in the layouts whose subject is desc.
+func (r *localRepository) Referrers(ctx context.Context, desc ocispec.Descriptor, artifactType string, fn func(referrers []ocispec.Descriptor) error) error {
+	seen := make(map[string]bool)
+	var referrers []ocispec.Descriptor
+	for _, s := range r.stores {
+		predecessors, err := s.Predecessors(ctx, desc)
+		if err != nil {
+			return err
+		}
+		for _, p := range predecessors {
+			if seen[p.Digest.String()] {
+				continue
+			}
+			data, err := content.FetchAll(ctx, s, p)
+			if err != nil {
+				continue
+			}
+			var manifest ocispec.Manifest
+			if json.Unmarshal(data, &manifest) != nil || manifest.Subject == nil || manifest.Subject.Digest != desc.Digest {
+				continue
+			}
+			p.ArtifactType = manifest.ArtifactType
+			if p.ArtifactType == "" {
+				p.ArtifactType = manifest.Config.MediaType
+			}
+			p.Annotations = manifest.Annotations
+			if artifactType != "" && p.ArtifactType != artifactType {
+				continue
+			}
+			seen[p.Digest.String()] = true
+			referrers = append(referrers, p)
+		}
+	}
+	return fn(referrers)
+}
+
+func (r *localRepository) Blobs() registry.BlobStore         { return r }
+func (r *localRepository) Manifests() registry.ManifestStore { return r }
+
+// The local registry is read-only: artifacts get there by pulling them.
+
+func (r *localRepository) Push(context.Context, ocispec.Descriptor, io.Reader) error {
+	return errdef.ErrUnsupported
+}
+
+func (r *localRepository) PushReference(context.Context, ocispec.Descriptor, io.Reader, string) error {
+	return errdef.ErrUnsupported
+}
+
+func (r *localRepository) Tag(context.Context, ocispec.Descriptor, string) error {
+	return errdef.ErrUnsupported
+}
+
+func (r *localRepository) Delete(context.Context, ocispec.Descriptor) error {
+	return errdef.ErrUnsupported
+}
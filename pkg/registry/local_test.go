@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/config"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/oci"
+)
+
+// pushLocal stores a manifest with one layer in the layout at dir, tagged
+// tag unless tag is empty, and returns its descriptor.
+func pushLocal(t *testing.T, dir, tag, layer string, subject *ocispec.Descriptor) ocispec.Descriptor {
+	t.Helper()
+	ctx := context.Background()
+	store, err := oci.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := func(mediaType string, data []byte) ocispec.Descriptor {
+		desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+		if exists, _ := store.Exists(ctx, desc); !exists {
+			if err := store.Push(ctx, desc, bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return desc
+	}
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    push(ocispec.MediaTypeImageConfig, []byte(`{"architecture":"amd64","os":"linux"}`)),
+		Layers:    []ocispec.Descriptor{push(ocispec.MediaTypeImageLayer, []byte(layer))},
+		Subject:   subject,
+	}
+	if subject != nil {
+		manifest.ArtifactType = "application/spdx+json"
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := push(ocispec.MediaTypeImageManifest, data)
+	if tag != "" {
+		if err := store.Tag(ctx, desc, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return desc
+}
+
+func TestLocalRegistry(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("LAZYOCI_ARTIFACT_DIR", base)
+	nginxDir := filepath.Join(base, "oci", "docker.io", "library", "nginx")
+	stable := pushLocal(t, filepath.Join(nginxDir, "stable"), "stable", "nginx stable", nil)
+	pushLocal(t, filepath.Join(nginxDir, "1.25"), "1.25", "nginx 1.25", nil)
+	sbom := pushLocal(t, filepath.Join(nginxDir, "stable"), "", "sbom", &stable)
+	pushLocal(t, filepath.Join(base, "helm", "ghcr.io", "org", "charts", "app", "1.0.0"), "1.0.0", "chart", nil)
+
+	c := NewClientWithCredentialStore(&config.Config{}, NewChainedStore())
+
+	results, err := c.Search(LocalRegistryURL, "NGINX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "docker.io/library/nginx" || results[0].RegistryURL != LocalRegistryURL {
+		t.Fatalf("Search() = %+v, want the nginx repository once", results)
+	}
+	if all, _ := c.Search(LocalRegistryURL, ""); len(all) != 2 {
+		t.Errorf("Search(\"\") found %d repositories, want 2", len(all))
+	}
+
+	repoPath := LocalRegistryURL + "/docker.io/library/nginx"
+	artifacts, err := c.ListArtifactsWithOptions(repoPath, ListArtifactsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tags []string
+	for _, a := range artifacts {
+		tags = append(tags, a.Tag)
+	}
+	if !reflect.DeepEqual(tags, []string{"1.25", "stable"}) {
+		t.Errorf("tags = %v, want the tags of both layouts", tags)
+	}
+
+	details, err := c.GetArtifactDetails(repoPath, "stable")
+	if err != nil || details.Digest != stable.Digest.String() {
+		t.Fatalf("GetArtifactDetails() = %+v, %v", details, err)
+	}
+	manifest, _, err := c.GetManifest(repoPath, "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := c.FetchBlob(repoPath, manifest.Layers[0])
+	if err != nil || string(layer) != "nginx stable" {
+		t.Errorf("FetchBlob() = %q, %v", layer, err)
+	}
+
+	referrers, err := c.Referrers(repoPath, *fromOCIDescriptor(stable), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(referrers) != 1 || referrers[0].Digest != sbom.Digest.String() || referrers[0].ArtifactType != "application/spdx+json" {
+		t.Errorf("Referrers() = %+v, want the SBOM", referrers)
+	}
+
+	if _, err := c.ListArtifacts(LocalRegistryURL + "/docker.io/library/redis"); err == nil {
+		t.Error("ListArtifacts() of a repository with nothing pulled succeeded")
+	}
+}
@@ -0,0 +1,33 @@
+// Package registrytest provides in-memory registry fakes for tests of
+// packages that read artifact content.
+package registrytest
+
+import (
+	"errors"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+)
+
+// Fetcher is a registry.Fetcher serving a single manifest and its blobs,
+// keyed by digest, from memory. Every reference resolves to Manifest.
+type Fetcher struct {
+	Manifest *registry.Manifest
+	Blobs    map[string][]byte
+}
+
+// GetManifest returns f.Manifest, or an error if it is nil.
+func (f *Fetcher) GetManifest(repoPath, reference string) (*registry.Manifest, *registry.Descriptor, error) {
+	if f.Manifest == nil {
+		return nil, nil, errors.New("manifest not found")
+	}
+	return f.Manifest, &registry.Descriptor{}, nil
+}
+
+// FetchBlob returns the blob with desc's digest.
+func (f *Fetcher) FetchBlob(repoPath string, desc registry.Descriptor) ([]byte, error) {
+	data, ok := f.Blobs[desc.Digest]
+	if !ok {
+		return nil, errors.New("blob not found")
+	}
+	return data, nil
+}
@@ -56,6 +56,9 @@ func (c *Client) Search(registryURL, query string) ([]*SearchResult, error) {
 	var err error
 
 	switch registryURL {
+	case LocalRegistryURL:
+		// Always read from disk, so pulls show up at once
+		return c.searchLocal(query)
 	case "docker.io":
 		results, err = c.searchDockerHub(query)
 	case "quay.io":
@@ -130,29 +130,34 @@ type Layer struct {
 	MediaType string
 }
 
-// Manifest represents an OCI manifest
+// Manifest represents an OCI manifest.
+// For image indexes, Manifests lists the child manifests instead of Layers.
 type Manifest struct {
-	SchemaVersion int
-	MediaType     string
-	Config        Descriptor
-	Layers        []Descriptor
-	Annotations   map[string]string
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers,omitempty"`
+	Manifests     []Descriptor      `json:"manifests,omitempty"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
 }
 
 // Descriptor represents an OCI content descriptor
 type Descriptor struct {
-	MediaType   string
-	Digest      string
-	Size        int64
-	Annotations map[string]string
-	Platform    *Platform
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Platform     *Platform         `json:"platform,omitempty"`
 }
 
 // Platform represents a platform specification
 type Platform struct {
-	Architecture string
-	OS           string
-	Variant      string
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
 }
 
 // String returns a string representation of the platform
@@ -0,0 +1,262 @@
+package sbom
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// maxBinarySize bounds the executables read for Go build info.
+const maxBinarySize = 512 << 20
+
+// Package databases read by the Cataloger, by path in the image.
+const (
+	dpkgStatus    = "var/lib/dpkg/status"
+	dpkgStatusDir = "var/lib/dpkg/status.d"
+	apkInstalled  = "lib/apk/db/installed"
+)
+
+// rpmDatabases are the SQLite rpm databases of current distributions.
+var rpmDatabases = []string{
+	"var/lib/rpm/rpmdb.sqlite",
+	"usr/lib/sysimage/rpm/rpmdb.sqlite",
+}
+
+// legacyRPMDatabases are Berkeley DB and NDB rpm databases, which are
+// not read.
+var legacyRPMDatabases = []string{
+	"var/lib/rpm/Packages",
+	"var/lib/rpm/Packages.db",
+	"usr/lib/sysimage/rpm/Packages.db",
+}
+
+// osReleaseFiles identify the distribution, in order of preference.
+var osReleaseFiles = []string{"etc/os-release", "usr/lib/os-release"}
+
+// Cataloger lists the packages installed in a container image, read one
+// layer at a time from the bottom up: OS packages from the dpkg, apk and
+// rpm (SQLite) databases, and Go modules from the build info of Go
+// binaries. Whiteouts in upper layers remove what lower layers added.
+type Cataloger struct {
+	files    map[string][]byte    // package databases and os-release
+	binaries map[string][]Package // Go modules, by binary path
+}
+
+// NewCataloger returns a Cataloger for an empty filesystem.
+func NewCataloger() *Cataloger {
+	return &Cataloger{
+		files:    map[string][]byte{},
+		binaries: map[string][]Package{},
+	}
+}
+
+// AddLayer reads an uncompressed layer tar on top of the layers added so far.
+func (c *Cataloger) AddLayer(r io.Reader) error {
+	files := map[string][]byte{}
+	binaries := map[string][]Package{}
+	var removed []string // files and directories hidden from lower layers
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read layer: %w", err)
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		dir, base := path.Split(name)
+		if base == ".wh..wh..opq" {
+			removed = append(removed, strings.TrimSuffix(dir, "/"))
+			continue
+		}
+		if hidden, ok := strings.CutPrefix(base, ".wh."); ok {
+			removed = append(removed, dir+hidden)
+			continue
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		removed = append(removed, name) // replaced, whatever it is now
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		switch {
+		case isPackageDatabase(name):
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			files[name] = data
+		case hdr.Mode&0o111 != 0 && hdr.Size > 4 && hdr.Size <= maxBinarySize:
+			br := bufio.NewReader(tr)
+			magic, err := br.Peek(4)
+			if err != nil || string(magic) != "\x7fELF" {
+				continue
+			}
+			data, err := io.ReadAll(br)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			if info, err := buildinfo.Read(bytes.NewReader(data)); err == nil {
+				binaries[name] = goModules(info)
+			}
+		}
+	}
+
+	for _, p := range removed {
+		for name := range c.files {
+			if within(name, p) {
+				delete(c.files, name)
+			}
+		}
+		for name := range c.binaries {
+			if within(name, p) {
+				delete(c.binaries, name)
+			}
+		}
+	}
+	for name, data := range files {
+		c.files[name] = data
+	}
+	for name, modules := range binaries {
+		c.binaries[name] = modules
+	}
+	return nil
+}
+
+// Packages returns the packages found, sorted by package URL and without
+// duplicates. Packages from readable databases are returned even when err
+// reports a database that could not be read.
+func (c *Cataloger) Packages() ([]Package, error) {
+	distro := c.distro()
+	var pkgs []Package
+	var errs []error
+
+	if data, ok := c.files[dpkgStatus]; ok {
+		pkgs = append(pkgs, parseDpkgStatus(data, distro)...)
+	}
+	for name, data := range c.files {
+		if path.Dir(name) == dpkgStatusDir {
+			pkgs = append(pkgs, parseDpkgStatus(data, distro)...)
+		}
+	}
+	if data, ok := c.files[apkInstalled]; ok {
+		pkgs = append(pkgs, parseApkInstalled(data, distro)...)
+	}
+	for _, name := range rpmDatabases {
+		if data, ok := c.files[name]; ok {
+			rpms, err := parseRPMDB(data, distro)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			pkgs = append(pkgs, rpms...)
+		}
+	}
+	for _, name := range legacyRPMDatabases {
+		if _, ok := c.files[name]; ok {
+			errs = append(errs, fmt.Errorf("%s: only SQLite rpm databases are supported", name))
+		}
+	}
+	for _, modules := range c.binaries {
+		pkgs = append(pkgs, modules...)
+	}
+
+	return Unique(pkgs), errors.Join(errs...)
+}
+
+// Unique sorts packages by package URL and name and drops duplicates, as
+// when several layers or platforms install the same package.
+func Unique(pkgs []Package) []Package {
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgs[i].PURL != pkgs[j].PURL {
+			return pkgs[i].PURL < pkgs[j].PURL
+		}
+		return pkgs[i].Name < pkgs[j].Name
+	})
+	var unique []Package
+	for i, p := range pkgs {
+		if i > 0 && p.PURL == pkgs[i-1].PURL && p.Name == pkgs[i-1].Name && p.Version == pkgs[i-1].Version {
+			continue
+		}
+		unique = append(unique, p)
+	}
+	return unique
+}
+
+// isPackageDatabase reports whether the Cataloger keeps the file at name.
+func isPackageDatabase(name string) bool {
+	switch {
+	case name == dpkgStatus, name == apkInstalled:
+		return true
+	case path.Dir(name) == dpkgStatusDir:
+		return !strings.HasSuffix(name, ".md5sums")
+	}
+	for _, list := range [][]string{rpmDatabases, legacyRPMDatabases, osReleaseFiles} {
+		for _, db := range list {
+			if name == db {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// within reports whether name is p or lies under the directory p.
+func within(name, p string) bool {
+	return p == "" || name == p || strings.HasPrefix(name, p+"/")
+}
+
+// goModules returns the main module, dependencies and standard library of
+// a Go binary as packages. Replaced modules are listed as their
+// replacement.
+func goModules(info *buildinfo.BuildInfo) []Package {
+	var pkgs []Package
+	add := func(m *debug.Module) {
+		if m == nil || m.Path == "" {
+			return
+		}
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		version := m.Version
+		if version == "(devel)" {
+			version = ""
+		}
+		pkgs = append(pkgs, Package{
+			Name:    m.Path,
+			Version: version,
+			PURL:    goPURL(m.Path, version),
+		})
+	}
+
+	add(&info.Main)
+	for _, dep := range info.Deps {
+		add(dep)
+	}
+	if v, ok := strings.CutPrefix(info.GoVersion, "go"); ok {
+		pkgs = append(pkgs, Package{
+			Name:    "stdlib",
+			Version: info.GoVersion,
+			PURL:    goPURL("stdlib", v),
+		})
+	}
+	return pkgs
+}
+
+// goPURL returns the package URL of a Go module.
+func goPURL(module, version string) string {
+	namespace, name := path.Split(module)
+	return PURL("golang", strings.TrimSuffix(namespace, "/"), name, version, nil)
+}
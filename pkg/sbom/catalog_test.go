@@ -0,0 +1,192 @@
+package sbom
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// layer is a layer tar of regular files, keyed by path, with mode 0644 or,
+// for names ending in "*", 0755.
+func layer(t *testing.T, files map[string][]byte) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, data := range files {
+		mode := int64(0o644)
+		if n, ok := strings.CutSuffix(name, "*"); ok {
+			name, mode = n, 0o755
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: mode, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func findPackage(pkgs []Package, name string) *Package {
+	for i := range pkgs {
+		if pkgs[i].Name == name {
+			return &pkgs[i]
+		}
+	}
+	return nil
+}
+
+func TestCatalogerOSPackages(t *testing.T) {
+	dpkg := `Package: libc6
+Status: install ok installed
+Architecture: amd64
+Version: 2.36-9+deb12u4
+Description: GNU C Library
+ continuation line: ignored
+
+Package: removed
+Status: deinstall ok config-files
+Version: 1.0
+`
+	c := NewCataloger()
+	if err := c.AddLayer(layer(t, map[string][]byte{
+		"etc/os-release":               []byte("ID=debian\nVERSION_ID=\"12\"\n"),
+		"var/lib/dpkg/status":          []byte(dpkg),
+		"./lib/apk/db/installed":       []byte("P:busybox\nV:1.36.1-r15\nA:x86_64\nL:GPL-2.0-only\n\n"),
+		"var/lib/dpkg/status.d/tzdata": []byte("Package: tzdata\nVersion: 2024a-0+deb12u1\nArchitecture: all\n"),
+	})); err != nil {
+		t.Fatal(err)
+	}
+	// The upper layer deletes the apk database
+	if err := c.AddLayer(layer(t, map[string][]byte{"lib/apk/db/.wh.installed": nil})); err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := c.Packages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("Packages() = %+v, want libc6 and tzdata", pkgs)
+	}
+	if p := findPackage(pkgs, "libc6"); p == nil || p.PURL != "pkg:deb/debian/libc6@2.36-9+deb12u4?arch=amd64&distro=debian-12" {
+		t.Errorf("libc6 = %+v", p)
+	}
+	if p := findPackage(pkgs, "tzdata"); p == nil || p.Version != "2024a-0+deb12u1" {
+		t.Errorf("tzdata = %+v", p)
+	}
+}
+
+func TestCatalogerApk(t *testing.T) {
+	c := NewCataloger()
+	if err := c.AddLayer(layer(t, map[string][]byte{
+		"etc/os-release":       []byte("ID=alpine\nVERSION_ID=3.19.1\n"),
+		"lib/apk/db/installed": []byte("C:Q1abc=\nP:musl\nV:1.2.4_git20230717-r4\nA:aarch64\nL:MIT\n\nP:zlib\nV:1.3.1-r0\nA:aarch64\nL:Zlib\n"),
+	})); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := c.Packages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := findPackage(pkgs, "musl")
+	if p == nil || p.PURL != "pkg:apk/alpine/musl@1.2.4_git20230717-r4?arch=aarch64&distro=alpine-3.19.1" ||
+		len(p.Licenses) != 1 || p.Licenses[0] != "MIT" {
+		t.Errorf("musl = %+v", p)
+	}
+	if len(pkgs) != 2 {
+		t.Errorf("got %d packages, want 2", len(pkgs))
+	}
+}
+
+func TestCatalogerRPM(t *testing.T) {
+	c := NewCataloger()
+	if err := c.AddLayer(layer(t, map[string][]byte{
+		"etc/os-release":           []byte("ID=\"rhel\"\nVERSION_ID=\"9.3\"\n"),
+		"var/lib/rpm/rpmdb.sqlite": readFixture(t, "rpmdb.sqlite"),
+	})); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := c.Packages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 40 filler packages and openssl-libs, whose header overflows its page;
+	// gpg-pubkey entries are not packages
+	if len(pkgs) != 41 {
+		t.Errorf("got %d packages, want 41", len(pkgs))
+	}
+	if findPackage(pkgs, "gpg-pubkey") != nil {
+		t.Error("gpg-pubkey listed as a package")
+	}
+	p := findPackage(pkgs, "openssl-libs")
+	if p == nil || p.Version != "1:3.0.7-27.el9" ||
+		p.PURL != "pkg:rpm/rhel/openssl-libs@3.0.7-27.el9?arch=x86_64&distro=rhel-9.3&epoch=1" {
+		t.Errorf("openssl-libs = %+v", p)
+	}
+	if p := findPackage(pkgs, "pkg39"); p == nil || p.Version != "1.39-1.el9" || len(p.Licenses) != 1 {
+		t.Errorf("pkg39 = %+v", p)
+	}
+}
+
+func TestCatalogerLegacyRPM(t *testing.T) {
+	c := NewCataloger()
+	if err := c.AddLayer(layer(t, map[string][]byte{"var/lib/rpm/Packages": []byte("bdb")})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Packages(); err == nil || !strings.Contains(err.Error(), "only SQLite") {
+		t.Errorf("Packages() error = %v, want unsupported database", err)
+	}
+}
+
+func TestCatalogerGoBinary(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("\x7fELF")) {
+		t.Skip("test binary is not ELF")
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		t.Skip("no build info")
+	}
+
+	c := NewCataloger()
+	if err := c.AddLayer(layer(t, map[string][]byte{
+		"usr/bin/app*":  data,
+		"usr/share/app": data, // not executable
+	})); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := c.Packages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdlib := findPackage(pkgs, "stdlib")
+	if stdlib == nil || stdlib.Version != info.GoVersion || !strings.HasPrefix(stdlib.PURL, "pkg:golang/stdlib@") {
+		t.Errorf("stdlib = %+v", stdlib)
+	}
+	if p := findPackage(pkgs, info.Main.Path); p == nil || !strings.HasPrefix(p.PURL, "pkg:golang/") {
+		t.Errorf("main module %s = %+v", info.Main.Path, p)
+	}
+
+	// Replacing the binary with a non-binary drops its modules
+	if err := c.AddLayer(layer(t, map[string][]byte{"usr/bin/app": []byte("#!/bin/sh\n")})); err != nil {
+		t.Fatal(err)
+	}
+	if pkgs, _ := c.Packages(); len(pkgs) != 0 {
+		t.Errorf("Packages() after replacing the binary = %+v", pkgs)
+	}
+}
@@ -0,0 +1,91 @@
+package sbom
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+)
+
+// annotationReferenceType marks the attestation manifests buildx adds to
+// an image index.
+const annotationReferenceType = "vnd.docker.reference.type"
+
+// Discover locates the SBOM describing the image at reference. It tries, in
+// order: reference itself being an SBOM, buildx attestation manifests in an
+// index, OCI referrers (when f also implements ReferrerLister), and the
+// cosign "sha256-<hex>.sbom" and ".att" tags. source describes where the
+// SBOM was found, e.g. "referrer sha256:abc…".
+func Discover(f registry.Fetcher, repoPath, reference string) (doc *Document, source string, err error) {
+	manifest, desc, err := f.GetManifest(repoPath, reference)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(manifest.Manifests) == 0 && hasSBOMLayer(manifest) {
+		if doc, err := Fetch(f, repoPath, reference); err == nil {
+			return doc, reference, nil
+		}
+	}
+
+	for _, m := range manifest.Manifests {
+		if m.Annotations[annotationReferenceType] != "attestation-manifest" {
+			continue
+		}
+		if doc, err := Fetch(f, repoPath, m.Digest); err == nil {
+			return doc, "attestation manifest " + m.Digest, nil
+		}
+	}
+
+	if desc == nil || desc.Digest == "" {
+		return nil, "", fmt.Errorf("no SBOM found for %s", reference)
+	}
+
+	if rl, ok := f.(registry.ReferrerLister); ok {
+		// Listing errors are not fatal: many registries lack the API and
+		// the tag fallback below may still succeed.
+		if referrers, err := rl.Referrers(repoPath, *desc, ""); err == nil {
+			for _, r := range referrers {
+				if !isSBOMArtifactType(r.ArtifactType) {
+					continue
+				}
+				if doc, err := Fetch(f, repoPath, r.Digest); err == nil {
+					return doc, "referrer " + r.Digest, nil
+				}
+			}
+		}
+	}
+
+	if hex, ok := strings.CutPrefix(desc.Digest, "sha256:"); ok {
+		for _, suffix := range []string{".sbom", ".att"} {
+			tag := "sha256-" + hex + suffix
+			if doc, err := Fetch(f, repoPath, tag); err == nil {
+				return doc, "tag " + tag, nil
+			}
+		}
+	}
+
+	return nil, "", fmt.Errorf("no SBOM found for %s", reference)
+}
+
+// hasSBOMLayer reports whether any layer of manifest may carry an SBOM.
+// Image layers (tar archives) are excluded so plain images are not fetched.
+func hasSBOMLayer(manifest *registry.Manifest) bool {
+	for _, layer := range manifest.Layers {
+		if isSBOMLayer(layer.MediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSBOMArtifactType reports whether a referrer's artifact type may be an
+// SBOM or an attestation wrapping one.
+func isSBOMArtifactType(artifactType string) bool {
+	at := strings.ToLower(artifactType)
+	return strings.Contains(at, "spdx") ||
+		strings.Contains(at, "cyclonedx") ||
+		strings.Contains(at, "sbom") ||
+		strings.Contains(at, "in-toto") ||
+		strings.Contains(at, "dsse")
+}
@@ -0,0 +1,257 @@
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Media types of generated SBOM documents, also used as the artifactType of
+// the referrers that carry them.
+const (
+	MediaTypeSPDX      = "application/spdx+json"
+	MediaTypeCycloneDX = "application/vnd.cyclonedx+json"
+)
+
+// ToolName is recorded as the creator of generated documents that name no
+// tools.
+const ToolName = "lazyoci"
+
+// MediaType returns the media type of documents in format f, or "" for an
+// unknown format.
+func (f Format) MediaType() string {
+	switch f {
+	case FormatSPDX:
+		return MediaTypeSPDX
+	case FormatCycloneDX:
+		return MediaTypeCycloneDX
+	}
+	return ""
+}
+
+// Encode writes doc as an SPDX 2.3 or CycloneDX 1.5 JSON document,
+// according to doc.Format. The document namespace and serial number are
+// derived from the content, so the same document always encodes to the same
+// bytes; Created should be set by the caller.
+func Encode(doc *Document) ([]byte, error) {
+	// Everything but the identifiers goes into the content hash
+	id, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(id)
+
+	switch doc.Format {
+	case FormatSPDX:
+		return encodeSPDX(doc, hex.EncodeToString(sum[:]))
+	case FormatCycloneDX:
+		return encodeCycloneDX(doc, sum)
+	}
+	return nil, fmt.Errorf("unsupported SBOM format %q (must be spdx or cyclonedx)", doc.Format)
+}
+
+func encodeSPDX(doc *Document, id string) ([]byte, error) {
+	type checksum struct {
+		Algorithm     string `json:"algorithm"`
+		ChecksumValue string `json:"checksumValue"`
+	}
+	type externalRef struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	}
+	type pkg struct {
+		Name             string        `json:"name"`
+		SPDXID           string        `json:"SPDXID"`
+		VersionInfo      string        `json:"versionInfo,omitempty"`
+		DownloadLocation string        `json:"downloadLocation"`
+		FilesAnalyzed    bool          `json:"filesAnalyzed"`
+		LicenseConcluded string        `json:"licenseConcluded"`
+		LicenseDeclared  string        `json:"licenseDeclared"`
+		CopyrightText    string        `json:"copyrightText"`
+		Checksums        []checksum    `json:"checksums,omitempty"`
+		ExternalRefs     []externalRef `json:"externalRefs,omitempty"`
+	}
+	type relationship struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+	}
+
+	name := doc.Name
+	if name == "" {
+		name = "sbom"
+	}
+	out := struct {
+		SPDXVersion       string `json:"spdxVersion"`
+		DataLicense       string `json:"dataLicense"`
+		SPDXID            string `json:"SPDXID"`
+		Name              string `json:"name"`
+		DocumentNamespace string `json:"documentNamespace"`
+		CreationInfo      struct {
+			Created  string   `json:"created"`
+			Creators []string `json:"creators"`
+		} `json:"creationInfo"`
+		Packages      []pkg          `json:"packages"`
+		Relationships []relationship `json:"relationships,omitempty"`
+	}{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: "https://lazyoci.dev/spdxdocs/" + url.PathEscape(name) + "-" + id,
+		Packages:          []pkg{},
+	}
+	out.CreationInfo.Created = doc.Created
+	for _, t := range tools(doc) {
+		out.CreationInfo.Creators = append(out.CreationInfo.Creators, "Tool: "+t)
+	}
+
+	for i, p := range doc.Packages {
+		sp := pkg{
+			Name:             p.Name,
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i+1),
+			VersionInfo:      p.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+		}
+		if len(p.Licenses) > 0 {
+			sp.LicenseDeclared = strings.Join(p.Licenses, " AND ")
+		}
+		if hexDigest, ok := strings.CutPrefix(p.Digest, "sha256:"); ok {
+			sp.Checksums = []checksum{{Algorithm: "SHA256", ChecksumValue: hexDigest}}
+		}
+		if p.PURL != "" {
+			sp.ExternalRefs = []externalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: p.PURL}}
+		}
+		out.Packages = append(out.Packages, sp)
+		out.Relationships = append(out.Relationships, relationship{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: sp.SPDXID,
+		})
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+func encodeCycloneDX(doc *Document, sum [32]byte) ([]byte, error) {
+	type hash struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	}
+	type license struct {
+		Expression string `json:"expression"`
+	}
+	type component struct {
+		Type     string    `json:"type"`
+		BOMRef   string    `json:"bom-ref,omitempty"`
+		Name     string    `json:"name"`
+		Version  string    `json:"version,omitempty"`
+		PURL     string    `json:"purl,omitempty"`
+		Licenses []license `json:"licenses,omitempty"`
+		Hashes   []hash    `json:"hashes,omitempty"`
+	}
+
+	// A version 4 UUID from the content hash
+	sum[6] = sum[6]&0x0f | 0x40
+	sum[8] = sum[8]&0x3f | 0x80
+	uuid := fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+
+	out := struct {
+		BOMFormat    string `json:"bomFormat"`
+		SpecVersion  string `json:"specVersion"`
+		SerialNumber string `json:"serialNumber"`
+		Version      int    `json:"version"`
+		Metadata     struct {
+			Timestamp string `json:"timestamp,omitempty"`
+			Tools     struct {
+				Components []component `json:"components"`
+			} `json:"tools"`
+			Component *component `json:"component,omitempty"`
+		} `json:"metadata"`
+		Components []component `json:"components"`
+	}{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid,
+		Version:      1,
+		Components:   []component{},
+	}
+	out.Metadata.Timestamp = doc.Created
+	out.Metadata.Tools.Components = []component{}
+	for _, t := range tools(doc) {
+		out.Metadata.Tools.Components = append(out.Metadata.Tools.Components, component{Type: "application", Name: t})
+	}
+	if doc.Name != "" {
+		out.Metadata.Component = &component{Type: "container", Name: doc.Name}
+	}
+
+	for i, p := range doc.Packages {
+		c := component{
+			Type:    "library",
+			BOMRef:  fmt.Sprintf("pkg-%d", i+1),
+			Name:    p.Name,
+			Version: p.Version,
+			PURL:    p.PURL,
+		}
+		if p.PURL == "" {
+			c.Type = "file"
+		}
+		for _, l := range p.Licenses {
+			c.Licenses = append(c.Licenses, license{Expression: l})
+		}
+		if hexDigest, ok := strings.CutPrefix(p.Digest, "sha256:"); ok {
+			c.Hashes = []hash{{Alg: "SHA-256", Content: hexDigest}}
+		}
+		out.Components = append(out.Components, c)
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// tools returns the document's tools, or lazyoci when it records none.
+func tools(doc *Document) []string {
+	if len(doc.Tools) == 0 {
+		return []string{ToolName}
+	}
+	return doc.Tools
+}
+
+// PURL builds a package URL from its components. Qualifiers with empty
+// values are left out; they are written in sorted order.
+func PURL(typ, namespace, name, version string, qualifiers map[string]string) string {
+	var sb strings.Builder
+	sb.WriteString("pkg:" + typ + "/")
+	if namespace != "" {
+		for _, seg := range strings.Split(namespace, "/") {
+			sb.WriteString(url.PathEscape(seg) + "/")
+		}
+	}
+	sb.WriteString(url.PathEscape(name))
+	if version != "" {
+		sb.WriteString("@" + url.PathEscape(version))
+	}
+	var keys []string
+	for k, v := range qualifiers {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		for i, k := range keys {
+			if i == 0 {
+				sb.WriteString("?")
+			} else {
+				sb.WriteString("&")
+			}
+			sb.WriteString(k + "=" + url.QueryEscape(qualifiers[k]))
+		}
+	}
+	return sb.String()
+}
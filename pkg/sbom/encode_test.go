@@ -0,0 +1,72 @@
+package sbom
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	packages := []Package{
+		{Name: "libc6", Version: "2.36-9", PURL: "pkg:deb/debian/libc6@2.36-9?arch=amd64"},
+		{Name: "musl", Version: "1.2.4-r4", PURL: "pkg:apk/alpine/musl@1.2.4-r4", Licenses: []string{"MIT"}},
+		{Name: "config.yaml", Digest: "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+	}
+
+	for _, format := range []Format{FormatSPDX, FormatCycloneDX} {
+		t.Run(string(format), func(t *testing.T) {
+			doc := &Document{
+				Format:   format,
+				Name:     "myapp",
+				Created:  "1970-01-01T00:00:00Z",
+				Packages: packages,
+			}
+			data, err := Encode(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := Encode(doc)
+			if err != nil || !bytes.Equal(data, again) {
+				t.Error("Encode() is not deterministic")
+			}
+
+			got, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse(Encode()) error = %v", err)
+			}
+			if got.Format != format || got.Name != "myapp" || got.Created != doc.Created {
+				t.Errorf("document = %+v", got)
+			}
+			if !reflect.DeepEqual(got.Tools, []string{ToolName}) {
+				t.Errorf("Tools = %v", got.Tools)
+			}
+			if !reflect.DeepEqual(got.Packages, packages) {
+				t.Errorf("Packages = %+v\nwant %+v", got.Packages, packages)
+			}
+		})
+	}
+}
+
+func TestEncodeUnknownFormat(t *testing.T) {
+	if _, err := Encode(&Document{Format: "swid"}); err == nil {
+		t.Error("Encode() accepted an unknown format")
+	}
+}
+
+func TestPURL(t *testing.T) {
+	tests := []struct {
+		typ, namespace, name, version string
+		qualifiers                    map[string]string
+		want                          string
+	}{
+		{"golang", "github.com/spf13", "cobra", "v1.10.2", nil, "pkg:golang/github.com/spf13/cobra@v1.10.2"},
+		{"golang", "", "stdlib", "1.25.5", nil, "pkg:golang/stdlib@1.25.5"},
+		{"deb", "debian", "libc6", "2.36-9", map[string]string{"distro": "debian-12", "arch": "amd64", "epoch": ""}, "pkg:deb/debian/libc6@2.36-9?arch=amd64&distro=debian-12"},
+		{"rpm", "fedora", "curl", "", nil, "pkg:rpm/fedora/curl"},
+	}
+	for _, tt := range tests {
+		if got := PURL(tt.typ, tt.namespace, tt.name, tt.version, tt.qualifiers); got != tt.want {
+			t.Errorf("PURL() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package sbom
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+)
+
+// Fetch loads the SBOM stored in the manifest at reference. The first
+// JSON layer that decodes as an SBOM is returned.
+func Fetch(f registry.Fetcher, repoPath, reference string) (*Document, error) {
+	manifest, _, err := f.GetManifest(repoPath, reference)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Manifests) > 0 {
+		return nil, fmt.Errorf("%s is an index, not an SBOM manifest", reference)
+	}
+
+	var lastErr error
+	for _, layer := range manifest.Layers {
+		if !isSBOMLayer(layer.MediaType) {
+			continue
+		}
+		data, err := f.FetchBlob(repoPath, layer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		doc, err := Parse(data)
+		if err != nil {
+			lastErr = fmt.Errorf("layer %s: %w", layer.Digest, err)
+			continue
+		}
+		return doc, nil
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("no SBOM layers found in %s", reference)
+}
+
+// isSBOMLayer reports whether a layer media type may carry a JSON SBOM.
+func isSBOMLayer(mediaType string) bool {
+	mt := strings.ToLower(mediaType)
+	return strings.Contains(mt, "spdx") ||
+		strings.Contains(mt, "cyclonedx") ||
+		strings.Contains(mt, "in-toto") ||
+		strings.Contains(mt, "dsse") ||
+		strings.HasSuffix(mt, "json")
+}
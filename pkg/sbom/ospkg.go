@@ -0,0 +1,134 @@
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// distro identifies the distribution of an image from its os-release file.
+type distro struct {
+	ID        string // e.g. "debian"
+	VersionID string // e.g. "12"
+}
+
+// qualifier returns the purl "distro" qualifier, e.g. "debian-12".
+func (d distro) qualifier() string {
+	if d.ID == "" || d.VersionID == "" {
+		return d.ID
+	}
+	return d.ID + "-" + d.VersionID
+}
+
+// distro parses the image's os-release file. An image without one has an
+// empty distro.
+func (c *Cataloger) distro() distro {
+	for _, name := range osReleaseFiles {
+		data, ok := c.files[name]
+		if !ok {
+			continue
+		}
+		var d distro
+		for _, line := range strings.Split(string(data), "\n") {
+			key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if !ok {
+				continue
+			}
+			value = strings.Trim(value, `"'`)
+			switch key {
+			case "ID":
+				d.ID = value
+			case "VERSION_ID":
+				d.VersionID = value
+			}
+		}
+		return d
+	}
+	return distro{}
+}
+
+// parseStanzas splits a dpkg status or apk installed database into records
+// of "key: value" (dpkg) or "k:value" (apk) fields separated by blank
+// lines. Continuation lines, which start with a space, are dropped.
+func parseStanzas(data []byte) []map[string]string {
+	var records []map[string]string
+	record := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(record) > 0 {
+				records = append(records, record)
+				record = map[string]string{}
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			record[key] = strings.TrimSpace(value)
+		}
+	}
+	if len(record) > 0 {
+		records = append(records, record)
+	}
+	return records
+}
+
+// parseDpkgStatus lists the installed packages of a dpkg status file, or of
+// a distroless status.d file, which has no Status field.
+func parseDpkgStatus(data []byte, d distro) []Package {
+	var pkgs []Package
+	for _, r := range parseStanzas(data) {
+		name := r["Package"]
+		if name == "" {
+			continue
+		}
+		if status, ok := r["Status"]; ok && !strings.HasSuffix(status, " installed") {
+			continue
+		}
+		namespace := d.ID
+		if namespace == "" {
+			namespace = "debian"
+		}
+		pkgs = append(pkgs, Package{
+			Name:    name,
+			Version: r["Version"],
+			PURL: PURL("deb", namespace, name, r["Version"], map[string]string{
+				"arch":   r["Architecture"],
+				"distro": d.qualifier(),
+			}),
+		})
+	}
+	return pkgs
+}
+
+// parseApkInstalled lists the packages of an apk installed database.
+func parseApkInstalled(data []byte, d distro) []Package {
+	var pkgs []Package
+	for _, r := range parseStanzas(data) {
+		name := r["P"]
+		if name == "" {
+			continue
+		}
+		namespace := d.ID
+		if namespace == "" {
+			namespace = "alpine"
+		}
+		p := Package{
+			Name:    name,
+			Version: r["V"],
+			PURL: PURL("apk", namespace, name, r["V"], map[string]string{
+				"arch":   r["A"],
+				"distro": d.qualifier(),
+			}),
+		}
+		if l := r["L"]; l != "" {
+			p.Licenses = []string{l}
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs
+}
@@ -0,0 +1,422 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// parseRPMDB lists the packages of an rpm SQLite database (rpmdb.sqlite):
+// each row of its Packages table is an rpm header blob.
+func parseRPMDB(data []byte, d distro) ([]Package, error) {
+	db, err := openSQLite(data)
+	if err != nil {
+		return nil, err
+	}
+	root, err := db.tableRoot("Packages")
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := d.ID
+	if namespace == "" {
+		namespace = "rpm"
+	}
+	var pkgs []Package
+	err = db.scan(root, func(record []any) error {
+		if len(record) < 2 {
+			return fmt.Errorf("unexpected Packages row with %d columns", len(record))
+		}
+		blob, ok := record[1].([]byte)
+		if !ok {
+			return fmt.Errorf("Packages row has no header blob")
+		}
+		h, err := parseRPMHeader(blob)
+		if err != nil {
+			return err
+		}
+		if h.name == "" || h.name == "gpg-pubkey" {
+			return nil
+		}
+
+		version := h.version
+		if h.release != "" {
+			version += "-" + h.release
+		}
+		qualifiers := map[string]string{
+			"arch":   h.arch,
+			"distro": d.qualifier(),
+		}
+		full := version
+		if h.epoch != "" {
+			full = h.epoch + ":" + version
+			qualifiers["epoch"] = h.epoch
+		}
+		p := Package{
+			Name:    h.name,
+			Version: full,
+			PURL:    PURL("rpm", namespace, h.name, version, qualifiers),
+		}
+		if h.license != "" {
+			p.Licenses = []string{h.license}
+		}
+		pkgs = append(pkgs, p)
+		return nil
+	})
+	return pkgs, err
+}
+
+// rpm header tags and types read by parseRPMHeader.
+const (
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+	rpmTagLicense = 1014
+	rpmTagArch    = 1022
+
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeI18NString  = 9
+	rpmHeaderEntrySize = 16
+)
+
+// rpmHeader holds the fields of an rpm header that identify a package.
+type rpmHeader struct {
+	name, version, release, epoch, license, arch string
+}
+
+// parseRPMHeader decodes a header blob as stored in the rpm database: the
+// index length and data length, the index entries, then the data store.
+func parseRPMHeader(blob []byte) (*rpmHeader, error) {
+	if len(blob) < 8 {
+		return nil, fmt.Errorf("rpm header too short")
+	}
+	il := binary.BigEndian.Uint32(blob[0:4])
+	dl := binary.BigEndian.Uint32(blob[4:8])
+	if uint64(il)*rpmHeaderEntrySize+uint64(dl)+8 > uint64(len(blob)) {
+		return nil, fmt.Errorf("rpm header is truncated")
+	}
+	start := 8 + int(il)*rpmHeaderEntrySize
+	store := blob[start : start+int(dl)]
+
+	h := &rpmHeader{}
+	for i := 0; i < int(il); i++ {
+		e := blob[8+i*rpmHeaderEntrySize:]
+		tag := binary.BigEndian.Uint32(e[0:4])
+		typ := binary.BigEndian.Uint32(e[4:8])
+		off := int(binary.BigEndian.Uint32(e[8:12]))
+		if off < 0 || off >= len(store) {
+			continue
+		}
+
+		var value string
+		switch typ {
+		case rpmTypeString, rpmTypeI18NString:
+			// An I18N string's first entry is the untranslated one
+			end := bytes.IndexByte(store[off:], 0)
+			if end < 0 {
+				continue
+			}
+			value = string(store[off : off+end])
+		case rpmTypeInt32:
+			if off+4 > len(store) {
+				continue
+			}
+			value = strconv.FormatUint(uint64(binary.BigEndian.Uint32(store[off:])), 10)
+		default:
+			continue
+		}
+
+		switch tag {
+		case rpmTagName:
+			h.name = value
+		case rpmTagVersion:
+			h.version = value
+		case rpmTagRelease:
+			h.release = value
+		case rpmTagEpoch:
+			h.epoch = value
+		case rpmTagLicense:
+			h.license = value
+		case rpmTagArch:
+			h.arch = value
+		}
+	}
+	return h, nil
+}
+
+// sqliteDB reads tables of an SQLite 3 database file held in memory. It
+// supports what the rpm database needs: table b-trees with overflow pages,
+// and records of integers, text and blobs.
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	usable   int // page size less the reserved bytes at the end of a page
+}
+
+// openSQLite checks the database header.
+func openSQLite(data []byte) (*sqliteDB, error) {
+	if len(data) < 100 || string(data[:16]) != "SQLite format 3\x00" {
+		return nil, fmt.Errorf("not an SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 {
+		return nil, fmt.Errorf("invalid SQLite page size %d", pageSize)
+	}
+	return &sqliteDB{data: data, pageSize: pageSize, usable: pageSize - int(data[20])}, nil
+}
+
+// page returns page n (1-based) and the offset of its b-tree header, which
+// follows the database header on page 1.
+func (db *sqliteDB) page(n uint32) ([]byte, int, error) {
+	start := int(n-1) * db.pageSize
+	if n == 0 || start+db.pageSize > len(db.data) {
+		return nil, 0, fmt.Errorf("SQLite page %d out of range", n)
+	}
+	hdr := 0
+	if n == 1 {
+		hdr = 100
+	}
+	return db.data[start : start+db.pageSize], hdr, nil
+}
+
+// tableRoot returns the root page of a table, from the schema on page 1.
+func (db *sqliteDB) tableRoot(name string) (uint32, error) {
+	var root uint32
+	errFound := errors.New("found")
+	err := db.scan(1, func(record []any) error {
+		if len(record) < 4 {
+			return nil
+		}
+		if typ, _ := record[0].(string); typ != "table" {
+			return nil
+		}
+		if tbl, _ := record[1].(string); tbl != name {
+			return nil
+		}
+		n, ok := record[3].(int64)
+		if !ok {
+			return fmt.Errorf("table %s has no root page", name)
+		}
+		root = uint32(n)
+		return errFound
+	})
+	if errors.Is(err, errFound) {
+		return root, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no %s table", name)
+}
+
+// scan calls fn with the record of each row of the table b-tree rooted at
+// page root, in rowid order.
+func (db *sqliteDB) scan(root uint32, fn func(record []any) error) error {
+	return db.scanPage(root, fn, 0)
+}
+
+func (db *sqliteDB) scanPage(n uint32, fn func(record []any) error, depth int) error {
+	if depth > 64 {
+		return fmt.Errorf("SQLite b-tree too deep")
+	}
+	page, hdr, err := db.page(n)
+	if err != nil {
+		return err
+	}
+	if hdr+8 > len(page) {
+		return fmt.Errorf("SQLite page %d is truncated", n)
+	}
+	kind := page[hdr]
+	cells := int(binary.BigEndian.Uint16(page[hdr+3 : hdr+5]))
+
+	switch kind {
+	case 0x05: // interior table page
+		ptrs := hdr + 12
+		if ptrs+2*cells > len(page) {
+			return fmt.Errorf("SQLite page %d is truncated", n)
+		}
+		for i := 0; i < cells; i++ {
+			off := int(binary.BigEndian.Uint16(page[ptrs+2*i:]))
+			if off+4 > len(page) {
+				return fmt.Errorf("SQLite page %d has a bad cell", n)
+			}
+			if err := db.scanPage(binary.BigEndian.Uint32(page[off:]), fn, depth+1); err != nil {
+				return err
+			}
+		}
+		return db.scanPage(binary.BigEndian.Uint32(page[hdr+8:]), fn, depth+1)
+
+	case 0x0d: // leaf table page
+		ptrs := hdr + 8
+		if ptrs+2*cells > len(page) {
+			return fmt.Errorf("SQLite page %d is truncated", n)
+		}
+		for i := 0; i < cells; i++ {
+			off := int(binary.BigEndian.Uint16(page[ptrs+2*i:]))
+			payload, err := db.cellPayload(page, off)
+			if err != nil {
+				return fmt.Errorf("SQLite page %d: %w", n, err)
+			}
+			record, err := decodeRecord(payload)
+			if err != nil {
+				return fmt.Errorf("SQLite page %d: %w", n, err)
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("SQLite page %d is not a table page (type %#x)", n, kind)
+}
+
+// cellPayload returns the payload of the table leaf cell at off, following
+// its overflow pages.
+func (db *sqliteDB) cellPayload(page []byte, off int) ([]byte, error) {
+	if off >= len(page) {
+		return nil, fmt.Errorf("bad cell offset")
+	}
+	size, n := readVarint(page[off:])
+	if n == 0 {
+		return nil, fmt.Errorf("bad cell")
+	}
+	off += n
+	if _, n = readVarint(page[off:]); n == 0 { // rowid
+		return nil, fmt.Errorf("bad cell")
+	}
+	off += n
+
+	// How much of the payload is stored on the page itself
+	u := db.usable
+	maxLocal := u - 35
+	local := int(size)
+	if local > maxLocal {
+		minLocal := (u-12)*32/255 - 23
+		local = minLocal + (int(size)-minLocal)%(u-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if off+local > len(page) {
+		return nil, fmt.Errorf("cell overflows its page")
+	}
+	payload := append([]byte(nil), page[off:off+local]...)
+	if local == int(size) {
+		return payload, nil
+	}
+
+	if off+local+4 > len(page) {
+		return nil, fmt.Errorf("cell overflows its page")
+	}
+	next := binary.BigEndian.Uint32(page[off+local:])
+	for len(payload) < int(size) {
+		overflow, _, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		chunk := overflow[4:u]
+		if rest := int(size) - len(payload); len(chunk) > rest {
+			chunk = chunk[:rest]
+		}
+		payload = append(payload, chunk...)
+		next = binary.BigEndian.Uint32(overflow[0:4])
+	}
+	return payload, nil
+}
+
+// decodeRecord decodes an SQLite record into int64, float64, string, []byte
+// and nil values.
+func decodeRecord(payload []byte) ([]any, error) {
+	hdrSize, n := readVarint(payload)
+	if n == 0 || int(hdrSize) > len(payload) {
+		return nil, fmt.Errorf("bad record header")
+	}
+	var types []uint64
+	for p := n; p < int(hdrSize); {
+		t, n := readVarint(payload[p:])
+		if n == 0 {
+			return nil, fmt.Errorf("bad record header")
+		}
+		types = append(types, t)
+		p += n
+	}
+
+	body := payload[hdrSize:]
+	var values []any
+	for _, t := range types {
+		var size int
+		switch {
+		case t == 0, t == 8, t == 9:
+			size = 0
+		case t <= 4:
+			size = int(t)
+		case t == 5:
+			size = 6
+		case t == 6, t == 7:
+			size = 8
+		case t >= 12:
+			size = int(t-12) / 2
+		default:
+			return nil, fmt.Errorf("bad serial type %d", t)
+		}
+		if size > len(body) {
+			return nil, fmt.Errorf("record is truncated")
+		}
+		v := body[:size]
+		body = body[size:]
+
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t == 8:
+			values = append(values, int64(0))
+		case t == 9:
+			values = append(values, int64(1))
+		case t <= 6:
+			// Big-endian two's complement of 1 to 8 bytes
+			var x int64
+			if v[0]&0x80 != 0 {
+				x = -1
+			}
+			for _, b := range v {
+				x = x<<8 | int64(b)
+			}
+			values = append(values, x)
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case t%2 == 0:
+			values = append(values, v)
+		default:
+			values = append(values, string(v))
+		}
+	}
+	return values, nil
+}
+
+// readVarint decodes an SQLite varint: big-endian, 7 bits per byte with the
+// high bit set on all but the last, which has 8 bits when it is the ninth.
+// n is 0 if buf ends first.
+func readVarint(buf []byte) (v uint64, n int) {
+	for i := 0; i < 9; i++ {
+		if i >= len(buf) {
+			return 0, 0
+		}
+		b := buf[i]
+		if i == 8 {
+			return v<<8 | uint64(b), 9
+		}
+		v = v<<7 | uint64(b&0x7f)
+		if b&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, 9
+}
@@ -0,0 +1,304 @@
+// Package sbom decodes software bills of materials stored as OCI artifacts,
+// and generates them for built images.
+//
+// SPDX 2.x and CycloneDX JSON documents are supported, either as the raw
+// document or as the predicate of an in-toto attestation (as produced by
+// `cosign attest --type spdxjson|cyclonedx`). Both formats are normalized to
+// a flat package list keyed by name, version, and package URL.
+//
+// A Cataloger lists the packages of an image from its layers, and Encode
+// writes them back out as SPDX or CycloneDX.
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/attestation"
+)
+
+// Format identifies the SBOM document format.
+type Format string
+
+const (
+	FormatSPDX      Format = "spdx"
+	FormatCycloneDX Format = "cyclonedx"
+)
+
+// Document is a decoded SBOM.
+type Document struct {
+	// Format is "spdx" or "cyclonedx".
+	Format Format `json:"format" yaml:"format"`
+
+	// SpecVersion is the format version (e.g. "SPDX-2.3", "1.5").
+	SpecVersion string `json:"specVersion" yaml:"specVersion"`
+
+	// Name is the document or primary component name.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// Created is the document creation timestamp, as recorded.
+	Created string `json:"created,omitempty" yaml:"created,omitempty"`
+
+	// Tools lists the tools that generated the document.
+	Tools []string `json:"tools,omitempty" yaml:"tools,omitempty"`
+
+	// Packages lists every package or component in the document.
+	Packages []Package `json:"packages" yaml:"packages"`
+}
+
+// Package is a single SBOM package or component.
+type Package struct {
+	Name     string   `json:"name" yaml:"name"`
+	Version  string   `json:"version,omitempty" yaml:"version,omitempty"`
+	PURL     string   `json:"purl,omitempty" yaml:"purl,omitempty"`
+	Licenses []string `json:"licenses,omitempty" yaml:"licenses,omitempty"`
+
+	// Digest is the package's SHA-256 checksum ("sha256:<hex>"), when
+	// recorded.
+	Digest string `json:"digest,omitempty" yaml:"digest,omitempty"`
+}
+
+// Type returns the package URL type (e.g. "golang", "npm", "deb"),
+// or "" when the package has no PURL.
+func (p Package) Type() string {
+	return PURLType(p.PURL)
+}
+
+// PURLType extracts the type component of a package URL.
+func PURLType(purl string) string {
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return ""
+	}
+	rest = strings.TrimLeft(rest, "/")
+	if i := strings.IndexByte(rest, '/'); i != -1 {
+		return strings.ToLower(rest[:i])
+	}
+	return ""
+}
+
+// Parse decodes an SPDX or CycloneDX JSON document, unwrapping an in-toto
+// statement or DSSE envelope first when present.
+func Parse(data []byte) (*Document, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("SBOM is not a JSON object: %w", err)
+	}
+
+	switch {
+	case probe["spdxVersion"] != nil:
+		return parseSPDX(data)
+	case probe["bomFormat"] != nil:
+		return parseCycloneDX(data)
+	case probe["_type"] != nil, probe["payload"] != nil:
+		return parseAttestation(data)
+	}
+	return nil, fmt.Errorf("not an SPDX or CycloneDX document")
+}
+
+// parseAttestation extracts an SBOM predicate from an in-toto attestation.
+func parseAttestation(data []byte) (*Document, error) {
+	att, err := attestation.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	pt := strings.ToLower(att.PredicateType)
+	if !strings.Contains(pt, "spdx") && !strings.Contains(pt, "cyclonedx") {
+		return nil, fmt.Errorf("attestation predicate %s is not an SBOM", att.PredicateType)
+	}
+
+	predicate := []byte(att.Predicate)
+	// Some producers embed the document as a JSON string.
+	var embedded string
+	if err := json.Unmarshal(predicate, &embedded); err == nil {
+		predicate = []byte(embedded)
+	}
+	return Parse(predicate)
+}
+
+// spdxDocument mirrors the fields of an SPDX 2.x JSON document we display.
+type spdxDocument struct {
+	SPDXVersion  string `json:"spdxVersion"`
+	Name         string `json:"name"`
+	CreationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	} `json:"creationInfo"`
+	Packages []struct {
+		Name             string `json:"name"`
+		VersionInfo      string `json:"versionInfo"`
+		LicenseConcluded string `json:"licenseConcluded"`
+		LicenseDeclared  string `json:"licenseDeclared"`
+		ExternalRefs     []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+		Checksums []struct {
+			Algorithm     string `json:"algorithm"`
+			ChecksumValue string `json:"checksumValue"`
+		} `json:"checksums"`
+	} `json:"packages"`
+}
+
+func parseSPDX(data []byte) (*Document, error) {
+	var src spdxDocument
+	if err := json.Unmarshal(data, &src); err != nil {
+		return nil, fmt.Errorf("invalid SPDX document: %w", err)
+	}
+
+	doc := &Document{
+		Format:      FormatSPDX,
+		SpecVersion: src.SPDXVersion,
+		Name:        src.Name,
+		Created:     src.CreationInfo.Created,
+		Packages:    []Package{},
+	}
+	for _, c := range src.CreationInfo.Creators {
+		if tool, ok := strings.CutPrefix(c, "Tool:"); ok {
+			doc.Tools = append(doc.Tools, strings.TrimSpace(tool))
+		}
+	}
+
+	for _, p := range src.Packages {
+		pkg := Package{Name: p.Name, Version: p.VersionInfo}
+		for _, ref := range p.ExternalRefs {
+			if strings.EqualFold(ref.ReferenceType, "purl") {
+				pkg.PURL = ref.ReferenceLocator
+				break
+			}
+		}
+		for _, c := range p.Checksums {
+			if strings.EqualFold(c.Algorithm, "SHA256") {
+				pkg.Digest = "sha256:" + c.ChecksumValue
+				break
+			}
+		}
+		for _, l := range []string{p.LicenseConcluded, p.LicenseDeclared} {
+			if l != "" && l != "NOASSERTION" && l != "NONE" {
+				pkg.Licenses = append(pkg.Licenses, l)
+				break
+			}
+		}
+		doc.Packages = append(doc.Packages, pkg)
+	}
+	return doc, nil
+}
+
+// cdxComponent mirrors a CycloneDX component, which may nest components.
+type cdxComponent struct {
+	Group    string `json:"group"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	PURL     string `json:"purl"`
+	Licenses []struct {
+		License struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"license"`
+		Expression string `json:"expression"`
+	} `json:"licenses"`
+	Hashes []struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	} `json:"hashes"`
+	Components []cdxComponent `json:"components"`
+}
+
+// cdxDocument mirrors the fields of a CycloneDX JSON BOM we display.
+type cdxDocument struct {
+	BOMFormat   string `json:"bomFormat"`
+	SpecVersion string `json:"specVersion"`
+	Metadata    struct {
+		Timestamp string          `json:"timestamp"`
+		Tools     json.RawMessage `json:"tools"`
+		Component *cdxComponent   `json:"component"`
+	} `json:"metadata"`
+	Components []cdxComponent `json:"components"`
+}
+
+// cdxTool is a metadata tool entry. CycloneDX 1.5 moved tools from an array
+// to an object with "components"; both shapes are accepted.
+type cdxTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func parseCycloneDX(data []byte) (*Document, error) {
+	var src cdxDocument
+	if err := json.Unmarshal(data, &src); err != nil {
+		return nil, fmt.Errorf("invalid CycloneDX document: %w", err)
+	}
+	if !strings.EqualFold(src.BOMFormat, "CycloneDX") {
+		return nil, fmt.Errorf("unsupported bomFormat %q", src.BOMFormat)
+	}
+
+	doc := &Document{
+		Format:      FormatCycloneDX,
+		SpecVersion: src.SpecVersion,
+		Created:     src.Metadata.Timestamp,
+		Tools:       parseCDXTools(src.Metadata.Tools),
+		Packages:    []Package{},
+	}
+	if c := src.Metadata.Component; c != nil {
+		doc.Name = c.Name
+	}
+
+	var walk func([]cdxComponent)
+	walk = func(components []cdxComponent) {
+		for _, c := range components {
+			name := c.Name
+			if c.Group != "" {
+				name = c.Group + "/" + c.Name
+			}
+			pkg := Package{Name: name, Version: c.Version, PURL: c.PURL}
+			for _, h := range c.Hashes {
+				if strings.EqualFold(h.Alg, "SHA-256") {
+					pkg.Digest = "sha256:" + h.Content
+					break
+				}
+			}
+			for _, l := range c.Licenses {
+				switch {
+				case l.Expression != "":
+					pkg.Licenses = append(pkg.Licenses, l.Expression)
+				case l.License.ID != "":
+					pkg.Licenses = append(pkg.Licenses, l.License.ID)
+				case l.License.Name != "":
+					pkg.Licenses = append(pkg.Licenses, l.License.Name)
+				}
+			}
+			doc.Packages = append(doc.Packages, pkg)
+			walk(c.Components)
+		}
+	}
+	walk(src.Components)
+
+	return doc, nil
+}
+
+func parseCDXTools(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var tools []cdxTool
+	if err := json.Unmarshal(raw, &tools); err != nil {
+		var wrapped struct {
+			Components []cdxTool `json:"components"`
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil {
+			return nil
+		}
+		tools = wrapped.Components
+	}
+
+	var names []string
+	for _, t := range tools {
+		if t.Name == "" {
+			continue
+		}
+		names = append(names, strings.TrimSpace(t.Name+" "+t.Version))
+	}
+	return names
+}
@@ -0,0 +1,342 @@
+package sbom
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../testdata/fixtures/" + name)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return data
+}
+
+func TestParseSPDX(t *testing.T) {
+	doc, err := Parse(readFixture(t, "sbom-spdx.json"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if doc.Format != FormatSPDX {
+		t.Errorf("Format = %q, want %q", doc.Format, FormatSPDX)
+	}
+	if doc.SpecVersion != "SPDX-2.3" {
+		t.Errorf("SpecVersion = %q", doc.SpecVersion)
+	}
+	if doc.Name != "myapp-v1.0.0" {
+		t.Errorf("Name = %q", doc.Name)
+	}
+	if len(doc.Tools) != 1 || doc.Tools[0] != "lazyoci-test" {
+		t.Errorf("Tools = %v", doc.Tools)
+	}
+	if len(doc.Packages) != 1 {
+		t.Fatalf("len(Packages) = %d, want 1", len(doc.Packages))
+	}
+	if p := doc.Packages[0]; p.Name != "myapp" || p.Version != "1.0.0" {
+		t.Errorf("Packages[0] = %+v", p)
+	}
+}
+
+func TestParseSPDXPurlAndLicense(t *testing.T) {
+	data := []byte(`{
+		"spdxVersion": "SPDX-2.3",
+		"packages": [{
+			"name": "yaml",
+			"versionInfo": "3.0.1",
+			"licenseConcluded": "NOASSERTION",
+			"licenseDeclared": "Apache-2.0",
+			"externalRefs": [
+				{"referenceCategory": "SECURITY", "referenceType": "cpe23Type", "referenceLocator": "cpe:2.3:*"},
+				{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:golang/gopkg.in/yaml.v3@v3.0.1"}
+			]
+		}]
+	}`)
+
+	doc, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	p := doc.Packages[0]
+	if p.PURL != "pkg:golang/gopkg.in/yaml.v3@v3.0.1" {
+		t.Errorf("PURL = %q", p.PURL)
+	}
+	if p.Type() != "golang" {
+		t.Errorf("Type() = %q, want golang", p.Type())
+	}
+	if len(p.Licenses) != 1 || p.Licenses[0] != "Apache-2.0" {
+		t.Errorf("Licenses = %v", p.Licenses)
+	}
+}
+
+func TestParseCycloneDX(t *testing.T) {
+	doc, err := Parse(readFixture(t, "sbom-cyclonedx.json"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if doc.Format != FormatCycloneDX {
+		t.Errorf("Format = %q, want %q", doc.Format, FormatCycloneDX)
+	}
+	if doc.SpecVersion != "1.5" {
+		t.Errorf("SpecVersion = %q", doc.SpecVersion)
+	}
+	if doc.Name != "myapp" {
+		t.Errorf("Name = %q", doc.Name)
+	}
+	if len(doc.Tools) != 1 || doc.Tools[0] != "test-fixture 0.1.0" {
+		t.Errorf("Tools = %v", doc.Tools)
+	}
+	if len(doc.Packages) != 1 {
+		t.Fatalf("len(Packages) = %d, want 1", len(doc.Packages))
+	}
+	if p := doc.Packages[0]; p.PURL != "pkg:golang/example.com/lib@2.0.0" || p.Type() != "golang" {
+		t.Errorf("Packages[0] = %+v", p)
+	}
+}
+
+func TestParseCycloneDXNestedAndToolsObject(t *testing.T) {
+	data := []byte(`{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.6",
+		"metadata": {"tools": {"components": [{"name": "syft", "version": "1.0.0"}]}},
+		"components": [{
+			"group": "org.apache",
+			"name": "commons",
+			"version": "1.2",
+			"licenses": [{"license": {"id": "Apache-2.0"}}],
+			"components": [{"name": "inner", "version": "0.1"}]
+		}]
+	}`)
+
+	doc, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(doc.Tools) != 1 || doc.Tools[0] != "syft 1.0.0" {
+		t.Errorf("Tools = %v", doc.Tools)
+	}
+	if len(doc.Packages) != 2 {
+		t.Fatalf("len(Packages) = %d, want 2", len(doc.Packages))
+	}
+	if doc.Packages[0].Name != "org.apache/commons" {
+		t.Errorf("Packages[0].Name = %q", doc.Packages[0].Name)
+	}
+	if doc.Packages[1].Name != "inner" {
+		t.Errorf("Packages[1].Name = %q", doc.Packages[1].Name)
+	}
+}
+
+func TestParseInTotoPredicate(t *testing.T) {
+	var predicate json.RawMessage = readFixture(t, "sbom-cyclonedx.json")
+	statement, err := json.Marshal(map[string]any{
+		"_type":         "https://in-toto.io/Statement/v0.1",
+		"predicateType": "https://cyclonedx.org/bom",
+		"subject":       []any{},
+		"predicate":     predicate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := Parse(statement)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if doc.Format != FormatCycloneDX || len(doc.Packages) != 1 {
+		t.Errorf("doc = %+v", doc)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not JSON", data: "hello"},
+		{name: "unknown document", data: `{"foo": "bar"}`},
+		{name: "non-SBOM attestation", data: `{"_type": "x", "predicateType": "https://slsa.dev/provenance/v1", "predicate": {}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data)); err == nil {
+				t.Error("Parse() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestPURLType(t *testing.T) {
+	tests := map[string]string{
+		"pkg:npm/%40scope/name@1.0": "npm",
+		"pkg:deb/debian/curl@7.0":   "deb",
+		"pkg:PyPI/requests@2.0":     "pypi",
+		"":                          "",
+		"cpe:2.3:a":                 "",
+	}
+	for purl, want := range tests {
+		if got := PURLType(purl); got != want {
+			t.Errorf("PURLType(%q) = %q, want %q", purl, got, want)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	f := &registrytest.Fetcher{
+		Manifest: &registry.Manifest{
+			Layers: []registry.Descriptor{
+				{MediaType: "application/octet-stream", Digest: "sha256:bin"},
+				{MediaType: "application/json", Digest: "sha256:junk"},
+				{MediaType: "application/spdx+json", Digest: "sha256:spdx"},
+			},
+		},
+		Blobs: map[string][]byte{
+			"sha256:junk": []byte(`{"not": "an sbom"}`),
+			"sha256:spdx": readFixture(t, "sbom-spdx.json"),
+		},
+	}
+
+	doc, err := Fetch(f, "localhost:5050/test/myapp", "sbom")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if doc.Format != FormatSPDX {
+		t.Errorf("Format = %q, want %q", doc.Format, FormatSPDX)
+	}
+
+	empty := &registrytest.Fetcher{Manifest: &registry.Manifest{}}
+	if _, err := Fetch(empty, "localhost:5050/test/myapp", "sbom"); err == nil {
+		t.Error("Fetch() with no layers: error = nil, want error")
+	}
+}
+
+// repoFetcher serves several manifests by reference and, optionally,
+// referrers of any subject.
+type repoFetcher struct {
+	manifests map[string]*registry.Manifest
+	blobs     map[string][]byte
+	referrers []registry.Descriptor
+}
+
+func (f *repoFetcher) GetManifest(repoPath, reference string) (*registry.Manifest, *registry.Descriptor, error) {
+	m, ok := f.manifests[reference]
+	if !ok {
+		return nil, nil, errors.New("not found")
+	}
+	digest := reference
+	if !strings.HasPrefix(digest, "sha256:") {
+		digest = "sha256:" + strings.Repeat("a", 64)
+	}
+	return m, &registry.Descriptor{Digest: digest}, nil
+}
+
+func (f *repoFetcher) FetchBlob(repoPath string, desc registry.Descriptor) ([]byte, error) {
+	data, ok := f.blobs[desc.Digest]
+	if !ok {
+		return nil, errors.New("blob not found")
+	}
+	return data, nil
+}
+
+type referrerFetcher struct{ *repoFetcher }
+
+func (f referrerFetcher) Referrers(repoPath string, subject registry.Descriptor, artifactType string) ([]registry.Descriptor, error) {
+	return f.referrers, nil
+}
+
+func TestDiscover(t *testing.T) {
+	image := &registry.Manifest{
+		Layers: []registry.Descriptor{{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: "sha256:layer"}},
+	}
+	sbomManifest := &registry.Manifest{
+		Layers: []registry.Descriptor{{MediaType: "application/spdx+json", Digest: "sha256:spdx"}},
+	}
+	blobs := map[string][]byte{"sha256:spdx": readFixture(t, "sbom-spdx.json")}
+	cosignTag := "sha256-" + strings.Repeat("a", 64) + ".sbom"
+
+	tests := []struct {
+		name       string
+		fetcher    registry.Fetcher
+		wantSource string
+		wantErr    bool
+	}{
+		{
+			name: "reference is an SBOM",
+			fetcher: &repoFetcher{
+				manifests: map[string]*registry.Manifest{"v1": sbomManifest},
+				blobs:     blobs,
+			},
+			wantSource: "v1",
+		},
+		{
+			name: "buildx attestation manifest",
+			fetcher: &repoFetcher{
+				manifests: map[string]*registry.Manifest{
+					"v1": {Manifests: []registry.Descriptor{
+						{Digest: "sha256:amd64"},
+						{Digest: "sha256:att", Annotations: map[string]string{annotationReferenceType: "attestation-manifest"}},
+					}},
+					"sha256:att": sbomManifest,
+				},
+				blobs: blobs,
+			},
+			wantSource: "attestation manifest sha256:att",
+		},
+		{
+			name: "referrer",
+			fetcher: referrerFetcher{&repoFetcher{
+				manifests: map[string]*registry.Manifest{"v1": image, "sha256:ref": sbomManifest},
+				blobs:     blobs,
+				referrers: []registry.Descriptor{
+					{ArtifactType: "application/vnd.dev.cosign.simplesigning.v1+json", Digest: "sha256:sig"},
+					{ArtifactType: "application/spdx+json", Digest: "sha256:ref"},
+				},
+			}},
+			wantSource: "referrer sha256:ref",
+		},
+		{
+			name: "cosign tag",
+			fetcher: &repoFetcher{
+				manifests: map[string]*registry.Manifest{"v1": image, cosignTag: sbomManifest},
+				blobs:     blobs,
+			},
+			wantSource: "tag " + cosignTag,
+		},
+		{
+			name: "none",
+			fetcher: &repoFetcher{
+				manifests: map[string]*registry.Manifest{"v1": image},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, source, err := Discover(tt.fetcher, "localhost:5050/test/myapp", "v1")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Discover() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Discover() error = %v", err)
+			}
+			if source != tt.wantSource {
+				t.Errorf("source = %q, want %q", source, tt.wantSource)
+			}
+			if doc.Format != FormatSPDX {
+				t.Errorf("Format = %q, want %q", doc.Format, FormatSPDX)
+			}
+		})
+	}
+}
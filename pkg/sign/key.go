@@ -0,0 +1,171 @@
+package sign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordEnv is the environment variable holding the password of an
+// encrypted key, as with cosign.
+const PasswordEnv = "COSIGN_PASSWORD"
+
+// PEM block types of private keys. cosign generate-key-pair writes
+// encrypted keys; cosign 1.x named them COSIGN rather than SIGSTORE.
+const (
+	pemEncryptedSigstore = "ENCRYPTED SIGSTORE PRIVATE KEY"
+	pemEncryptedCosign   = "ENCRYPTED COSIGN PRIVATE KEY"
+	pemPKCS8             = "PRIVATE KEY"
+	pemEC                = "EC PRIVATE KEY"
+)
+
+// encryptedKey is the JSON inside an encrypted cosign key: a PKCS #8 key
+// sealed with NaCl secretbox under a key derived from the password with
+// scrypt. Byte fields are base64, as encoding/json writes them.
+type encryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Signer signs cosign payloads with an ECDSA or Ed25519 private key.
+type Signer struct {
+	key crypto.Signer
+}
+
+// LoadKey reads a private key file. Encrypted keys are decrypted with
+// password.
+func LoadKey(path string, password []byte) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	s, err := ParseKey(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// ParseKey parses a PEM private key: an encrypted cosign key, or an
+// unencrypted PKCS #8 or SEC 1 key.
+func ParseKey(data, password []byte) (*Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case pemEncryptedSigstore, pemEncryptedCosign:
+		der, derr := decryptKey(block.Bytes, password)
+		if derr != nil {
+			return nil, derr
+		}
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	case pemPKCS8:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case pemEC:
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return &Signer{key: k}, nil
+	case ed25519.PrivateKey:
+		return &Signer{key: k}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T (must be ECDSA or Ed25519)", key)
+}
+
+// decryptKey opens the sealed PKCS #8 key of an encrypted cosign key.
+func decryptKey(data, password []byte) ([]byte, error) {
+	var ek encryptedKey
+	if err := json.Unmarshal(data, &ek); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted key: %w", err)
+	}
+	if ek.KDF.Name != "scrypt" || ek.Cipher.Name != "nacl/secretbox" {
+		return nil, fmt.Errorf("unsupported key encryption %s with %s", ek.Cipher.Name, ek.KDF.Name)
+	}
+	if len(ek.Cipher.Nonce) != 24 {
+		return nil, fmt.Errorf("invalid encrypted key nonce")
+	}
+
+	p := ek.KDF.Params
+	k, err := scrypt.Key(password, ek.KDF.Salt, p.N, p.R, p.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	var nonce [24]byte
+	var secret [32]byte
+	copy(nonce[:], ek.Cipher.Nonce)
+	copy(secret[:], k)
+
+	der, ok := secretbox.Open(nil, ek.Ciphertext, &nonce, &secret)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt key: wrong password? (set %s)", PasswordEnv)
+	}
+	return der, nil
+}
+
+// SignPayload signs payload as cosign does: ECDSA over its SHA-256 digest,
+// with an ASN.1 signature, or Ed25519 over the payload itself.
+func (s *Signer) SignPayload(payload []byte) ([]byte, error) {
+	switch k := s.key.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, payload), nil
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256(payload)
+		return ecdsa.SignASN1(rand.Reader, k, sum[:])
+	}
+	return nil, fmt.Errorf("unsupported key type %T", s.key)
+}
+
+// VerifyPayload reports whether sig is the signer's signature of payload.
+func (s *Signer) VerifyPayload(payload, sig []byte) bool {
+	switch pub := s.key.Public().(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(pub, payload, sig)
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256(payload)
+		return ecdsa.VerifyASN1(pub, sum[:], sig)
+	}
+	return false
+}
+
+// PublicKeyPEM returns the signer's public key as PEM, the format of
+// cosign.pub.
+func (s *Signer) PublicKeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(s.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
@@ -0,0 +1,62 @@
+package sign
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../testdata/fixtures/" + name)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return data
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		password string
+		wantPub  string
+		wantErr  string
+	}{
+		{name: "ecdsa", key: "cosign.key", password: "lazyoci", wantPub: "cosign.pub"},
+		{name: "ed25519", key: "cosign-ed25519.key", password: "lazyoci", wantPub: "cosign-ed25519.pub"},
+		{name: "wrong password", key: "cosign.key", password: "nope", wantErr: "wrong password"},
+		{name: "public key", key: "cosign.pub", wantErr: `unsupported PEM block "PUBLIC KEY"`},
+		{name: "not PEM", key: "cosign-sig.json", wantErr: "no PEM private key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseKey(readFixture(t, tt.key), []byte(tt.password))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseKey() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, err := s.PublicKeyPEM()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(pub) != string(readFixture(t, tt.wantPub)) {
+				t.Errorf("PublicKeyPEM() = %s, want %s", pub, readFixture(t, tt.wantPub))
+			}
+
+			payload := []byte("payload")
+			sig, err := s.SignPayload(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !s.VerifyPayload(payload, sig) || s.VerifyPayload([]byte("other"), sig) {
+				t.Error("VerifyPayload() does not match SignPayload()")
+			}
+		})
+	}
+}
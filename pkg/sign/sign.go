@@ -0,0 +1,298 @@
+// Package sign signs image digests with a local key the way cosign does,
+// so the signatures verify with `cosign verify --key` and with admission
+// controllers that use cosign.
+//
+// The signed payload is cosign's simple-signing JSON naming the repository
+// and digest. Its signature is stored either under the tag cosign looks for
+// (sha256-<hex>.sig), or as an OCI 1.1 referrer as cosign's experimental
+// OCI 1.1 mode does.
+package sign
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+)
+
+// Media types and annotations of cosign signatures.
+const (
+	MediaTypeSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	ArtifactTypeSignature  = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	AnnotationSignature    = "dev.cosignproject.cosign/signature"
+
+	signatureType = "cosign container image signature"
+)
+
+// Mode selects where a signature is stored.
+type Mode string
+
+const (
+	// ModeTag stores signatures in an image tagged sha256-<hex>.sig, one
+	// layer per signature. This is where cosign looks by default.
+	ModeTag Mode = "tag"
+	// ModeReferrer pushes each signature as an OCI 1.1 referrer of the
+	// signed digest (cosign verify --experimental-oci11).
+	ModeReferrer Mode = "referrer"
+)
+
+// ParseMode parses a mode name. Empty means ModeTag.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeTag:
+		return ModeTag, nil
+	case ModeReferrer:
+		return ModeReferrer, nil
+	}
+	return "", fmt.Errorf("unsupported signature mode %q (must be tag or referrer)", s)
+}
+
+// Result describes a signature.
+type Result struct {
+	// Signature is the manifest holding the signature.
+	Signature ocispec.Descriptor
+
+	// Tag is the signature tag (ModeTag only).
+	Tag string
+
+	// Unchanged is true if the digest already had a signature from the
+	// key, so nothing was pushed.
+	Unchanged bool
+}
+
+// simpleSigning is cosign's simple-signing payload. Its field order and
+// the null "optional" match what cosign signs.
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]any `json:"optional"`
+}
+
+// DockerReference returns the repository name cosign records in a
+// payload's identity: registry/repository, with Docker Hub as
+// index.docker.io.
+func DockerReference(registry, repository string) string {
+	if registry == "docker.io" || registry == "registry-1.docker.io" {
+		registry = "index.docker.io"
+	}
+	return registry + "/" + repository
+}
+
+// Payload returns the simple-signing payload for digest d of the image in
+// repository (see DockerReference).
+func Payload(repository string, d digest.Digest) ([]byte, error) {
+	var p simpleSigning
+	p.Critical.Identity.DockerReference = repository
+	p.Critical.Image.DockerManifestDigest = d.String()
+	p.Critical.Type = signatureType
+	return json.Marshal(p)
+}
+
+// SignatureTag returns the tag cosign stores the signatures of d under.
+func SignatureTag(d digest.Digest) string {
+	return fmt.Sprintf("%s-%s.sig", d.Algorithm(), d.Encoded())
+}
+
+// Sign signs subject, an image in repository, and pushes the signature to
+// target. A digest the key has already signed is left as it is.
+func Sign(ctx context.Context, target oras.GraphTarget, repository string, subject ocispec.Descriptor, s *Signer, mode Mode) (*Result, error) {
+	payload, err := Payload(repository, subject.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	switch mode {
+	case ModeTag, "":
+		return signTag(ctx, target, subject, s, payload)
+	case ModeReferrer:
+		return signReferrer(ctx, target, subject, s, payload)
+	}
+	return nil, fmt.Errorf("unsupported signature mode %q", mode)
+}
+
+// signTag adds a signature layer to the image under the signature tag,
+// creating it if needed.
+func signTag(ctx context.Context, target oras.GraphTarget, subject ocispec.Descriptor, s *Signer, payload []byte) (*Result, error) {
+	tag := SignatureTag(subject.Digest)
+
+	var layers []ocispec.Descriptor
+	existing, err := target.Resolve(ctx, tag)
+	switch {
+	case err == nil:
+		manifest, err := fetchManifest(ctx, target, existing)
+		if err != nil {
+			return nil, err
+		}
+		if signedBy(manifest, s, payload) {
+			return &Result{Signature: existing, Tag: tag, Unchanged: true}, nil
+		}
+		layers = manifest.Layers
+	case !errors.Is(err, errdef.ErrNotFound):
+		return nil, fmt.Errorf("failed to resolve %s: %w", tag, err)
+	}
+
+	layer, err := signatureLayer(ctx, target, s, payload)
+	if err != nil {
+		return nil, err
+	}
+	layers = append(layers, layer)
+
+	config, err := pushJSON(ctx, target, ocispec.MediaTypeImageConfig, imageConfig(layers))
+	if err != nil {
+		return nil, fmt.Errorf("failed to push signature config: %w", err)
+	}
+	desc, err := pushJSON(ctx, target, ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    layers,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to push signature manifest: %w", err)
+	}
+	if err := target.Tag(ctx, desc, tag); err != nil {
+		return nil, fmt.Errorf("failed to tag signature: %w", err)
+	}
+	return &Result{Signature: desc, Tag: tag}, nil
+}
+
+// signReferrer pushes a signature manifest whose subject is the signed
+// digest.
+func signReferrer(ctx context.Context, target oras.GraphTarget, subject ocispec.Descriptor, s *Signer, payload []byte) (*Result, error) {
+	referrers, err := registry.Referrers(ctx, target, subject, ArtifactTypeSignature)
+	if err != nil && !errors.Is(err, errdef.ErrNotFound) {
+		return nil, fmt.Errorf("failed to list referrers: %w", err)
+	}
+	for _, desc := range referrers {
+		manifest, err := fetchManifest(ctx, target, desc)
+		if err != nil {
+			return nil, err
+		}
+		if signedBy(manifest, s, payload) {
+			return &Result{Signature: desc, Unchanged: true}, nil
+		}
+	}
+
+	layer, err := signatureLayer(ctx, target, s, payload)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := oras.PackManifest(ctx, target, oras.PackManifestVersion1_1, ArtifactTypeSignature, oras.PackManifestOptions{
+		Subject: &subject,
+		Layers:  []ocispec.Descriptor{layer},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to push signature manifest: %w", err)
+	}
+	return &Result{Signature: desc}, nil
+}
+
+// signatureLayer signs payload, pushes it and returns its layer, with the
+// signature as an annotation.
+func signatureLayer(ctx context.Context, target content.Storage, s *Signer, payload []byte) (ocispec.Descriptor, error) {
+	sig, err := s.SignPayload(payload)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to sign: %w", err)
+	}
+	layer, err := pushBlob(ctx, target, MediaTypeSimpleSigning, payload)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("failed to push payload: %w", err)
+	}
+	layer.Annotations = map[string]string{
+		AnnotationSignature: base64.StdEncoding.EncodeToString(sig),
+	}
+	return layer, nil
+}
+
+// signedBy reports whether one of manifest's layers is payload with a
+// signature from s.
+func signedBy(manifest *ocispec.Manifest, s *Signer, payload []byte) bool {
+	d := digest.FromBytes(payload)
+	for _, layer := range manifest.Layers {
+		if layer.Digest != d {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(layer.Annotations[AnnotationSignature])
+		if err == nil && s.VerifyPayload(payload, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageConfig returns the config of a signature image as cosign writes it:
+// an empty image whose diff IDs are the (uncompressed) payload layers.
+func imageConfig(layers []ocispec.Descriptor) any {
+	type history struct {
+		Created time.Time `json:"created"`
+	}
+	config := struct {
+		Architecture string    `json:"architecture"`
+		Created      time.Time `json:"created"`
+		History      []history `json:"history"`
+		OS           string    `json:"os"`
+		RootFS       struct {
+			Type    string          `json:"type"`
+			DiffIDs []digest.Digest `json:"diff_ids"`
+		} `json:"rootfs"`
+		Config struct{} `json:"config"`
+	}{}
+	config.RootFS.Type = "layers"
+	for _, l := range layers {
+		config.History = append(config.History, history{})
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, l.Digest)
+	}
+	return config
+}
+
+// fetchManifest fetches and decodes a signature manifest.
+func fetchManifest(ctx context.Context, target content.Fetcher, desc ocispec.Descriptor) (*ocispec.Manifest, error) {
+	data, err := content.FetchAll(ctx, target, desc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signature manifest: %w", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse signature manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// pushJSON encodes v and pushes it with pushBlob.
+func pushJSON(ctx context.Context, target content.Storage, mediaType string, v any) (ocispec.Descriptor, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return pushBlob(ctx, target, mediaType, data)
+}
+
+// pushBlob pushes data unless target already has it.
+func pushBlob(ctx context.Context, target content.Storage, mediaType string, data []byte) (ocispec.Descriptor, error) {
+	desc := content.NewDescriptorFromBytes(mediaType, data)
+	if exists, err := target.Exists(ctx, desc); err != nil || exists {
+		return desc, err
+	}
+	if err := target.Push(ctx, desc, bytes.NewReader(data)); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return ocispec.Descriptor{}, err
+	}
+	return desc, nil
+}
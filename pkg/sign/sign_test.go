@@ -0,0 +1,180 @@
+package sign
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/registry"
+)
+
+func loadFixtureKey(t *testing.T, name string) *Signer {
+	t.Helper()
+	s, err := ParseKey(readFixture(t, name), []byte("lazyoci"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPayload(t *testing.T) {
+	d := digest.Digest("sha256:abc123def456789000000000000000000000000000000000000000000000000")
+	payload, err := Payload(DockerReference("docker.io", "library/nginx"), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"critical":{"identity":{"docker-reference":"index.docker.io/library/nginx"},` +
+		`"image":{"docker-manifest-digest":"sha256:abc123def456789000000000000000000000000000000000000000000000000"},` +
+		`"type":"cosign container image signature"},"optional":null}`
+	if string(payload) != want {
+		t.Errorf("Payload() = %s\nwant %s", payload, want)
+	}
+	if tag := SignatureTag(d); tag != "sha256-abc123def456789000000000000000000000000000000000000000000000000.sig" {
+		t.Errorf("SignatureTag() = %s", tag)
+	}
+}
+
+// pushImage stores a minimal image manifest and returns its descriptor.
+func pushImage(t *testing.T, store *memory.Store) ocispec.Descriptor {
+	t.Helper()
+	data := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[]}`)
+	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeImageManifest, data)
+	if _, err := pushBlob(context.Background(), store, desc.MediaType, data); err != nil {
+		t.Fatal(err)
+	}
+	return desc
+}
+
+// signatureLayers returns the payload layers of the signature manifest desc,
+// checking each signature against s.
+func signatureLayers(t *testing.T, store *memory.Store, desc ocispec.Descriptor) []ocispec.Descriptor {
+	t.Helper()
+	manifest, err := fetchManifest(context.Background(), store, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range manifest.Layers {
+		if l.MediaType != MediaTypeSimpleSigning || l.Annotations[AnnotationSignature] == "" {
+			t.Errorf("layer = %+v", l)
+		}
+	}
+	return manifest.Layers
+}
+
+func TestSignTag(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	image := pushImage(t, store)
+	ecdsaKey := loadFixtureKey(t, "cosign.key")
+
+	result, err := Sign(ctx, store, "localhost:5050/test/myapp", image, ecdsaKey, ModeTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unchanged || result.Tag != SignatureTag(image.Digest) {
+		t.Fatalf("result = %+v", result)
+	}
+	tagged, err := store.Resolve(ctx, result.Tag)
+	if err != nil || tagged.Digest != result.Signature.Digest {
+		t.Fatalf("signature tag = %v, %v", tagged, err)
+	}
+
+	layers := signatureLayers(t, store, result.Signature)
+	if len(layers) != 1 {
+		t.Fatalf("layers = %+v", layers)
+	}
+	payload, err := content.FetchAll(ctx, store, layers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ss simpleSigning
+	if err := json.Unmarshal(payload, &ss); err != nil {
+		t.Fatal(err)
+	}
+	if ss.Critical.Image.DockerManifestDigest != image.Digest.String() ||
+		ss.Critical.Identity.DockerReference != "localhost:5050/test/myapp" {
+		t.Errorf("payload = %s", payload)
+	}
+	sig, _ := base64.StdEncoding.DecodeString(layers[0].Annotations[AnnotationSignature])
+	if !ecdsaKey.VerifyPayload(payload, sig) {
+		t.Error("signature does not verify")
+	}
+
+	// The config lists the payloads as diff IDs, as cosign's does
+	manifest, _ := fetchManifest(ctx, store, result.Signature)
+	var config ocispec.Image
+	data, _ := content.FetchAll(ctx, store, manifest.Config)
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.RootFS.DiffIDs) != 1 || config.RootFS.DiffIDs[0] != layers[0].Digest {
+		t.Errorf("config = %s", data)
+	}
+
+	// Signing again with the same key changes nothing
+	again, err := Sign(ctx, store, "localhost:5050/test/myapp", image, ecdsaKey, ModeTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Unchanged || again.Signature.Digest != result.Signature.Digest {
+		t.Errorf("second Sign() = %+v, want unchanged", again)
+	}
+
+	// Another key adds a layer next to the first signature
+	other, err := Sign(ctx, store, "localhost:5050/test/myapp", image, loadFixtureKey(t, "cosign-ed25519.key"), ModeTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Unchanged || len(signatureLayers(t, store, other.Signature)) != 2 {
+		t.Errorf("Sign() with a second key = %+v", other)
+	}
+}
+
+func TestSignReferrer(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	image := pushImage(t, store)
+	key := loadFixtureKey(t, "cosign-ed25519.key")
+
+	result, err := Sign(ctx, store, "localhost:5050/test/myapp", image, key, ModeReferrer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unchanged || result.Tag != "" {
+		t.Fatalf("result = %+v", result)
+	}
+	referrers, err := registry.Referrers(ctx, store, image, ArtifactTypeSignature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(referrers) != 1 || referrers[0].Digest != result.Signature.Digest {
+		t.Fatalf("referrers = %+v", referrers)
+	}
+	if layers := signatureLayers(t, store, result.Signature); len(layers) != 1 {
+		t.Errorf("layers = %+v", layers)
+	}
+
+	again, err := Sign(ctx, store, "localhost:5050/test/myapp", image, key, ModeReferrer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Unchanged || again.Signature.Digest != result.Signature.Digest {
+		t.Errorf("second Sign() = %+v, want unchanged", again)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"": ModeTag, "tag": ModeTag, "referrer": ModeReferrer} {
+		if got, err := ParseMode(in); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseMode("oci-1-1"); err == nil {
+		t.Error("ParseMode() accepted an unknown mode")
+	}
+}
@@ -0,0 +1,71 @@
+package unpack
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/ociutil"
+)
+
+// Result summarizes an unpacked image.
+type Result struct {
+	Layers  int `json:"layers" yaml:"layers"`
+	Skipped int `json:"skipped" yaml:"skipped"`
+}
+
+// Image applies the layers of the image manifest with the given digest,
+// stored in the OCI layout at ociLayoutPath, to dest. dest must be empty
+// or not exist yet.
+func Image(ociLayoutPath, manifestDigest, dest string) (*Result, error) {
+	manifest, err := ociutil.ReadOCIManifest(ociLayoutPath, manifestDigest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("destination %s is not empty", dest)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination: %w", err)
+	}
+
+	u := NewUnpacker(dest)
+	for i, layer := range manifest.Layers {
+		if err := applyBlob(u, ociLayoutPath, layer); err != nil {
+			return nil, fmt.Errorf("layer %d (%s): %w", i+1, layer.Digest, err)
+		}
+	}
+	if err := u.Finish(); err != nil {
+		return nil, err
+	}
+
+	return &Result{Layers: len(manifest.Layers), Skipped: u.Skipped()}, nil
+}
+
+// applyBlob decompresses a layer blob and applies it.
+func applyBlob(u *Unpacker, ociLayoutPath string, layer ociutil.OCIDescriptor) error {
+	f, err := os.Open(ociutil.BlobPath(ociLayoutPath, layer.Digest))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch {
+	case strings.Contains(layer.MediaType, "gzip"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case strings.Contains(layer.MediaType, "zstd"):
+		return fmt.Errorf("zstd-compressed layers are not supported")
+	case !strings.Contains(layer.MediaType, "tar"):
+		return fmt.Errorf("not a filesystem layer (%s)", layer.MediaType)
+	}
+	return u.ApplyLayer(r)
+}
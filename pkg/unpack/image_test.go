@@ -0,0 +1,120 @@
+package unpack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// writeLayout writes an OCI layout holding an image with the given layers
+// and returns its directory and the manifest digest.
+func writeLayout(t *testing.T, layers map[string][]byte, order []string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	blobs := filepath.Join(dir, "blobs", "sha256")
+	if err := os.MkdirAll(blobs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeBlob := func(data []byte) string {
+		d := digest.FromBytes(data)
+		if err := os.WriteFile(filepath.Join(blobs, d.Encoded()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return d.String()
+	}
+
+	type desc struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int    `json:"size"`
+	}
+	config := []byte(`{"os":"linux","architecture":"amd64"}`)
+	manifest := struct {
+		Config desc   `json:"config"`
+		Layers []desc `json:"layers"`
+	}{Config: desc{"application/vnd.oci.image.config.v1+json", writeBlob(config), len(config)}}
+	for _, mediaType := range order {
+		data := layers[mediaType]
+		manifest.Layers = append(manifest.Layers, desc{mediaType, writeBlob(data), len(data)})
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, writeBlob(data)
+}
+
+func gzipped(t *testing.T, b *bytes.Buffer) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	if _, err := zw.Write(b.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestImage(t *testing.T) {
+	layout, manifest := writeLayout(t, map[string][]byte{
+		"application/vnd.oci.image.layer.v1.tar+gzip":       gzipped(t, layer(t, file("etc/os-release", "ID=test"), file("tmp/scratch", "x"))),
+		"application/vnd.docker.image.rootfs.diff.tar":      layer(t, file("tmp/.wh.scratch", ""), file("app/run", "run")).Bytes(),
+		"application/vnd.docker.image.rootfs.diff.tar.gzip": gzipped(t, layer(t, file("app/run", "run v2"))),
+	}, []string{
+		"application/vnd.oci.image.layer.v1.tar+gzip",
+		"application/vnd.docker.image.rootfs.diff.tar",
+		"application/vnd.docker.image.rootfs.diff.tar.gzip",
+	})
+
+	dest := filepath.Join(t.TempDir(), "rootfs")
+	result, err := Image(layout, manifest, dest)
+	if err != nil {
+		t.Fatalf("Image() error = %v", err)
+	}
+	if result.Layers != 3 {
+		t.Errorf("Layers = %d, want 3", result.Layers)
+	}
+	assertTree(t, dest, "app/", "app/run", "etc/", "etc/os-release", "tmp/")
+	if got := readFile(t, filepath.Join(dest, "app/run")); got != "run v2" {
+		t.Errorf("app/run = %q, want the top layer's", got)
+	}
+}
+
+func TestImageErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		mediaType string
+		nonEmpty  bool
+		wantErr   string
+	}{
+		{name: "zstd", mediaType: "application/vnd.oci.image.layer.v1.tar+zstd", wantErr: "zstd"},
+		{name: "not a filesystem", mediaType: "application/json", wantErr: "not a filesystem layer"},
+		{name: "non-empty destination", mediaType: "application/vnd.oci.image.layer.v1.tar", nonEmpty: true, wantErr: "not empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := layer(t, file("a", "a")).Bytes()
+			layout, manifest := writeLayout(t, map[string][]byte{tt.mediaType: data}, []string{tt.mediaType})
+
+			dest := t.TempDir()
+			if tt.nonEmpty {
+				if err := os.WriteFile(filepath.Join(dest, "existing"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := Image(layout, manifest, dest)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Image() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
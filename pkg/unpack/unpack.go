@@ -0,0 +1,334 @@
+// Package unpack applies image layers to a directory to produce a root
+// filesystem, the way a container runtime's snapshotter does.
+//
+// Layers are applied in order. OCI whiteouts (".wh.<name>") delete entries
+// from lower layers and opaque markers (".wh..wh..opq") hide a directory's
+// lower contents. Every path is resolved inside the root, following
+// symlinks as if the root were "/", so a layer cannot write through a
+// symlink that points outside of it. Device nodes and FIFOs need
+// privileges to create and are skipped.
+package unpack
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// whiteoutPrefix marks a file deleting the lower-layer entry it names.
+	whiteoutPrefix = ".wh."
+	// opaqueWhiteout marks a directory whose lower-layer contents are hidden.
+	opaqueWhiteout = ".wh..wh..opq"
+	// maxSymlinks bounds symlink resolution, as the kernel's ELOOP does.
+	maxSymlinks = 255
+)
+
+// dirMeta is the mode and modification time a directory gets once all
+// layers are applied. Directories stay writable until then so later
+// entries can be created in them.
+type dirMeta struct {
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// Unpacker applies layers to a root directory.
+type Unpacker struct {
+	root    string
+	chown   bool
+	dirs    map[string]dirMeta
+	skipped int
+}
+
+// NewUnpacker creates an Unpacker for root, which must exist. File
+// ownership from the layers is applied only when running as root.
+func NewUnpacker(root string) *Unpacker {
+	return &Unpacker{
+		root:  root,
+		chown: os.Geteuid() == 0,
+		dirs:  make(map[string]dirMeta),
+	}
+}
+
+// Skipped returns how many device nodes and FIFOs were not created.
+func (u *Unpacker) Skipped() int {
+	return u.skipped
+}
+
+// ApplyLayer applies an uncompressed layer tarball on top of the root.
+func (u *Unpacker) ApplyLayer(r io.Reader) error {
+	tr := tar.NewReader(r)
+	// Whiteouts only apply to lower layers, so entries of this layer are
+	// tracked to keep them.
+	added := make(map[string]bool)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read layer: %w", err)
+		}
+
+		name := path.Clean("/" + strings.ReplaceAll(hdr.Name, "\\", "/"))
+		if name == "/" {
+			continue
+		}
+		dir, base := path.Split(name)
+
+		parent, err := u.resolve(dir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		switch {
+		case base == opaqueWhiteout:
+			if err := os.MkdirAll(parent, 0755); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err := u.clearDir(parent, path.Clean(dir), added); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			continue
+		case strings.HasPrefix(base, whiteoutPrefix+whiteoutPrefix):
+			// Other aufs metadata, such as hardlink directories
+			continue
+		case strings.HasPrefix(base, whiteoutPrefix):
+			hidden := strings.TrimPrefix(base, whiteoutPrefix)
+			if hidden == "" || hidden == "." || hidden == ".." {
+				return fmt.Errorf("%s: invalid whiteout", name)
+			}
+			if !added[path.Join(dir, hidden)] {
+				if err := u.remove(filepath.Join(parent, hidden)); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for p := name; p != "/"; p = path.Dir(p) {
+			added[p] = true
+		}
+		if err := u.apply(tr, hdr, filepath.Join(parent, base)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+}
+
+// Finish sets the mode and modification time of every directory, deepest
+// first. It must be called once all layers are applied.
+func (u *Unpacker) Finish() error {
+	paths := make([]string, 0, len(u.dirs))
+	for p := range u.dirs {
+		paths = append(paths, p)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+	for _, p := range paths {
+		meta := u.dirs[p]
+		if err := os.Chmod(p, meta.mode); err != nil {
+			return fmt.Errorf("failed to set mode of %s: %w", p, err)
+		}
+		if err := os.Chtimes(p, meta.modTime, meta.modTime); err != nil {
+			return fmt.Errorf("failed to set times of %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// apply creates the entry described by hdr at target, replacing whatever
+// a lower layer left there unless both are directories.
+func (u *Unpacker) apply(tr *tar.Reader, hdr *tar.Header, target string) error {
+	if fi, err := os.Lstat(target); err == nil {
+		if !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+			if err := u.remove(target); err != nil {
+				return err
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	mode := hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := os.Mkdir(target, 0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+		u.dirs[target] = dirMeta{mode: mode, modTime: hdr.ModTime}
+
+	case tar.TypeReg:
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+	case tar.TypeSymlink:
+		// The target is stored verbatim; it is only ever followed by resolve,
+		// which keeps it inside the root.
+		if err := os.Symlink(hdr.Linkname, target); err != nil {
+			return err
+		}
+		return u.lchown(target, hdr)
+
+	case tar.TypeLink:
+		// Only the parent is resolved: a hardlink to a symlink links the
+		// symlink itself, as runtimes do
+		dir, base := path.Split(path.Clean("/" + hdr.Linkname))
+		parent, err := u.resolve(dir)
+		if err != nil {
+			return err
+		}
+		source := filepath.Join(parent, base)
+		fi, err := os.Lstat(source)
+		if err != nil {
+			return fmt.Errorf("hardlink target %s: %w", hdr.Linkname, err)
+		}
+		if fi.IsDir() {
+			return fmt.Errorf("hardlink target %s is a directory", hdr.Linkname)
+		}
+		// A hardlink shares the target's inode, mode and owner
+		return os.Link(source, target)
+
+	default:
+		// Character and block devices and FIFOs
+		u.skipped++
+		return nil
+	}
+
+	if err := u.lchown(target, hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag == tar.TypeReg {
+		// After chown, which clears the setuid and setgid bits
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+		return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+	}
+	return nil
+}
+
+// lchown applies hdr's owner to target when running as root.
+func (u *Unpacker) lchown(target string, hdr *tar.Header) error {
+	if !u.chown {
+		return nil
+	}
+	return os.Lchown(target, hdr.Uid, hdr.Gid)
+}
+
+// remove deletes target and forgets the directory modes recorded under it.
+func (u *Unpacker) remove(target string) error {
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	prefix := target + string(filepath.Separator)
+	for p := range u.dirs {
+		if p == target || strings.HasPrefix(p, prefix) {
+			delete(u.dirs, p)
+		}
+	}
+	return nil
+}
+
+// clearDir removes the lower-layer contents of the directory at dirPath,
+// named name in the image, keeping entries added by the current layer.
+func (u *Unpacker) clearDir(dirPath, name string, added map[string]bool) error {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		child := path.Join(name, e.Name())
+		childPath := filepath.Join(dirPath, e.Name())
+		if !added[child] {
+			if err := u.remove(childPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if e.IsDir() {
+			if err := u.clearDir(childPath, child, added); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the host path of name, an absolute path in the image,
+// following symlinks as if the root were "/". ".." never leaves the root,
+// and absolute symlink targets restart from it. Components that do not
+// exist yet are joined as they are.
+func (u *Unpacker) resolve(name string) (string, error) {
+	resolved := ""
+	remaining := name
+	links := 0
+
+	for remaining != "" {
+		var part string
+		if i := strings.IndexByte(remaining, '/'); i >= 0 {
+			part, remaining = remaining[:i], remaining[i+1:]
+		} else {
+			part, remaining = remaining, ""
+		}
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			if resolved == "." || resolved == "/" {
+				resolved = ""
+			}
+			continue
+		}
+
+		next := path.Join(resolved, part)
+		fi, err := os.Lstat(filepath.Join(u.root, filepath.FromSlash(next)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				resolved = next
+				continue
+			}
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links")
+		}
+		target, err := os.Readlink(filepath.Join(u.root, filepath.FromSlash(next)))
+		if err != nil {
+			return "", err
+		}
+		target = filepath.ToSlash(target)
+		if path.IsAbs(target) {
+			resolved = ""
+		}
+		remaining = target + "/" + remaining
+	}
+
+	return filepath.Join(u.root, filepath.FromSlash(resolved)), nil
+}
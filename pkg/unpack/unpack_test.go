@@ -0,0 +1,387 @@
+package unpack
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entry is one tar entry of a test layer. A file's mode defaults to 0644
+// and a directory's to 0755.
+type entry struct {
+	name     string
+	typeflag byte
+	body     string
+	linkname string
+	mode     int64
+}
+
+func file(name, body string) entry { return entry{name: name, typeflag: tar.TypeReg, body: body} }
+func dir(name string) entry        { return entry{name: name, typeflag: tar.TypeDir} }
+func symlink(name, target string) entry {
+	return entry{name: name, typeflag: tar.TypeSymlink, linkname: target}
+}
+func hardlink(name, target string) entry {
+	return entry{name: name, typeflag: tar.TypeLink, linkname: target}
+}
+
+var testTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func layer(t *testing.T, entries ...entry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     e.mode,
+			Size:     int64(len(e.body)),
+			ModTime:  testTime,
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+			if e.typeflag == tar.TypeDir {
+				hdr.Mode = 0755
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// unpackLayers applies layers to a new root and returns it.
+func unpackLayers(t *testing.T, layers ...*bytes.Buffer) string {
+	t.Helper()
+	root := t.TempDir()
+	u := NewUnpacker(root)
+	for i, l := range layers {
+		if err := u.ApplyLayer(l); err != nil {
+			t.Fatalf("ApplyLayer(%d) error = %v", i, err)
+		}
+	}
+	if err := u.Finish(); err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	return root
+}
+
+// tree lists the paths under root, with "/" after directories and
+// "-> target" after symlinks.
+func tree(t *testing.T, root string) []string {
+	t.Helper()
+	var paths []string
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, _ := os.Readlink(p)
+			rel += " -> " + target
+		case info.IsDir():
+			rel += "/"
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func assertTree(t *testing.T, root string, want ...string) {
+	t.Helper()
+	sort.Strings(want)
+	got := tree(t, root)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("tree =\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApplyLayerEntries(t *testing.T) {
+	exec := file("bin/tool", "#!/bin/sh\n")
+	exec.mode = 0755
+	readOnly := dir("etc/ro")
+	readOnly.mode = 0555
+
+	root := unpackLayers(t,
+		layer(t,
+			dir("bin/"), exec,
+			dir("etc/"), readOnly, file("etc/ro/conf", "a=1"),
+			symlink("usr/bin/tool", "/bin/tool"),
+			hardlink("bin/tool-link", "bin/tool"),
+			// Implicit parents are created
+			file("var/lib/data/state", "s"),
+		),
+	)
+
+	assertTree(t, root,
+		"bin/", "bin/tool", "bin/tool-link",
+		"etc/", "etc/ro/", "etc/ro/conf",
+		"usr/", "usr/bin/", "usr/bin/tool -> /bin/tool",
+		"var/", "var/lib/", "var/lib/data/", "var/lib/data/state",
+	)
+
+	info, err := os.Stat(filepath.Join(root, "bin/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("bin/tool mode = %v, want 0755", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(testTime) {
+		t.Errorf("bin/tool mtime = %v, want %v", info.ModTime(), testTime)
+	}
+
+	linked, err := os.Stat(filepath.Join(root, "bin/tool-link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(info, linked) {
+		t.Error("bin/tool-link is not a hardlink to bin/tool")
+	}
+
+	ro, err := os.Stat(filepath.Join(root, "etc/ro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ro.Mode().Perm() != 0555 {
+		t.Errorf("etc/ro mode = %v, want 0555 once finished", ro.Mode().Perm())
+	}
+	if !ro.ModTime().Equal(testTime) {
+		t.Errorf("etc/ro mtime = %v, want %v", ro.ModTime(), testTime)
+	}
+}
+
+func TestApplyLayerReplaces(t *testing.T) {
+	root := unpackLayers(t,
+		layer(t, file("a", "lower"), dir("b/"), file("b/x", "x"), file("c", "c")),
+		layer(t, file("a", "upper"), file("b", "now a file"), dir("c/"), file("c/y", "y")),
+	)
+
+	assertTree(t, root, "a", "b", "c/", "c/y")
+	if got := readFile(t, filepath.Join(root, "a")); got != "upper" {
+		t.Errorf("a = %q, want upper", got)
+	}
+}
+
+func TestApplyLayerWhiteouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		layers [][]entry
+		want   []string
+	}{
+		{
+			name: "whiteout file",
+			layers: [][]entry{
+				{file("etc/a", "a"), file("etc/b", "b")},
+				{file("etc/.wh.a", "")},
+			},
+			want: []string{"etc/", "etc/b"},
+		},
+		{
+			name: "whiteout directory",
+			layers: [][]entry{
+				{dir("opt/app/"), file("opt/app/x", "x"), file("opt/keep", "k")},
+				{file("opt/.wh.app", "")},
+			},
+			want: []string{"opt/", "opt/keep"},
+		},
+		{
+			name: "whiteout only hides lower layers",
+			layers: [][]entry{
+				{file("f", "lower")},
+				{file("g", "same layer"), file(".wh.g", "")},
+			},
+			want: []string{"f", "g"},
+		},
+		{
+			name: "opaque directory",
+			layers: [][]entry{
+				{dir("d/"), file("d/old", "o"), dir("d/sub/"), file("d/sub/old", "o"), file("other", "k")},
+				{dir("d/"), file("d/.wh..wh..opq", ""), file("d/new", "n"), file("d/sub/new", "n")},
+			},
+			want: []string{"d/", "d/new", "d/sub/", "d/sub/new", "other"},
+		},
+		{
+			name: "opaque marker after the layer's entries",
+			layers: [][]entry{
+				{file("d/old", "o")},
+				{file("d/new", "n"), file("d/.wh..wh..opq", "")},
+			},
+			want: []string{"d/", "d/new"},
+		},
+		{
+			name: "whiteout of missing file",
+			layers: [][]entry{
+				{file("a", "a")},
+				{file("nothing/.wh.here", "")},
+			},
+			want: []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var layers []*bytes.Buffer
+			for _, entries := range tt.layers {
+				layers = append(layers, layer(t, entries...))
+			}
+			root := unpackLayers(t, layers...)
+			assertTree(t, root, tt.want...)
+		})
+	}
+}
+
+func TestApplyLayerInvalidWhiteout(t *testing.T) {
+	u := NewUnpacker(t.TempDir())
+	if err := u.ApplyLayer(layer(t, file("a/.wh...", ""))); err == nil {
+		t.Error("ApplyLayer() error = nil, want invalid whiteout")
+	}
+}
+
+func TestApplyLayerSymlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("host"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		link   string
+		target string
+	}{
+		{name: "absolute", link: "escape", target: outside},
+		{name: "relative", link: "escape", target: strings.Repeat("../", 20) + strings.TrimPrefix(filepath.ToSlash(outside), "/")},
+		{name: "nested dotdot", link: "a/b/escape", target: "../../../../.." + filepath.ToSlash(outside)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := unpackLayers(t,
+				layer(t, symlink(tt.link, tt.target)),
+				layer(t, file(tt.link+"/pwned", "x"), file(tt.link+"/secret", "overwritten")),
+			)
+
+			if _, err := os.Stat(filepath.Join(outside, "pwned")); !os.IsNotExist(err) {
+				t.Fatalf("file written outside the root: %v", err)
+			}
+			if got := readFile(t, filepath.Join(outside, "secret")); got != "host" {
+				t.Fatalf("host file overwritten: %q", got)
+			}
+			// The writes land where the link points inside the root.
+			inside := filepath.Join(root, filepath.FromSlash(filepath.ToSlash(outside)), "pwned")
+			if got := readFile(t, inside); got != "x" {
+				t.Errorf("%s = %q, want x", inside, got)
+			}
+		})
+	}
+}
+
+func TestApplyLayerSpecialBits(t *testing.T) {
+	setuid := file("bin/su", "su")
+	setuid.mode = 04755
+	setgid := file("bin/wall", "wall")
+	setgid.mode = 02755
+
+	// Ownership is applied before the mode, since chown clears these bits
+	root := unpackLayers(t, layer(t, setuid, setgid))
+	for name, want := range map[string]os.FileMode{
+		"bin/su":   os.ModeSetuid | 0755,
+		"bin/wall": os.ModeSetgid | 0755,
+	} {
+		info, err := os.Stat(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid); got != want {
+			t.Errorf("%s mode = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestApplyLayerHardlinkToSymlink(t *testing.T) {
+	root := unpackLayers(t, layer(t,
+		file("etc/conf", "a=1"),
+		symlink("etc/current", "conf"),
+		hardlink("etc/also-current", "etc/current"),
+	))
+
+	// The link is to the symlink itself, not the file it points to
+	target, err := os.Readlink(filepath.Join(root, "etc/also-current"))
+	if err != nil {
+		t.Fatalf("etc/also-current is not a symlink: %v", err)
+	}
+	if target != "conf" {
+		t.Errorf("etc/also-current -> %s, want conf", target)
+	}
+}
+
+func TestApplyLayerHardlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("host"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	u := NewUnpacker(t.TempDir())
+	err := u.ApplyLayer(layer(t, symlink("escape", outside), hardlink("stolen", "escape/secret")))
+	if err == nil {
+		t.Fatal("ApplyLayer() error = nil, want missing hardlink target")
+	}
+}
+
+func TestApplyLayerSymlinkLoop(t *testing.T) {
+	u := NewUnpacker(t.TempDir())
+	err := u.ApplyLayer(layer(t, symlink("a", "b"), symlink("b", "a"), file("a/x", "x")))
+	if err == nil || !strings.Contains(err.Error(), "too many levels of symbolic links") {
+		t.Errorf("ApplyLayer() error = %v, want symlink loop", err)
+	}
+}
+
+func TestApplyLayerSkipsDevices(t *testing.T) {
+	root := t.TempDir()
+	u := NewUnpacker(root)
+	err := u.ApplyLayer(layer(t,
+		entry{name: "dev/null", typeflag: tar.TypeChar},
+		entry{name: "run/fifo", typeflag: tar.TypeFifo},
+		file("etc/hostname", "box"),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Skipped() != 2 {
+		t.Errorf("Skipped() = %d, want 2", u.Skipped())
+	}
+	assertTree(t, root, "dev/", "etc/", "etc/hostname", "run/")
+}
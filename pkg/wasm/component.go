@@ -0,0 +1,1003 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Component section IDs.
+const (
+	compSectionCustom       = 0
+	compSectionCoreModule   = 1
+	compSectionCoreInstance = 2
+	compSectionCoreType     = 3
+	compSectionComponent    = 4
+	compSectionInstance     = 5
+	compSectionAlias        = 6
+	compSectionType         = 7
+	compSectionCanon        = 8
+	compSectionStart        = 9
+	compSectionImport       = 10
+	compSectionExport       = 11
+)
+
+// Component sorts (the kinds of things that can be imported or exported).
+const (
+	sortCore      = 0x00
+	sortFunc      = 0x01
+	sortValue     = 0x02
+	sortType      = 0x03
+	sortComponent = 0x04
+	sortInstance  = 0x05
+)
+
+var sortNames = map[byte]string{
+	sortCore:      "core",
+	sortFunc:      "func",
+	sortValue:     "value",
+	sortType:      "type",
+	sortComponent: "component",
+	sortInstance:  "instance",
+}
+
+// errUnsupported stops decoding of a construct the parser does not model.
+var errUnsupported = errors.New("unsupported component encoding")
+
+// compType is a resolved entry in a component type index space.
+type compType struct {
+	kind    string   // func, instance, component, resource, value, type
+	name    string   // exported name, for named types such as resources
+	sig     string   // rendered func or value type
+	members []Export // exports of instance and component types
+}
+
+// typeSpace is a type index space. Instance and component types have their
+// own local spaces that can alias into the enclosing one.
+type typeSpace struct {
+	types  []compType
+	parent *typeSpace
+	broken bool // an undecodable definition shifted later indices
+}
+
+func (s *typeSpace) add(t compType) {
+	s.types = append(s.types, t)
+}
+
+func (s *typeSpace) get(idx uint32) (compType, bool) {
+	if s.broken || int(idx) >= len(s.types) {
+		return compType{}, false
+	}
+	return s.types[idx], true
+}
+
+// maxInlineSig is the longest signature render inlines. Longer ones are
+// referred to by index: inlining every reference lets a chain of types
+// such as tuple<T, T> double in length at each step.
+const maxInlineSig = 64
+
+// render names a type reference for use inside a signature.
+func (s *typeSpace) render(idx uint32) string {
+	t, ok := s.get(idx)
+	switch {
+	case !ok:
+		return fmt.Sprintf("type%d", idx)
+	case t.name != "":
+		return t.name
+	case t.sig != "" && len(t.sig) <= maxInlineSig:
+		return t.sig
+	default:
+		return fmt.Sprintf("type%d", idx)
+	}
+}
+
+// componentParser tracks the index spaces needed to resolve signatures.
+type componentParser struct {
+	m         *Module
+	types     *typeSpace
+	funcs     []string   // rendered signature per component func
+	instances [][]Export // members per component instance
+	funcsOK   bool
+	instOK    bool
+}
+
+func parseComponent(data []byte) (*Module, error) {
+	p := &componentParser{
+		m:       &Module{Kind: KindComponent, Imports: []Import{}, Exports: []Export{}},
+		types:   &typeSpace{},
+		funcsOK: true,
+		instOK:  true,
+	}
+
+	err := forEachSection(data, func(id byte, payload []byte) error {
+		r := newReader(payload)
+		switch id {
+		case compSectionCustom:
+			p.m.parseCustomSection(payload)
+		case compSectionCoreModule:
+			if core, err := Parse(payload); err == nil {
+				p.m.CoreModules = append(p.m.CoreModules, core)
+			}
+		case compSectionInstance:
+			p.parseInstances(r)
+		case compSectionAlias:
+			p.parseAliases(r)
+		case compSectionType:
+			p.parseTypes(r)
+		case compSectionCanon:
+			p.parseCanon(r)
+		case compSectionImport:
+			p.parseImports(r)
+		case compSectionExport:
+			p.parseExports(r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid component: %w", err)
+	}
+
+	return p.m, nil
+}
+
+func (p *componentParser) parseTypes(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		p.types.broken = true
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		t, err := readDefType(r, p.types)
+		if err != nil {
+			p.types.broken = true
+			return
+		}
+		p.types.add(t)
+	}
+}
+
+func (p *componentParser) parseImports(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		name, err := readExternName(r)
+		if err != nil {
+			return
+		}
+		desc, err := readExternDesc(r, p.types)
+		if err != nil {
+			return
+		}
+		p.m.Imports = append(p.m.Imports, Import{
+			Name:      name,
+			Kind:      desc.kind,
+			Signature: desc.signature(),
+			Members:   desc.members,
+		})
+		p.addToIndexSpace(desc, name)
+	}
+}
+
+func (p *componentParser) parseExports(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		name, err := readExternName(r)
+		if err != nil {
+			return
+		}
+		sort, idx, err := readSortIdx(r)
+		if err != nil {
+			return
+		}
+
+		// The referenced item supplies the type unless one is ascribed.
+		desc := p.describeSortIdx(sort, idx)
+		hasDesc, err := r.readOptional()
+		if err != nil {
+			return
+		}
+		if hasDesc {
+			if desc, err = readExternDesc(r, p.types); err != nil {
+				return
+			}
+		}
+
+		p.m.Exports = append(p.m.Exports, Export{
+			Name:      name,
+			Kind:      desc.kind,
+			Signature: desc.signature(),
+			Members:   desc.members,
+		})
+		p.addToIndexSpace(desc, name)
+	}
+}
+
+// addToIndexSpace records a new func, instance, or type introduced by an
+// import or export so later references resolve.
+func (p *componentParser) addToIndexSpace(d externDesc, name string) {
+	switch d.kind {
+	case "func":
+		p.funcs = append(p.funcs, d.sig)
+	case "instance":
+		p.instances = append(p.instances, d.members)
+	case "type", "resource":
+		t := d.typ
+		t.name = name
+		p.types.add(t)
+	}
+}
+
+// describeSortIdx resolves an existing index to a description.
+func (p *componentParser) describeSortIdx(sort byte, idx uint32) externDesc {
+	d := externDesc{kind: sortNames[sort]}
+	switch sort {
+	case sortFunc:
+		if p.funcsOK && int(idx) < len(p.funcs) {
+			d.sig = p.funcs[idx]
+		}
+	case sortInstance:
+		if p.instOK && int(idx) < len(p.instances) {
+			d.members = p.instances[idx]
+		}
+	case sortType:
+		if t, ok := p.types.get(idx); ok {
+			d.typ = t
+			if t.kind == "resource" {
+				d.kind = "resource"
+			}
+		}
+	}
+	return d
+}
+
+func (p *componentParser) parseInstances(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		p.instOK = false
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		form, err := r.readByte()
+		if err != nil {
+			p.instOK = false
+			return
+		}
+		switch form {
+		case 0x00: // instantiate a component with named arguments
+			if _, err := r.readU32(); err != nil {
+				p.instOK = false
+				return
+			}
+			n, err := r.readU32()
+			if err != nil {
+				p.instOK = false
+				return
+			}
+			for j := uint32(0); j < n; j++ {
+				if _, err := r.readName(); err != nil {
+					p.instOK = false
+					return
+				}
+				if _, _, err := readSortIdx(r); err != nil {
+					p.instOK = false
+					return
+				}
+			}
+			p.instances = append(p.instances, nil)
+		case 0x01: // instance from inline exports
+			n, err := r.readU32()
+			if err != nil {
+				p.instOK = false
+				return
+			}
+			var members []Export
+			for j := uint32(0); j < n; j++ {
+				name, err := readExternName(r)
+				if err != nil {
+					p.instOK = false
+					return
+				}
+				sort, idx, err := readSortIdx(r)
+				if err != nil {
+					p.instOK = false
+					return
+				}
+				d := p.describeSortIdx(sort, idx)
+				members = append(members, Export{Name: name, Kind: d.kind, Signature: d.signature()})
+			}
+			p.instances = append(p.instances, members)
+		default:
+			p.instOK = false
+			return
+		}
+	}
+}
+
+func (p *componentParser) parseAliases(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		p.funcsOK, p.instOK, p.types.broken = false, false, true
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		a, err := readAlias(r)
+		if err != nil {
+			p.funcsOK, p.instOK, p.types.broken = false, false, true
+			return
+		}
+
+		// Aliasing an export of a typed instance keeps its signature.
+		var member *Export
+		if a.target == 0x00 && p.instOK && int(a.instance) < len(p.instances) {
+			for j := range p.instances[a.instance] {
+				if p.instances[a.instance][j].Name == a.name {
+					member = &p.instances[a.instance][j]
+					break
+				}
+			}
+		}
+
+		switch a.sort {
+		case sortFunc:
+			sig := ""
+			if member != nil {
+				sig = member.Signature
+			}
+			p.funcs = append(p.funcs, sig)
+		case sortInstance:
+			var members []Export
+			if member != nil {
+				members = member.Members
+			}
+			p.instances = append(p.instances, members)
+		case sortType:
+			t := compType{kind: "type", name: a.name}
+			if a.target == 0x02 {
+				t = p.outerType(a.count, a.index)
+			} else if member != nil && member.Kind == "resource" {
+				t.kind = "resource"
+			}
+			p.types.add(t)
+		}
+	}
+}
+
+// outerType resolves an outer alias from the top-level component's view.
+func (p *componentParser) outerType(count, idx uint32) compType {
+	if count == 0 {
+		if t, ok := p.types.get(idx); ok {
+			return t
+		}
+	}
+	return compType{kind: "type"}
+}
+
+// parseCanon tracks functions introduced by canon lift. Other canonical
+// built-ins produce core functions and are skipped.
+func (p *componentParser) parseCanon(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		p.funcsOK = false
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		op, err := r.readByte()
+		if err != nil {
+			p.funcsOK = false
+			return
+		}
+		switch op {
+		case 0x00: // lift
+			if err := skipBytes(r, 1); err != nil {
+				p.funcsOK = false
+				return
+			}
+			if _, err := r.readU32(); err != nil {
+				p.funcsOK = false
+				return
+			}
+			if err := skipCanonOpts(r); err != nil {
+				p.funcsOK = false
+				return
+			}
+			typeIdx, err := r.readU32()
+			if err != nil {
+				p.funcsOK = false
+				return
+			}
+			sig := ""
+			if t, ok := p.types.get(typeIdx); ok {
+				sig = t.sig
+			}
+			p.funcs = append(p.funcs, sig)
+		case 0x01: // lower
+			if err := skipBytes(r, 1); err != nil {
+				p.funcsOK = false
+				return
+			}
+			if _, err := r.readU32(); err != nil {
+				p.funcsOK = false
+				return
+			}
+			if err := skipCanonOpts(r); err != nil {
+				p.funcsOK = false
+				return
+			}
+		case 0x02, 0x03, 0x04: // resource.new, resource.drop, resource.rep
+			if _, err := r.readU32(); err != nil {
+				p.funcsOK = false
+				return
+			}
+		default:
+			// Later canonical built-ins (async, threads) have varied
+			// immediates; stop here. No further component funcs are
+			// created by this section, so the func space stays valid.
+			return
+		}
+	}
+}
+
+func skipBytes(r *reader, n int) error {
+	_, err := r.readBytes(n)
+	return err
+}
+
+func skipCanonOpts(r *reader) error {
+	n, err := r.readU32()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < n; i++ {
+		opt, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		switch opt {
+		case 0x00, 0x01, 0x02, 0x06: // string encodings, async
+		case 0x03, 0x04, 0x05, 0x07: // memory, realloc, post-return, callback
+			if _, err := r.readU32(); err != nil {
+				return err
+			}
+		default:
+			return errUnsupported
+		}
+	}
+	return nil
+}
+
+// alias is a decoded alias definition.
+type alias struct {
+	sort     byte
+	target   byte // 0x00 export, 0x01 core export, 0x02 outer
+	instance uint32
+	name     string
+	count    uint32 // outer: enclosing component count
+	index    uint32 // outer: index in that component
+}
+
+func readAlias(r *reader) (alias, error) {
+	var a alias
+	sort, err := r.readByte()
+	if err != nil {
+		return a, err
+	}
+	if sort == sortCore {
+		if _, err := r.readByte(); err != nil {
+			return a, err
+		}
+	}
+	a.sort = sort
+
+	if a.target, err = r.readByte(); err != nil {
+		return a, err
+	}
+	switch a.target {
+	case 0x00, 0x01:
+		if a.instance, err = r.readU32(); err != nil {
+			return a, err
+		}
+		a.name, err = r.readName()
+	case 0x02:
+		if a.count, err = r.readU32(); err != nil {
+			return a, err
+		}
+		a.index, err = r.readU32()
+	default:
+		err = errUnsupported
+	}
+	return a, err
+}
+
+// readExternName decodes an import or export name. Both the plain (0x00)
+// and legacy interface (0x01) forms carry a single string.
+func readExternName(r *reader) (string, error) {
+	form, err := r.readByte()
+	if err != nil {
+		return "", err
+	}
+	if form != 0x00 && form != 0x01 {
+		return "", errUnsupported
+	}
+	return r.readName()
+}
+
+func readSortIdx(r *reader) (byte, uint32, error) {
+	sort, err := r.readByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	if sort == sortCore {
+		if _, err := r.readByte(); err != nil {
+			return 0, 0, err
+		}
+	}
+	idx, err := r.readU32()
+	return sort, idx, err
+}
+
+// externDesc is a decoded import/export type.
+type externDesc struct {
+	kind    string
+	sig     string
+	members []Export
+	typ     compType
+}
+
+// signature renders the description for display.
+func (d externDesc) signature() string {
+	switch d.kind {
+	case "func", "value":
+		return d.sig
+	case "instance", "component":
+		if len(d.members) == 0 {
+			return ""
+		}
+		funcs := 0
+		for _, m := range d.members {
+			if m.Kind == "func" {
+				funcs++
+			}
+		}
+		return fmt.Sprintf("%d funcs, %d exports", funcs, len(d.members))
+	}
+	return ""
+}
+
+func readExternDesc(r *reader, space *typeSpace) (externDesc, error) {
+	kind, err := r.readByte()
+	if err != nil {
+		return externDesc{}, err
+	}
+
+	switch kind {
+	case 0x00: // core module
+		if err := skipBytes(r, 1); err != nil {
+			return externDesc{}, err
+		}
+		_, err := r.readU32()
+		return externDesc{kind: "core module"}, err
+
+	case 0x01: // func
+		idx, err := r.readU32()
+		if err != nil {
+			return externDesc{}, err
+		}
+		d := externDesc{kind: "func"}
+		if t, ok := space.get(idx); ok {
+			d.sig = t.sig
+		}
+		return d, nil
+
+	case 0x02: // value
+		bound, err := r.readByte()
+		if err != nil {
+			return externDesc{}, err
+		}
+		d := externDesc{kind: "value"}
+		if bound == 0x00 {
+			_, err = r.readU32()
+		} else {
+			d.sig, err = readCompValType(r, space)
+		}
+		return d, err
+
+	case 0x03: // type
+		bound, err := r.readByte()
+		if err != nil {
+			return externDesc{}, err
+		}
+		if bound == 0x01 {
+			return externDesc{kind: "resource", typ: compType{kind: "resource"}}, nil
+		}
+		idx, err := r.readU32()
+		if err != nil {
+			return externDesc{}, err
+		}
+		d := externDesc{kind: "type", typ: compType{kind: "type"}}
+		if t, ok := space.get(idx); ok {
+			d.typ = t
+			d.typ.name = ""
+			if t.kind == "resource" {
+				d.kind = "resource"
+			}
+		}
+		return d, nil
+
+	case 0x04, 0x05: // component, instance
+		idx, err := r.readU32()
+		if err != nil {
+			return externDesc{}, err
+		}
+		d := externDesc{kind: "component"}
+		if kind == 0x05 {
+			d.kind = "instance"
+		}
+		if t, ok := space.get(idx); ok {
+			d.members = t.members
+		}
+		return d, nil
+	}
+	return externDesc{}, errUnsupported
+}
+
+// Component primitive value types, by encoding byte.
+var compPrimitives = map[byte]string{
+	0x7F: "bool",
+	0x7E: "s8",
+	0x7D: "u8",
+	0x7C: "s16",
+	0x7B: "u16",
+	0x7A: "s32",
+	0x79: "u32",
+	0x78: "s64",
+	0x77: "u64",
+	0x76: "f32",
+	0x75: "f64",
+	0x74: "char",
+	0x73: "string",
+	0x64: "error-context",
+}
+
+// readCompValType decodes a valtype: a primitive or a type index (s33).
+func readCompValType(r *reader, space *typeSpace) (string, error) {
+	v, err := r.readSleb(5)
+	if err != nil {
+		return "", err
+	}
+	if v < 0 {
+		if name, ok := compPrimitives[byte(v+0x80)]; ok {
+			return name, nil
+		}
+		return "", errUnsupported
+	}
+	return space.render(uint32(v)), nil
+}
+
+func readOptValType(r *reader, space *typeSpace) (string, bool, error) {
+	present, err := r.readOptional()
+	if err != nil || !present {
+		return "", false, err
+	}
+	t, err := readCompValType(r, space)
+	return t, true, err
+}
+
+// readDefType decodes a component-level type definition.
+func readDefType(r *reader, space *typeSpace) (compType, error) {
+	b, err := r.peekByte()
+	if err != nil {
+		return compType{}, err
+	}
+
+	switch b {
+	case 0x40, 0x43: // func, async func
+		r.pos++
+		sig, err := readCompFuncType(r, space)
+		return compType{kind: "func", sig: sig}, err
+
+	case 0x41: // component type
+		r.pos++
+		members, err := readDecls(r, space, true)
+		return compType{kind: "component", members: members}, err
+
+	case 0x42: // instance type
+		r.pos++
+		members, err := readDecls(r, space, false)
+		return compType{kind: "instance", members: members}, err
+
+	case 0x3F: // resource
+		r.pos++
+		if err := skipBytes(r, 1); err != nil {
+			return compType{}, err
+		}
+		if present, err := r.readOptional(); err != nil {
+			return compType{}, err
+		} else if present {
+			if _, err := r.readU32(); err != nil {
+				return compType{}, err
+			}
+		}
+		return compType{kind: "resource"}, nil
+	}
+
+	sig, err := readDefValType(r, space)
+	return compType{kind: "value", sig: sig}, err
+}
+
+// readCompFuncType decodes params and results after the func form byte.
+func readCompFuncType(r *reader, space *typeSpace) (string, error) {
+	params, err := readLabeledTypes(r, space)
+	if err != nil {
+		return "", err
+	}
+	sig := "func(" + strings.Join(params, ", ") + ")"
+
+	form, err := r.readByte()
+	if err != nil {
+		return "", err
+	}
+	switch form {
+	case 0x00:
+		result, err := readCompValType(r, space)
+		if err != nil {
+			return "", err
+		}
+		sig += " -> " + result
+	case 0x01:
+		results, err := readLabeledTypes(r, space)
+		if err != nil {
+			return "", err
+		}
+		if len(results) > 0 {
+			sig += " -> (" + strings.Join(results, ", ") + ")"
+		}
+	default:
+		return "", errUnsupported
+	}
+	return sig, nil
+}
+
+// readLabeledTypes decodes vec(label valtype) as "label: type" strings.
+func readLabeledTypes(r *reader, space *typeSpace) ([]string, error) {
+	n, err := r.readU32()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for i := uint32(0); i < n; i++ {
+		label, err := r.readName()
+		if err != nil {
+			return nil, err
+		}
+		t, err := readCompValType(r, space)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, label+": "+t)
+	}
+	return out, nil
+}
+
+func readLabels(r *reader) ([]string, error) {
+	n, err := r.readU32()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for i := uint32(0); i < n; i++ {
+		l, err := r.readName()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+// readDefValType decodes a defined value type into WIT-like syntax.
+func readDefValType(r *reader, space *typeSpace) (string, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return "", err
+	}
+	if name, ok := compPrimitives[b]; ok {
+		return name, nil
+	}
+
+	switch b {
+	case 0x72: // record
+		fields, err := readLabeledTypes(r, space)
+		return "record { " + strings.Join(fields, ", ") + " }", err
+
+	case 0x71: // variant
+		n, err := r.readU32()
+		if err != nil {
+			return "", err
+		}
+		var cases []string
+		for i := uint32(0); i < n; i++ {
+			label, err := r.readName()
+			if err != nil {
+				return "", err
+			}
+			t, ok, err := readOptValType(r, space)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				label += "(" + t + ")"
+			}
+			// Trailing "refines" index, always absent in current encodings.
+			if present, err := r.readOptional(); err != nil {
+				return "", err
+			} else if present {
+				if _, err := r.readU32(); err != nil {
+					return "", err
+				}
+			}
+			cases = append(cases, label)
+		}
+		return "variant { " + strings.Join(cases, ", ") + " }", nil
+
+	case 0x70: // list
+		t, err := readCompValType(r, space)
+		return "list<" + t + ">", err
+
+	case 0x67: // fixed-size list
+		t, err := readCompValType(r, space)
+		if err != nil {
+			return "", err
+		}
+		n, err := r.readU32()
+		return fmt.Sprintf("list<%s, %d>", t, n), err
+
+	case 0x6F: // tuple
+		n, err := r.readU32()
+		if err != nil {
+			return "", err
+		}
+		var elems []string
+		for i := uint32(0); i < n; i++ {
+			t, err := readCompValType(r, space)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, t)
+		}
+		return "tuple<" + strings.Join(elems, ", ") + ">", nil
+
+	case 0x6E: // flags
+		labels, err := readLabels(r)
+		return "flags { " + strings.Join(labels, ", ") + " }", err
+
+	case 0x6D: // enum
+		labels, err := readLabels(r)
+		return "enum { " + strings.Join(labels, ", ") + " }", err
+
+	case 0x6B: // option
+		t, err := readCompValType(r, space)
+		return "option<" + t + ">", err
+
+	case 0x6A: // result
+		ok, hasOK, err := readOptValType(r, space)
+		if err != nil {
+			return "", err
+		}
+		e, hasErr, err := readOptValType(r, space)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case hasOK && hasErr:
+			return "result<" + ok + ", " + e + ">", nil
+		case hasOK:
+			return "result<" + ok + ">", nil
+		case hasErr:
+			return "result<_, " + e + ">", nil
+		}
+		return "result", nil
+
+	case 0x69, 0x68: // own, borrow
+		idx, err := r.readU32()
+		if err != nil {
+			return "", err
+		}
+		if b == 0x69 {
+			return "own<" + space.render(idx) + ">", nil
+		}
+		return "borrow<" + space.render(idx) + ">", nil
+
+	case 0x66, 0x65: // stream, future
+		t, ok, err := readOptValType(r, space)
+		if err != nil {
+			return "", err
+		}
+		name := "stream"
+		if b == 0x65 {
+			name = "future"
+		}
+		if ok {
+			return name + "<" + t + ">", nil
+		}
+		return name, nil
+	}
+	return "", errUnsupported
+}
+
+// readDecls decodes the declarations of an instance or component type,
+// returning its exports. Declarations use a fresh local type space whose
+// outer aliases resolve against space.
+func readDecls(r *reader, space *typeSpace, component bool) ([]Export, error) {
+	n, err := r.readU32()
+	if err != nil {
+		return nil, err
+	}
+	local := &typeSpace{parent: space}
+	var exports []Export
+
+	for i := uint32(0); i < n; i++ {
+		tag, err := r.readByte()
+		if err != nil {
+			return exports, err
+		}
+		switch tag {
+		case 0x01: // type
+			t, err := readDefType(r, local)
+			if err != nil {
+				return exports, err
+			}
+			local.add(t)
+
+		case 0x02: // alias
+			a, err := readAlias(r)
+			if err != nil {
+				return exports, err
+			}
+			if a.sort == sortType {
+				t := compType{kind: "type"}
+				if a.target == 0x02 {
+					target := local
+					for c := uint32(0); c < a.count && target != nil; c++ {
+						target = target.parent
+					}
+					if target != nil {
+						if resolved, ok := target.get(a.index); ok {
+							t = resolved
+						}
+					}
+				}
+				local.add(t)
+			}
+
+		case 0x03, 0x04: // import (component types only), export
+			if tag == 0x03 && !component {
+				return exports, errUnsupported
+			}
+			name, err := readExternName(r)
+			if err != nil {
+				return exports, err
+			}
+			d, err := readExternDesc(r, local)
+			if err != nil {
+				return exports, err
+			}
+			if d.kind == "type" || d.kind == "resource" {
+				t := d.typ
+				t.name = name
+				local.add(t)
+			}
+			if tag == 0x04 {
+				exports = append(exports, Export{Name: name, Kind: d.kind, Signature: d.signature()})
+			}
+
+		default: // core types are not modeled
+			return exports, errUnsupported
+		}
+	}
+	return exports, nil
+}
@@ -0,0 +1,485 @@
+package wasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Core module section IDs.
+const (
+	sectionCustom   = 0
+	sectionType     = 1
+	sectionImport   = 2
+	sectionFunction = 3
+	sectionTable    = 4
+	sectionMemory   = 5
+	sectionGlobal   = 6
+	sectionExport   = 7
+)
+
+// Core external kinds shared by imports and exports.
+var coreExternKinds = map[byte]string{
+	0x00: "func",
+	0x01: "table",
+	0x02: "memory",
+	0x03: "global",
+	0x04: "tag",
+}
+
+// coreModule accumulates the index spaces needed to render export signatures.
+type coreModule struct {
+	types   []string // rendered function types; "" when undecodable
+	funcs   []uint32 // type index per function (imports first)
+	tables  []string
+	mems    []string
+	globals []string
+	tags    []string
+}
+
+func parseCore(data []byte) (*Module, error) {
+	m := &Module{Kind: KindCore, Imports: []Import{}, Exports: []Export{}}
+	c := &coreModule{}
+	importedFuncs := 0
+
+	err := forEachSection(data, func(id byte, payload []byte) error {
+		r := newReader(payload)
+		switch id {
+		case sectionCustom:
+			m.parseCustomSection(payload)
+		case sectionType:
+			c.parseTypes(r)
+		case sectionImport:
+			imports := c.parseImports(r)
+			m.Imports = append(m.Imports, imports...)
+			for _, imp := range imports {
+				if imp.Kind == "func" {
+					importedFuncs++
+				}
+			}
+		case sectionFunction:
+			c.parseFunctions(r)
+		case sectionTable:
+			c.parseTables(r)
+		case sectionMemory:
+			c.parseMemories(r)
+		case sectionGlobal:
+			c.parseGlobals(r)
+		case sectionExport:
+			m.Exports = append(m.Exports, c.parseExports(r)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid core module: %w", err)
+	}
+
+	m.Functions = len(c.funcs) - importedFuncs
+	return m, nil
+}
+
+// parseTypes decodes function types. Types introduced by the GC proposal
+// (rec groups, sub types, structs, arrays) stop decoding; indices past that
+// point render without a signature.
+func (c *coreModule) parseTypes(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		form, err := r.readByte()
+		if err != nil || form != 0x60 {
+			return
+		}
+		sig, err := readFuncType(r)
+		if err != nil {
+			return
+		}
+		c.types = append(c.types, sig)
+	}
+}
+
+// readFuncType decodes the params and results following a 0x60 form byte.
+func readFuncType(r *reader) (string, error) {
+	params, err := readValTypes(r)
+	if err != nil {
+		return "", err
+	}
+	results, err := readValTypes(r)
+	if err != nil {
+		return "", err
+	}
+
+	sig := "(" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+	case 1:
+		sig += " -> " + results[0]
+	default:
+		sig += " -> (" + strings.Join(results, ", ") + ")"
+	}
+	return sig, nil
+}
+
+func readValTypes(r *reader) ([]string, error) {
+	n, err := r.readU32()
+	if err != nil {
+		return nil, err
+	}
+	var types []string
+	for i := uint32(0); i < n; i++ {
+		t, err := readValType(r)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// Core value and abstract heap type names by encoding byte.
+var coreValTypes = map[byte]string{
+	0x7F: "i32",
+	0x7E: "i64",
+	0x7D: "f32",
+	0x7C: "f64",
+	0x7B: "v128",
+	0x70: "funcref",
+	0x6F: "externref",
+	0x6E: "anyref",
+	0x6D: "eqref",
+	0x6C: "i31ref",
+	0x6B: "structref",
+	0x6A: "arrayref",
+	0x69: "exnref",
+	0x71: "nullref",
+	0x72: "nullexternref",
+	0x73: "nullfuncref",
+}
+
+var heapTypes = map[byte]string{
+	0x70: "func",
+	0x6F: "extern",
+	0x6E: "any",
+	0x6D: "eq",
+	0x6C: "i31",
+	0x6B: "struct",
+	0x6A: "array",
+	0x69: "exn",
+	0x71: "none",
+	0x72: "noextern",
+	0x73: "nofunc",
+}
+
+func readValType(r *reader) (string, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return "", err
+	}
+	if name, ok := coreValTypes[b]; ok {
+		return name, nil
+	}
+	if b == 0x64 || b == 0x63 {
+		ht, err := r.readSleb(5)
+		if err != nil {
+			return "", err
+		}
+		heap := fmt.Sprintf("%d", ht)
+		if ht < 0 {
+			if name, ok := heapTypes[byte(ht+0x80)]; ok {
+				heap = name
+			}
+		}
+		if b == 0x63 {
+			return "(ref null " + heap + ")", nil
+		}
+		return "(ref " + heap + ")", nil
+	}
+	return "", fmt.Errorf("unknown value type 0x%02x", b)
+}
+
+// readLimits decodes table/memory limits and renders them as "min..max".
+func readLimits(r *reader) (limits string, shared bool, err error) {
+	flags, err := r.readByte()
+	if err != nil {
+		return "", false, err
+	}
+	min, err := r.readU64()
+	if err != nil {
+		return "", false, err
+	}
+	limits = fmt.Sprintf("%d", min)
+	if flags&0x01 != 0 {
+		max, err := r.readU64()
+		if err != nil {
+			return "", false, err
+		}
+		limits = fmt.Sprintf("%d..%d", min, max)
+	}
+	return limits, flags&0x02 != 0, nil
+}
+
+func readTableType(r *reader) (string, error) {
+	ref, err := readValType(r)
+	if err != nil {
+		return "", err
+	}
+	limits, _, err := readLimits(r)
+	if err != nil {
+		return "", err
+	}
+	return ref + " " + limits, nil
+}
+
+func readMemType(r *reader) (string, error) {
+	limits, shared, err := readLimits(r)
+	if err != nil {
+		return "", err
+	}
+	s := limits + " pages"
+	if shared {
+		s += " shared"
+	}
+	return s, nil
+}
+
+func readGlobalType(r *reader) (string, error) {
+	vt, err := readValType(r)
+	if err != nil {
+		return "", err
+	}
+	mut, err := r.readByte()
+	if err != nil {
+		return "", err
+	}
+	if mut == 0x01 {
+		return "mut " + vt, nil
+	}
+	return vt, nil
+}
+
+func (c *coreModule) funcSig(idx uint32) string {
+	if int(idx) < len(c.funcs) {
+		if t := c.funcs[idx]; int(t) < len(c.types) {
+			return c.types[t]
+		}
+	}
+	return ""
+}
+
+func (c *coreModule) typeSig(idx uint32) string {
+	if int(idx) < len(c.types) {
+		return c.types[idx]
+	}
+	return ""
+}
+
+// parseImports decodes the import section. Decoding stops at the first
+// malformed entry; the imports read so far are returned.
+func (c *coreModule) parseImports(r *reader) []Import {
+	count, err := r.readU32()
+	if err != nil {
+		return nil
+	}
+	var imports []Import
+	for i := uint32(0); i < count; i++ {
+		module, err := r.readName()
+		if err != nil {
+			return imports
+		}
+		name, err := r.readName()
+		if err != nil {
+			return imports
+		}
+		kind, err := r.readByte()
+		if err != nil {
+			return imports
+		}
+
+		imp := Import{Module: module, Name: name, Kind: coreExternKinds[kind]}
+		switch kind {
+		case 0x00:
+			idx, err := r.readU32()
+			if err != nil {
+				return imports
+			}
+			c.funcs = append(c.funcs, idx)
+			imp.Signature = c.typeSig(idx)
+		case 0x01:
+			imp.Signature, err = readTableType(r)
+			c.tables = append(c.tables, imp.Signature)
+		case 0x02:
+			imp.Signature, err = readMemType(r)
+			c.mems = append(c.mems, imp.Signature)
+		case 0x03:
+			imp.Signature, err = readGlobalType(r)
+			c.globals = append(c.globals, imp.Signature)
+		case 0x04:
+			if _, err = r.readByte(); err == nil {
+				var idx uint32
+				idx, err = r.readU32()
+				imp.Signature = c.typeSig(idx)
+				c.tags = append(c.tags, imp.Signature)
+			}
+		default:
+			return imports
+		}
+		if err != nil {
+			return imports
+		}
+		imports = append(imports, imp)
+	}
+	return imports
+}
+
+func (c *coreModule) parseFunctions(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		idx, err := r.readU32()
+		if err != nil {
+			return
+		}
+		c.funcs = append(c.funcs, idx)
+	}
+}
+
+func (c *coreModule) parseTables(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		// Tables with an explicit initializer are prefixed by 0x40 0x00.
+		if b, err := r.peekByte(); err == nil && b == 0x40 {
+			if _, err := r.readBytes(2); err != nil {
+				return
+			}
+			sig, err := readTableType(r)
+			if err != nil || skipConstExpr(r) != nil {
+				return
+			}
+			c.tables = append(c.tables, sig)
+			continue
+		}
+		sig, err := readTableType(r)
+		if err != nil {
+			return
+		}
+		c.tables = append(c.tables, sig)
+	}
+}
+
+func (c *coreModule) parseMemories(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		sig, err := readMemType(r)
+		if err != nil {
+			return
+		}
+		c.mems = append(c.mems, sig)
+	}
+}
+
+func (c *coreModule) parseGlobals(r *reader) {
+	count, err := r.readU32()
+	if err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		sig, err := readGlobalType(r)
+		if err != nil || skipConstExpr(r) != nil {
+			return
+		}
+		c.globals = append(c.globals, sig)
+	}
+}
+
+// parseExports decodes the export section, stopping at the first malformed entry.
+func (c *coreModule) parseExports(r *reader) []Export {
+	count, err := r.readU32()
+	if err != nil {
+		return nil
+	}
+	var exports []Export
+	for i := uint32(0); i < count; i++ {
+		name, err := r.readName()
+		if err != nil {
+			return exports
+		}
+		kind, err := r.readByte()
+		if err != nil {
+			return exports
+		}
+		idx, err := r.readU32()
+		if err != nil {
+			return exports
+		}
+
+		exp := Export{Name: name, Kind: coreExternKinds[kind]}
+		switch kind {
+		case 0x00:
+			exp.Signature = c.funcSig(idx)
+		case 0x01:
+			exp.Signature = indexOr(c.tables, idx)
+		case 0x02:
+			exp.Signature = indexOr(c.mems, idx)
+		case 0x03:
+			exp.Signature = indexOr(c.globals, idx)
+		case 0x04:
+			exp.Signature = indexOr(c.tags, idx)
+		default:
+			exp.Kind = fmt.Sprintf("unknown(0x%02x)", kind)
+		}
+		exports = append(exports, exp)
+	}
+	return exports
+}
+
+func indexOr(list []string, idx uint32) string {
+	if int(idx) < len(list) {
+		return list[idx]
+	}
+	return ""
+}
+
+// skipConstExpr advances past a constant expression terminated by 0x0B.
+// Only the instructions permitted in constant expressions are understood.
+func skipConstExpr(r *reader) error {
+	for {
+		op, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case 0x0B: // end
+			return nil
+		case 0x41: // i32.const
+			_, err = r.readSleb(5)
+		case 0x42: // i64.const
+			_, err = r.readSleb(10)
+		case 0x43: // f32.const
+			_, err = r.readBytes(4)
+		case 0x44: // f64.const
+			_, err = r.readBytes(8)
+		case 0x23, 0xD2: // global.get, ref.func
+			_, err = r.readU32()
+		case 0xD0: // ref.null
+			_, err = r.readSleb(5)
+		case 0x6A, 0x6B, 0x6C, 0x7C, 0x7D, 0x7E: // extended-const arithmetic
+		case 0xFD: // v128.const
+			if _, err = r.readU32(); err == nil {
+				_, err = r.readBytes(16)
+			}
+		default:
+			return fmt.Errorf("unsupported constant instruction 0x%02x", op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package wasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+)
+
+// Fetch downloads the wasm layer of the artifact at reference and parses it.
+// The first layer with a wasm media type is used; a single-layer artifact
+// is tried regardless of media type.
+func Fetch(f registry.Fetcher, repoPath, reference string) (*Module, error) {
+	manifest, _, err := f.GetManifest(repoPath, reference)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Manifests) > 0 {
+		return nil, fmt.Errorf("%s is an index; select a platform-specific manifest", reference)
+	}
+
+	layer, ok := findWasmLayer(manifest.Layers)
+	if !ok {
+		return nil, fmt.Errorf("no wasm layer found in %s", reference)
+	}
+
+	data, err := f.FetchBlob(repoPath, layer)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// findWasmLayer picks the layer carrying the wasm binary.
+func findWasmLayer(layers []registry.Descriptor) (registry.Descriptor, bool) {
+	for _, l := range layers {
+		if strings.Contains(strings.ToLower(l.MediaType), "wasm") {
+			return l, true
+		}
+	}
+	if len(layers) == 1 {
+		return layers[0], true
+	}
+	return registry.Descriptor{}, false
+}
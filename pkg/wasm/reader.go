@@ -0,0 +1,133 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errTruncated is returned when a read runs past the end of the input.
+var errTruncated = errors.New("unexpected end of wasm binary")
+
+// reader decodes the primitive encodings used by the wasm binary format.
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func newReader(data []byte) *reader {
+	return &reader{data: data}
+}
+
+// remaining reports the number of unread bytes.
+func (r *reader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *reader) readByte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errTruncated
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) peekByte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errTruncated
+	}
+	return r.data[r.pos], nil
+}
+
+func (r *reader) readBytes(n int) ([]byte, error) {
+	if n < 0 || n > r.remaining() {
+		return nil, errTruncated
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// readU32 decodes an unsigned LEB128 value of at most 32 bits.
+func (r *reader) readU32() (uint32, error) {
+	v, err := r.readUleb(5)
+	if err != nil {
+		return 0, err
+	}
+	if v > 0xFFFFFFFF {
+		return 0, fmt.Errorf("u32 out of range at offset %d", r.pos)
+	}
+	return uint32(v), nil
+}
+
+// readU64 decodes an unsigned LEB128 value of at most 64 bits.
+func (r *reader) readU64() (uint64, error) {
+	return r.readUleb(10)
+}
+
+func (r *reader) readUleb(maxBytes int) (uint64, error) {
+	var result uint64
+	var shift uint
+	for i := 0; i < maxBytes; i++ {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint64(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+		shift += 7
+	}
+	return 0, fmt.Errorf("LEB128 value too long at offset %d", r.pos)
+}
+
+// readSleb decodes a signed LEB128 value (s32, s33, or s64).
+func (r *reader) readSleb(maxBytes int) (int64, error) {
+	var result int64
+	var shift uint
+	for i := 0; i < maxBytes; i++ {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= int64(b&0x7F) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				result |= -1 << shift
+			}
+			return result, nil
+		}
+	}
+	return 0, fmt.Errorf("LEB128 value too long at offset %d", r.pos)
+}
+
+// readName decodes a length-prefixed UTF-8 name.
+func (r *reader) readName() (string, error) {
+	n, err := r.readU32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.readBytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// readOptional decodes the 0x00/0x01 presence flag used by the component model.
+func (r *reader) readOptional() (bool, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return false, err
+	}
+	switch b {
+	case 0x00:
+		return false, nil
+	case 0x01:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid optional flag 0x%02x at offset %d", b, r.pos-1)
+	}
+}
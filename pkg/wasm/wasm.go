@@ -0,0 +1,257 @@
+// Package wasm inspects WebAssembly binaries without executing them.
+//
+// Both core modules (binary version 1) and component-model components
+// (version 0x0d, layer 1) are recognized. Parsing is best-effort: a section
+// that cannot be fully decoded leaves its details incomplete rather than
+// failing the whole inspection, so newer proposals degrade gracefully.
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kind distinguishes core modules from components.
+type Kind string
+
+const (
+	KindCore      Kind = "core"
+	KindComponent Kind = "component"
+)
+
+// Module is the inspected metadata of a wasm binary.
+type Module struct {
+	// Kind is "core" or "component".
+	Kind Kind `json:"kind" yaml:"kind"`
+
+	// Version is the binary format version from the preamble.
+	Version uint16 `json:"version" yaml:"version"`
+
+	// Size is the binary size in bytes.
+	Size int64 `json:"size" yaml:"size"`
+
+	// WASIVersion describes the WASI interfaces imported, if any
+	// (e.g. "preview1", "preview2 (0.2.0)").
+	WASIVersion string `json:"wasiVersion,omitempty" yaml:"wasiVersion,omitempty"`
+
+	// Imports lists imported functions, memories, instances, etc.
+	Imports []Import `json:"imports" yaml:"imports"`
+
+	// Exports lists exported items.
+	Exports []Export `json:"exports" yaml:"exports"`
+
+	// Functions is the number of functions defined in a core module.
+	Functions int `json:"functions,omitempty" yaml:"functions,omitempty"`
+
+	// CustomSections lists custom sections by name and payload size.
+	CustomSections []CustomSection `json:"customSections,omitempty" yaml:"customSections,omitempty"`
+
+	// Producers is the decoded "producers" custom section
+	// (field → "name version" entries), when present.
+	Producers map[string][]string `json:"producers,omitempty" yaml:"producers,omitempty"`
+
+	// CoreModules lists the core modules embedded in a component.
+	CoreModules []*Module `json:"coreModules,omitempty" yaml:"coreModules,omitempty"`
+}
+
+// Import is a single import.
+type Import struct {
+	// Module is the core import module name (empty for components).
+	Module string `json:"module,omitempty" yaml:"module,omitempty"`
+
+	// Name is the import field name, or the full interface name for components.
+	Name string `json:"name" yaml:"name"`
+
+	// Kind is the import kind: func, table, memory, global, tag, instance, ...
+	Kind string `json:"kind" yaml:"kind"`
+
+	// Signature is a rendered type, when it could be resolved.
+	Signature string `json:"signature,omitempty" yaml:"signature,omitempty"`
+
+	// Members lists the exports of an imported component instance.
+	Members []Export `json:"members,omitempty" yaml:"members,omitempty"`
+}
+
+// Export is a single export.
+type Export struct {
+	Name      string `json:"name" yaml:"name"`
+	Kind      string `json:"kind" yaml:"kind"`
+	Signature string `json:"signature,omitempty" yaml:"signature,omitempty"`
+
+	// Members lists the exports of an exported component instance.
+	Members []Export `json:"members,omitempty" yaml:"members,omitempty"`
+}
+
+// CustomSection records a custom section's name and payload size.
+type CustomSection struct {
+	Name string `json:"name" yaml:"name"`
+	Size int    `json:"size" yaml:"size"`
+}
+
+// wasm preamble constants.
+var (
+	magic            = []byte{0x00, 'a', 's', 'm'}
+	versionCore      = uint16(0x0001)
+	versionComponent = uint16(0x000d)
+	layerCore        = uint16(0x0000)
+	layerComponent   = uint16(0x0001)
+)
+
+// IsWasm reports whether data starts with the wasm magic number.
+func IsWasm(data []byte) bool {
+	return len(data) >= 4 && bytes.Equal(data[:4], magic)
+}
+
+// Parse inspects a wasm binary.
+func Parse(data []byte) (*Module, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("too short to be a wasm binary (%d bytes)", len(data))
+	}
+	if !IsWasm(data) {
+		return nil, fmt.Errorf("not a wasm binary (bad magic number)")
+	}
+
+	version := binary.LittleEndian.Uint16(data[4:6])
+	layer := binary.LittleEndian.Uint16(data[6:8])
+
+	var (
+		m   *Module
+		err error
+	)
+	switch {
+	case version == versionCore && layer == layerCore:
+		m, err = parseCore(data)
+	case version == versionComponent && layer == layerComponent:
+		m, err = parseComponent(data)
+	default:
+		return nil, fmt.Errorf("unsupported wasm version %d (layer %d)", version, layer)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.Version = version
+	m.Size = int64(len(data))
+	m.WASIVersion = detectWASI(m)
+	return m, nil
+}
+
+// forEachSection iterates the sections following the 8-byte preamble.
+// Section payloads are passed as independent slices.
+func forEachSection(data []byte, fn func(id byte, payload []byte) error) error {
+	r := newReader(data[8:])
+	for r.remaining() > 0 {
+		id, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		size, err := r.readU32()
+		if err != nil {
+			return fmt.Errorf("section %d: %w", id, err)
+		}
+		payload, err := r.readBytes(int(size))
+		if err != nil {
+			return fmt.Errorf("section %d: %w", id, err)
+		}
+		if err := fn(id, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseCustomSection records a custom section and decodes known payloads.
+func (m *Module) parseCustomSection(payload []byte) {
+	r := newReader(payload)
+	name, err := r.readName()
+	if err != nil {
+		m.CustomSections = append(m.CustomSections, CustomSection{Name: "<invalid>", Size: len(payload)})
+		return
+	}
+	m.CustomSections = append(m.CustomSections, CustomSection{Name: name, Size: r.remaining()})
+
+	if name == "producers" {
+		if producers, err := parseProducers(r); err == nil {
+			m.Producers = producers
+		}
+	}
+}
+
+// parseProducers decodes the tool-conventions producers section.
+func parseProducers(r *reader) (map[string][]string, error) {
+	fieldCount, err := r.readU32()
+	if err != nil {
+		return nil, err
+	}
+	producers := make(map[string][]string)
+	for i := uint32(0); i < fieldCount; i++ {
+		field, err := r.readName()
+		if err != nil {
+			return nil, err
+		}
+		valueCount, err := r.readU32()
+		if err != nil {
+			return nil, err
+		}
+		for j := uint32(0); j < valueCount; j++ {
+			name, err := r.readName()
+			if err != nil {
+				return nil, err
+			}
+			version, err := r.readName()
+			if err != nil {
+				return nil, err
+			}
+			producers[field] = append(producers[field], strings.TrimSpace(name+" "+version))
+		}
+	}
+	return producers, nil
+}
+
+// detectWASI derives a WASI version label from the module's imports.
+func detectWASI(m *Module) string {
+	if m.Kind == KindComponent {
+		versions := make(map[string]bool)
+		for _, imp := range m.Imports {
+			if !strings.HasPrefix(imp.Name, "wasi:") {
+				continue
+			}
+			if at := strings.LastIndex(imp.Name, "@"); at != -1 {
+				versions[imp.Name[at+1:]] = true
+			} else {
+				versions[""] = true
+			}
+		}
+		if len(versions) == 0 {
+			return ""
+		}
+		var list []string
+		for v := range versions {
+			if v != "" {
+				list = append(list, v)
+			}
+		}
+		sort.Strings(list)
+		label := "preview2"
+		if len(list) > 0 && strings.HasPrefix(list[len(list)-1], "0.3") {
+			label = "preview3"
+		}
+		if len(list) == 0 {
+			return label
+		}
+		return fmt.Sprintf("%s (%s)", label, strings.Join(list, ", "))
+	}
+
+	for _, imp := range m.Imports {
+		switch imp.Module {
+		case "wasi_snapshot_preview1":
+			return "preview1"
+		case "wasi_unstable":
+			return "preview0 (wasi_unstable)"
+		}
+	}
+	return ""
+}
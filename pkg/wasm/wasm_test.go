@@ -0,0 +1,400 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/mistergrinvalds/lazyoci/pkg/registry"
+	"github.com/mistergrinvalds/lazyoci/pkg/registry/registrytest"
+)
+
+// Binary builders for hand-assembled test modules.
+
+func leb(v uint32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+		if v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+func name(s string) []byte {
+	return append(leb(uint32(len(s))), s...)
+}
+
+func vec(items ...[]byte) []byte {
+	return append(leb(uint32(len(items))), bytes.Join(items, nil)...)
+}
+
+func section(id byte, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	return append(append([]byte{id}, leb(uint32(len(body)))...), body...)
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+var (
+	corePreamble      = []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	componentPreamble = []byte{0x00, 'a', 's', 'm', 0x0d, 0x00, 0x01, 0x00}
+)
+
+// wasiCoreModule is a small WASI preview1 command module.
+func wasiCoreModule() []byte {
+	const i32 = 0x7F
+	return cat(
+		corePreamble,
+		// type 0: (i32, i32, i32, i32) -> i32, type 1: () -> ()
+		section(sectionType, vec(
+			cat([]byte{0x60}, vec([]byte{i32}, []byte{i32}, []byte{i32}, []byte{i32}), vec([]byte{i32})),
+			cat([]byte{0x60}, vec(), vec()),
+		)),
+		section(sectionImport, vec(
+			cat(name("wasi_snapshot_preview1"), name("fd_write"), []byte{0x00}, leb(0)),
+		)),
+		section(sectionFunction, vec(leb(1))),
+		// memory 0: min 1, max 16
+		section(sectionMemory, vec([]byte{0x01, 0x01, 0x10})),
+		// global 0: mut i32 = 1024
+		section(sectionGlobal, vec(cat([]byte{i32, 0x01, 0x41}, []byte{0x80, 0x08}, []byte{0x0B}))),
+		section(sectionExport, vec(
+			cat(name("_start"), []byte{0x00}, leb(1)),
+			cat(name("memory"), []byte{0x02}, leb(0)),
+			cat(name("__stack_pointer"), []byte{0x03}, leb(0)),
+		)),
+		section(sectionCustom, name("producers"), vec(
+			cat(name("language"), vec(cat(name("Rust"), name("")))),
+			cat(name("processed-by"), vec(cat(name("rustc"), name("1.80.0")))),
+		)),
+	)
+}
+
+func TestParseCoreModule(t *testing.T) {
+	m, err := Parse(wasiCoreModule())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if m.Kind != KindCore {
+		t.Errorf("Kind = %q, want %q", m.Kind, KindCore)
+	}
+	if m.Version != 1 {
+		t.Errorf("Version = %d, want 1", m.Version)
+	}
+	if m.WASIVersion != "preview1" {
+		t.Errorf("WASIVersion = %q, want preview1", m.WASIVersion)
+	}
+	if m.Functions != 1 {
+		t.Errorf("Functions = %d, want 1", m.Functions)
+	}
+
+	if len(m.Imports) != 1 {
+		t.Fatalf("Imports = %d, want 1", len(m.Imports))
+	}
+	imp := m.Imports[0]
+	if imp.Module != "wasi_snapshot_preview1" || imp.Name != "fd_write" || imp.Kind != "func" {
+		t.Errorf("import = %+v", imp)
+	}
+	if imp.Signature != "(i32, i32, i32, i32) -> i32" {
+		t.Errorf("import signature = %q", imp.Signature)
+	}
+
+	wantExports := []Export{
+		{Name: "_start", Kind: "func", Signature: "()"},
+		{Name: "memory", Kind: "memory", Signature: "1..16 pages"},
+		{Name: "__stack_pointer", Kind: "global", Signature: "mut i32"},
+	}
+	if len(m.Exports) != len(wantExports) {
+		t.Fatalf("Exports = %+v", m.Exports)
+	}
+	for i, want := range wantExports {
+		got := m.Exports[i]
+		if got.Name != want.Name || got.Kind != want.Kind || got.Signature != want.Signature {
+			t.Errorf("export[%d] = %+v, want %+v", i, got, want)
+		}
+	}
+
+	if len(m.CustomSections) != 1 || m.CustomSections[0].Name != "producers" {
+		t.Errorf("CustomSections = %+v", m.CustomSections)
+	}
+	if got := m.Producers["processed-by"]; len(got) != 1 || got[0] != "rustc 1.80.0" {
+		t.Errorf("Producers[processed-by] = %v", got)
+	}
+	if got := m.Producers["language"]; len(got) != 1 || got[0] != "Rust" {
+		t.Errorf("Producers[language] = %v", got)
+	}
+}
+
+func TestParseComponent(t *testing.T) {
+	const (
+		str = 0x73
+		u32 = 0x79
+	)
+
+	data := cat(
+		componentPreamble,
+		section(compSectionCoreModule, corePreamble),
+		section(compSectionType, vec(
+			// type 0: instance { type 0: func() -> string; export "get-cwd" (func 0) }
+			cat([]byte{0x42}, vec(
+				cat([]byte{0x01, 0x40}, vec(), []byte{0x00, str}),
+				cat([]byte{0x04, 0x00}, name("get-cwd"), []byte{0x01}, leb(0)),
+			)),
+			// type 1: func(name: string)
+			cat([]byte{0x40}, vec(cat(name("name"), []byte{str})), []byte{0x01, 0x00}),
+			// type 2: result<string, u32>
+			[]byte{0x6A, 0x01, str, 0x01, u32},
+			// type 3: func(r: result<string, u32>) -> bool
+			cat([]byte{0x40}, vec(cat(name("r"), []byte{0x02})), []byte{0x00, 0x7F}),
+		)),
+		section(compSectionImport, vec(
+			cat([]byte{0x00}, name("wasi:cli/environment@0.2.0"), []byte{0x05}, leb(0)),
+		)),
+		section(compSectionCanon, vec(
+			cat([]byte{0x00, 0x00}, leb(0), vec(), leb(1)),
+			cat([]byte{0x00, 0x00}, leb(1), vec([]byte{0x00}, cat([]byte{0x03}, leb(0))), leb(3)),
+		)),
+		section(compSectionExport, vec(
+			cat([]byte{0x00}, name("greet"), []byte{sortFunc}, leb(0), []byte{0x00}),
+			cat([]byte{0x00}, name("check"), []byte{sortFunc}, leb(1), []byte{0x00}),
+		)),
+		section(compSectionCustom, name("component-type:world"), []byte{0x01, 0x02}),
+	)
+
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if m.Kind != KindComponent {
+		t.Errorf("Kind = %q, want %q", m.Kind, KindComponent)
+	}
+	if m.Version != 0x0d {
+		t.Errorf("Version = %d, want 13", m.Version)
+	}
+	if m.WASIVersion != "preview2 (0.2.0)" {
+		t.Errorf("WASIVersion = %q", m.WASIVersion)
+	}
+	if len(m.CoreModules) != 1 || m.CoreModules[0].Kind != KindCore {
+		t.Errorf("CoreModules = %+v", m.CoreModules)
+	}
+
+	if len(m.Imports) != 1 {
+		t.Fatalf("Imports = %+v", m.Imports)
+	}
+	imp := m.Imports[0]
+	if imp.Kind != "instance" || imp.Name != "wasi:cli/environment@0.2.0" {
+		t.Errorf("import = %+v", imp)
+	}
+	if len(imp.Members) != 1 || imp.Members[0].Name != "get-cwd" || imp.Members[0].Signature != "func() -> string" {
+		t.Errorf("import members = %+v", imp.Members)
+	}
+
+	wantExports := map[string]string{
+		"greet": "func(name: string)",
+		"check": "func(r: result<string, u32>) -> bool",
+	}
+	if len(m.Exports) != len(wantExports) {
+		t.Fatalf("Exports = %+v", m.Exports)
+	}
+	for _, exp := range m.Exports {
+		if exp.Kind != "func" || exp.Signature != wantExports[exp.Name] {
+			t.Errorf("export %q = %+v, want signature %q", exp.Name, exp, wantExports[exp.Name])
+		}
+	}
+
+	if len(m.CustomSections) != 1 || m.CustomSections[0].Name != "component-type:world" || m.CustomSections[0].Size != 2 {
+		t.Errorf("CustomSections = %+v", m.CustomSections)
+	}
+}
+
+func TestParseNestedTypes(t *testing.T) {
+	const (
+		u32   = 0x79
+		depth = 24
+	)
+	// type 0: tuple<u32, u32>; type i: tuple<type i-1, type i-1>. Inlined,
+	// the last type would render as 2^24 u32s
+	types := [][]byte{{0x6F, 0x02, u32, u32}}
+	for i := 1; i < depth; i++ {
+		types = append(types, cat([]byte{0x6F, 0x02}, leb(uint32(i-1)), leb(uint32(i-1))))
+	}
+	// type depth: func(t: type depth-1)
+	types = append(types, cat([]byte{0x40}, vec(cat(name("t"), leb(depth-1))), []byte{0x01, 0x00}))
+	data := cat(
+		componentPreamble,
+		section(compSectionType, vec(types...)),
+		section(compSectionImport, vec(
+			cat([]byte{0x00}, name("nested"), []byte{0x01}, leb(depth)),
+		)),
+	)
+
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(m.Imports) != 1 {
+		t.Fatalf("Imports = %+v", m.Imports)
+	}
+	if got, want := m.Imports[0].Signature, fmt.Sprintf("func(t: type%d)", depth-1); got != want {
+		t.Errorf("signature = %.200q, want %q", got, want)
+	}
+}
+
+func TestParseDegradesOnUnknownTypes(t *testing.T) {
+	// A GC struct type (0x5F) stops type decoding; the export is still listed.
+	data := cat(
+		corePreamble,
+		section(sectionType, vec([]byte{0x5F, 0x00})),
+		section(sectionFunction, vec(leb(0))),
+		section(sectionExport, vec(cat(name("run"), []byte{0x00}, leb(0)))),
+	)
+
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(m.Exports) != 1 || m.Exports[0].Name != "run" || m.Exports[0].Signature != "" {
+		t.Errorf("Exports = %+v", m.Exports)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "bad magic", data: []byte{0x7F, 'E', 'L', 'F', 0x01, 0x00, 0x00, 0x00}},
+		{name: "unknown version", data: []byte{0x00, 'a', 's', 'm', 0x02, 0x00, 0x00, 0x00}},
+		{name: "truncated section", data: cat(corePreamble, []byte{sectionType, 0x10, 0x01})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.data); err == nil {
+				t.Error("Parse() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestDetectWASI(t *testing.T) {
+	tests := []struct {
+		name string
+		m    *Module
+		want string
+	}{
+		{
+			name: "core preview1",
+			m:    &Module{Kind: KindCore, Imports: []Import{{Module: "wasi_snapshot_preview1", Name: "fd_write"}}},
+			want: "preview1",
+		},
+		{
+			name: "core unstable",
+			m:    &Module{Kind: KindCore, Imports: []Import{{Module: "wasi_unstable", Name: "fd_write"}}},
+			want: "preview0 (wasi_unstable)",
+		},
+		{
+			name: "core without WASI",
+			m:    &Module{Kind: KindCore, Imports: []Import{{Module: "env", Name: "log"}}},
+			want: "",
+		},
+		{
+			name: "component mixed versions",
+			m: &Module{Kind: KindComponent, Imports: []Import{
+				{Name: "wasi:io/streams@0.2.0"},
+				{Name: "wasi:http/types@0.2.1"},
+				{Name: "fermyon:spin/key-value@2.0.0"},
+			}},
+			want: "preview2 (0.2.0, 0.2.1)",
+		},
+		{
+			name: "component preview3",
+			m:    &Module{Kind: KindComponent, Imports: []Import{{Name: "wasi:cli/run@0.3.0"}}},
+			want: "preview3 (0.3.0)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectWASI(tt.m); got != tt.want {
+				t.Errorf("detectWASI() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderLEB(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want int64
+	}{
+		{[]byte{0x00}, 0},
+		{[]byte{0x7F}, -1},
+		{[]byte{0x80, 0x7F}, -128},
+		{[]byte{0x73}, -13},
+		{[]byte{0xE5, 0x8E, 0x26}, 624485},
+	}
+	for _, tt := range tests {
+		got, err := newReader(tt.data).readSleb(5)
+		if err != nil || got != tt.want {
+			t.Errorf("readSleb(% x) = %d, %v; want %d", tt.data, got, err, tt.want)
+		}
+	}
+
+	if _, err := newReader([]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x01}).readU32(); err == nil {
+		t.Error("readU32() expected error for overlong encoding")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	tests := []struct {
+		name    string
+		layers  []registry.Descriptor
+		wantErr bool
+	}{
+		{
+			name: "wasm layer among others",
+			layers: []registry.Descriptor{
+				{MediaType: "application/vnd.oci.image.config.v1+json", Digest: "sha256:cfg"},
+				{MediaType: "application/vnd.wasm.content.layer.v1+wasm", Digest: "sha256:wasm"},
+			},
+		},
+		{
+			name:   "single untyped layer",
+			layers: []registry.Descriptor{{MediaType: "application/octet-stream", Digest: "sha256:wasm"}},
+		},
+		{
+			name: "no wasm layer",
+			layers: []registry.Descriptor{
+				{MediaType: "application/json", Digest: "sha256:a"},
+				{MediaType: "application/json", Digest: "sha256:b"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &registrytest.Fetcher{
+				Manifest: &registry.Manifest{Layers: tt.layers},
+				Blobs:    map[string][]byte{"sha256:wasm": wasiCoreModule()},
+			}
+			m, err := Fetch(f, "localhost:5050/test/hello-wasm", "v1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && m.Kind != KindCore {
+				t.Errorf("Kind = %q, want %q", m.Kind, KindCore)
+			}
+		})
+	}
+}